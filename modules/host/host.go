@@ -383,6 +383,7 @@ func (h *Host) managedUpdatePriceTable() {
 		// Registry related fields.
 		RegistryEntriesLeft:  h.staticRegistry.Cap() - h.staticRegistry.Len(),
 		RegistryEntriesTotal: h.staticRegistry.Cap(),
+		MaxRegistryDataSize:  modules.RegistryDataSize,
 
 		// Subscription related fields.
 		SubscriptionMemoryCost:       types.NewCurrency64(1),
@@ -417,6 +417,8 @@ func (h *Host) threadedHandleStream(stream siamux.Stream) {
 		err = h.managedRPCFundEphemeralAccount(stream)
 	case modules.RPCLatestRevision:
 		err = h.managedRPCLatestRevision(stream)
+	case modules.RPCRegistryEnumerate:
+		err = h.managedRPCRegistryEnumerate(stream)
 	case modules.RPCRegistrySubscription:
 		cleanup, err = h.managedRPCRegistrySubscribe(stream)
 	case modules.RPCRenewContract:
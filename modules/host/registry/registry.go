@@ -2,6 +2,7 @@ package registry
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -48,7 +49,13 @@ type (
 	// Registry is an in-memory key-value store. Renter's can pay the host to
 	// register data with a given pubkey and secondary key (tweak).
 	Registry struct {
-		entries    map[modules.RegistryEntryID]*value
+		entries map[modules.RegistryEntryID]*value
+
+		// keyEntries indexes the entries by the string representation of
+		// their public key and their tweak. It allows for enumerating the
+		// entries of a public key without scanning the whole registry.
+		keyEntries map[string]map[crypto.Hash]*value
+
 		staticHPK  types.SiaPublicKey
 		staticPath string
 		staticFile *os.File
@@ -139,6 +146,42 @@ func (r *Registry) Get(sid modules.RegistryEntryID) (types.SiaPublicKey, modules
 	return v.key, modules.NewSignedRegistryValue(v.tweak, v.data, v.revision, v.signature, v.entryType), true
 }
 
+// Enumerate returns the tweaks and revisions of up to limit entries stored for
+// the provided public key. Only entries with a tweak larger than or equal to
+// start are returned. The entries are sorted by tweak which allows for paging
+// through the entries by passing the returned next tweak as start. more is
+// true if there are entries left after the returned ones.
+func (r *Registry) Enumerate(pubKey types.SiaPublicKey, start crypto.Hash, limit int) (entries []modules.RegistryEnumerateEntry, next crypto.Hash, more bool) {
+	// Only hold the lock while collecting the entries of the public key.
+	r.mu.Lock()
+	keyEntries := r.keyEntries[pubKey.String()]
+	values := make([]*value, 0, len(keyEntries))
+	for tweak, v := range keyEntries {
+		if bytes.Compare(tweak[:], start[:]) >= 0 {
+			values = append(values, v)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, v := range values {
+		v.mu.Lock()
+		if !v.invalid {
+			entries = append(entries, modules.RegistryEnumerateEntry{
+				Tweak:    v.tweak,
+				Revision: v.revision,
+			})
+		}
+		v.mu.Unlock()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Tweak[:], entries[j].Tweak[:]) < 0
+	})
+	if len(entries) > limit {
+		return entries[:limit], entries[limit].Tweak, true
+	}
+	return entries, crypto.Hash{}, false
+}
+
 // Len returns the length of the registry.
 func (r *Registry) Len() uint64 {
 	r.mu.Lock()
@@ -214,7 +257,7 @@ func (r *Registry) Truncate(newMaxEntries uint64, force bool) error {
 		// If 'force' was specified, the remaining entries need to be removed from
 		// the in-memory map.
 		for _, entry := range entriesToMove {
-			delete(r.entries, entry.mapKey())
+			r.removeEntry(entry)
 		}
 	}
 
@@ -278,10 +321,15 @@ func New(path string, maxEntries uint64, hpk types.SiaPublicKey) (_ *Registry, e
 		usage:      b,
 	}
 	// Load the remaining entries.
-	reg.entries, err = loadRegistryEntries(r, fi.Size()/PersistedEntrySize, b, compatV100)
+	entries, err := loadRegistryEntries(r, fi.Size()/PersistedEntrySize, b, compatV100)
 	if err != nil {
 		return nil, errors.AddContext(err, "failed to load registry entries")
 	}
+	reg.entries = make(map[modules.RegistryEntryID]*value, len(entries))
+	reg.keyEntries = make(map[string]map[crypto.Hash]*value)
+	for _, entry := range entries {
+		reg.addEntry(entry)
+	}
 	// If an upgrade happened, sync the body and upgrade the metadata
 	// afterwards. Then sync again.
 	if compatV100 {
@@ -382,7 +430,7 @@ func (r *Registry) managedDeleteFromMemory(v *value) {
 		build.Critical("managedDeleteFromMemory: unsetting an index should never fail")
 	}
 	// Delete the entry from the map.
-	delete(r.entries, v.mapKey())
+	r.removeEntry(v)
 }
 
 // newValue creates a new value and assigns it a free bit from the bitfield. It
@@ -410,10 +458,30 @@ func (r *Registry) newValue(rv modules.SignedRegistryValue, pubKey types.SiaPubl
 		revision:    rv.Revision,
 		signature:   rv.Signature,
 	}
-	r.entries[v.mapKey()] = v
+	r.addEntry(v)
 	return v, nil
 }
 
+// addEntry adds an entry to the in-memory maps of the registry.
+func (r *Registry) addEntry(v *value) {
+	r.entries[v.mapKey()] = v
+	key := v.key.String()
+	if _, exists := r.keyEntries[key]; !exists {
+		r.keyEntries[key] = make(map[crypto.Hash]*value)
+	}
+	r.keyEntries[key][v.tweak] = v
+}
+
+// removeEntry removes an entry from the in-memory maps of the registry.
+func (r *Registry) removeEntry(v *value) {
+	delete(r.entries, v.mapKey())
+	key := v.key.String()
+	delete(r.keyEntries[key], v.tweak)
+	if len(r.keyEntries[key]) == 0 {
+		delete(r.keyEntries, key)
+	}
+}
+
 // Prune deletes all entries from the registry that expire at a height smaller
 // than or equal to the provided expiry argument.
 func (r *Registry) Prune(expiry types.BlockHeight) (uint64, error) {
@@ -427,6 +427,100 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+// TestEnumerate tests enumerating the entries of a public key.
+func TestEnumerate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := testDir(t.Name())
+
+	// Create a new registry.
+	registryPath := filepath.Join(dir, "registry")
+	r, err := New(registryPath, testingDefaultMaxEntries, types.SiaPublicKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(c io.Closer) {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(r)
+
+	// Add an entry for another key.
+	rv, v, _ := randomValue(0)
+	_, err = r.Update(rv, v.key, v.expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add some entries for the same key.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	entries, _, more := r.Enumerate(spk, crypto.Hash{}, 10)
+	if len(entries) != 0 || more {
+		t.Fatal("key shouldn't have entries yet")
+	}
+	expected := make(map[crypto.Hash]uint64)
+	for i := 0; i < 5; i++ {
+		var tweak crypto.Hash
+		fastrand.Read(tweak[:])
+		rev := fastrand.Uint64n(1000)
+		rv := modules.NewRegistryValue(tweak, fastrand.Bytes(10), rev, modules.RegistryTypeWithoutPubkey).Sign(sk)
+		_, err = r.Update(rv, spk, types.BlockHeight(1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[tweak] = rev
+	}
+
+	// Enumerate them.
+	entries, _, more = r.Enumerate(spk, crypto.Hash{}, 10)
+	if more {
+		t.Fatal("there shouldn't be more entries")
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %v entries but got %v", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		rev, exists := expected[entry.Tweak]
+		if !exists || rev != entry.Revision {
+			t.Fatal("wrong entry", entry)
+		}
+		if i > 0 && bytes.Compare(entries[i-1].Tweak[:], entry.Tweak[:]) >= 0 {
+			t.Fatal("entries aren't sorted")
+		}
+	}
+
+	// Page through them with a lower limit.
+	var paged []modules.RegistryEnumerateEntry
+	var start crypto.Hash
+	for pages := 1; ; pages++ {
+		page, next, more := r.Enumerate(spk, start, 2)
+		if len(page) > 2 {
+			t.Fatal("page exceeds limit", len(page))
+		}
+		paged = append(paged, page...)
+		if !more {
+			if pages != 3 {
+				t.Fatal("expected 3 pages but got", pages)
+			}
+			break
+		}
+		start = next
+	}
+	if !reflect.DeepEqual(paged, entries) {
+		t.Fatal("wrong entries", paged)
+	}
+
+	// Starting at a tweak should include the entry with that tweak.
+	page, _, _ := r.Enumerate(spk, entries[1].Tweak, 10)
+	if !reflect.DeepEqual(page, entries[1:]) {
+		t.Fatal("wrong entries", page)
+	}
+}
+
 // TestRegistryLimit checks if the bitfield of the limit enforces its
 // preallocated size.
 func TestRegistryLimit(t *testing.T) {
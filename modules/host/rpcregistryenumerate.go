@@ -0,0 +1,68 @@
+package host
+
+import (
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/siamux"
+	"go.sia.tech/siad/modules"
+)
+
+// errInvalidEnumerateLimit is returned if a RegistryEnumerate request asks for
+// no entries or more than MaxRegistryEnumerateEntries entries.
+var errInvalidEnumerateLimit = fmt.Errorf("limit must be between 1 and %v", modules.MaxRegistryEnumerateEntries)
+
+// managedRPCRegistryEnumerate handles the RPC which returns a page of the
+// tweaks and revisions of the registry entries stored for a public key.
+func (h *Host) managedRPCRegistryEnumerate(stream siamux.Stream) error {
+	// read the price table
+	pt, err := h.staticReadPriceTableID(stream)
+	if err != nil {
+		return errors.AddContext(err, "failed to read price table")
+	}
+
+	// Read request. It is read before the payment since the cost depends on
+	// the limit.
+	var rer modules.RPCRegistryEnumerateRequest
+	err = modules.RPCRead(stream, &rer)
+	if err != nil {
+		return errors.AddContext(err, "failed to read RegistryEnumerateRequest")
+	}
+	if rer.Limit == 0 || rer.Limit > modules.MaxRegistryEnumerateEntries {
+		return errInvalidEnumerateLimit
+	}
+
+	// Process payment.
+	pd, err := h.ProcessPayment(stream, pt.HostBlockHeight)
+	if err != nil {
+		return errors.AddContext(err, "failed to process payment")
+	}
+
+	// Check payment. The renter pays for a full page.
+	if pd.Amount().Cmp(modules.RegistryEnumerateCost(pt, rer.Limit)) < 0 {
+		return modules.ErrInsufficientPaymentForRPC
+	}
+
+	// Enumerate the entries.
+	entries, next, more := h.staticRegistry.Enumerate(rer.PubKey, rer.Start, int(rer.Limit))
+
+	// Refund the payment for the entries which weren't returned.
+	refund := pd.Amount().Sub(modules.RegistryEnumerateCost(pt, uint64(len(entries))))
+	if !refund.IsZero() {
+		err = h.staticAccountManager.callRefund(pd.AccountID(), refund)
+		if err != nil {
+			return errors.AddContext(err, "failed to refund excessive payment")
+		}
+	}
+
+	// Send response.
+	err = modules.RPCWrite(stream, modules.RPCRegistryEnumerateResponse{
+		Entries: entries,
+		More:    more,
+		Next:    next,
+	})
+	if err != nil {
+		return errors.AddContext(err, "failed to send RegistryEnumerateResponse")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package modules
+
+import (
+	"sort"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// multiWalletBatchHeight is the number of blocks worth of transactions
+	// which the MultiWalletReader fetches from every wallet at once.
+	multiWalletBatchHeight types.BlockHeight = 144
+)
+
+var (
+	// ErrInvalidHeightRange is returned by the MultiWalletReader if the start
+	// of the requested range is greater than its end.
+	ErrInvalidHeightRange = errors.New("start height of range is greater than end height")
+)
+
+type (
+	// MultiWalletReader provides a unified view of the transaction history
+	// of multiple wallets.
+	MultiWalletReader struct {
+		ids         []string
+		wallets     map[string]Wallet
+		batchHeight types.BlockHeight
+	}
+
+	// MultiWalletTransaction is a transaction returned by the
+	// MultiWalletReader together with the id of the wallet it belongs to.
+	MultiWalletTransaction struct {
+		ProcessedTransaction
+		WalletID string `json:"walletid"`
+	}
+)
+
+// NewMultiWalletReader creates a MultiWalletReader for the provided wallets
+// which are identified by their keys.
+func NewMultiWalletReader(wallets map[string]Wallet) *MultiWalletReader {
+	ids := make([]string, 0, len(wallets))
+	for id := range wallets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return &MultiWalletReader{
+		ids:         ids,
+		wallets:     wallets,
+		batchHeight: multiWalletBatchHeight,
+	}
+}
+
+// Transactions returns the transactions of all wallets that were confirmed at
+// heights [startHeight, endHeight] ordered by confirmation height.
+func (mwr *MultiWalletReader) Transactions(startHeight, endHeight types.BlockHeight) (txns []MultiWalletTransaction, err error) {
+	err = mwr.VisitTransactions(startHeight, endHeight, func(txn MultiWalletTransaction) error {
+		txns = append(txns, txn)
+		return nil
+	})
+	return txns, err
+}
+
+// VisitTransactions calls fn for every transaction of all wallets that was
+// confirmed at heights [startHeight, endHeight] in ascending order of
+// confirmation height. Transactions confirmed at the same height are ordered
+// by wallet id. The transactions are fetched from the wallets in batches of
+// blocks which are merged to avoid loading the whole range at once. The walk
+// stops at the first error returned by fn which is then returned.
+func (mwr *MultiWalletReader) VisitTransactions(startHeight, endHeight types.BlockHeight, fn func(MultiWalletTransaction) error) error {
+	if startHeight > endHeight {
+		return ErrInvalidHeightRange
+	}
+
+	// A wallet can't return transactions beyond its height.
+	heights := make(map[string]types.BlockHeight, len(mwr.ids))
+	var maxHeight types.BlockHeight
+	for _, id := range mwr.ids {
+		height, err := mwr.wallets[id].Height()
+		if err != nil {
+			return errors.AddContext(err, "failed to get height of wallet "+id)
+		}
+		heights[id] = height
+		if height > maxHeight {
+			maxHeight = height
+		}
+	}
+	if endHeight > maxHeight {
+		endHeight = maxHeight
+	}
+
+	for batchStart := startHeight; batchStart <= endHeight; batchStart += mwr.batchHeight {
+		batchEnd := endHeight
+		if endHeight-batchStart >= mwr.batchHeight {
+			batchEnd = batchStart + mwr.batchHeight - 1
+		}
+		if err := mwr.visitBatch(batchStart, batchEnd, heights, fn); err != nil {
+			return err
+		}
+		if batchEnd == endHeight {
+			break
+		}
+	}
+	return nil
+}
+
+// visitBatch fetches the transactions confirmed at heights [startHeight,
+// endHeight] from every wallet and merges them relying on every wallet
+// returning them in ascending order of confirmation height.
+func (mwr *MultiWalletReader) visitBatch(startHeight, endHeight types.BlockHeight, heights map[string]types.BlockHeight, fn func(MultiWalletTransaction) error) error {
+	batches := make([][]ProcessedTransaction, len(mwr.ids))
+	for i, id := range mwr.ids {
+		if heights[id] < startHeight {
+			continue
+		}
+		end := endHeight
+		if heights[id] < end {
+			end = heights[id]
+		}
+		pts, err := mwr.wallets[id].Transactions(startHeight, end)
+		if err != nil {
+			return errors.AddContext(err, "failed to get transactions of wallet "+id)
+		}
+		batches[i] = pts
+	}
+
+	// Merge the batches. Ties are broken by the order of the ids.
+	for {
+		next := -1
+		for i, pts := range batches {
+			if len(pts) == 0 {
+				continue
+			}
+			if next == -1 || pts[0].ConfirmationHeight < batches[next][0].ConfirmationHeight {
+				next = i
+			}
+		}
+		if next == -1 {
+			return nil
+		}
+		pt := batches[next][0]
+		batches[next] = batches[next][1:]
+		err := fn(MultiWalletTransaction{
+			ProcessedTransaction: pt,
+			WalletID:             mwr.ids[next],
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
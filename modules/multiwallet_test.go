@@ -0,0 +1,119 @@
+package modules
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/types"
+)
+
+// testTransactionsWallet is a Wallet which only implements Height and
+// Transactions.
+type testTransactionsWallet struct {
+	Wallet
+	height types.BlockHeight
+	pts    []ProcessedTransaction
+	calls  int
+}
+
+// Height returns the height of the wallet.
+func (w *testTransactionsWallet) Height() (types.BlockHeight, error) {
+	return w.height, nil
+}
+
+// Transactions returns the wallet's transactions within the range.
+func (w *testTransactionsWallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []ProcessedTransaction, err error) {
+	w.calls++
+	if startHeight > w.height || startHeight > endHeight {
+		return nil, errors.New("out of bounds")
+	}
+	for _, pt := range w.pts {
+		if pt.ConfirmationHeight >= startHeight && pt.ConfirmationHeight <= endHeight {
+			pts = append(pts, pt)
+		}
+	}
+	return pts, nil
+}
+
+// newTestTransactionsWallet creates a wallet with one transaction at every
+// provided height.
+func newTestTransactionsWallet(height types.BlockHeight, txnHeights ...types.BlockHeight) *testTransactionsWallet {
+	w := &testTransactionsWallet{height: height}
+	for i, h := range txnHeights {
+		w.pts = append(w.pts, ProcessedTransaction{
+			TransactionID:      types.TransactionID{byte(h), byte(i)},
+			ConfirmationHeight: h,
+		})
+	}
+	return w
+}
+
+// TestMultiWalletReader tests merging the transactions of two wallets into a
+// single stream ordered by confirmation height.
+func TestMultiWalletReader(t *testing.T) {
+	a := newTestTransactionsWallet(20, 1, 3, 3, 7, 12, 20)
+	b := newTestTransactionsWallet(15, 2, 3, 8, 8, 15)
+	mwr := NewMultiWalletReader(map[string]Wallet{"b": b, "a": a})
+	mwr.batchHeight = 5
+
+	type expected struct {
+		id     string
+		height types.BlockHeight
+	}
+	check := func(start, end types.BlockHeight, exp []expected) {
+		t.Helper()
+		txns, err := mwr.Transactions(start, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(txns) != len(exp) {
+			t.Fatalf("[%v, %v]: expected %v transactions but got %v", start, end, len(exp), len(txns))
+		}
+		for i, txn := range txns {
+			if txn.WalletID != exp[i].id || txn.ConfirmationHeight != exp[i].height {
+				t.Fatalf("[%v, %v]: wrong transaction at %v: %v %v", start, end, i, txn.WalletID, txn.ConfirmationHeight)
+			}
+		}
+	}
+
+	// All transactions. The range extends beyond the height of both
+	// wallets.
+	check(0, 100, []expected{
+		{"a", 1}, {"b", 2}, {"a", 3}, {"a", 3}, {"b", 3}, {"a", 7},
+		{"b", 8}, {"b", 8}, {"a", 12}, {"b", 15}, {"a", 20},
+	})
+	// A range which only covers the height of one of the wallets.
+	check(16, 20, []expected{{"a", 20}})
+	// A range within a single batch.
+	check(3, 3, []expected{{"a", 3}, {"a", 3}, {"b", 3}})
+	// A range starting beyond the height of all wallets.
+	check(21, 30, nil)
+
+	// The transactions of a wallet are fetched in batches.
+	a.calls = 0
+	if _, err := mwr.Transactions(0, 20); err != nil {
+		t.Fatal(err)
+	}
+	if a.calls != 5 {
+		t.Fatal("wrong number of calls", a.calls)
+	}
+
+	// An invalid range returns an error.
+	if _, err := mwr.Transactions(2, 1); !errors.Contains(err, ErrInvalidHeightRange) {
+		t.Fatal("expected ErrInvalidHeightRange", err)
+	}
+
+	// The walk stops at the first error.
+	errStop := errors.New("stop")
+	visited := 0
+	err := mwr.VisitTransactions(0, 100, func(MultiWalletTransaction) error {
+		visited++
+		if visited == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Contains(err, errStop) || visited != 3 {
+		t.Fatal("walk didn't stop", err, visited)
+	}
+}
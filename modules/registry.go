@@ -50,6 +50,13 @@ const (
 type (
 	// RegistryEntryType signals the type of a registry entry.
 	RegistryEntryType uint8
+
+	// Signer signs the hash of a registry value. It allows for signing
+	// registry values without access to the secret key, e.g. by using a
+	// hardware security module.
+	Signer interface {
+		Sign(hash crypto.Hash) (crypto.Signature, error)
+	}
 )
 
 var (
@@ -130,6 +137,23 @@ func (entry RegistryValue) Sign(sk crypto.SecretKey) SignedRegistryValue {
 	}
 }
 
+// SignWith signs the RegistryValue using the provided signer. The signature is
+// verified against pk before the signed value is returned.
+func (entry RegistryValue) SignWith(signer Signer, pk crypto.PublicKey) (SignedRegistryValue, error) {
+	sig, err := signer.Sign(entry.hash())
+	if err != nil {
+		return SignedRegistryValue{}, errors.AddContext(err, "failed to sign registry value")
+	}
+	srv := SignedRegistryValue{
+		RegistryValue: entry,
+		Signature:     sig,
+	}
+	if err := srv.Verify(pk); err != nil {
+		return SignedRegistryValue{}, errors.AddContext(err, "signer produced an invalid signature")
+	}
+	return srv, nil
+}
+
 // ShouldUpdateWith returns true if entry2 would replace entry1 in a host's
 // registry. It returns false if it shouldn't. An error might be returned in
 // that case to specify the reason.
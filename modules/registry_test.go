@@ -3,8 +3,10 @@ package modules
 import (
 	"encoding/hex"
 	"math"
+	"reflect"
 	"testing"
 
+	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/types"
@@ -159,6 +161,55 @@ func TestRegistryValueSignature(t *testing.T) {
 	test(RegistryTypeWithoutPubkey)
 }
 
+// testSigner is a Signer which signs hashes with a secret key.
+type testSigner struct {
+	sk  crypto.SecretKey
+	err error
+}
+
+// Sign implements the Signer interface.
+func (ts testSigner) Sign(hash crypto.Hash) (crypto.Signature, error) {
+	if ts.err != nil {
+		return crypto.Signature{}, ts.err
+	}
+	return crypto.SignHash(hash, ts.sk), nil
+}
+
+// TestRegistryValueSignWith tests signing registry values with a Signer.
+func TestRegistryValueSignWith(t *testing.T) {
+	t.Parallel()
+
+	sk, pk := crypto.GenerateKeyPair()
+	rv := NewRegistryValue(crypto.Hash{1}, fastrand.Bytes(100), 2, RegistryTypeWithoutPubkey)
+
+	// Signing with a signer should produce the same value as signing with
+	// the secret key.
+	srv, err := rv.SignWith(testSigner{sk: sk}, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(srv, rv.Sign(sk)) {
+		t.Fatal("signed values don't match")
+	}
+	if err := srv.Verify(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	// A signer using the wrong key should fail.
+	wrongSK, _ := crypto.GenerateKeyPair()
+	_, err = rv.SignWith(testSigner{sk: wrongSK}, pk)
+	if !errors.Contains(err, crypto.ErrInvalidSignature) {
+		t.Fatal("expected invalid signature error but got", err)
+	}
+
+	// A failing signer should fail.
+	errSigner := errors.New("signer failed")
+	_, err = rv.SignWith(testSigner{err: errSigner}, pk)
+	if !errors.Contains(err, errSigner) {
+		t.Fatal("expected signer error but got", err)
+	}
+}
+
 // TestIsPrimaryKey is a unit test for the IsPrimaryKey method.
 func TestIsPrimaryKey(t *testing.T) {
 	t.Parallel()
@@ -485,6 +485,14 @@ func (f FileInfo) IsDir() bool { return false }
 // Sys implements os.FileInfo.
 func (f FileInfo) Sys() interface{} { return nil }
 
+// FileSystemInfo provides information about either a file or a dir. File is
+// only set for files and Dir is only set for dirs.
+type FileSystemInfo struct {
+	IsDir bool           `json:"isdir"`
+	File  *FileInfo      `json:"file,omitempty"`
+	Dir   *DirectoryInfo `json:"dir,omitempty"`
+}
+
 // A HostDBEntry represents one host entry in the Renter's host DB. It
 // aggregates the host's external settings and metrics with its public key.
 type HostDBEntry struct {
@@ -614,11 +622,26 @@ type RenterPriceEstimation struct {
 
 // RenterSettings control the behavior of the Renter.
 type RenterSettings struct {
-	Allowance        Allowance     `json:"allowance"`
-	IPViolationCheck bool          `json:"ipviolationcheck"`
-	MaxUploadSpeed   int64         `json:"maxuploadspeed"`
-	MaxDownloadSpeed int64         `json:"maxdownloadspeed"`
-	UploadsStatus    UploadsStatus `json:"uploadsstatus"`
+	Allowance               Allowance               `json:"allowance"`
+	IPViolationCheck        bool                    `json:"ipviolationcheck"`
+	MaxUploadSpeed          int64                   `json:"maxuploadspeed"`
+	MaxDownloadSpeed        int64                   `json:"maxdownloadspeed"`
+	RegistryUpdateCooldowns RegistryUpdateCooldowns `json:"registryupdatecooldowns"`
+	UploadsStatus           UploadsStatus           `json:"uploadsstatus"`
+}
+
+// RegistryUpdateCooldowns contains the base cooldowns of the workers'
+// UpdateRegistry queues for different classes of errors. The cooldown is
+// doubled for every consecutive failure. A zero value uses the default
+// randomized cooldown.
+type RegistryUpdateCooldowns struct {
+	// SignatureErr is used when the host returns an invalid signature, an
+	// invalid proof or a lower revision than expected.
+	SignatureErr time.Duration `json:"signatureerr"`
+
+	// NetworkErr is used for all other errors such as failing to connect to
+	// the host or to execute the program.
+	NetworkErr time.Duration `json:"networkerr"`
 }
 
 // UploadsStatus contains information about the Renter's Uploads
@@ -1187,6 +1210,10 @@ type Renter interface {
 	// registry value.
 	UpdateRegistry(spk types.SiaPublicKey, srv SignedRegistryValue, timeout time.Duration) error
 
+	// WriteRegistryMetrics writes the metrics of the workers' registry queues
+	// to w using the Prometheus text format.
+	WriteRegistryMetrics(w io.Writer) error
+
 	// PauseRepairsAndUploads pauses the renter's repairs and uploads for a time
 	// duration
 	PauseRepairsAndUploads(duration time.Duration) error
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"fmt"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/build"
@@ -375,6 +376,30 @@ func (bs *bubbleScheduler) managedQueueParent(siaPath modules.SiaPath) error {
 	return nil
 }
 
+// managedBlockingBubble queues a bubble update for the directory and blocks
+// until it is complete or the renter is shut down.
+func (bs *bubbleScheduler) managedBlockingBubble(siaPath modules.SiaPath) error {
+	select {
+	case <-bs.callQueueBubble(siaPath):
+		return nil
+	case <-bs.staticRenter.tg.StopChan():
+		return errors.New("renter shut down before bubble completed")
+	}
+}
+
+// StartMetadataScanner starts the background metadata scanner of the
+// filesystem. It bubbles every directory of the filesystem every interval
+// while reading and writing at most maxOpsPerSecond files and directories per
+// second. Every bubble also queues a bubble of the parent directory which the
+// bubble scheduler performs without a rate limit.
+func (r *Renter) StartMetadataScanner(interval time.Duration, maxOpsPerSecond uint64) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.staticFileSystem.StartMetadataScanner(interval, maxOpsPerSecond, r.staticBubbleScheduler.managedBlockingBubble)
+}
+
 // BubbleMetadata will queue a bubble update for the directory. A bubble update
 // includes calculating the updated values of a directory's metadata, updating
 // the siadir metadata on disk, and then queuing a bubble update for the parent
@@ -7,7 +7,10 @@ import (
 	"time"
 
 	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/siatest/dependencies"
 )
 
 var (
@@ -464,3 +467,90 @@ func testBubbleScheduler_managedQueueParent(t *testing.T) {
 		t.Error("map and popped update don't match")
 	}
 }
+
+// TestRenterMetadataScanner tests that a scan of the renter's metadata scanner
+// updates a dir's aggregate metadata after a file's health changed.
+func TestRenterMetadataScanner(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create test renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a file to a dir and bubble the dir.
+	dirSiaPath, err := modules.NewSiaPath("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.CreateDir(dirSiaPath, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	fileSiaPath, err := dirSiaPath.Join("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 1)
+	f, err := rt.renter.createRenterTestFileWithParams(fileSiaPath, rsc, crypto.RandomCipherType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := rt.bubble(dirSiaPath); err != nil {
+		t.Fatal(err)
+	}
+	md, err := rt.renter.managedDirectoryMetadata(dirSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.AggregateNumStuckChunks != 0 || md.AggregateNumFiles != 1 {
+		t.Fatal("unexpected metadata", md.AggregateNumStuckChunks, md.AggregateNumFiles)
+	}
+
+	// Mark the file's chunk as stuck which changes its cached health.
+	if err := f.SetStuck(0, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run a single scan.
+	lastScan := rt.renter.staticFileSystem.LastMetadataScan()
+	if err := rt.renter.StartMetadataScanner(time.Hour, 1000); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if !rt.renter.staticFileSystem.LastMetadataScan().After(lastScan) {
+			return errors.New("no scan completed yet")
+		}
+		return nil
+	})
+	rt.renter.staticFileSystem.StopMetadataScanner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dir's aggregate metadata should match the file's updated health.
+	fmd := f.Metadata()
+	md, err = rt.renter.managedDirectoryMetadata(dirSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmd.CachedNumStuckChunks != 1 {
+		t.Fatal("file's cached health wasn't updated", fmd.CachedNumStuckChunks)
+	}
+	if md.AggregateNumStuckChunks != fmd.CachedNumStuckChunks || md.AggregateStuckHealth != fmd.CachedStuckHealth || md.AggregateHealth != fmd.CachedHealth {
+		t.Fatal("dir's aggregate metadata doesn't match the file", md, fmd)
+	}
+}
@@ -0,0 +1,305 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// aliasExtension is the extension of the files which store aliases on
+	// disk.
+	aliasExtension = ".siaalias"
+
+	// defaultMaxAliasHops is the default maximum number of aliases which are
+	// followed when resolving a path. It prevents chains of aliases which
+	// grow a path with every hop from being followed indefinitely.
+	defaultMaxAliasHops = 32
+)
+
+var (
+	// ErrAliasCycle is returned if resolving an alias would result in a loop.
+	ErrAliasCycle = errors.New("alias results in a cycle")
+
+	// ErrDanglingAlias is returned when opening an alias whose target no
+	// longer exists.
+	ErrDanglingAlias = errors.New("target of alias does not exist")
+
+	// ErrTooManyAliasHops is returned if resolving a path requires following
+	// more aliases than allowed.
+	ErrTooManyAliasHops = errors.New("resolving path requires too many alias hops")
+)
+
+type (
+	// aliasMetadata is the on-disk representation of an alias.
+	aliasMetadata struct {
+		Target modules.SiaPath `json:"target"`
+	}
+
+	// aliasLookupFunc returns the target of the alias at the provided path.
+	aliasLookupFunc func(modules.SiaPath) (target modules.SiaPath, isAlias bool, err error)
+)
+
+// CreateAlias creates an alias which points to the target. Opening the alias
+// or a path within the alias with OpenSiaDir or OpenSiaFile opens the
+// corresponding path within the target instead. Aliases are only resolved if
+// there is no dir or file at the opened path. If the target is deleted, the
+// alias remains and opening it returns ErrDanglingAlias.
+func (fs *FileSystem) CreateAlias(alias, target modules.SiaPath) (err error) {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	if alias.IsRoot() {
+		return errors.New("alias can't be the root")
+	}
+	unlock, err := fs.managedLockWritableParents(alias)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// Make sure there is nothing at the alias's path yet.
+	exists, err := fs.managedAliasPathExists(alias)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrExists
+	}
+
+	// Make sure the target exists and that the new alias doesn't result in a
+	// cycle. An alias which points into itself grows the path with every hop
+	// instead of revisiting it, so it needs to be checked separately.
+	if strings.HasPrefix(target.String()+"/", alias.String()+"/") {
+		return ErrAliasCycle
+	}
+	lookup := func(sp modules.SiaPath) (modules.SiaPath, bool, error) {
+		if sp.Equals(alias) {
+			return target, true, nil
+		}
+		return fs.managedReadAlias(sp)
+	}
+	resolved, _, err := fs.managedResolveAlias(alias, lookup)
+	if err != nil {
+		return err
+	}
+	dirExists, errDir := fs.managedDirExists(resolved)
+	fileExists, errFile := fs.managedFileExists(resolved)
+	if err := errors.Compose(errDir, errFile); err != nil {
+		return err
+	}
+	if !dirExists && !fileExists {
+		return errors.AddContext(ErrNotExist, "target of alias doesn't exist")
+	}
+
+	// Create the parent dir of the alias.
+	dirSiaPath, err := alias.Dir()
+	if err != nil {
+		return err
+	}
+	if err := fs.managedNewSiaDir(dirSiaPath, modules.DefaultDirPerm); err != nil {
+		return errors.AddContext(err, "failed to create parent dir of alias")
+	}
+
+	// Persist the alias.
+	data, err := json.Marshal(aliasMetadata{Target: target})
+	if err != nil {
+		return errors.AddContext(err, "failed to marshal alias")
+	}
+	f, err := fs.staticDeps.OpenFile(fs.aliasSysPath(alias), os.O_RDWR|os.O_CREATE|os.O_EXCL, modules.DefaultFilePerm)
+	if os.IsExist(err) {
+		return ErrExists
+	}
+	if err != nil {
+		return errors.AddContext(err, "failed to create alias")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	if _, err := f.Write(data); err != nil {
+		return errors.AddContext(err, "failed to write alias")
+	}
+	return f.Sync()
+}
+
+// SetMaxAliasHops sets the maximum number of aliases which are followed when
+// resolving a path. Resolving a path which requires more hops fails with
+// ErrTooManyAliasHops. A limit of 0 disables following aliases.
+func (fs *FileSystem) SetMaxAliasHops(hops uint64) {
+	atomic.StoreUint64(&fs.atomicMaxAliasHops, hops)
+}
+
+// Exists returns whether there is a dir or file at the provided path. Unlike
+// DirExists and FileExists, aliases within the path are followed.
+func (fs *FileSystem) Exists(sp modules.SiaPath) (bool, error) {
+	if err := fs.tg.Add(); err != nil {
+		return false, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	resolved, _, err := fs.managedResolveAlias(sp, fs.managedReadAlias)
+	if err != nil {
+		return false, err
+	}
+	exists, err := fs.managedDirExists(resolved)
+	if err != nil || exists {
+		return exists, err
+	}
+	return fs.managedFileExists(resolved)
+}
+
+// aliasSysPath returns the system path of the alias at the provided path.
+func (fs *FileSystem) aliasSysPath(sp modules.SiaPath) string {
+	return filepath.Join(fs.managedAbsPath(), filepath.FromSlash(sp.Path)+aliasExtension)
+}
+
+// managedAliasPathExists returns whether there is a dir, file or alias at the
+// provided path.
+func (fs *FileSystem) managedAliasPathExists(sp modules.SiaPath) (bool, error) {
+	for _, path := range []string{fs.DirPath(sp), fs.FilePath(sp), fs.aliasSysPath(sp)} {
+		_, err := os.Stat(path)
+		if err == nil {
+			return true, nil
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// managedReadAlias returns the target of the alias at the provided path.
+// isAlias is false if there is no alias at the path.
+func (fs *FileSystem) managedReadAlias(sp modules.SiaPath) (target modules.SiaPath, isAlias bool, err error) {
+	data, err := ioutil.ReadFile(fs.aliasSysPath(sp))
+	if os.IsNotExist(err) {
+		return modules.SiaPath{}, false, nil
+	}
+	if err != nil {
+		return modules.SiaPath{}, false, errors.AddContext(err, "failed to read alias")
+	}
+	var am aliasMetadata
+	if err := json.Unmarshal(data, &am); err != nil {
+		return modules.SiaPath{}, false, errors.AddContext(err, "failed to unmarshal alias")
+	}
+	return am.Target, true, nil
+}
+
+// managedResolveAlias replaces the aliases within the provided path with
+// their targets until the path no longer contains an alias. Only the
+// components of the path which don't exist as a dir are checked for aliases.
+// isAlias indicates whether the path contained an alias.
+// ErrTooManyAliasHops is returned if more than the FileSystem's max number of
+// aliases need to be followed.
+func (fs *FileSystem) managedResolveAlias(sp modules.SiaPath, lookup aliasLookupFunc) (resolved modules.SiaPath, isAlias bool, err error) {
+	maxHops := atomic.LoadUint64(&fs.atomicMaxAliasHops)
+	visited := make(map[string]struct{})
+	for hops := uint64(0); ; hops++ {
+		if _, exists := visited[sp.String()]; exists {
+			return modules.SiaPath{}, false, ErrAliasCycle
+		}
+		visited[sp.String()] = struct{}{}
+
+		next, found, err := fs.managedResolveAliasOnce(sp, lookup)
+		if err != nil {
+			return modules.SiaPath{}, false, err
+		}
+		if !found {
+			return sp, isAlias, nil
+		}
+		if hops == maxHops {
+			return modules.SiaPath{}, false, errors.AddContext(ErrTooManyAliasHops, fmt.Sprintf("limit is %v", maxHops))
+		}
+		isAlias = true
+		sp = next
+	}
+}
+
+// managedResolveAliasOnce replaces the first alias within the provided path
+// with its target.
+func (fs *FileSystem) managedResolveAliasOnce(sp modules.SiaPath, lookup aliasLookupFunc) (modules.SiaPath, bool, error) {
+	components := strings.Split(sp.String(), "/")
+	for i := 1; i <= len(components); i++ {
+		prefix, err := modules.NewSiaPath(strings.Join(components[:i], "/"))
+		if err != nil {
+			return modules.SiaPath{}, false, err
+		}
+		target, isAlias, err := lookup(prefix)
+		if err != nil {
+			return modules.SiaPath{}, false, err
+		}
+		if isAlias {
+			// Dirs and files take precedence over aliases.
+			exists, err := fs.managedDirExists(prefix)
+			if err != nil {
+				return modules.SiaPath{}, false, err
+			}
+			if !exists && i == len(components) {
+				exists, err = fs.managedFileExists(prefix)
+				if err != nil {
+					return modules.SiaPath{}, false, err
+				}
+			}
+			if exists {
+				continue
+			}
+			resolved, err := sp.Rebase(prefix, target)
+			if err != nil {
+				return modules.SiaPath{}, false, err
+			}
+			return resolved, true, nil
+		}
+		// If the prefix is neither a dir nor an alias, the path can't
+		// contain an alias.
+		if i < len(components) {
+			exists, err := fs.managedDirExists(prefix)
+			if err != nil {
+				return modules.SiaPath{}, false, err
+			}
+			if !exists {
+				return modules.SiaPath{}, false, nil
+			}
+		}
+	}
+	return modules.SiaPath{}, false, nil
+}
+
+// managedOpenAliasDir opens the dir an alias at the provided path points to.
+// ErrNotExist is returned if the path doesn't contain an alias.
+func (fs *FileSystem) managedOpenAliasDir(sp modules.SiaPath) (*DirNode, error) {
+	resolved, isAlias, err := fs.managedResolveAlias(sp, fs.managedReadAlias)
+	if err != nil {
+		return nil, err
+	}
+	if !isAlias {
+		return nil, ErrNotExist
+	}
+	dn, err := fs.managedOpenSiaDir(resolved)
+	if errors.Contains(err, ErrNotExist) {
+		return nil, ErrDanglingAlias
+	}
+	return dn, err
+}
+
+// managedOpenAliasFile opens the file an alias at the provided path points
+// to. ErrNotExist is returned if the path doesn't contain an alias.
+func (fs *FileSystem) managedOpenAliasFile(sp modules.SiaPath) (*FileNode, error) {
+	resolved, isAlias, err := fs.managedResolveAlias(sp, fs.managedReadAlias)
+	if err != nil {
+		return nil, err
+	}
+	if !isAlias {
+		return nil, ErrNotExist
+	}
+	fn, err := fs.managedOpenFile(resolved.String())
+	if errors.Contains(err, ErrNotExist) {
+		return nil, ErrDanglingAlias
+	}
+	return fn, err
+}
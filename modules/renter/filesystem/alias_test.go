@@ -0,0 +1,201 @@
+package filesystem
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+// TestAlias tests creating and resolving aliases.
+func TestAlias(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Add a versioned dir with a file.
+	fs.addTestSiaFile(newSiaPath("backups/v1/file"))
+	fs.addTestSiaFile(newSiaPath("backups/v2/file"))
+
+	// Create an alias for v2.
+	latest := newSiaPath("backups/latest")
+	if err := fs.CreateAlias(latest, newSiaPath("backups/v2")); err != nil {
+		t.Fatal(err)
+	}
+	// Creating it again should fail.
+	if err := fs.CreateAlias(latest, newSiaPath("backups/v1")); !errors.Contains(err, ErrExists) {
+		t.Fatal("expected ErrExists but got", err)
+	}
+	// Creating an alias for a target which doesn't exist should fail.
+	if err := fs.CreateAlias(newSiaPath("foo"), newSiaPath("bar")); !errors.Contains(err, ErrNotExist) {
+		t.Fatal("expected ErrNotExist but got", err)
+	}
+
+	// Opening the alias should open the target dir.
+	dn, err := fs.OpenSiaDir(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp := fs.DirSiaPath(dn); !sp.Equals(newSiaPath("backups/v2")) {
+		t.Fatal("alias resolved to wrong dir", sp)
+	}
+	if err := dn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Opening a file within the alias should open the file within the target.
+	fn, err := fs.OpenSiaFile(newSiaPath("backups/latest/file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp := fs.FileSiaPath(fn); !sp.Equals(newSiaPath("backups/v2/file")) {
+		t.Fatal("alias resolved to wrong file", sp)
+	}
+	if err := fn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The same applies when opening multiple files at once.
+	fns, closeFiles, err := fs.OpenSiaFiles([]modules.SiaPath{newSiaPath("backups/latest/file"), newSiaPath("backups/v1/file")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp := fs.FileSiaPath(fns[0]); !sp.Equals(newSiaPath("backups/v2/file")) {
+		t.Fatal("alias resolved to wrong file", sp)
+	}
+	if sp := fs.FileSiaPath(fns[1]); !sp.Equals(newSiaPath("backups/v1/file")) {
+		t.Fatal("wrong file", sp)
+	}
+	closeFiles()
+
+	// Aliases can point to aliases.
+	current := newSiaPath("current")
+	if err := fs.CreateAlias(current, latest); err != nil {
+		t.Fatal(err)
+	}
+	fn, err = fs.OpenSiaFile(newSiaPath("current/file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp := fs.FileSiaPath(fn); !sp.Equals(newSiaPath("backups/v2/file")) {
+		t.Fatal("alias resolved to wrong file", sp)
+	}
+	if err := fn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cycles should be rejected, both direct ones and ones that point into
+	// the alias itself.
+	if err := fs.CreateAlias(newSiaPath("loop"), newSiaPath("loop")); !errors.Contains(err, ErrAliasCycle) {
+		t.Fatal("expected ErrAliasCycle but got", err)
+	}
+	if err := fs.CreateAlias(newSiaPath("deep"), newSiaPath("deep/deeper")); !errors.Contains(err, ErrAliasCycle) {
+		t.Fatal("expected ErrAliasCycle but got", err)
+	}
+
+	// Deleting the target should leave a dangling alias.
+	if err := fs.DeleteDir(newSiaPath("backups/v2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.OpenSiaDir(latest); !errors.Contains(err, ErrDanglingAlias) {
+		t.Fatal("expected ErrDanglingAlias but got", err)
+	}
+	if _, err := fs.OpenSiaFile(newSiaPath("current/file")); !errors.Contains(err, ErrDanglingAlias) {
+		t.Fatal("expected ErrDanglingAlias but got", err)
+	}
+
+	// Replacing the target with an alias to the alias should be rejected.
+	if err := fs.CreateAlias(newSiaPath("backups/v2"), current); !errors.Contains(err, ErrAliasCycle) {
+		t.Fatal("expected ErrAliasCycle but got", err)
+	}
+
+	// Paths without aliases should still return ErrNotExist.
+	if _, err := fs.OpenSiaDir(newSiaPath("backups/v3")); !errors.Contains(err, ErrNotExist) {
+		t.Fatal("expected ErrNotExist but got", err)
+	}
+}
+
+// TestAliasHops tests that chains of aliases are only followed up to the
+// FileSystem's max number of hops.
+func TestAliasHops(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create a chain of 3 aliases pointing to a dir with a file.
+	fs.addTestSiaFile(newSiaPath("target/file"))
+	prev := newSiaPath("target")
+	for i := 1; i <= 3; i++ {
+		alias := newSiaPath(fmt.Sprintf("alias%v", i))
+		if err := fs.CreateAlias(alias, prev); err != nil {
+			t.Fatal(err)
+		}
+		prev = alias
+	}
+	last := prev
+
+	// checkHops checks that all ways of resolving the path either succeed or
+	// fail with ErrTooManyAliasHops.
+	checkHops := func(succeed bool) {
+		t.Helper()
+		check := func(err error) {
+			t.Helper()
+			if succeed && err != nil {
+				t.Fatal(err)
+			} else if !succeed && !errors.Contains(err, ErrTooManyAliasHops) {
+				t.Fatal("expected ErrTooManyAliasHops but got", err)
+			}
+		}
+		dn, err := fs.OpenSiaDir(last)
+		check(err)
+		if err == nil {
+			check(dn.Close())
+		}
+		fn, err := fs.OpenSiaFile(newSiaPath(last.String() + "/file"))
+		check(err)
+		if err == nil {
+			check(fn.Close())
+		}
+		fi, err := fs.Stat(last)
+		check(err)
+		if err == nil && !fi.IsDir() {
+			t.Fatal("alias should resolve to a dir")
+		}
+		exists, err := fs.Exists(newSiaPath(last.String() + "/file"))
+		check(err)
+		if succeed && !exists {
+			t.Fatal("file within alias should exist")
+		}
+	}
+
+	// A chain at the limit resolves.
+	fs.SetMaxAliasHops(3)
+	checkHops(true)
+
+	// A chain over the limit fails.
+	fs.SetMaxAliasHops(2)
+	checkHops(false)
+
+	// Extending the chain beyond the limit fails too.
+	if err := fs.CreateAlias(newSiaPath("alias4"), last); !errors.Contains(err, ErrTooManyAliasHops) {
+		t.Fatal("expected ErrTooManyAliasHops but got", err)
+	}
+
+	// Paths without aliases are unaffected by the limit.
+	fs.SetMaxAliasHops(0)
+	if exists, err := fs.Exists(newSiaPath("target/file")); err != nil || !exists {
+		t.Fatal("file should exist", exists, err)
+	}
+	if exists, err := fs.Exists(newSiaPath("missing")); err != nil || exists {
+		t.Fatal("file shouldn't exist", exists, err)
+	}
+}
@@ -0,0 +1,127 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// ErrNodeNameMismatch indicates that a node's name doesn't match the name
+	// it is stored under within its parent.
+	ErrNodeNameMismatch = errors.New("node name doesn't match its name within the parent")
+
+	// ErrNodePathMismatch indicates that a node's on-disk path doesn't match
+	// the path derived from its position within the tree.
+	ErrNodePathMismatch = errors.New("node path doesn't match its position within the tree")
+
+	// ErrNodeMissingOnDisk indicates that there is no dir or file on disk for
+	// a node.
+	ErrNodeMissingOnDisk = errors.New("node doesn't exist on disk")
+)
+
+type (
+	// NodeInconsistency describes a resident node whose in-memory state
+	// diverged from the disk.
+	NodeInconsistency struct {
+		// SiaPath is the path of the node derived from its position within
+		// the tree.
+		SiaPath modules.SiaPath
+
+		// Name and Path are the name and on-disk path stored in the node.
+		Name string
+		Path string
+
+		// Err is one of ErrNodeNameMismatch, ErrNodePathMismatch or
+		// ErrNodeMissingOnDisk.
+		Err error
+	}
+
+	// consistencyNode is a child of a dir that is checked by
+	// CheckNodeConsistency.
+	consistencyNode struct {
+		key   string
+		n     *node
+		dir   *DirNode
+		isDir bool
+	}
+)
+
+// CheckNodeConsistency verifies that every node which is currently loaded into
+// memory still matches the disk. For every node, its name must match the name
+// it is stored under, its path must match the path derived from the tree and
+// the corresponding dir or file must exist on disk. All mismatches are
+// returned. Nodes which are loaded or renamed while the check is running might
+// be reported.
+func (fs *FileSystem) CheckNodeConsistency() ([]NodeInconsistency, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedCheckNodeConsistency(&fs.DirNode, fs.managedAbsPath(), modules.RootSiaPath())
+}
+
+// managedCheckNodeConsistency checks the children of a dir recursively.
+// dirPath and siaPath are the paths of the dir derived from the tree.
+func (fs *FileSystem) managedCheckNodeConsistency(d *DirNode, dirPath string, siaPath modules.SiaPath) ([]NodeInconsistency, error) {
+	// Grab the children of the dir.
+	d.mu.Lock()
+	children := make([]consistencyNode, 0, len(d.directories)+len(d.files))
+	for key, dir := range d.directories {
+		children = append(children, consistencyNode{key: key, n: &dir.node, dir: dir, isDir: true})
+	}
+	for key, file := range d.files {
+		children = append(children, consistencyNode{key: key, n: &file.node})
+	}
+	d.mu.Unlock()
+
+	var inconsistencies []NodeInconsistency
+	for _, child := range children {
+		childSiaPath, err := siaPath.Join(child.key)
+		if err != nil {
+			return nil, err
+		}
+		expectedPath := filepath.Join(dirPath, child.key)
+		if !child.isDir {
+			expectedPath += modules.SiaFileExtension
+		}
+
+		child.n.mu.Lock()
+		name, path := *child.n.name, child.n.absPath()
+		child.n.mu.Unlock()
+
+		inconsistency := NodeInconsistency{
+			SiaPath: childSiaPath,
+			Name:    name,
+			Path:    path,
+		}
+		if name != child.key {
+			inconsistency.Err = ErrNodeNameMismatch
+		} else if path != expectedPath {
+			inconsistency.Err = ErrNodePathMismatch
+		} else {
+			fi, err := os.Stat(path)
+			if os.IsNotExist(err) || (err == nil && fi.IsDir() != child.isDir) {
+				inconsistency.Err = ErrNodeMissingOnDisk
+			} else if err != nil {
+				return nil, errors.AddContext(err, "failed to stat node")
+			}
+		}
+		if inconsistency.Err != nil {
+			inconsistencies = append(inconsistencies, inconsistency)
+		}
+
+		// Check the children of dirs.
+		if !child.isDir {
+			continue
+		}
+		childInconsistencies, err := fs.managedCheckNodeConsistency(child.dir, expectedPath, childSiaPath)
+		if err != nil {
+			return nil, err
+		}
+		inconsistencies = append(inconsistencies, childInconsistencies...)
+	}
+	return inconsistencies, nil
+}
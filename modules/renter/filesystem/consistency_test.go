@@ -0,0 +1,131 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+// TestCheckNodeConsistency tests that CheckNodeConsistency detects nodes that
+// diverged from the disk.
+func TestCheckNodeConsistency(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create a file within a nested dir and a file next to it.
+	fileSP := newSiaPath("a/b/file")
+	otherSP := newSiaPath("a/other")
+	fs.addTestSiaFile(fileSP)
+	fs.addTestSiaFile(otherSP)
+
+	// Open the file and the dirs to keep them loaded.
+	dirSP := newSiaPath("a/b")
+	dn, err := fs.OpenSiaDir(dirSP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := dn.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	fn, err := fs.OpenSiaFile(fileSP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := fn.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	other, err := fs.OpenSiaFile(otherSP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := other.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The tree should be consistent.
+	inconsistencies, err := fs.CheckNodeConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inconsistencies) != 0 {
+		t.Fatal("expected no inconsistencies", inconsistencies)
+	}
+
+	// Rename the dir out-of-band.
+	err = os.Rename(fs.DirPath(dirSP), fs.DirPath(newSiaPath("a/c")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dir and the file within it should be reported.
+	inconsistencies, err = fs.CheckNodeConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inconsistencies) != 2 {
+		t.Fatal("expected 2 inconsistencies", inconsistencies)
+	}
+	for _, inconsistency := range inconsistencies {
+		if inconsistency.Err != ErrNodeMissingOnDisk {
+			t.Fatal("wrong error", inconsistency.Err)
+		}
+		if !inconsistency.SiaPath.Equals(dirSP) && !inconsistency.SiaPath.Equals(fileSP) {
+			t.Fatal("wrong node reported", inconsistency.SiaPath)
+		}
+	}
+
+	// Rename the dir back and corrupt the name of the other file.
+	err = os.Rename(fs.DirPath(newSiaPath("a/c")), fs.DirPath(dirSP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.mu.Lock()
+	*other.name = "wrongname"
+	other.mu.Unlock()
+	inconsistencies, err = fs.CheckNodeConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inconsistencies) != 1 {
+		t.Fatal("expected 1 inconsistency", inconsistencies)
+	}
+	if inconsistencies[0].Err != ErrNodeNameMismatch || !inconsistencies[0].SiaPath.Equals(otherSP) {
+		t.Fatal("wrong inconsistency", inconsistencies[0])
+	}
+	other.mu.Lock()
+	*other.name = "other"
+	other.mu.Unlock()
+
+	// Corrupt the path of the file instead.
+	fn.mu.Lock()
+	oldPath := *fn.path
+	*fn.path = filepath.Join(root, "wrongpath"+modules.SiaFileExtension)
+	fn.mu.Unlock()
+	inconsistencies, err = fs.CheckNodeConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inconsistencies) != 1 {
+		t.Fatal("expected 1 inconsistency", inconsistencies)
+	}
+	if inconsistencies[0].Err != ErrNodePathMismatch || !inconsistencies[0].SiaPath.Equals(fileSP) {
+		t.Fatal("wrong inconsistency", inconsistencies[0])
+	}
+	fn.mu.Lock()
+	*fn.path = oldPath
+	fn.mu.Unlock()
+}
@@ -0,0 +1,189 @@
+package filesystem
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+)
+
+// dirFileInfo is an os.FileInfo for a dir which reports the dir's
+// AggregateModTime as its ModTime.
+type dirFileInfo struct {
+	os.FileInfo
+	modTime time.Time
+}
+
+// ModTime returns the AggregateModTime of the dir.
+func (fi dirFileInfo) ModTime() time.Time { return fi.modTime }
+
+// UpdateDirModTime recomputes the AggregateModTime of the dir at siaPath by
+// calling bubble for the dir and all of its subdirs bottom-up. The
+// AggregateModTime of a dir is maintained by the bubble, which sets it to the
+// most recent ModTime of the dir's files and the AggregateModTime of its
+// subdirs. In between bubbles, modifications of files advance the
+// AggregateModTime of their dirs. The updated AggregateModTime of the dir is
+// returned.
+func (fs *FileSystem) UpdateDirModTime(siaPath modules.SiaPath, bubble BubbleFunc) (time.Time, error) {
+	if err := fs.tg.Add(); err != nil {
+		return time.Time{}, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	if err := fs.managedBubbleTree(siaPath, bubble); err != nil {
+		return time.Time{}, err
+	}
+	md, err := fs.managedDirMetadata(siaPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return md.AggregateModTime, nil
+}
+
+// managedBubbleTree calls bubble for the dir at siaPath after calling it for
+// all of its subdirs. Subdirs which are deleted in the meantime are skipped.
+func (fs *FileSystem) managedBubbleTree(siaPath modules.SiaPath, bubble BubbleFunc) error {
+	fis, err := fs.managedReadDir(siaPath)
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		sp, err := siaPath.Join(fi.Name())
+		if err != nil {
+			return err
+		}
+		err = fs.managedBubbleTree(sp, bubble)
+		if err != nil && !errors.Contains(err, ErrNotExist) {
+			return err
+		}
+	}
+	return bubble(siaPath)
+}
+
+// managedAdvanceAggregateModTime advances the AggregateModTime of the dir and
+// its ancestors to modTime. It is called whenever a file or dir within the
+// dir's sub tree was created, moved or modified to guarantee that the
+// AggregateModTime of a dir is never before the ModTime of any file within it,
+// even before the next bubble. Ancestors are only updated if the dir was,
+// since the AggregateModTime of a dir is never before the one of its subdirs.
+func (n *DirNode) managedAdvanceAggregateModTime(modTime time.Time) error {
+	for dir := n; dir != nil; {
+		dir.mu.Lock()
+		sd, err := dir.siaDir()
+		var advanced bool
+		if err == nil {
+			advanced, err = sd.AdvanceAggregateModTime(modTime)
+		}
+		parent := dir.parent
+		dir.mu.Unlock()
+		if err != nil {
+			return errors.AddContext(err, "failed to advance AggregateModTime")
+		}
+		if !advanced {
+			return nil
+		}
+		dir = parent
+	}
+	return nil
+}
+
+// ModifiedSince returns the SiaPaths of the files within the dir at siaPath
+// and its subdirs whose ModTime is not before since, sorted by SiaPath. Dirs
+// whose AggregateModTime is before since are skipped without reading them
+// since none of their files can have been modified.
+func (fs *FileSystem) ModifiedSince(siaPath modules.SiaPath, since time.Time) ([]modules.SiaPath, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	var paths []modules.SiaPath
+	err := fs.managedModifiedSince(siaPath, since, &paths)
+	if errors.Contains(err, ErrNotExist) || os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].String() < paths[j].String()
+	})
+	return paths, nil
+}
+
+// managedModifiedSince appends the files within the dir at siaPath and its
+// subdirs which were modified since since to paths.
+func (fs *FileSystem) managedModifiedSince(siaPath modules.SiaPath, since time.Time, paths *[]modules.SiaPath) error {
+	md, err := fs.managedDirMetadata(siaPath)
+	if err != nil {
+		return err
+	}
+	if md.AggregateModTime.Before(since) {
+		return nil
+	}
+
+	fis, err := fs.managedReadDir(siaPath)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if !fi.IsDir() && !strings.HasSuffix(fi.Name(), modules.SiaFileExtension) {
+			continue
+		}
+		sp, err := siaPath.Join(strings.TrimSuffix(fi.Name(), modules.SiaFileExtension))
+		if err != nil {
+			return err
+		}
+		// Files and dirs which are deleted in the meantime are ignored.
+		if fi.IsDir() {
+			err = fs.managedModifiedSince(sp, since, paths)
+		} else {
+			var fmd siafile.BubbledMetadata
+			fmd, err = fs.managedCachedFileMetadata(sp)
+			if err == nil && !fmd.ModTime.Before(since) {
+				*paths = append(*paths, sp)
+			}
+		}
+		if errors.Contains(err, ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedCachedFileMetadata returns the cached metadata of the file at
+// siaPath.
+func (fs *FileSystem) managedCachedFileMetadata(siaPath modules.SiaPath) (_ siafile.BubbledMetadata, err error) {
+	sf, err := fs.managedOpenSiaFile(siaPath)
+	if err != nil {
+		return siafile.BubbledMetadata{}, err
+	}
+	defer func() {
+		err = errors.Compose(err, sf.Close())
+	}()
+	md := sf.Metadata()
+	_, statErr := os.Stat(sf.LocalPath())
+	return siafile.BubbledMetadata{
+		Health:              md.CachedHealth,
+		LastHealthCheckTime: sf.LastHealthCheckTime(),
+		ModTime:             sf.ModTime(),
+		NumStuckChunks:      md.CachedNumStuckChunks,
+		OnDisk:              statErr == nil,
+		Redundancy:          md.CachedRedundancy,
+		RepairBytes:         md.CachedRepairBytes,
+		Size:                sf.Size(),
+		StuckBytes:          md.CachedStuckBytes,
+		StuckHealth:         md.CachedStuckHealth,
+		UID:                 sf.UID(),
+	}, nil
+}
@@ -2,6 +2,7 @@ package filesystem
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
@@ -25,6 +26,11 @@ type (
 		directories map[string]*DirNode
 		files       map[string]*FileNode
 
+		// reservedNames contains the names of the children which are
+		// currently being created on disk. The channel is closed once the
+		// creation is done.
+		reservedNames map[string]chan struct{}
+
 		// lazySiaDir is the SiaDir of the DirNode. 'lazy' means that it will
 		// only be loaded on demand and destroyed as soon as the length of
 		// 'threads' reaches 0.
@@ -134,6 +140,28 @@ func (n *DirNode) Path() (string, error) {
 	return sd.Path(), nil
 }
 
+// SetQuota is a wrapper for SiaDir.SetQuota.
+func (n *DirNode) SetQuota(quota uint64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	sd, err := n.siaDir()
+	if err != nil {
+		return err
+	}
+	return sd.SetQuota(quota)
+}
+
+// SetReadOnly is a wrapper for SiaDir.SetReadOnly.
+func (n *DirNode) SetReadOnly(readOnly bool) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	sd, err := n.siaDir()
+	if err != nil {
+		return err
+	}
+	return sd.SetReadOnly(readOnly)
+}
+
 // UpdateBubbledMetadata is a wrapper for SiaDir.UpdateBubbledMetadata.
 func (n *DirNode) UpdateBubbledMetadata(md siadir.Metadata) error {
 	n.mu.Lock()
@@ -331,8 +359,8 @@ func (n *DirNode) closeDirNode() {
 
 // managedNewSiaFileFromReader will read a siafile and its chunks from the given
 // reader and add it to the directory. This will always load the file from the
-// given reader.
-func (n *DirNode) managedNewSiaFileFromExisting(sf *siafile.SiaFile, chunks siafile.Chunks) error {
+// given reader. 'created' is false if the exact same file already exists.
+func (n *DirNode) managedNewSiaFileFromExisting(sf *siafile.SiaFile, chunks siafile.Chunks) (created bool, err error) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	// Get the initial path of the siafile.
@@ -340,7 +368,7 @@ func (n *DirNode) managedNewSiaFileFromExisting(sf *siafile.SiaFile, chunks siaf
 	// Check if the path is taken.
 	currentPath, exists := n.uniquePrefix(path, sf.UID())
 	if exists {
-		return nil // file already exists
+		return false, nil // file already exists
 	}
 	// Either the file doesn't exist yet or we found a filename that doesn't
 	// exist. Update the UID for safety and set the correct siafilepath.
@@ -348,16 +376,16 @@ func (n *DirNode) managedNewSiaFileFromExisting(sf *siafile.SiaFile, chunks siaf
 	sf.SetSiaFilePath(currentPath)
 	// Save the file to disk.
 	if err := sf.SaveWithChunks(chunks); err != nil {
-		return err
+		return false, err
 	}
 	// Add the node to the dir.
 	fileName := strings.TrimSuffix(filepath.Base(currentPath), modules.SiaFileExtension)
 	fn := &FileNode{
-		node:    newNode(n, currentPath, fileName, 0, n.staticWal, n.staticLog),
+		node:    newNode(n, currentPath, fileName, 0, n.staticWal, n.staticDeps, n.staticLog, n.staticMigrations),
 		SiaFile: sf,
 	}
 	n.files[fileName] = fn
-	return nil
+	return true, nil
 }
 
 // managedNewSiaFileFromLegacyData adds an existing SiaFile to the filesystem
@@ -382,7 +410,7 @@ func (n *DirNode) managedNewSiaFileFromLegacyData(fileName string, fd siafile.Fi
 	}
 	// Add it to the node.
 	fn := &FileNode{
-		node:    newNode(n, path, key, 0, n.staticWal, n.staticLog),
+		node:    newNode(n, path, key, 0, n.staticWal, n.staticDeps, n.staticLog, n.staticMigrations),
 		SiaFile: sf,
 	}
 	n.files[key] = fn
@@ -421,7 +449,15 @@ func (n *DirNode) siaDir() (*siadir.SiaDir, error) {
 	if *n.lazySiaDir != nil {
 		return *n.lazySiaDir, nil
 	}
-	sd, err := siadir.LoadSiaDir(n.absPath(), modules.ProdDependencies)
+	// Upgrade the metadata before it is parsed.
+	err := n.staticMigrations.managedMigrateDir(n.absPath(), n.staticDeps)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to migrate dir metadata")
+	}
+	sd, err := siadir.LoadSiaDir(n.absPath(), n.staticDeps)
 	if os.IsNotExist(err) {
 		return nil, ErrNotExist
 	}
@@ -527,9 +563,25 @@ func (n *DirNode) managedDelete() error {
 func (n *DirNode) managedDeleteFile(fileName string) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	return n.deleteFile(fileName, false)
+}
+
+// managedDeleteClosedFile is like managedDeleteFile but returns ErrFileInUse
+// instead of deleting the file if it is currently open.
+func (n *DirNode) managedDeleteClosedFile(fileName string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.deleteFile(fileName, true)
+}
 
+// deleteFile deletes the file with the given name from the directory. If
+// onlyClosed is true, open files are not deleted.
+func (n *DirNode) deleteFile(fileName string, onlyClosed bool) error {
 	// Check if the file is open in memory. If it is delete it.
 	sf, exists := n.files[fileName]
+	if exists && onlyClosed {
+		return ErrFileInUse
+	}
 	if exists {
 		err := sf.managedDelete()
 		if err != nil {
@@ -632,6 +684,9 @@ func (n *DirNode) childExists(name string) bool {
 	if _, exists := n.directories[name]; exists {
 		return true
 	}
+	if _, reserved := n.reservedNames[name]; reserved {
+		return true
+	}
 	// Check that no dir or file exists on disk.
 	_, errFile := os.Stat(filepath.Join(n.absPath(), name))
 	_, errDir := os.Stat(filepath.Join(n.absPath(), name+modules.SiaFileExtension))
@@ -660,27 +715,70 @@ func (n *DirNode) managedNewSiaFile(fileName string, source string, ec modules.E
 	return errors.AddContext(err, "NewSiaFile: failed to create file")
 }
 
-// managedNewSiaDir creates the SiaDir with the given dirName as its child. We
-// try to create the SiaDir if it exists in memory but not on disk, as it may
-// have just been deleted. We also do not return an error if the SiaDir exists
-// in memory and on disk already, which may be due to a race.
-func (n *DirNode) managedNewSiaDir(dirName string, rootPath string, mode os.FileMode) error {
+// managedNewSiaDir creates the SiaDir with the given dirName as its child. The
+// name is reserved while the SiaDir is created on disk which prevents
+// concurrent creations of the same name from racing on disk. If the name is
+// already reserved, we wait for the reservation to be released. We do not
+// return an error if the SiaDir exists on disk already. 'created' is only true
+// if the SiaDir was actually created.
+func (n *DirNode) managedNewSiaDir(dirName string, rootPath string, mode os.FileMode) (created bool, err error) {
 	n.mu.Lock()
-	defer n.mu.Unlock()
+	for {
+		done, reserved := n.reservedNames[dirName]
+		if !reserved {
+			break
+		}
+		n.mu.Unlock()
+		<-done
+		n.mu.Lock()
+	}
 	// Check if a file already exists with that name.
 	if _, exists := n.files[dirName]; exists {
-		return ErrExists
+		n.mu.Unlock()
+		return false, ErrExists
 	}
 	// Check that no dir or file exists on disk.
-	_, err := os.Stat(filepath.Join(n.absPath(), dirName+modules.SiaFileExtension))
+	_, err = os.Stat(filepath.Join(n.absPath(), dirName+modules.SiaFileExtension))
 	if !os.IsNotExist(err) {
-		return ErrExists
+		n.mu.Unlock()
+		return false, ErrExists
 	}
-	_, err = siadir.New(filepath.Join(n.absPath(), dirName), rootPath, mode)
+	// Reserve the name and create the dir without holding the lock.
+	done := make(chan struct{})
+	n.reservedNames[dirName] = done
+	dirPath := filepath.Join(n.absPath(), dirName)
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		delete(n.reservedNames, dirName)
+		close(done)
+		n.mu.Unlock()
+	}()
+	// Allow tests to block the creation while the name is reserved.
+	n.staticDeps.Disrupt("BlockNewSiaDir")
+
+	_, err = siadir.New(dirPath, rootPath, mode, n.staticDeps)
 	if errors.Contains(err, os.ErrExist) {
-		return nil
+		return false, nil
 	}
-	return err
+	return err == nil, err
+}
+
+// managedReplaceSiaFile replaces the SiaFile with the given name with the one
+// read from r. The dir's lock is held for the duration of the replacement to
+// prevent the file from being loaded from disk while it is swapped.
+// The ModTime of the new SiaFile is returned.
+func (n *DirNode) managedReplaceSiaFile(fileName string, r io.ReadSeeker) (time.Time, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	fn, err := n.readonlyOpenFile(fileName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := fn.ReplaceFromReader(r); err != nil {
+		return time.Time{}, err
+	}
+	return fn.ModTime(), nil
 }
 
 // managedOpenFile opens a SiaFile and adds it and all of its parents to the
@@ -713,7 +811,15 @@ func (n *DirNode) readonlyOpenFile(fileName string) (*FileNode, error) {
 	}
 	// Load file from disk.
 	filePath := filepath.Join(n.absPath(), fileName+modules.SiaFileExtension)
-	sf, err := siafile.LoadSiaFile(filePath, n.staticWal)
+	// Upgrade the metadata before it is parsed.
+	err := n.staticMigrations.managedMigrateFile(filePath, n.staticWal, n.staticDeps)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, errors.AddContext(err, fmt.Sprintf("failed to migrate metadata of SiaFile '%v'", filePath))
+	}
+	sf, err := siafile.LoadSiaFileWithDeps(filePath, n.staticWal, n.staticDeps)
 	if errors.Contains(err, siafile.ErrUnknownPath) || os.IsNotExist(err) {
 		return nil, ErrNotExist
 	}
@@ -721,7 +827,7 @@ func (n *DirNode) readonlyOpenFile(fileName string) (*FileNode, error) {
 		return nil, errors.AddContext(err, fmt.Sprintf("failed to load SiaFile '%v' from disk", filePath))
 	}
 	fn = &FileNode{
-		node:    newNode(n, filePath, fileName, 0, n.staticWal, n.staticLog),
+		node:    newNode(n, filePath, fileName, 0, n.staticWal, n.staticDeps, n.staticLog, n.staticMigrations),
 		SiaFile: sf,
 	}
 	// Clone the node, give it a new UID and return it.
@@ -755,10 +861,11 @@ func (n *DirNode) openDir(dirName string) (*DirNode, error) {
 	}
 	// Add the dir to the opened dirs.
 	dir = &DirNode{
-		node:        newNode(n, dirPath, dirName, 0, n.staticWal, n.staticLog),
-		directories: make(map[string]*DirNode),
-		files:       make(map[string]*FileNode),
-		lazySiaDir:  new(*siadir.SiaDir),
+		node:          newNode(n, dirPath, dirName, 0, n.staticWal, n.staticDeps, n.staticLog, n.staticMigrations),
+		directories:   make(map[string]*DirNode),
+		files:         make(map[string]*FileNode),
+		reservedNames: make(map[string]chan struct{}),
+		lazySiaDir:    new(*siadir.SiaDir),
 	}
 	n.directories[*dir.name] = dir
 	return dir.managedCopy(), nil
@@ -0,0 +1,153 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// EventCreateDir is logged when a directory is created.
+	EventCreateDir EventOp = "createdir"
+
+	// EventCreateFile is logged when a file is created.
+	EventCreateFile EventOp = "createfile"
+
+	// EventDeleteDir is logged when a directory is deleted.
+	EventDeleteDir EventOp = "deletedir"
+
+	// EventDeleteFile is logged when a file is deleted.
+	EventDeleteFile EventOp = "deletefile"
+
+	// EventRenameDir is logged when a directory is renamed or moved.
+	EventRenameDir EventOp = "renamedir"
+
+	// EventRenameFile is logged when a file is renamed or moved.
+	EventRenameFile EventOp = "renamefile"
+)
+
+var (
+	// errEventLogEnabled is returned when enabling the event log while it is
+	// already enabled.
+	errEventLogEnabled = errors.New("event log is already enabled")
+)
+
+type (
+	// EventOp describes the type of a structural mutation of the FileSystem.
+	EventOp string
+
+	// Event is a record of a structural mutation of the FileSystem.
+	Event struct {
+		Timestamp time.Time `json:"timestamp"`
+		Op        EventOp   `json:"op"`
+
+		// OldPath is the path of the node before the mutation. It is empty
+		// for created nodes. NewPath is the path of the node after the
+		// mutation. It is empty for deleted nodes.
+		OldPath modules.SiaPath `json:"oldpath"`
+		NewPath modules.SiaPath `json:"newpath"`
+
+		// Thread is the threadUID of the node copy the mutation was
+		// performed on. It is 0 if the mutation was performed on the
+		// FileSystem directly.
+		Thread uint64 `json:"thread"`
+	}
+
+	// EventReader reads events from an event log to replay them.
+	EventReader struct {
+		staticDecoder *json.Decoder
+	}
+
+	// eventLog is an append-only log of events. Writes to the log are synced
+	// according to the FileSystem's SyncOptions.
+	eventLog struct {
+		f       modules.File
+		encoder *json.Encoder
+		mu      sync.Mutex
+	}
+)
+
+// NewEventReader creates a new reader for an event log.
+func NewEventReader(r io.Reader) *EventReader {
+	return &EventReader{
+		staticDecoder: json.NewDecoder(r),
+	}
+}
+
+// Next returns the next event of the log. Once all events were read, io.EOF is
+// returned.
+func (er *EventReader) Next() (Event, error) {
+	var e Event
+	err := er.staticDecoder.Decode(&e)
+	if errors.Contains(err, io.EOF) {
+		return Event{}, io.EOF
+	}
+	if err != nil {
+		return Event{}, errors.AddContext(err, "failed to decode event")
+	}
+	return e, nil
+}
+
+// DisableEventLog stops recording events and closes the event log. It is a
+// no-op if the event log isn't enabled.
+func (fs *FileSystem) DisableEventLog() error {
+	el := fs.staticEventLog
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if el.f == nil {
+		return nil
+	}
+	err := errors.Compose(el.f.Sync(), el.f.Close())
+	el.f = nil
+	el.encoder = nil
+	return err
+}
+
+// EnableEventLog starts recording all structural mutations of the FileSystem
+// to the log at the provided path. Events are appended to the log if it
+// already exists.
+func (fs *FileSystem) EnableEventLog(path string) error {
+	el := fs.staticEventLog
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if el.f != nil {
+		return errEventLogEnabled
+	}
+	f, err := fs.staticDeps.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, modules.DefaultFilePerm)
+	if err != nil {
+		return errors.AddContext(err, "failed to open event log")
+	}
+	el.f = f
+	el.encoder = json.NewEncoder(f)
+	return nil
+}
+
+// logEvent appends an event to the event log if it is enabled. Since the
+// mutation already happened, failures are logged instead of returned.
+func (fs *FileSystem) logEvent(op EventOp, oldPath, newPath modules.SiaPath, thread threadUID) {
+	el := fs.staticEventLog
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if el.f == nil {
+		return
+	}
+	e := Event{
+		Timestamp: time.Now(),
+		Op:        op,
+		OldPath:   oldPath,
+		NewPath:   newPath,
+		Thread:    uint64(thread),
+	}
+	err := el.encoder.Encode(e)
+	if err == nil {
+		err = el.f.Sync()
+	}
+	if err != nil {
+		fs.staticLog.Printf("WARN: failed to log %v event for '%v' -> '%v': %v", op, oldPath, newPath, err)
+	}
+}
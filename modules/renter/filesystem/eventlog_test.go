@@ -0,0 +1,122 @@
+package filesystem
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+// readEvents is a helper to read all events from an event log.
+func readEvents(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var events []Event
+	er := NewEventReader(f)
+	for {
+		e, err := er.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+}
+
+// TestEventLog tests that structural mutations of the FileSystem are recorded
+// in the event log.
+func TestEventLog(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	dir := testDir(t.Name())
+	root := filepath.Join(dir, "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create a file before enabling the log. It shouldn't be recorded.
+	oldPath := newSiaPath("dir/file")
+	fs.addTestSiaFile(oldPath)
+
+	// Enable the event log.
+	logPath := filepath.Join(dir, "events.log")
+	if err := fs.EnableEventLog(logPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.EnableEventLog(logPath); err != errEventLogEnabled {
+		t.Fatal("expected errEventLogEnabled but got", err)
+	}
+
+	// Rename the file. This should result in exactly one event.
+	newPath := newSiaPath("dir/file2")
+	if err := fs.RenameFile(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	events, err := readEvents(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatal("expected 1 event but got", len(events))
+	}
+	e := events[0]
+	if e.Op != EventRenameFile {
+		t.Fatal("wrong op", e.Op)
+	}
+	if !e.OldPath.Equals(oldPath) || !e.NewPath.Equals(newPath) {
+		t.Fatal("wrong paths", e.OldPath, e.NewPath)
+	}
+	if e.Timestamp.IsZero() {
+		t.Fatal("timestamp not set")
+	}
+
+	// Create a dir and delete the file.
+	dirPath := newSiaPath("dir2")
+	if err := fs.NewSiaDir(dirPath, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.DeleteFile(newPath); err != nil {
+		t.Fatal(err)
+	}
+	// Creating the same dir again is a no-op and shouldn't be recorded.
+	if err := fs.NewSiaDir(dirPath, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	events, err = readEvents(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatal("expected 3 events but got", len(events))
+	}
+	if events[1].Op != EventCreateDir || !events[1].NewPath.Equals(dirPath) {
+		t.Fatal("wrong event", events[1])
+	}
+	if events[2].Op != EventDeleteFile || !events[2].OldPath.Equals(newPath) {
+		t.Fatal("wrong event", events[2])
+	}
+
+	// Disable the log. Further mutations shouldn't be recorded.
+	if err := fs.DisableEventLog(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.DeleteDir(dirPath); err != nil {
+		t.Fatal(err)
+	}
+	events, err = readEvents(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatal("expected 3 events but got", len(events))
+	}
+}
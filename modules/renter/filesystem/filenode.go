@@ -24,16 +24,23 @@ type (
 )
 
 // AddPiece wraps siafile.AddPiece to guarantee that it's not called when the
-// fileNode was already closed.
+// fileNode was already closed. Since adding a piece modifies the file, the
+// AggregateModTime of the file's dirs is advanced afterwards.
 func (n *FileNode) AddPiece(pk types.SiaPublicKey, chunkIndex, pieceIndex uint64, merkleRoot crypto.Hash) (err error) {
 	n.mu.Lock()
-	defer n.mu.Unlock()
 	if n.closed {
+		n.mu.Unlock()
 		err := errors.New("AddPiece called on close FileNode")
 		build.Critical(err)
 		return err
 	}
-	return n.SiaFile.AddPiece(pk, chunkIndex, pieceIndex, merkleRoot)
+	err = n.SiaFile.AddPiece(pk, chunkIndex, pieceIndex, merkleRoot)
+	parent := n.parent
+	n.mu.Unlock()
+	if err != nil || parent == nil {
+		return err
+	}
+	return parent.managedAdvanceAggregateModTime(n.ModTime())
 }
 
 // close closes the file and removes it from the parent if it was the last open
@@ -8,10 +8,13 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/NebulousLabs/threadgroup"
 	"gitlab.com/NebulousLabs/writeaheadlog"
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
@@ -21,6 +24,16 @@ import (
 	"go.sia.tech/siad/persist"
 )
 
+const (
+	// maxNameLength is the max length of a single element of a path on
+	// disk.
+	maxNameLength = 255
+
+	// maxPathLength is the max length of a path on disk including the
+	// terminating null byte.
+	maxPathLength = 4096
+)
+
 var (
 	// ErrNotExist is returned when a file or folder can't be found on disk.
 	ErrNotExist = errors.New("path does not exist")
@@ -32,6 +45,33 @@ var (
 	// ErrDeleteFileIsDir is returned when the file delete method is used but
 	// the filename corresponds to a directory
 	ErrDeleteFileIsDir = errors.New("cannot delete file, file is a directory")
+
+	// ErrQuotaExceeded is returned when adding a file to a directory would
+	// exceed the soft quota of the directory or of one of its ancestors.
+	ErrQuotaExceeded = errors.New("directory quota exceeded")
+
+	// ErrReadOnlyDir is returned when creating, renaming or deleting a file
+	// or directory within the sub tree of a read-only directory.
+	ErrReadOnlyDir = errors.New("directory is read-only")
+
+	// ErrShuttingDown is returned when a node is opened after the FileSystem
+	// was closed.
+	ErrShuttingDown = errors.New("filesystem is shutting down")
+
+	// ErrReservedName is returned when a path contains an element which
+	// clashes with the metadata stored on disk.
+	ErrReservedName = errors.New("path contains a reserved name")
+
+	// ErrPathTooLong is returned when the path on disk would exceed the
+	// limits of the OS.
+	ErrPathTooLong = errors.New("path is too long")
+
+	// ErrFileInUse is returned by DeleteFiles for files which are currently
+	// open.
+	ErrFileInUse = errors.New("file is currently open")
+
+	// errNoSiaPaths is returned by CommonAncestor if no paths are provided.
+	errNoSiaPaths = errors.New("no SiaPaths provided")
 )
 
 type (
@@ -39,20 +79,52 @@ type (
 	// SiaFiles, SiaDirs and potentially other supported Sia types in the
 	// future.
 	FileSystem struct {
+		// atomicMaxAliasHops is the max number of aliases which are followed
+		// when resolving a path. It comes first to guarantee 64-bit
+		// alignment.
+		atomicMaxAliasHops uint64
+
 		DirNode
+
+		// staticEventLog records structural mutations of the FileSystem if
+		// enabled.
+		staticEventLog *eventLog
+
+		// staticSyncer applies the FileSystem's SyncOptions to metadata
+		// writes.
+		staticSyncer *metadataSyncer
+
+		// staticScanner is the opt-in background metadata scanner.
+		staticScanner *metadataScanner
+
+		// staticQuotaReservations tracks the bytes of files which are
+		// currently being created within dirs with a quota.
+		staticQuotaReservations *quotaReservations
+
+		// staticReadOnlyFlags caches the read-only flags of dirs and
+		// prevents them from changing during writes.
+		staticReadOnlyFlags *readOnlyFlags
+
+		// tg tracks the in-flight operations of the FileSystem's methods to
+		// allow for draining them on shutdown.
+		tg threadgroup.ThreadGroup
 	}
 
 	// node is a struct that contains the common fields of every node.
 	node struct {
 		// fields that all copies of a node share.
-		path      *string
-		parent    *DirNode
-		name      *string
-		staticWal *writeaheadlog.WAL
-		threads   map[threadUID]struct{} // tracks all the threadUIDs of evey copy of the node
-		staticLog *persist.Logger
-		staticUID uint64
-		mu        *sync.Mutex
+		path       *string
+		parent     *DirNode
+		name       *string
+		staticWal  *writeaheadlog.WAL
+		staticDeps modules.Dependencies
+		threads    map[threadUID]struct{} // tracks all the threadUIDs of evey copy of the node
+		staticLog  *persist.Logger
+		staticUID  uint64
+		mu         *sync.Mutex
+
+		// staticMigrations are the metadata migrations of the FileSystem.
+		staticMigrations *metadataMigrations
 
 		// fields that differ between copies of the same node.
 		threadUID threadUID // unique ID of a copy of a node
@@ -61,17 +133,19 @@ type (
 )
 
 // newNode is a convenience function to initialize a node.
-func newNode(parent *DirNode, path, name string, uid threadUID, wal *writeaheadlog.WAL, log *persist.Logger) node {
+func newNode(parent *DirNode, path, name string, uid threadUID, wal *writeaheadlog.WAL, deps modules.Dependencies, log *persist.Logger, migrations *metadataMigrations) node {
 	return node{
-		path:      &path,
-		parent:    parent,
-		name:      &name,
-		staticLog: log,
-		staticUID: newInode(),
-		staticWal: wal,
-		threads:   make(map[threadUID]struct{}),
-		threadUID: uid,
-		mu:        new(sync.Mutex),
+		path:             &path,
+		staticDeps:       deps,
+		parent:           parent,
+		name:             &name,
+		staticLog:        log,
+		staticUID:        newInode(),
+		staticWal:        wal,
+		threads:          make(map[threadUID]struct{}),
+		threadUID:        uid,
+		mu:               new(sync.Mutex),
+		staticMigrations: migrations,
 	}
 }
 
@@ -147,19 +221,36 @@ func (n *node) managedAbsPath() string {
 }
 
 // New creates a new FileSystem at the specified root path. The folder will be
-// created if it doesn't exist already.
-func New(root string, log *persist.Logger, wal *writeaheadlog.WAL) (*FileSystem, error) {
+// created if it doesn't exist already. The SyncOptions determine how metadata
+// writes are synced to disk.
+func New(root string, log *persist.Logger, wal *writeaheadlog.WAL, opts SyncOptions) (*FileSystem, error) {
+	syncer, err := newMetadataSyncer(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	deps := &syncDependencies{
+		ProductionDependencies: modules.ProdDependencies,
+		staticSyncer:           syncer,
+	}
 	fs := &FileSystem{
+		atomicMaxAliasHops: defaultMaxAliasHops,
 		DirNode: DirNode{
 			// The root doesn't require a parent, a name or uid.
-			node:        newNode(nil, root, "", 0, wal, log),
-			directories: make(map[string]*DirNode),
-			files:       make(map[string]*FileNode),
-			lazySiaDir:  new(*siadir.SiaDir),
+			node:          newNode(nil, root, "", 0, wal, deps, log, newMetadataMigrations()),
+			directories:   make(map[string]*DirNode),
+			files:         make(map[string]*FileNode),
+			reservedNames: make(map[string]chan struct{}),
+			lazySiaDir:    new(*siadir.SiaDir),
 		},
+		staticEventLog: new(eventLog),
+		staticSyncer:   syncer,
+		staticScanner:  new(metadataScanner),
+
+		staticQuotaReservations: newQuotaReservations(),
+		staticReadOnlyFlags:     newReadOnlyFlags(),
 	}
 	// Prepare root folder.
-	err := fs.NewSiaDir(modules.RootSiaPath(), modules.DefaultDirPerm)
+	err = fs.NewSiaDir(modules.RootSiaPath(), modules.DefaultDirPerm)
 	if err != nil && !errors.Contains(err, ErrExists) {
 		return nil, err
 	}
@@ -172,6 +263,10 @@ func New(root string, log *persist.Logger, wal *writeaheadlog.WAL) (*FileSystem,
 // path will be chosen. If no file exists, the UID will be updated but the path
 // remains the same.
 func (fs *FileSystem) AddSiaFileFromReader(rs io.ReadSeeker, siaPath modules.SiaPath) (err error) {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	// Load the file.
 	path := fs.FilePath(siaPath)
 	sf, chunks, err := siafile.LoadSiaFileFromReaderWithChunks(rs, path, fs.staticWal)
@@ -184,9 +279,20 @@ func (fs *FileSystem) AddSiaFileFromReader(rs io.ReadSeeker, siaPath modules.Sia
 	if err != nil {
 		return err
 	}
+	unlock, err := fs.managedLockWritable(dirSiaPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if err := fs.managedNewSiaDir(dirSiaPath, sf.Mode()); err != nil {
 		return err
 	}
+	// Make sure the file fits within the quota.
+	releaseQuota, err := fs.managedReserveQuota(dirSiaPath, sf.Size())
+	if err != nil {
+		return err
+	}
+	defer releaseQuota()
 	dir, err := fs.managedOpenDir(dirSiaPath.String())
 	if err != nil {
 		return err
@@ -195,22 +301,78 @@ func (fs *FileSystem) AddSiaFileFromReader(rs io.ReadSeeker, siaPath modules.Sia
 		err = errors.Compose(err, dir.Close())
 	}()
 	// Add the file to the dir.
-	return dir.managedNewSiaFileFromExisting(sf, chunks)
+	created, err := dir.managedNewSiaFileFromExisting(sf, chunks)
+	if err != nil || !created {
+		return err
+	}
+	if err := dir.managedAdvanceAggregateModTime(sf.ModTime()); err != nil {
+		return err
+	}
+	var newSiaPath modules.SiaPath
+	if err := newSiaPath.FromSysPath(sf.SiaFilePath(), fs.managedAbsPath()); err != nil {
+		return err
+	}
+	fs.logEvent(EventCreateFile, modules.SiaPath{}, newSiaPath, 0)
+	return nil
+}
+
+// ReplaceSiaFileMetadata atomically replaces the metadata and chunks of the
+// file at siaPath with those of the SiaFile read from rs. Concurrent opens of
+// the file will either see the old or the new file but never a partially
+// replaced one.
+func (fs *FileSystem) ReplaceSiaFileMetadata(siaPath modules.SiaPath, rs io.ReadSeeker) (err error) {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	dirSiaPath, err := siaPath.Dir()
+	if err != nil {
+		return err
+	}
+	unlock, err := fs.managedLockWritable(dirSiaPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	dir, err := fs.managedOpenSiaDir(dirSiaPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Compose(err, dir.Close())
+	}()
+	modTime, err := dir.managedReplaceSiaFile(siaPath.Name(), rs)
+	if err != nil {
+		return err
+	}
+	return dir.managedAdvanceAggregateModTime(modTime)
 }
 
 // CachedFileInfo returns the cached File Information of the siafile
 func (fs *FileSystem) CachedFileInfo(siaPath modules.SiaPath) (modules.FileInfo, error) {
+	if err := fs.tg.Add(); err != nil {
+		return modules.FileInfo{}, ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	return fs.managedFileInfo(siaPath, true, nil, nil, nil)
 }
 
 // CachedList lists the files and directories within a SiaDir.
 func (fs *FileSystem) CachedList(siaPath modules.SiaPath, recursive bool, flf modules.FileListFunc, dlf modules.DirListFunc) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	return fs.managedList(siaPath, recursive, true, nil, nil, nil, flf, dlf)
 }
 
 // CachedListOnNode will return the files and directories within a given siadir
 // node in a non-recursive way.
 func (fs *FileSystem) CachedListOnNode(d *DirNode) (fis []modules.FileInfo, dis []modules.DirectoryInfo, err error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	var fmu, dmu sync.Mutex
 	flf := func(fi modules.FileInfo) {
 		fmu.Lock()
@@ -234,6 +396,50 @@ func (fs *FileSystem) CachedListOnNode(d *DirNode) (fis []modules.FileInfo, dis
 	return
 }
 
+// CommonAncestor returns the deepest directory which contains all of the
+// provided paths. The root is returned if the paths don't share any
+// directories. This can be used to lock the smallest subtree which covers all
+// targets of a bulk operation.
+func (fs *FileSystem) CommonAncestor(paths []modules.SiaPath) (modules.SiaPath, error) {
+	if len(paths) == 0 {
+		return modules.SiaPath{}, errNoSiaPaths
+	}
+	// Find the longest common prefix of the paths' components.
+	prefix := strings.Split(paths[0].String(), "/")
+	for _, sp := range paths[1:] {
+		components := strings.Split(sp.String(), "/")
+		n := 0
+		for n < len(prefix) && n < len(components) && prefix[n] == components[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	if len(prefix) == 0 || prefix[0] == "" {
+		return modules.RootSiaPath(), nil
+	}
+	ancestor, err := modules.NewSiaPath(strings.Join(prefix, "/"))
+	if err != nil {
+		return modules.SiaPath{}, err
+	}
+
+	// If the prefix is one of the paths, it might be a file. In that case the
+	// ancestor is the file's directory.
+	for _, sp := range paths {
+		if !sp.Equals(ancestor) {
+			continue
+		}
+		exists, err := fs.managedDirExists(ancestor)
+		if err != nil {
+			return modules.SiaPath{}, err
+		}
+		if !exists {
+			return ancestor.Dir()
+		}
+		break
+	}
+	return ancestor, nil
+}
+
 // DeleteDir deletes a dir from the filesystem. The dir will be marked as
 // 'deleted' which should cause all remaining instances of the dir to be close
 // shortly. Only when all instances of the dir are closed it will be removed
@@ -241,7 +447,27 @@ func (fs *FileSystem) CachedListOnNode(d *DirNode) (fis []modules.FileInfo, dis
 // file of the same path can be created and the existing file can't be opened
 // until all instances of it are closed.
 func (fs *FileSystem) DeleteDir(siaPath modules.SiaPath) error {
-	return fs.managedDeleteDir(siaPath.String())
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	unlock, err := fs.managedLockWritable(siaPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fs.managedDeleteSiaDir(siaPath)
+}
+
+// managedDeleteSiaDir is DeleteDir without the threadgroup guard and the
+// read-only check. It is used by the FileSystem's methods which already hold
+// both.
+func (fs *FileSystem) managedDeleteSiaDir(siaPath modules.SiaPath) error {
+	if err := fs.managedDeleteDir(siaPath); err != nil {
+		return err
+	}
+	fs.staticReadOnlyFlags.managedDrop(siaPath)
+	return nil
 }
 
 // DeleteFile deletes a file from the filesystem. The file will be marked as
@@ -251,11 +477,108 @@ func (fs *FileSystem) DeleteDir(siaPath modules.SiaPath) error {
 // file of the same path can be created and the existing file can't be opened
 // until all instances of it are closed.
 func (fs *FileSystem) DeleteFile(siaPath modules.SiaPath) error {
-	return fs.managedDeleteFile(siaPath.String())
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	unlock, err := fs.managedLockWritableParents(siaPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fs.managedDeleteSiaFile(siaPath)
+}
+
+// managedDeleteSiaFile is DeleteFile without the threadgroup guard and the
+// read-only check. It is used by the FileSystem's methods which already hold
+// both.
+func (fs *FileSystem) managedDeleteSiaFile(siaPath modules.SiaPath) error {
+	err := fs.managedDeleteFile(siaPath.String())
+	if err != nil {
+		return err
+	}
+	fs.logEvent(EventDeleteFile, siaPath, modules.SiaPath{}, 0)
+	return nil
+}
+
+// DeleteFiles deletes the files at the provided paths. The files are grouped by
+// their dir and every dir is only opened once. Files which are currently open
+// are not deleted and fail with ErrFileInUse. A failure doesn't stop the
+// remaining deletions. The paths of the deleted files are returned grouped by
+// dir and the paths of the files which couldn't be deleted are returned
+// together with the reason.
+func (fs *FileSystem) DeleteFiles(siaPaths []modules.SiaPath) (deleted []modules.SiaPath, failed map[modules.SiaPath]error) {
+	if err := fs.tg.Add(); err != nil {
+		failed = make(map[modules.SiaPath]error, len(siaPaths))
+		for _, sp := range siaPaths {
+			failed[sp] = ErrShuttingDown
+		}
+		return nil, failed
+	}
+	defer fs.tg.Done()
+	failed = make(map[modules.SiaPath]error)
+
+	// Group the paths by their dir. The dirs keep the order in which they
+	// appear.
+	var dirs []modules.SiaPath
+	groups := make(map[modules.SiaPath][]modules.SiaPath)
+	for _, siaPath := range siaPaths {
+		dirSiaPath, err := siaPath.Dir()
+		if err != nil {
+			failed[siaPath] = err
+			continue
+		}
+		if _, exists := groups[dirSiaPath]; !exists {
+			dirs = append(dirs, dirSiaPath)
+		}
+		groups[dirSiaPath] = append(groups[dirSiaPath], siaPath)
+	}
+
+	// Lock the read-only flags for the whole deletion and check every dir.
+	unlock, _ := fs.managedLockWritable()
+	defer unlock()
+	for _, dirSiaPath := range dirs {
+		err := fs.managedCheckWritable(dirSiaPath)
+		if err != nil {
+			for _, siaPath := range groups[dirSiaPath] {
+				failed[siaPath] = err
+			}
+			continue
+		}
+		dir, err := fs.managedOpenSiaDir(dirSiaPath)
+		if err != nil {
+			for _, siaPath := range groups[dirSiaPath] {
+				failed[siaPath] = errors.AddContext(err, "failed to open parent dir of file")
+			}
+			continue
+		}
+		for _, siaPath := range groups[dirSiaPath] {
+			if err := dir.managedDeleteClosedFile(siaPath.Name()); err != nil {
+				failed[siaPath] = err
+				continue
+			}
+			fs.logEvent(EventDeleteFile, siaPath, modules.SiaPath{}, 0)
+			deleted = append(deleted, siaPath)
+		}
+		if err := dir.Close(); err != nil {
+			fs.staticLog.Printf("WARN: failed to close dir '%v' after deleting files: %v", dirSiaPath, err)
+		}
+	}
+	return deleted, failed
 }
 
 // DirInfo returns the Directory Information of the siadir
 func (fs *FileSystem) DirInfo(siaPath modules.SiaPath) (_ modules.DirectoryInfo, err error) {
+	if err := fs.tg.Add(); err != nil {
+		return modules.DirectoryInfo{}, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedDirInfo(siaPath)
+}
+
+// managedDirInfo is DirInfo without the threadgroup guard. It is used by the
+// FileSystem's methods which are already tracked by the threadgroup.
+func (fs *FileSystem) managedDirInfo(siaPath modules.SiaPath) (_ modules.DirectoryInfo, err error) {
 	dir, err := fs.managedOpenDir(siaPath.String())
 	if err != nil {
 		return modules.DirectoryInfo{}, nil
@@ -274,30 +597,56 @@ func (fs *FileSystem) DirInfo(siaPath modules.SiaPath) (_ modules.DirectoryInfo,
 // DirNodeInfo will return the DirectoryInfo of a siadir given the node. This is
 // more efficient than calling fs.DirInfo.
 func (fs *FileSystem) DirNodeInfo(n *DirNode) (modules.DirectoryInfo, error) {
+	if err := fs.tg.Add(); err != nil {
+		return modules.DirectoryInfo{}, ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	sp := fs.DirSiaPath(n)
 	return n.managedInfo(sp)
 }
 
 // FileInfo returns the File Information of the siafile
 func (fs *FileSystem) FileInfo(siaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool, contracts map[string]modules.RenterContract) (modules.FileInfo, error) {
+	if err := fs.tg.Add(); err != nil {
+		return modules.FileInfo{}, ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	return fs.managedFileInfo(siaPath, false, offline, goodForRenew, contracts)
 }
 
 // FileNodeInfo returns the FileInfo of a siafile given the node for the
 // siafile. This is faster than calling fs.FileInfo.
 func (fs *FileSystem) FileNodeInfo(n *FileNode) (modules.FileInfo, error) {
+	if err := fs.tg.Add(); err != nil {
+		return modules.FileInfo{}, ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	sp := fs.FileSiaPath(n)
 	return n.staticCachedInfo(sp)
 }
 
 // List lists the files and directories within a SiaDir.
 func (fs *FileSystem) List(siaPath modules.SiaPath, recursive bool, offlineMap, goodForRenewMap map[string]bool, contractsMap map[string]modules.RenterContract, flf modules.FileListFunc, dlf modules.DirListFunc) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	return fs.managedList(siaPath, recursive, false, offlineMap, goodForRenewMap, contractsMap, flf, dlf)
 }
 
 // FileExists checks to see if a file with the provided siaPath already exists
 // in the renter.
 func (fs *FileSystem) FileExists(siaPath modules.SiaPath) (bool, error) {
+	if err := fs.tg.Add(); err != nil {
+		return false, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedFileExists(siaPath)
+}
+
+// managedFileExists is FileExists without the threadgroup guard. It is used by
+// the FileSystem's methods which are already tracked by the threadgroup.
+func (fs *FileSystem) managedFileExists(siaPath modules.SiaPath) (bool, error) {
 	path := fs.FilePath(siaPath)
 	_, err := os.Stat(path)
 	if os.IsNotExist(err) {
@@ -311,26 +660,97 @@ func (fs *FileSystem) FilePath(siaPath modules.SiaPath) string {
 	return siaPath.SiaFileSysPath(fs.managedAbsPath())
 }
 
-// NewSiaDir creates the folder for the specified siaPath.
+// NewSiaDir creates the folder for the specified siaPath and its missing
+// parents. Creating a folder which exists already is a no-op. If the folder is
+// concurrently being created by another thread, NewSiaDir waits for the other
+// thread to finish the creation.
 func (fs *FileSystem) NewSiaDir(siaPath modules.SiaPath, mode os.FileMode) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	if err := fs.ValidatePath(siaPath); err != nil {
+		return err
+	}
+	unlock, err := fs.managedLockWritable(siaPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	return fs.managedNewSiaDir(siaPath, mode)
 }
 
+// ValidatePath checks whether a SiaDir could be created at siaPath without
+// touching the disk. It returns the same errors NewSiaDir would return for an
+// invalid path.
+func (fs *FileSystem) ValidatePath(siaPath modules.SiaPath) error {
+	if err := siaPath.Validate(siaPath.IsRoot()); err != nil {
+		return err
+	}
+	if !siaPath.IsRoot() {
+		for _, name := range strings.Split(siaPath.Path, "/") {
+			if name == modules.SiaDirExtension {
+				return errors.AddContext(ErrReservedName, name)
+			}
+			if len(name) > maxNameLength {
+				return errors.AddContext(ErrPathTooLong, fmt.Sprintf("element of length %v exceeds %v", len(name), maxNameLength))
+			}
+		}
+	}
+	// The longest path created for a SiaDir is the temporary file used when
+	// saving its metadata.
+	mdPath := siaPath.SiaDirSysPath(fs.managedAbsPath()) + "_temp"
+	if len(mdPath) >= maxPathLength {
+		return errors.AddContext(ErrPathTooLong, fmt.Sprintf("path of length %v exceeds %v", len(mdPath), maxPathLength-1))
+	}
+	return nil
+}
+
 // NewSiaFile creates a SiaFile at the specified siaPath.
 func (fs *FileSystem) NewSiaFile(siaPath modules.SiaPath, source string, ec modules.ErasureCoder, mk crypto.CipherKey, fileSize uint64, fileMode os.FileMode, disablePartialUpload bool) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	// Create SiaDir for file.
 	dirSiaPath, err := siaPath.Dir()
 	if err != nil {
 		return err
 	}
-	if err = fs.NewSiaDir(dirSiaPath, fileMode); err != nil {
+	unlock, err := fs.managedLockWritable(dirSiaPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = fs.managedNewSiaDir(dirSiaPath, fileMode); err != nil {
 		return errors.AddContext(err, fmt.Sprintf("failed to create SiaDir %v for SiaFile %v", dirSiaPath.String(), siaPath.String()))
 	}
-	return fs.managedNewSiaFile(siaPath.String(), source, ec, mk, fileSize, fileMode, disablePartialUpload)
+	// Make sure the file fits within the quota.
+	releaseQuota, err := fs.managedReserveQuota(dirSiaPath, fileSize)
+	if err != nil {
+		return err
+	}
+	defer releaseQuota()
+	err = fs.managedNewSiaFile(siaPath.String(), source, ec, mk, fileSize, fileMode, disablePartialUpload)
+	if err != nil {
+		return err
+	}
+	fs.logEvent(EventCreateFile, modules.SiaPath{}, siaPath, 0)
+	return nil
 }
 
 // ReadDir reads all the fileinfos of the specified dir.
 func (fs *FileSystem) ReadDir(siaPath modules.SiaPath) ([]os.FileInfo, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedReadDir(siaPath)
+}
+
+// managedReadDir is ReadDir without the threadgroup guard. It is used by the
+// FileSystem's methods which are already tracked by the threadgroup.
+func (fs *FileSystem) managedReadDir(siaPath modules.SiaPath) ([]os.FileInfo, error) {
 	// Open dir.
 	dirPath := siaPath.SiaDirSysPath(fs.managedAbsPath())
 	f, err := os.Open(dirPath)
@@ -347,6 +767,16 @@ func (fs *FileSystem) ReadDir(siaPath modules.SiaPath) ([]os.FileInfo, error) {
 // DirExists checks to see if a dir with the provided siaPath already exists in
 // the renter.
 func (fs *FileSystem) DirExists(siaPath modules.SiaPath) (bool, error) {
+	if err := fs.tg.Add(); err != nil {
+		return false, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedDirExists(siaPath)
+}
+
+// managedDirExists is DirExists without the threadgroup guard. It is used by
+// the FileSystem's methods which are already tracked by the threadgroup.
+func (fs *FileSystem) managedDirExists(siaPath modules.SiaPath) (bool, error) {
 	path := fs.DirPath(siaPath)
 	_, err := os.Stat(path)
 	if os.IsNotExist(err) {
@@ -355,6 +785,70 @@ func (fs *FileSystem) DirExists(siaPath modules.SiaPath) (bool, error) {
 	return true, err
 }
 
+// BatchStat returns the cached infos of the files and dirs at the provided
+// paths. Paths within the same dir are resolved while holding the dir's lock
+// only once. The returned errors are in the same order as the paths and nil
+// for the paths which were resolved successfully.
+func (fs *FileSystem) BatchStat(siaPaths []modules.SiaPath) (map[modules.SiaPath]modules.FileSystemInfo, []error) {
+	errs := make([]error, len(siaPaths))
+	if err := fs.tg.Add(); err != nil {
+		for i := range errs {
+			errs[i] = ErrShuttingDown
+		}
+		return nil, errs
+	}
+	defer fs.tg.Done()
+	infos := make(map[modules.SiaPath]modules.FileSystemInfo)
+
+	// Group the paths by their parent dir. The root doesn't have a parent.
+	groups := make(map[modules.SiaPath][]int)
+	for i, siaPath := range siaPaths {
+		if siaPath.IsRoot() {
+			di, err := fs.managedDirInfo(siaPath)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			infos[siaPath] = modules.FileSystemInfo{IsDir: true, Dir: &di}
+			continue
+		}
+		dirSiaPath, err := siaPath.Dir()
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		groups[dirSiaPath] = append(groups[dirSiaPath], i)
+	}
+	for dirSiaPath, indices := range groups {
+		fs.managedBatchStat(dirSiaPath, siaPaths, indices, infos, errs)
+	}
+	return infos, errs
+}
+
+// DeepestExisting returns the longest prefix of siaPath which is an existing
+// dir. Only the dirs on disk are checked, none of the nodes are opened. If none
+// of the dirs exist, the root is returned.
+func (fs *FileSystem) DeepestExisting(siaPath modules.SiaPath) (modules.SiaPath, error) {
+	if err := fs.tg.Add(); err != nil {
+		return modules.SiaPath{}, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	for !siaPath.IsRoot() {
+		fi, err := os.Stat(fs.DirPath(siaPath))
+		if err == nil && fi.IsDir() {
+			return siaPath, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return modules.SiaPath{}, err
+		}
+		siaPath, err = siaPath.Dir()
+		if err != nil {
+			return modules.SiaPath{}, err
+		}
+	}
+	return modules.RootSiaPath(), nil
+}
+
 // DirPath converts a SiaPath into a dir's system path.
 func (fs *FileSystem) DirPath(siaPath modules.SiaPath) string {
 	return siaPath.SiaDirSysPath(fs.managedAbsPath())
@@ -375,16 +869,39 @@ func (fs *FileSystem) DirSiaPath(n *DirNode) (sp modules.SiaPath) {
 	return fs.managedSiaPath(&n.node)
 }
 
-// UpdateDirMetadata updates the metadata of a SiaDir.
-func (fs *FileSystem) UpdateDirMetadata(siaPath modules.SiaPath, metadata siadir.Metadata) (err error) {
-	dir, err := fs.OpenSiaDir(siaPath)
+// GetFileMetadata returns the value of the custom metadata with the given key
+// of the file at siaPath.
+func (fs *FileSystem) GetFileMetadata(siaPath modules.SiaPath, key string) (_ string, _ bool, err error) {
+	if err := fs.tg.Add(); err != nil {
+		return "", false, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	sf, err := fs.managedOpenSiaFile(siaPath)
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		err = errors.Compose(err, sf.Close())
+	}()
+	value, exists := sf.CustomMetadata(key)
+	return value, exists, nil
+}
+
+// SetFileMetadata sets the custom metadata with the given key of the file at
+// siaPath to value. An empty value removes the key.
+func (fs *FileSystem) SetFileMetadata(siaPath modules.SiaPath, key, value string) (err error) {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	sf, err := fs.managedOpenSiaFile(siaPath)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		err = errors.Compose(err, dir.Close())
+		err = errors.Compose(err, sf.Close())
 	}()
-	return dir.UpdateMetadata(metadata)
+	return sf.SetCustomMetadata(key, value)
 }
 
 // managedSiaPath returns the SiaPath of a node.
@@ -393,15 +910,56 @@ func (fs *FileSystem) managedSiaPath(n *node) modules.SiaPath {
 }
 
 // Stat is a wrapper for os.Stat which takes a SiaPath as an argument instead of
-// a system path.
+// a system path. The ModTime of a dir is its AggregateModTime. If nothing
+// exists at the path, aliases within the path are followed.
 func (fs *FileSystem) Stat(siaPath modules.SiaPath) (os.FileInfo, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	path := siaPath.SiaDirSysPath(fs.managedAbsPath())
-	return os.Stat(path)
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		resolved, isAlias, resolveErr := fs.managedResolveAlias(siaPath, fs.managedReadAlias)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		if isAlias {
+			siaPath = resolved
+			fi, err = os.Stat(siaPath.SiaDirSysPath(fs.managedAbsPath()))
+		}
+	}
+	if err != nil || !fi.IsDir() {
+		return fi, err
+	}
+	md, err := fs.managedDirMetadata(siaPath)
+	if errors.Contains(err, ErrNotExist) {
+		return fi, nil // dir without metadata
+	} else if err != nil {
+		return nil, err
+	}
+	return dirFileInfo{FileInfo: fi, modTime: md.AggregateModTime}, nil
+}
+
+// managedDirMetadata returns the metadata of the dir at siaPath.
+func (fs *FileSystem) managedDirMetadata(siaPath modules.SiaPath) (_ siadir.Metadata, err error) {
+	dir, err := fs.managedOpenSiaDirCustom(siaPath, false)
+	if err != nil {
+		return siadir.Metadata{}, err
+	}
+	defer func() {
+		err = errors.Compose(err, dir.Close())
+	}()
+	return dir.Metadata()
 }
 
 // Walk is a wrapper for filepath.Walk which takes a SiaPath as an argument
 // instead of a system path.
 func (fs *FileSystem) Walk(siaPath modules.SiaPath, walkFn filepath.WalkFunc) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	dirPath := siaPath.SiaDirSysPath(fs.managedAbsPath())
 	return filepath.Walk(dirPath, walkFn)
 }
@@ -409,6 +967,10 @@ func (fs *FileSystem) Walk(siaPath modules.SiaPath, walkFn filepath.WalkFunc) er
 // WriteFile is a wrapper for ioutil.WriteFile which takes a SiaPath as an
 // argument instead of a system path.
 func (fs *FileSystem) WriteFile(siaPath modules.SiaPath, data []byte, perm os.FileMode) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	path := siaPath.SiaFileSysPath(fs.managedAbsPath())
 	return ioutil.WriteFile(path, data, perm)
 }
@@ -416,6 +978,10 @@ func (fs *FileSystem) WriteFile(siaPath modules.SiaPath, data []byte, perm os.Fi
 // NewSiaFileFromLegacyData creates a new SiaFile from data that was previously loaded
 // from a legacy file.
 func (fs *FileSystem) NewSiaFileFromLegacyData(fd siafile.FileData) (_ *FileNode, err error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	// Get file's SiaPath.
 	sp, err := modules.UserFolder.Join(fd.Name)
 	if err != nil {
@@ -426,8 +992,13 @@ func (fs *FileSystem) NewSiaFileFromLegacyData(fd siafile.FileData) (_ *FileNode
 	if err != nil {
 		return nil, err
 	}
+	unlock, err := fs.managedLockWritable(dirSiaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	// Create the dir if it doesn't exist.
-	if err := fs.NewSiaDir(dirSiaPath, 0755); err != nil {
+	if err := fs.managedNewSiaDir(dirSiaPath, 0755); err != nil {
 		return nil, err
 	}
 	// Open dir.
@@ -439,23 +1010,100 @@ func (fs *FileSystem) NewSiaFileFromLegacyData(fd siafile.FileData) (_ *FileNode
 		err = errors.Compose(err, dir.Close())
 	}()
 	// Add the file to the dir.
-	return dir.managedNewSiaFileFromLegacyData(sp.Name(), fd)
+	fn, err := dir.managedNewSiaFileFromLegacyData(sp.Name(), fd)
+	if err != nil {
+		return nil, err
+	}
+	if err := dir.managedAdvanceAggregateModTime(fn.ModTime()); err != nil {
+		return nil, errors.Compose(err, fn.Close())
+	}
+	fs.logEvent(EventCreateFile, modules.SiaPath{}, fs.FileSiaPath(fn), 0)
+	return fn, nil
+}
+
+// Close shuts down the FileSystem. It stops accepting new operations, which
+// return ErrShuttingDown afterwards, waits for the in-flight ones to finish,
+// stops the metadata scanner, flushes the metadata and releases the nodes
+// loaded into memory. Nodes which are still held by callers remain usable
+// until they are closed.
+func (fs *FileSystem) Close() error {
+	err := fs.tg.Stop()
+	fs.StopMetadataScanner()
+	err = errors.Compose(err, fs.staticSyncer.managedClose())
+
+	// Release the tree.
+	fs.mu.Lock()
+	fs.directories = make(map[string]*DirNode)
+	fs.files = make(map[string]*FileNode)
+	fs.mu.Unlock()
+	return errors.AddContext(err, "failed to close filesystem")
+}
+
+// OpenDirectories returns the SiaPaths of all the dirs which are currently
+// loaded into memory, sorted by path. The root dir is not included. The tree is
+// only inspected and no dirs are opened. Only a single node is locked at a time
+// which means that the result might be slightly outdated if dirs are opened
+// or closed concurrently.
+func (fs *FileSystem) OpenDirectories() []modules.SiaPath {
+	var siaPaths []modules.SiaPath
+	dirs := []*DirNode{&fs.DirNode}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		dir.mu.Lock()
+		for _, subDir := range dir.directories {
+			dirs = append(dirs, subDir)
+		}
+		dir.mu.Unlock()
+		if dir != &fs.DirNode {
+			siaPaths = append(siaPaths, fs.managedSiaPath(&dir.node))
+		}
+	}
+	sort.Slice(siaPaths, func(i, j int) bool {
+		return siaPaths[i].String() < siaPaths[j].String()
+	})
+	return siaPaths
 }
 
 // OpenSiaDir opens a SiaDir and adds it and all of its parents to the
-// filesystem tree.
+// filesystem tree. If the dir doesn't exist but its path contains an alias,
+// the dir within the alias's target is opened instead.
 func (fs *FileSystem) OpenSiaDir(siaPath modules.SiaPath) (*DirNode, error) {
-	return fs.OpenSiaDirCustom(siaPath, false)
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedOpenSiaDirCustom(siaPath, false)
 }
 
 // OpenSiaDirCustom opens a SiaDir and adds it and all of its parents to the
 // filesystem tree. If create is true it will create the dir if it doesn't
 // exist.
 func (fs *FileSystem) OpenSiaDirCustom(siaPath modules.SiaPath, create bool) (*DirNode, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedOpenSiaDirCustom(siaPath, create)
+}
+
+// managedOpenSiaDirCustom is OpenSiaDirCustom without the threadgroup guard. It
+// is used by the FileSystem's methods which are already tracked by the
+// threadgroup.
+func (fs *FileSystem) managedOpenSiaDirCustom(siaPath modules.SiaPath, create bool) (*DirNode, error) {
 	dn, err := fs.managedOpenSiaDir(siaPath)
+	if errors.Contains(err, ErrNotExist) {
+		// Check if the path is an alias.
+		dn, err = fs.managedOpenAliasDir(siaPath)
+	}
 	if create && errors.Contains(err, ErrNotExist) {
 		// If siadir doesn't exist create one
-		err = fs.NewSiaDir(siaPath, modules.DefaultDirPerm)
+		unlock, err := fs.managedLockWritable(siaPath)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		err = fs.managedNewSiaDir(siaPath, modules.DefaultDirPerm)
 		if err != nil && !errors.Contains(err, ErrExists) {
 			return nil, err
 		}
@@ -465,69 +1113,225 @@ func (fs *FileSystem) OpenSiaDirCustom(siaPath modules.SiaPath, create bool) (*D
 }
 
 // OpenSiaFile opens a SiaFile and adds it and all of its parents to the
-// filesystem tree.
+// filesystem tree. If the file doesn't exist but its path contains an alias,
+// the file within the alias's target is opened instead.
 func (fs *FileSystem) OpenSiaFile(siaPath modules.SiaPath) (*FileNode, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedOpenSiaFile(siaPath)
+}
+
+// managedOpenSiaFile is OpenSiaFile without the threadgroup guard. It is used
+// by the FileSystem's methods which are already tracked by the threadgroup.
+func (fs *FileSystem) managedOpenSiaFile(siaPath modules.SiaPath) (*FileNode, error) {
 	sf, err := fs.managedOpenFile(siaPath.String())
+	if errors.Contains(err, ErrNotExist) {
+		// Check if the path is an alias.
+		sf, err = fs.managedOpenAliasFile(siaPath)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return sf, nil
 }
 
+// OpenSiaFiles opens the files at the provided siaPaths. In contrast to calling
+// OpenSiaFile for every file, the files are grouped by their parent dir and
+// every parent dir is opened and locked only once. Like OpenSiaFile, files
+// which don't exist are looked up within the target of an alias on their path.
+// The returned function closes all of the files. If opening any of the files fails, the files which
+// were opened already are closed before returning the error.
+func (fs *FileSystem) OpenSiaFiles(siaPaths []modules.SiaPath) (_ []*FileNode, _ func(), err error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+
+	files := make([]*FileNode, len(siaPaths))
+	closeFiles := func() {
+		for i, sf := range files {
+			if sf == nil {
+				continue
+			}
+			if err := sf.Close(); err != nil {
+				fs.staticLog.Printf("WARN: failed to close file '%v': %v", siaPaths[i], err)
+			}
+		}
+	}
+	defer func() {
+		if err != nil {
+			closeFiles()
+		}
+	}()
+
+	// Group the files by their parent dir.
+	var dirPaths []string
+	dirFiles := make(map[string][]int)
+	for i, siaPath := range siaPaths {
+		dirPath, _ := filepath.Split(siaPath.String())
+		if _, exists := dirFiles[dirPath]; !exists {
+			dirPaths = append(dirPaths, dirPath)
+		}
+		dirFiles[dirPath] = append(dirFiles[dirPath], i)
+	}
+
+	// Open the files of every dir.
+	for _, dirPath := range dirPaths {
+		indices := dirFiles[dirPath]
+		fileNames := make([]string, len(indices))
+		for i, idx := range indices {
+			_, fileNames[i] = filepath.Split(siaPaths[idx].String())
+		}
+		nodes, err := fs.managedOpenFiles(dirPath, fileNames)
+		for i, sf := range nodes {
+			files[indices[i]] = sf
+		}
+		// If the dir doesn't exist, its path might contain an alias. The
+		// files are opened through the alias below.
+		if errors.Contains(err, ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Check if the files which don't exist are aliases.
+	for i, sf := range files {
+		if sf != nil {
+			continue
+		}
+		files[i], err = fs.managedOpenAliasFile(siaPaths[i])
+		if err != nil {
+			return nil, nil, errors.AddContext(err, fmt.Sprintf("failed to open file '%v'", siaPaths[i]))
+		}
+	}
+	return files, closeFiles, nil
+}
+
 // RenameFile renames the file with oldSiaPath to newSiaPath.
-func (fs *FileSystem) RenameFile(oldSiaPath, newSiaPath modules.SiaPath) (err error) {
+func (fs *FileSystem) RenameFile(oldSiaPath, newSiaPath modules.SiaPath) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	unlock, err := fs.managedLockWritableParents(oldSiaPath, newSiaPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fs.managedRenameSiaFile(oldSiaPath, newSiaPath)
+}
+
+// managedRenameSiaFile is RenameFile without the threadgroup guard and the
+// read-only check. It is used by the FileSystem's methods which already hold
+// both.
+func (fs *FileSystem) managedRenameSiaFile(oldSiaPath, newSiaPath modules.SiaPath) error {
+	sf, err := fs.managedRenameFile(oldSiaPath, newSiaPath)
+	if err != nil {
+		return err
+	}
+	return sf.Close()
+}
+
+// RenameSiaFileTx renames the file with oldSiaPath to newSiaPath and returns
+// the file opened at its new location. The file stays open during the rename
+// which means that the caller doesn't need to open it again and race with
+// other renames. The caller is responsible for closing the returned node.
+func (fs *FileSystem) RenameSiaFileTx(oldSiaPath, newSiaPath modules.SiaPath) (*FileNode, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	unlock, err := fs.managedLockWritableParents(oldSiaPath, newSiaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return fs.managedRenameFile(oldSiaPath, newSiaPath)
+}
+
+// managedRenameFile renames the file with oldSiaPath to newSiaPath and returns
+// the open file. If the rename fails, the file is closed.
+func (fs *FileSystem) managedRenameFile(oldSiaPath, newSiaPath modules.SiaPath) (_ *FileNode, err error) {
+	var sf *FileNode
+	defer func() {
+		if err != nil && sf != nil {
+			err = errors.Compose(err, sf.Close())
+		}
+	}()
 	// Open SiaDir for file at old location.
 	oldDirSiaPath, err := oldSiaPath.Dir()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	newDirSiaPath, err := newSiaPath.Dir()
+	if err != nil {
+		return nil, err
 	}
 	oldDir, err := fs.managedOpenSiaDir(oldDirSiaPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		err = errors.Compose(err, oldDir.Close())
 	}()
 	// Open the file.
-	sf, err := oldDir.managedOpenFile(oldSiaPath.Name())
+	sf, err = oldDir.managedOpenFile(oldSiaPath.Name())
 	if errors.Contains(err, ErrNotExist) {
-		return ErrNotExist
+		return nil, ErrNotExist
 	}
 	if err != nil {
-		return errors.AddContext(err, "failed to open file for renaming")
+		return nil, errors.AddContext(err, "failed to open file for renaming")
 	}
-	defer func() {
-		err = errors.Compose(err, sf.Close())
-	}()
 
 	// Create and Open SiaDir for file at new location.
-	newDirSiaPath, err := newSiaPath.Dir()
-	if err != nil {
-		return err
-	}
-	if err := fs.NewSiaDir(newDirSiaPath, sf.managedMode()); err != nil {
-		return errors.AddContext(err, fmt.Sprintf("failed to create SiaDir %v for SiaFile %v", newDirSiaPath.String(), oldSiaPath.String()))
+	if err := fs.managedNewSiaDir(newDirSiaPath, sf.managedMode()); err != nil {
+		return nil, errors.AddContext(err, fmt.Sprintf("failed to create SiaDir %v for SiaFile %v", newDirSiaPath.String(), oldSiaPath.String()))
 	}
 	newDir, err := fs.managedOpenSiaDir(newDirSiaPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		err = errors.Compose(err, newDir.Close())
 	}()
 	// Rename the file.
-	return sf.managedRename(newSiaPath.Name(), oldDir, newDir)
+	err = sf.managedRename(newSiaPath.Name(), oldDir, newDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := newDir.managedAdvanceAggregateModTime(sf.ModTime()); err != nil {
+		return nil, err
+	}
+	fs.logEvent(EventRenameFile, oldSiaPath, newSiaPath, sf.threadUID)
+	return sf, nil
 }
 
 // RenameDir takes an existing directory and changes the path. The original
 // directory must exist, and there must not be any directory that already has
 // the replacement path.  All sia files within directory will also be renamed
 func (fs *FileSystem) RenameDir(oldSiaPath, newSiaPath modules.SiaPath) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
 	// Open SiaDir for parent dir at old location.
 	oldDirSiaPath, err := oldSiaPath.Dir()
 	if err != nil {
 		return err
 	}
+	newDirSiaPath, err := newSiaPath.Dir()
+	if err != nil {
+		return err
+	}
+	unlock, err := fs.managedLockWritable(oldSiaPath, newDirSiaPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	oldDir, err := fs.managedOpenSiaDir(oldDirSiaPath)
 	if err != nil {
 		return err
@@ -548,15 +1352,11 @@ func (fs *FileSystem) RenameDir(oldSiaPath, newSiaPath modules.SiaPath) error {
 	}()
 
 	// Create and Open parent SiaDir for dir at new location.
-	newDirSiaPath, err := newSiaPath.Dir()
-	if err != nil {
-		return err
-	}
 	md, err := sd.Metadata()
 	if err != nil {
 		return err
 	}
-	if err := fs.NewSiaDir(newDirSiaPath, md.Mode); err != nil {
+	if err := fs.managedNewSiaDir(newDirSiaPath, md.Mode); err != nil {
 		return errors.AddContext(err, fmt.Sprintf("failed to create SiaDir %v for SiaFile %v", newDirSiaPath.String(), oldSiaPath.String()))
 	}
 	newDir, err := fs.managedOpenSiaDir(newDirSiaPath)
@@ -568,7 +1368,15 @@ func (fs *FileSystem) RenameDir(oldSiaPath, newSiaPath modules.SiaPath) error {
 	}()
 	// Rename the dir.
 	err = sd.managedRename(newSiaPath.Name(), oldDir, newDir)
-	return err
+	if err != nil {
+		return err
+	}
+	if err := newDir.managedAdvanceAggregateModTime(md.AggregateModTime); err != nil {
+		return err
+	}
+	fs.staticReadOnlyFlags.managedDrop(oldSiaPath, newSiaPath)
+	fs.logEvent(EventRenameDir, oldSiaPath, newSiaPath, sd.threadUID)
+	return nil
 }
 
 // managedDeleteFile opens the parent folder of the file to delete and calls
@@ -596,9 +1404,9 @@ func (fs *FileSystem) managedDeleteFile(relPath string) (err error) {
 
 // managedDeleteDir opens the parent folder of the dir to delete and calls
 // managedDelete on it.
-func (fs *FileSystem) managedDeleteDir(path string) (err error) {
+func (fs *FileSystem) managedDeleteDir(siaPath modules.SiaPath) (err error) {
 	// Open the dir.
-	dir, err := fs.managedOpenDir(path)
+	dir, err := fs.managedOpenDir(siaPath.String())
 	if err != nil {
 		return errors.AddContext(err, "failed to open parent dir of file")
 	}
@@ -607,7 +1415,12 @@ func (fs *FileSystem) managedDeleteDir(path string) (err error) {
 	defer func() {
 		err = errors.Compose(err, dir.Close())
 	}()
-	return dir.managedDelete()
+	err = dir.managedDelete()
+	if err != nil {
+		return err
+	}
+	fs.logEvent(EventDeleteDir, siaPath, modules.SiaPath{}, dir.threadUID)
+	return nil
 }
 
 // managedFileInfo returns the FileInfo of the siafile.
@@ -626,6 +1439,66 @@ func (fs *FileSystem) managedFileInfo(siaPath modules.SiaPath, cached bool, offl
 	return file.managedFileInfo(siaPath, offline, goodForRenew, contracts)
 }
 
+// managedBatchStat resolves the infos of the paths at the provided indices
+// which all share the parent dir at dirSiaPath.
+func (fs *FileSystem) managedBatchStat(dirSiaPath modules.SiaPath, siaPaths []modules.SiaPath, indices []int, infos map[modules.SiaPath]modules.FileSystemInfo, errs []error) {
+	dir, err := fs.managedOpenSiaDir(dirSiaPath)
+	if err != nil {
+		for _, i := range indices {
+			errs[i] = err
+		}
+		return
+	}
+
+	// Look up the children while holding the lock. Files are opened without
+	// adding them to the tree.
+	files := make(map[int]*FileNode)
+	dirs := make(map[int]*DirNode)
+	dir.mu.Lock()
+	for _, i := range indices {
+		name := siaPaths[i].Name()
+		file, err := dir.readonlyOpenFile(name)
+		if err == nil {
+			files[i] = file
+			continue
+		}
+		if !errors.Contains(err, ErrNotExist) {
+			errs[i] = err
+			continue
+		}
+		subDir, err := dir.openDir(name)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		dirs[i] = subDir
+	}
+	dir.mu.Unlock()
+
+	// Build the infos.
+	for i, file := range files {
+		fi, err := file.staticCachedInfo(siaPaths[i])
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		infos[siaPaths[i]] = modules.FileSystemInfo{File: &fi}
+	}
+	for i, subDir := range dirs {
+		di, err := subDir.managedInfo(siaPaths[i])
+		errs[i] = errors.Compose(err, subDir.Close())
+		if errs[i] != nil {
+			continue
+		}
+		infos[siaPaths[i]] = modules.FileSystemInfo{IsDir: true, Dir: &di}
+	}
+	if err := dir.Close(); err != nil {
+		for _, i := range indices {
+			errs[i] = errors.Compose(errs[i], err)
+		}
+	}
+}
+
 // managedList returns the files and dirs within the SiaDir specified by siaPath.
 // offlineMap, goodForRenewMap and contractMap don't need to be provided if
 // 'cached' is set to 'true'.
@@ -641,19 +1514,25 @@ func (fs *FileSystem) managedList(siaPath modules.SiaPath, recursive, cached boo
 	return dir.managedList(fs.managedAbsPath(), recursive, cached, offlineMap, goodForRenewMap, contractsMap, flf, dlf)
 }
 
-// managedNewSiaDir creates the folder at the specified siaPath.
+// managedNewSiaDir creates the folder at the specified siaPath. If the folder is
+// concurrently being created by another thread, the creation waits for the
+// other thread. An existing folder is not an error.
 func (fs *FileSystem) managedNewSiaDir(siaPath modules.SiaPath, mode os.FileMode) (err error) {
 	// If siaPath is the root dir we just create the metadata for it.
 	if siaPath.IsRoot() {
 		fs.mu.Lock()
 		defer fs.mu.Unlock()
 		dirPath := siaPath.SiaDirSysPath(fs.absPath())
-		_, err := siadir.New(dirPath, fs.absPath(), mode)
+		_, err := siadir.New(dirPath, fs.absPath(), mode, fs.staticDeps)
 		// If the SiaDir already exists on disk, return without an error.
 		if errors.Contains(err, os.ErrExist) {
 			return nil // nothing to do
 		}
-		return err
+		if err != nil {
+			return err
+		}
+		fs.logEvent(EventCreateDir, modules.SiaPath{}, siaPath, 0)
+		return nil
 	}
 	// If siaPath isn't the root dir we need to grab the parent.
 	parentPath, err := siaPath.Dir()
@@ -675,7 +1554,12 @@ func (fs *FileSystem) managedNewSiaDir(siaPath modules.SiaPath, mode os.FileMode
 		err = errors.Compose(err, parent.Close())
 	}()
 	// Create the dir within the parent.
-	return parent.managedNewSiaDir(siaPath.Name(), fs.managedAbsPath(), mode)
+	created, err := parent.managedNewSiaDir(siaPath.Name(), fs.managedAbsPath(), mode)
+	if err != nil || !created {
+		return err
+	}
+	fs.logEvent(EventCreateDir, modules.SiaPath{}, siaPath, 0)
+	return nil
 }
 
 // managedOpenFile opens a SiaFile and adds it and all of its parents to the
@@ -701,6 +1585,44 @@ func (fs *FileSystem) managedOpenFile(relPath string) (_ *FileNode, err error) {
 	return dir.managedOpenFile(fileName)
 }
 
+// managedOpenFiles opens the files with the provided names within the dir at
+// dirPath while locking the dir only once. The returned slice contains a node
+// for every file name. The nodes of files which don't exist are nil. If an
+// error is returned, the nodes which were opened already are returned as well
+// and need to be closed by the caller.
+func (fs *FileSystem) managedOpenFiles(dirPath string, fileNames []string) (_ []*FileNode, err error) {
+	// Open the folder that contains the files.
+	var dir *DirNode
+	if dirPath == string(filepath.Separator) || dirPath == "." || dirPath == "" {
+		dir = &fs.DirNode // files are in the root dir
+	} else {
+		var err error
+		dir, err = fs.managedOpenDir(filepath.Dir(dirPath))
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to open parent dir of files")
+		}
+		// Close the dir since we are not returning it. The open files keep it
+		// loaded in memory.
+		defer func() {
+			err = errors.Compose(err, dir.Close())
+		}()
+	}
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+	nodes := make([]*FileNode, len(fileNames))
+	for i, fileName := range fileNames {
+		sf, err := dir.openFile(fileName)
+		if errors.Contains(err, ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nodes, err
+		}
+		nodes[i] = sf
+	}
+	return nodes, nil
+}
+
 // managedNewSiaFile opens the parent folder of the new SiaFile and calls
 // managedNewSiaFile on it.
 func (fs *FileSystem) managedNewSiaFile(relPath string, source string, ec modules.ErasureCoder, mk crypto.CipherKey, fileSize uint64, fileMode os.FileMode, disablePartialUpload bool) (err error) {
@@ -719,7 +1641,12 @@ func (fs *FileSystem) managedNewSiaFile(relPath string, source string, ec module
 			err = errors.Compose(err, dir.Close())
 		}()
 	}
-	return dir.managedNewSiaFile(fileName, source, ec, mk, fileSize, fileMode, disablePartialUpload)
+	err = dir.managedNewSiaFile(fileName, source, ec, mk, fileSize, fileMode, disablePartialUpload)
+	if err != nil {
+		return err
+	}
+	// The file's ModTime is set during its creation.
+	return dir.managedAdvanceAggregateModTime(time.Now())
 }
 
 // managedOpenSiaDir opens a SiaDir and adds it and all of its parents to the
@@ -23,6 +23,7 @@ import (
 	"go.sia.tech/siad/modules/renter/filesystem/siadir"
 	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/types"
 
 	"go.sia.tech/siad/build"
 )
@@ -98,12 +99,18 @@ func newSiaPath(path string) modules.SiaPath {
 
 // newTestFileSystem creates a new filesystem for testing.
 func newTestFileSystem(root string) *FileSystem {
+	return newTestFileSystemWithSyncOptions(root, DefaultSyncOptions)
+}
+
+// newTestFileSystemWithSyncOptions creates a new filesystem for testing which
+// uses the provided sync options.
+func newTestFileSystemWithSyncOptions(root string, opts SyncOptions) *FileSystem {
 	wal, _ := newTestWAL()
 	logger, err := persist.NewLogger(ioutil.Discard)
 	if err != nil {
 		panic(err.Error())
 	}
-	fs, err := New(root, logger, wal)
+	fs, err := New(root, logger, wal, opts)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -203,6 +210,117 @@ func TestNewSiaDir(t *testing.T) {
 	}
 }
 
+// blockNewSiaDirDeps is a dependency which blocks the creation of a SiaDir
+// after its name was reserved until release is closed.
+type blockNewSiaDirDeps struct {
+	modules.Dependencies
+	reserved chan struct{}
+	release  chan struct{}
+	once     sync.Once
+}
+
+// Disrupt blocks the first creation of a SiaDir.
+func (d *blockNewSiaDirDeps) Disrupt(s string) bool {
+	if s != "BlockNewSiaDir" {
+		return d.Dependencies.Disrupt(s)
+	}
+	blocked := false
+	d.once.Do(func() {
+		close(d.reserved)
+		<-d.release
+		blocked = true
+	})
+	return blocked
+}
+
+// TestNewSiaDirConcurrent tests that creating the same dir concurrently waits
+// for the creation of the other thread, that the dir is only created once and
+// that creating an existing dir is a no-op. Creating files within the dir
+// waits for the dir to be created too.
+func TestNewSiaDirConcurrent(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem with a parent dir and block the first dir creation
+	// afterwards.
+	dir := testDir(t.Name())
+	root := filepath.Join(dir, "fs-root")
+	fs := newTestFileSystem(root)
+	if err := fs.NewSiaDir(newSiaPath("a"), modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "events.log")
+	if err := fs.EnableEventLog(logPath); err != nil {
+		t.Fatal(err)
+	}
+	deps := &blockNewSiaDirDeps{
+		Dependencies: fs.staticDeps,
+		reserved:     make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	fs.staticDeps = deps
+
+	// Start creating the dir in one thread.
+	sp := newSiaPath("a/b")
+	errs := make(chan error, 2)
+	go func() {
+		errs <- fs.NewSiaDir(sp, modules.DefaultDirPerm)
+	}()
+	<-deps.reserved
+
+	// Creating the same dir or a file within the dir in another thread should
+	// wait for the dir.
+	go func() {
+		errs <- fs.NewSiaDir(sp, modules.DefaultDirPerm)
+	}()
+	fileCreated := make(chan error)
+	go func() {
+		fileCreated <- fs.addTestSiaFileWithErr(newSiaPath("a/b/file"))
+	}()
+	select {
+	case err := <-errs:
+		t.Fatal("dir creation returned while dir was reserved", err)
+	case err := <-fileCreated:
+		t.Fatal("file was created while dir was reserved", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Release the first thread. Both creations should succeed.
+	close(deps.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-fileCreated; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, sp.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating the existing dir again should be a no-op.
+	if err := fs.NewSiaDir(sp, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// The dir should only have been created once.
+	events, err := readEvents(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created int
+	for _, e := range events {
+		if e.Op == EventCreateDir && e.NewPath.Equals(sp) {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Fatal("expected dir to be created once but was created", created, "times")
+	}
+}
+
 // TestNewSiaFile tests if creating a new file using NewSiaFiles creates the
 // correct folder structure and file.
 func TestNewSiaFile(t *testing.T) {
@@ -377,6 +495,270 @@ func TestOpenSiaDir(t *testing.T) {
 	}
 }
 
+// TestFileSystemClose tests that closing the FileSystem waits for in-flight
+// opens and rejects new operations.
+func TestFileSystemClose(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem with a file.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	sp := newSiaPath("dir/file")
+	fs.addTestSiaFile(sp)
+
+	// Start opening the file while the root is locked to block the open.
+	fs.mu.Lock()
+	type openResult struct {
+		node *FileNode
+		err  error
+	}
+	opened := make(chan openResult)
+	go func() {
+		node, err := fs.OpenSiaFile(sp)
+		opened <- openResult{node: node, err: err}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Close the filesystem. It should wait for the open.
+	closed := make(chan error)
+	go func() {
+		closed <- fs.Close()
+	}()
+	select {
+	case err := <-closed:
+		t.Fatal("Close returned before the open finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Unblock the open. It should finish cleanly followed by Close.
+	fs.mu.Unlock()
+	res := <-opened
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if err := res.node.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// New operations should fail.
+	if err := fs.NewSiaDir(newSiaPath("dir2"), modules.DefaultDirPerm); !errors.Contains(err, ErrShuttingDown) {
+		t.Fatal("expected ErrShuttingDown but got", err)
+	}
+	if err := fs.DeleteFile(sp); !errors.Contains(err, ErrShuttingDown) {
+		t.Fatal("expected ErrShuttingDown but got", err)
+	}
+	if _, err := fs.ReadDir(newSiaPath("dir")); !errors.Contains(err, ErrShuttingDown) {
+		t.Fatal("expected ErrShuttingDown but got", err)
+	}
+	if _, err := fs.OpenSiaFile(sp); !errors.Contains(err, ErrShuttingDown) {
+		t.Fatal("expected ErrShuttingDown but got", err)
+	}
+	if _, err := fs.OpenSiaDir(newSiaPath("dir")); !errors.Contains(err, ErrShuttingDown) {
+		t.Fatal("expected ErrShuttingDown but got", err)
+	}
+	if dirs := fs.OpenDirectories(); len(dirs) != 0 {
+		t.Fatal("expected no open dirs but got", dirs)
+	}
+}
+
+// TestFileSystemCloseInFlightOperation tests that closing the FileSystem waits
+// for in-flight operations other than opens.
+func TestFileSystemCloseInFlightOperation(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	deps := &blockNewSiaDirDeps{
+		Dependencies: fs.staticDeps,
+		reserved:     make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	fs.staticDeps = deps
+
+	// Start creating a dir and block the creation.
+	sp := newSiaPath("dir")
+	created := make(chan error)
+	go func() {
+		created <- fs.NewSiaDir(sp, modules.DefaultDirPerm)
+	}()
+	<-deps.reserved
+
+	// Close the filesystem. It should wait for the creation.
+	closed := make(chan error)
+	go func() {
+		closed <- fs.Close()
+	}()
+	select {
+	case err := <-closed:
+		t.Fatal("Close returned before the creation finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Unblock the creation. It should finish cleanly followed by Close.
+	close(deps.release)
+	if err := <-created; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sp.SiaDirMetadataSysPath(root)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBatchStat tests that BatchStat returns the infos of existing files and
+// dirs and errors for missing paths.
+func TestBatchStat(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem with the dirs /a and /a/b and the files /a/file and
+	// /file.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	if err := fs.NewSiaDir(newSiaPath("a/b"), modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	fs.addTestSiaFile(newSiaPath("a/file"))
+	fs.addTestSiaFile(newSiaPath("file"))
+
+	paths := []modules.SiaPath{
+		newSiaPath("a/file"),
+		newSiaPath("a/b"),
+		newSiaPath("a/missing"),
+		newSiaPath("file"),
+		newSiaPath("a"),
+		modules.RootSiaPath(),
+		newSiaPath("missing/file"),
+	}
+	infos, errs := fs.BatchStat(paths)
+	if len(errs) != len(paths) {
+		t.Fatalf("expected %v errors but got %v", len(paths), len(errs))
+	}
+	for i, sp := range paths {
+		missing := strings.Contains(sp.String(), "missing")
+		if missing != errors.Contains(errs[i], ErrNotExist) {
+			t.Fatalf("%v: unexpected error %v", sp, errs[i])
+		}
+		info, exists := infos[sp]
+		if exists == missing {
+			t.Fatalf("%v: unexpected info %v", sp, info)
+		}
+		if missing {
+			continue
+		}
+		isFile := sp.Name() == "file"
+		if info.IsDir == isFile || (info.File != nil) != isFile || (info.Dir != nil) == isFile {
+			t.Fatalf("%v: wrong info type %v", sp, info)
+		}
+		if isFile && !info.File.SiaPath.Equals(sp) {
+			t.Fatalf("%v: wrong file siapath %v", sp, info.File.SiaPath)
+		}
+		if !isFile && !info.Dir.SiaPath.Equals(sp) {
+			t.Fatalf("%v: wrong dir siapath %v", sp, info.Dir.SiaPath)
+		}
+	}
+
+	// All the dirs should be closed again.
+	if dirs := fs.OpenDirectories(); len(dirs) != 0 {
+		t.Fatal("expected no open dirs but got", dirs)
+	}
+}
+
+// TestDeepestExisting tests that DeepestExisting returns the longest existing
+// prefix of a path without opening any dirs.
+func TestDeepestExisting(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem with /a/b and a file /a/b/file.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	if err := fs.NewSiaDir(newSiaPath("a/b"), modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	fs.addTestSiaFile(newSiaPath("a/b/file"))
+
+	tests := []struct {
+		path     modules.SiaPath
+		expected modules.SiaPath
+	}{
+		{newSiaPath("a/b/c/d"), newSiaPath("a/b")},
+		{newSiaPath("a/b"), newSiaPath("a/b")},
+		{newSiaPath("a/c"), newSiaPath("a")},
+		{newSiaPath("a/b/file"), newSiaPath("a/b")},
+		{newSiaPath("x/y"), modules.RootSiaPath()},
+		{modules.RootSiaPath(), modules.RootSiaPath()},
+	}
+	for _, test := range tests {
+		sp, err := fs.DeepestExisting(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sp.Equals(test.expected) {
+			t.Fatalf("%v: expected %v but got %v", test.path, test.expected, sp)
+		}
+	}
+
+	// No dirs should have been opened.
+	if dirs := fs.OpenDirectories(); len(dirs) != 0 {
+		t.Fatal("expected no open dirs but got", dirs)
+	}
+}
+
+// TestOpenDirectories tests that OpenDirectories returns the dirs which are
+// loaded into memory.
+func TestOpenDirectories(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	sp := newSiaPath("sub/foo")
+	if err := fs.NewSiaDir(sp, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	if dirs := fs.OpenDirectories(); len(dirs) != 0 {
+		t.Fatal("expected no open dirs but got", dirs)
+	}
+
+	// Open /sub/foo. /sub and /sub/foo should be open.
+	foo, err := fs.OpenSiaDir(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs := fs.OpenDirectories()
+	expected := []modules.SiaPath{newSiaPath("sub"), sp}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Fatalf("expected %v but got %v", expected, dirs)
+	}
+
+	// Calling OpenDirectories shouldn't open anything.
+	if len(foo.threads) != 1 {
+		t.Fatal("wrong number of threads", len(foo.threads))
+	}
+
+	// Close /sub/foo. No dirs should be open.
+	if err := foo.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if dirs := fs.OpenDirectories(); len(dirs) != 0 {
+		t.Fatal("expected no open dirs but got", dirs)
+	}
+}
+
 // TestOpenSiaFile confirms that a previously created SiaFile can be opened and
 // that the filesystem tree is extended accordingly in the process.
 func TestOpenSiaFile(t *testing.T) {
@@ -2319,3 +2701,1013 @@ func TestList(t *testing.T) {
 		t.Fatal("wrong number of dirs", len(dis), len(dirStructure))
 	}
 }
+
+// TestDirQuota tests that the quota of a directory is enforced when creating
+// files within its sub tree.
+func TestDirQuota(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFile := func(siaPath modules.SiaPath, size uint64) error {
+		return fs.NewSiaFile(siaPath, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), size, persist.DefaultDiskPermissionsTest, false)
+	}
+
+	// Create a dir with a quota.
+	quotaDir := newSiaPath("quota")
+	if err := fs.NewSiaDir(quotaDir, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SetDirSoftQuota(quotaDir, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating a file below the quota should work.
+	if err := newFile(newSiaPath("quota/file1"), 60); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a bubble which updates the size of the dir. The quota should
+	// be preserved.
+	dir, err := fs.OpenSiaDir(quotaDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := dir.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	md.AggregateSize = 60
+	md.Quota = 0
+	if err := dir.UpdateBubbledMetadata(md); err != nil {
+		t.Fatal(err)
+	}
+	md, err = dir.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if md.Quota != 100 {
+		t.Fatal("quota wasn't preserved", md.Quota)
+	}
+
+	// Creating a file that exceeds the quota should fail. Also within a sub
+	// dir which inherits the quota.
+	err = newFile(newSiaPath("quota/file2"), 50)
+	if !errors.Contains(err, ErrQuotaExceeded) {
+		t.Fatal("expected ErrQuotaExceeded but got", err)
+	}
+	err = newFile(newSiaPath("quota/sub/file2"), 50)
+	if !errors.Contains(err, ErrQuotaExceeded) {
+		t.Fatal("expected ErrQuotaExceeded but got", err)
+	}
+
+	// A file that fits the remaining quota should work.
+	if err := newFile(newSiaPath("quota/sub/file2"), 40); err != nil {
+		t.Fatal(err)
+	}
+
+	// The quotas of all ancestors apply. Setting a larger quota on the sub dir
+	// doesn't allow for larger files.
+	if err := fs.SetDirSoftQuota(newSiaPath("quota/sub"), 1000); err != nil {
+		t.Fatal(err)
+	}
+	err = newFile(newSiaPath("quota/sub/file3"), 500)
+	if !errors.Contains(err, ErrQuotaExceeded) {
+		t.Fatal("expected ErrQuotaExceeded but got", err)
+	}
+
+	// A smaller quota on the sub dir applies as well.
+	if err := fs.SetDirSoftQuota(newSiaPath("quota/sub"), 10); err != nil {
+		t.Fatal(err)
+	}
+	err = newFile(newSiaPath("quota/sub/file3"), 20)
+	if !errors.Contains(err, ErrQuotaExceeded) {
+		t.Fatal("expected ErrQuotaExceeded but got", err)
+	}
+
+	// Bytes which are reserved by a file that is being created count against
+	// the quota until the reservation is released.
+	release, err := fs.managedReserveQuota(newSiaPath("quota/sub"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fs.managedReserveQuota(quotaDir, 40)
+	if !errors.Contains(err, ErrQuotaExceeded) {
+		t.Fatal("expected ErrQuotaExceeded but got", err)
+	}
+	release()
+	release, err = fs.managedReserveQuota(quotaDir, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	// Files outside of the dir are not affected.
+	if err := newFile(newSiaPath("file"), 1000); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDirReadOnly tests that a read-only dir prevents modifications within its
+// sub tree while reads still work.
+func TestDirReadOnly(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFile := func(siaPath modules.SiaPath) error {
+		return fs.NewSiaFile(siaPath, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 10, persist.DefaultDiskPermissionsTest, false)
+	}
+
+	// Create a parent with a child dir containing a file and a file outside
+	// of the parent.
+	parent := newSiaPath("parent")
+	child := newSiaPath("parent/child")
+	file := newSiaPath("parent/child/file")
+	outside := newSiaPath("outside")
+	for _, sp := range []modules.SiaPath{file, outside} {
+		if err := newFile(sp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Make the parent read-only.
+	if err := fs.SetDirReadOnly(parent, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes within the child should fail.
+	checkErr := func(err error) {
+		t.Helper()
+		if !errors.Contains(err, ErrReadOnlyDir) {
+			t.Fatal("expected ErrReadOnlyDir but got", err)
+		}
+	}
+	checkErr(fs.NewSiaDir(newSiaPath("parent/child/dir"), modules.DefaultDirPerm))
+	checkErr(newFile(newSiaPath("parent/child/file2")))
+	checkErr(newFile(newSiaPath("parent/child/newdir/file2")))
+	checkErr(fs.RenameFile(file, newSiaPath("parent/child/file2")))
+	checkErr(fs.RenameFile(outside, newSiaPath("parent/child/outside")))
+	checkErr(fs.RenameFile(file, newSiaPath("file")))
+	checkErr(fs.RenameDir(child, newSiaPath("child")))
+	checkErr(fs.DeleteFile(file))
+	checkErr(fs.DeleteDir(child))
+	_, failed := fs.DeleteFiles([]modules.SiaPath{file})
+	checkErr(failed[file])
+	checkErr(fs.CreateAlias(newSiaPath("parent/child/alias"), outside))
+	checkErr(fs.ReplaceSiaFileMetadata(file, bytes.NewReader(nil)))
+	_, err = fs.OpenSiaDirCustom(newSiaPath("parent/child/dir"), true)
+	checkErr(err)
+
+	// Reads still work and nothing was changed.
+	if _, err := fs.FileInfo(file, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DirInfo(child); err != nil {
+		t.Fatal(err)
+	}
+	sf, err := fs.OpenSiaFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := fs.FileExists(newSiaPath("parent/child/file2")); err != nil || exists {
+		t.Fatal("file was created", exists, err)
+	}
+
+	// Writes outside of the parent are not affected.
+	if err := newFile(newSiaPath("outside2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unset the flag. Writes should work again.
+	if err := fs.SetDirReadOnly(parent, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.NewSiaDir(newSiaPath("parent/child/dir"), modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := newFile(newSiaPath("parent/child/file2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.RenameFile(file, newSiaPath("parent/child/file3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.DeleteDir(child); err != nil {
+		t.Fatal(err)
+	}
+
+	// Make a dir within the parent read-only and rename the parent. The
+	// flag should move with the dir and the old path should be writable.
+	frozen := newSiaPath("parent/frozen")
+	if err := fs.NewSiaDir(frozen, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SetDirReadOnly(frozen, true); err != nil {
+		t.Fatal(err)
+	}
+	checkErr(newFile(newSiaPath("parent/frozen/file")))
+	if err := fs.RenameDir(parent, newSiaPath("renamed")); err != nil {
+		t.Fatal(err)
+	}
+	checkErr(newFile(newSiaPath("renamed/frozen/file")))
+	if err := newFile(newSiaPath("parent/frozen/file")); err != nil {
+		t.Fatal(err)
+	}
+
+	// While a write is in progress, metadata updates which don't change the
+	// flag don't block. Updates which change it wait for the write.
+	unlock, err := fs.managedLockWritable(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := fs.managedDirMetadata(frozen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md.AggregateNumFiles = 42
+	if err := fs.UpdateDirMetadata(frozen, md); err != nil {
+		t.Fatal(err)
+	}
+	md.ReadOnly = true
+	done := make(chan error)
+	go func() {
+		done <- fs.UpdateDirMetadata(frozen, md)
+	}()
+	select {
+	case err := <-done:
+		t.Fatal("update of the flag didn't wait for the write", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	checkErr(newFile(newSiaPath("parent/frozen/file2")))
+	md, err = fs.managedDirMetadata(frozen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.AggregateNumFiles != 42 {
+		t.Fatal("metadata wasn't updated", md.AggregateNumFiles)
+	}
+}
+
+// TestCommonAncestor tests finding the common ancestor of multiple SiaPaths.
+func TestCommonAncestor(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Add some files.
+	fs.addTestSiaFile(newSiaPath("a/b/c/file1"))
+	fs.addTestSiaFile(newSiaPath("a/b/d/file2"))
+	fs.addTestSiaFile(newSiaPath("a/bc/file3"))
+	fs.addTestSiaFile(newSiaPath("e/file4"))
+
+	tests := []struct {
+		paths    []string
+		ancestor string
+	}{
+		// Nested paths.
+		{[]string{"a/b/c/file1", "a/b/d/file2"}, "a/b"},
+		{[]string{"a/b/c", "a/b/c/file1"}, "a/b/c"},
+		{[]string{"a/b/c/file1", "a/bc/file3"}, "a"},
+		// A single file or dir.
+		{[]string{"a/b/c/file1"}, "a/b/c"},
+		{[]string{"a/b/c"}, "a/b/c"},
+		// Disjoint paths.
+		{[]string{"a/b/c/file1", "e/file4"}, ""},
+		{[]string{"e/file4", "e"}, "e"},
+	}
+	for _, test := range tests {
+		var paths []modules.SiaPath
+		for _, path := range test.paths {
+			paths = append(paths, newSiaPath(path))
+		}
+		ancestor, err := fs.CommonAncestor(paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ancestor.String() != test.ancestor {
+			t.Fatalf("%v: expected ancestor '%v' but got '%v'", test.paths, test.ancestor, ancestor)
+		}
+	}
+
+	// No paths should return an error.
+	if _, err := fs.CommonAncestor(nil); !errors.Contains(err, errNoSiaPaths) {
+		t.Fatal("expected errNoSiaPaths but got", err)
+	}
+}
+
+// TestReplaceSiaFileMetadata tests that replacing a file's metadata is atomic
+// with regard to concurrent opens of the file.
+func TestReplaceSiaFileMetadata(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create the file to replace.
+	sp := newSiaPath("dir/file")
+	fs.addTestSiaFile(sp)
+	fn, err := fs.OpenSiaFile(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldMD := fn.Metadata()
+	oldEC := fn.ErasureCode()
+	if err := fn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a file with a different erasure code and size and grab its
+	// serialized form.
+	ec, err := modules.NewRSSubCode(5, 15, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSP := newSiaPath("other")
+	err = fs.NewSiaFile(otherSP, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 12345, persist.DefaultDiskPermissionsTest, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := fs.OpenSiaFile(otherSP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newMD := other.Metadata()
+	newEC := other.ErasureCode()
+	sr, err := other.SnapshotReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(sr)
+	if err := errors.Compose(err, sr.Close(), other.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	// consistent checks whether the metadata matches either the old or the
+	// new file.
+	consistent := func(md siafile.Metadata) bool {
+		if md.UniqueID != oldMD.UniqueID {
+			return false
+		}
+		isOld := md.FileSize == oldMD.FileSize && md.StaticErasureCodeParams == oldMD.StaticErasureCodeParams
+		isNew := md.FileSize == newMD.FileSize && md.StaticErasureCodeParams == newMD.StaticErasureCodeParams
+		return isOld || isNew
+	}
+
+	// Open the file in a loop from multiple threads while replacing it.
+	var wg sync.WaitGroup
+	var inconsistent, failed uint64
+	stop := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				fn, err := fs.OpenSiaFile(sp)
+				if err != nil {
+					atomic.AddUint64(&failed, 1)
+					continue
+				}
+				if !consistent(fn.Metadata()) {
+					atomic.AddUint64(&inconsistent, 1)
+				}
+				// The erasure code related accessors are replaced as well
+				// and must not race with the replacement.
+				ec := fn.ErasureCode()
+				if ec.NumPieces() != oldEC.NumPieces() && ec.NumPieces() != newEC.NumPieces() {
+					atomic.AddUint64(&inconsistent, 1)
+				}
+				_ = fn.ChunkSize()
+				_ = fn.PieceSize()
+				_ = fn.MasterKey()
+				if err := fn.Close(); err != nil {
+					atomic.AddUint64(&failed, 1)
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	err = fs.ReplaceSiaFileMetadata(sp, bytes.NewReader(b))
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadUint64(&failed); n > 0 {
+		t.Fatalf("%v opens failed", n)
+	}
+	if n := atomic.LoadUint64(&inconsistent); n > 0 {
+		t.Fatalf("%v opens returned inconsistent metadata", n)
+	}
+
+	// The file should now have the new metadata but keep its UID, both in
+	// memory and on disk.
+	fn, err = fs.OpenSiaFile(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := fn.Metadata()
+	path := fn.SiaFilePath()
+	if err := fn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	diskMD, err := siafile.LoadSiaFileMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, md := range []siafile.Metadata{md, diskMD} {
+		if md.UniqueID != oldMD.UniqueID {
+			t.Fatal("UID changed", md.UniqueID, oldMD.UniqueID)
+		}
+		if md.FileSize != newMD.FileSize || md.StaticErasureCodeParams != newMD.StaticErasureCodeParams {
+			t.Fatal("file wasn't replaced")
+		}
+	}
+	// The temporary file should be gone.
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatal("expected the siafile and the .siadir file but got", len(files))
+	}
+}
+
+// TestFileCustomMetadata tests setting, reading, overwriting and persisting
+// the custom metadata of a file.
+func TestFileCustomMetadata(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	sp := newSiaPath("dir/file")
+	fs.addTestSiaFile(sp)
+
+	// checkValue is a helper to check the value of a key.
+	checkValue := func(key, expected string, expectedExists bool) {
+		t.Helper()
+		value, exists, err := fs.GetFileMetadata(sp, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != expectedExists || value != expected {
+			t.Fatalf("expected %v (%v) for key %v but got %v (%v)", expected, expectedExists, key, value, exists)
+		}
+	}
+
+	// Unknown key.
+	checkValue("content-type", "", false)
+
+	// Set a few values.
+	if err := fs.SetFileMetadata(sp, "content-type", "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SetFileMetadata(sp, "owner", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	checkValue("content-type", "text/plain", true)
+	checkValue("owner", "alice", true)
+
+	// Overwrite a value.
+	if err := fs.SetFileMetadata(sp, "owner", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	checkValue("owner", "bob", true)
+
+	// Remove a value.
+	if err := fs.SetFileMetadata(sp, "content-type", ""); err != nil {
+		t.Fatal(err)
+	}
+	checkValue("content-type", "", false)
+
+	// Empty keys are not allowed.
+	if err := fs.SetFileMetadata(sp, "", "value"); !errors.Contains(err, siafile.ErrEmptyCustomMetadataKey) {
+		t.Fatal("expected ErrEmptyCustomMetadataKey", err)
+	}
+
+	// Add a value which requires more than a single page for the header but
+	// stays within the limit.
+	checksum := strings.Repeat("a", siafile.MaxCustomMetadataSize-len("owner")-len("bob")-len("checksum"))
+	if err := fs.SetFileMetadata(sp, "checksum", checksum); err != nil {
+		t.Fatal(err)
+	}
+	// Exceeding the limit should fail without changing the metadata.
+	if err := fs.SetFileMetadata(sp, "owner", "bobby"); !errors.Contains(err, siafile.ErrCustomMetadataTooLarge) {
+		t.Fatal("expected ErrCustomMetadataTooLarge", err)
+	}
+	checkValue("owner", "bob", true)
+
+	// The file isn't open anymore which means the values are loaded from
+	// disk.
+	if len(fs.directories) != 0 {
+		t.Fatal("file shouldn't be loaded")
+	}
+	checkValue("owner", "bob", true)
+	checkValue("checksum", checksum, true)
+	checkValue("content-type", "", false)
+	md, err := siafile.LoadSiaFileMetadata(fs.FilePath(sp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.CustomMetadata) != 2 || md.CustomMetadata["owner"] != "bob" || md.CustomMetadata["checksum"] != checksum {
+		t.Fatal("wrong metadata on disk", len(md.CustomMetadata))
+	}
+}
+
+// TestDeleteFiles tests deleting a batch of files which contains deletable,
+// missing and open files.
+func TestDeleteFiles(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	deletable := []modules.SiaPath{newSiaPath("a"), newSiaPath("dir/b"), newSiaPath("dir/c")}
+	inUse := newSiaPath("dir/open")
+	missing := newSiaPath("dir/missing")
+	missingDir := newSiaPath("nodir/file")
+	for _, sp := range append(deletable, inUse) {
+		fs.addTestSiaFile(sp)
+	}
+	sf, err := fs.OpenSiaFile(inUse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := sf.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	paths := []modules.SiaPath{deletable[0], deletable[1], inUse, missing, missingDir, deletable[2]}
+	deleted, failed := fs.DeleteFiles(paths)
+
+	// The deletable files are deleted in the order of their dirs.
+	if !reflect.DeepEqual(deleted, deletable) {
+		t.Fatal("wrong files deleted", deleted)
+	}
+	for _, sp := range deletable {
+		if exists, err := fs.FileExists(sp); err != nil || exists {
+			t.Fatal("file wasn't deleted", sp, err)
+		}
+	}
+
+	// The other ones failed with the right errors.
+	if len(failed) != 3 {
+		t.Fatal("wrong number of failures", failed)
+	}
+	if !errors.Contains(failed[inUse], ErrFileInUse) {
+		t.Fatal("expected ErrFileInUse", failed[inUse])
+	}
+	if !errors.Contains(failed[missing], ErrNotExist) {
+		t.Fatal("expected ErrNotExist", failed[missing])
+	}
+	if !errors.Contains(failed[missingDir], ErrNotExist) {
+		t.Fatal("expected ErrNotExist", failed[missingDir])
+	}
+	if exists, err := fs.FileExists(inUse); err != nil || !exists {
+		t.Fatal("open file was deleted", err)
+	}
+}
+
+// TestValidatePath tests validating valid paths and every class of invalid
+// paths.
+func TestValidatePath(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Valid paths.
+	for _, sp := range []modules.SiaPath{modules.RootSiaPath(), newSiaPath("a"), newSiaPath("a/b/.c")} {
+		if err := fs.ValidatePath(sp); err != nil {
+			t.Fatal(sp, err)
+		}
+	}
+
+	// Invalid paths.
+	tooLongPath := strings.Repeat(strings.Repeat("a", maxNameLength)+"/", maxPathLength/maxNameLength)
+	tests := []struct {
+		path string
+		err  error
+	}{
+		{"a//b", modules.ErrInvalidSiaPath},
+		{"../a", modules.ErrInvalidSiaPath},
+		{"a/./b", modules.ErrInvalidSiaPath},
+		{"/a", modules.ErrInvalidSiaPath},
+		{"a/\xff", modules.ErrInvalidSiaPath},
+		{modules.SiaDirExtension, ErrReservedName},
+		{"a/" + modules.SiaDirExtension + "/b", ErrReservedName},
+		{strings.Repeat("a", maxNameLength+1), ErrPathTooLong},
+		{strings.TrimSuffix(tooLongPath, "/"), ErrPathTooLong},
+	}
+	for _, test := range tests {
+		sp := modules.SiaPath{Path: test.path}
+		if err := fs.ValidatePath(sp); !errors.Contains(err, test.err) {
+			t.Fatalf("%v: expected %v but got %v", test.path, test.err, err)
+		}
+		// NewSiaDir should fail with the same error.
+		if err := fs.NewSiaDir(sp, persist.DefaultDiskPermissionsTest); !errors.Contains(err, test.err) {
+			t.Fatalf("%v: expected %v but got %v", test.path, test.err, err)
+		}
+	}
+
+	// Validating doesn't create anything.
+	if exists, err := fs.DirExists(newSiaPath("a")); err != nil || exists {
+		t.Fatal("dir was created", exists, err)
+	}
+}
+
+// newModTimeBubble returns a BubbleFunc which updates the AggregateModTime of
+// a dir the same way the renter's bubble does.
+func newModTimeBubble(fs *FileSystem) BubbleFunc {
+	return func(siaPath modules.SiaPath) error {
+		fis, err := fs.ReadDir(siaPath)
+		if err != nil {
+			return err
+		}
+		md, err := fs.managedDirMetadata(siaPath)
+		if err != nil {
+			return err
+		}
+		var modTime time.Time
+		for _, fi := range fis {
+			if !fi.IsDir() && !strings.HasSuffix(fi.Name(), modules.SiaFileExtension) {
+				continue
+			}
+			sp, err := siaPath.Join(strings.TrimSuffix(fi.Name(), modules.SiaFileExtension))
+			if err != nil {
+				return err
+			}
+			var childModTime time.Time
+			if fi.IsDir() {
+				cmd, err := fs.managedDirMetadata(sp)
+				if err != nil {
+					return err
+				}
+				childModTime = cmd.AggregateModTime
+			} else {
+				fmd, err := fs.managedCachedFileMetadata(sp)
+				if err != nil {
+					return err
+				}
+				childModTime = fmd.ModTime
+			}
+			if childModTime.After(modTime) {
+				modTime = childModTime
+			}
+		}
+		if !modTime.IsZero() {
+			md.AggregateModTime = modTime
+		}
+		return fs.UpdateDirMetadata(siaPath, md)
+	}
+}
+
+// TestUpdateDirModTime tests that modifying a deep file propagates its ModTime
+// up to the ancestors of the file right away and after recomputing the mod
+// times.
+func TestUpdateDirModTime(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create a deep file and a file in a sibling dir.
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deep := newSiaPath("a/b/c/file")
+	sibling := newSiaPath("a/d/file")
+	for _, sp := range []modules.SiaPath{deep, sibling} {
+		err := fs.NewSiaFile(sp, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 10, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	statModTime := func(sp modules.SiaPath) time.Time {
+		t.Helper()
+		fi, err := fs.Stat(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.ModTime()
+	}
+
+	// Recompute the mod times after creating the files.
+	sf, err := fs.OpenSiaFile(deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := sf.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	bubble := newModTimeBubble(fs)
+	if _, err := fs.UpdateDirModTime(modules.RootSiaPath(), bubble); err != nil {
+		t.Fatal(err)
+	}
+	before := statModTime(newSiaPath("a"))
+	if before.Before(sf.ModTime()) {
+		t.Fatal("ancestor wasn't updated after creating a file")
+	}
+
+	// Modify the deep file.
+	time.Sleep(10 * time.Millisecond)
+	if err := sf.AddPiece(types.SiaPublicKey{}, 0, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	modTime := sf.ModTime()
+	if !modTime.After(before) {
+		t.Fatal("file's ModTime wasn't updated")
+	}
+	if !statModTime(newSiaPath("a")).Equal(modTime) {
+		t.Fatal("ancestor wasn't advanced after modifying the file")
+	}
+
+	// Recompute the mod times. The file's ModTime should be propagated to
+	// its ancestors but not to the sibling dir.
+	updated, err := fs.UpdateDirModTime(modules.RootSiaPath(), bubble)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.Equal(modTime) {
+		t.Fatal("wrong ModTime returned", updated, modTime)
+	}
+	for _, sp := range []string{"a", "a/b", "a/b/c"} {
+		if mt := statModTime(newSiaPath(sp)); !mt.Equal(modTime) {
+			t.Fatalf("%v: expected ModTime %v but got %v", sp, modTime, mt)
+		}
+	}
+	if !statModTime(newSiaPath("a/d")).Before(modTime) {
+		t.Fatal("sibling dir shouldn't be updated")
+	}
+}
+
+// TestModifiedSince tests that ModifiedSince returns the modified files and
+// skips subtrees which weren't modified.
+func TestModifiedSince(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFile := func(sp modules.SiaPath) {
+		t.Helper()
+		err := fs.NewSiaFile(sp, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 10, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create a file in each subtree before and after since.
+	newFile(newSiaPath("unchanged/old"))
+	newFile(newSiaPath("changed/old"))
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	newFile(newSiaPath("changed/sub/new"))
+	newFile(newSiaPath("unchanged/new"))
+	if _, err := fs.UpdateDirModTime(modules.RootSiaPath(), newModTimeBubble(fs)); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := fs.ModifiedSince(modules.RootSiaPath(), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []modules.SiaPath{newSiaPath("changed/sub/new"), newSiaPath("unchanged/new")}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatal("wrong paths", paths, expected)
+	}
+
+	// Pretend that the unchanged dir wasn't modified since. Its new file is
+	// no longer returned which means that the subtree was skipped.
+	md, err := fs.managedDirMetadata(newSiaPath("unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	md.AggregateModTime = since.Add(-time.Hour)
+	if err := fs.UpdateDirMetadata(newSiaPath("unchanged"), md); err != nil {
+		t.Fatal(err)
+	}
+	paths, err = fs.ModifiedSince(modules.RootSiaPath(), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []modules.SiaPath{newSiaPath("changed/sub/new")}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatal("unchanged subtree wasn't skipped", paths, expected)
+	}
+
+	// Modify an existing file, create a new one and move a file without
+	// bubbling. The AggregateModTime of their dirs is advanced right away
+	// which means that they are returned.
+	sf, err := fs.OpenSiaFile(newSiaPath("unchanged/old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = errors.Compose(sf.AddPiece(types.SiaPublicKey{}, 0, 0, crypto.Hash{}), sf.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFile(newSiaPath("created/new"))
+	if err := fs.RenameFile(newSiaPath("changed/sub/new"), newSiaPath("moved/new")); err != nil {
+		t.Fatal(err)
+	}
+	paths, err = fs.ModifiedSince(modules.RootSiaPath(), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []modules.SiaPath{newSiaPath("created/new"), newSiaPath("moved/new"), newSiaPath("unchanged/new"), newSiaPath("unchanged/old")}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatal("modified files weren't returned", paths, expected)
+	}
+
+	// A dir that doesn't exist returns ErrNotExist.
+	if _, err := fs.ModifiedSince(newSiaPath("missing"), since); !errors.Contains(err, ErrNotExist) {
+		t.Fatal("expected ErrNotExist", err)
+	}
+}
+
+// TestRenameSiaFileTx tests that RenameSiaFileTx returns a node which is
+// valid at the new path right away.
+func TestRenameSiaFileTx(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPath := newSiaPath("a/file")
+	newPath := newSiaPath("b/c/file")
+	err = fs.NewSiaFile(oldPath, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 10, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rename the file.
+	sf, err := fs.RenameSiaFileTx(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp := fs.FileSiaPath(sf); !sp.Equals(newPath) {
+		t.Fatal("node has wrong path", sp, newPath)
+	}
+	if sf.SiaFilePath() != newPath.SiaFileSysPath(fs.managedAbsPath()) {
+		t.Fatal("node has wrong sys path", sf.SiaFilePath())
+	}
+	// The node can be used right away.
+	if err := sf.SetLocalPath("local"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := fs.FileExists(oldPath); err != nil || exists {
+		t.Fatal("old path still exists", exists, err)
+	}
+	sf, err = fs.OpenSiaFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sf.LocalPath() != "local" {
+		t.Fatal("update through the returned node was lost", sf.LocalPath())
+	}
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renaming a missing file fails.
+	if _, err := fs.RenameSiaFileTx(oldPath, newPath); !errors.Contains(err, ErrNotExist) {
+		t.Fatal("expected ErrNotExist", err)
+	}
+}
+
+// TestOpenSiaFiles tests opening multiple files at once and closing them with
+// the returned function.
+func TestOpenSiaFiles(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create three files. Two of them share a dir.
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siaPaths := []modules.SiaPath{
+		newSiaPath("a/file1"),
+		newSiaPath("b/file2"),
+		newSiaPath("a/file3"),
+	}
+	for _, sp := range siaPaths {
+		err := fs.NewSiaFile(sp, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 10, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// assertLoaded checks the number of dirs loaded into memory.
+	assertLoaded := func(numDirs int) {
+		t.Helper()
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		if len(fs.directories) != numDirs {
+			t.Fatalf("expected %v loaded dirs but got %v", numDirs, len(fs.directories))
+		}
+	}
+
+	// Open them.
+	files, closeFiles, err := fs.OpenSiaFiles(siaPaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(siaPaths) {
+		t.Fatalf("expected %v files but got %v", len(siaPaths), len(files))
+	}
+	for i, sf := range files {
+		if sp := fs.FileSiaPath(sf); !sp.Equals(siaPaths[i]) {
+			t.Fatal("wrong file", sp, siaPaths[i])
+		}
+	}
+	assertLoaded(2)
+
+	// Closing them should unload all of the dirs.
+	closeFiles()
+	assertLoaded(0)
+
+	// Opening a missing file should close the other files again.
+	_, _, err = fs.OpenSiaFiles(append(siaPaths, newSiaPath("a/missing")))
+	if !errors.Contains(err, ErrNotExist) {
+		t.Fatal("expected ErrNotExist but got", err)
+	}
+	assertLoaded(0)
+}
@@ -0,0 +1,245 @@
+package filesystem
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+const (
+	// SyncModeDefault fsyncs .sia metadata writes before the write returns
+	// but leaves .siadir writes to the operating system. .siadir files are
+	// protected by a checksum and are recomputed on load if they are corrupt.
+	// This is the behavior of the FileSystem before the sync modes were
+	// introduced.
+	SyncModeDefault SyncMode = iota
+
+	// SyncModeFull fsyncs every .siadir and .sia metadata write before the
+	// write returns. This is the safest mode. After a crash, every write that
+	// returned successfully is guaranteed to be on disk.
+	SyncModeFull
+
+	// SyncModeBatched coalesces fsyncs. A write only marks the file as dirty
+	// and all dirty files are fsynced together once SyncOptions.BatchWrites
+	// writes were performed, once SyncOptions.BatchInterval has passed since
+	// the first unsynced write or when Flush is called. A crash can lose all
+	// writes since the last batch was synced. Since the WAL considers a .sia
+	// update applied once it was written, a crash can also leave a .sia file
+	// partially written. .siadir files are protected by a checksum and are
+	// reset and recomputed on load if they are corrupt.
+	SyncModeBatched
+
+	// SyncModeNone never fsyncs metadata writes and leaves it to the
+	// operating system to persist the data. Flush is a no-op. This is the
+	// fastest mode but it has the same crash-safety implications as
+	// SyncModeBatched with an unbounded window of lost writes.
+	SyncModeNone
+)
+
+const (
+	// defaultSyncBatchWrites is the number of writes after which the dirty
+	// files are synced in batched mode if no value is specified.
+	defaultSyncBatchWrites = 100
+
+	// defaultSyncBatchInterval is the interval after which the dirty files
+	// are synced in batched mode if no value is specified.
+	defaultSyncBatchInterval = 5 * time.Second
+)
+
+var (
+	// DefaultSyncOptions are the sync options used by the renter's
+	// filesystem.
+	DefaultSyncOptions = SyncOptions{
+		Mode: SyncModeDefault,
+	}
+
+	// errUnknownSyncMode is returned if a FileSystem is created with an
+	// unknown sync mode.
+	errUnknownSyncMode = errors.New("unknown sync mode")
+)
+
+type (
+	// SyncMode describes when the FileSystem fsyncs metadata writes.
+	SyncMode int
+
+	// SyncOptions configure the durability of the FileSystem's metadata
+	// writes.
+	SyncOptions struct {
+		Mode SyncMode
+
+		// BatchWrites and BatchInterval are only used by SyncModeBatched. If
+		// they are 0, defaultSyncBatchWrites and defaultSyncBatchInterval
+		// are used.
+		BatchWrites   uint64
+		BatchInterval time.Duration
+	}
+
+	// metadataSyncer applies the SyncOptions to the metadata writes of a
+	// FileSystem.
+	metadataSyncer struct {
+		// dirty contains the paths of the files which were written to but not
+		// synced yet. numWrites is the number of writes since the last sync.
+		dirty     map[string]struct{}
+		numWrites uint64
+		timer     *time.Timer
+
+		// numSyncs is the number of fsyncs performed by the syncer.
+		numSyncs uint64
+
+		// closed indicates that the FileSystem was closed. Writes of nodes
+		// which are still held by callers are synced right away after that
+		// since no timer is started anymore.
+		closed bool
+
+		staticLog  *persist.Logger
+		staticOpts SyncOptions
+		mu         sync.Mutex
+	}
+
+	// syncDependencies are the dependencies passed to the siadirs and
+	// siafiles of a FileSystem. They wrap the files returned by OpenFile to
+	// apply the FileSystem's SyncOptions.
+	syncDependencies struct {
+		*modules.ProductionDependencies
+		staticSyncer *metadataSyncer
+	}
+
+	// syncFile is a modules.File which routes calls to Sync through a
+	// metadataSyncer.
+	syncFile struct {
+		modules.File
+		staticPath   string
+		staticSyncer *metadataSyncer
+	}
+)
+
+// newMetadataSyncer creates a new metadataSyncer from the provided options.
+func newMetadataSyncer(opts SyncOptions, log *persist.Logger) (*metadataSyncer, error) {
+	switch opts.Mode {
+	case SyncModeDefault, SyncModeFull, SyncModeNone:
+	case SyncModeBatched:
+		if opts.BatchWrites == 0 {
+			opts.BatchWrites = defaultSyncBatchWrites
+		}
+		if opts.BatchInterval == 0 {
+			opts.BatchInterval = defaultSyncBatchInterval
+		}
+	default:
+		return nil, errUnknownSyncMode
+	}
+	return &metadataSyncer{
+		dirty:      make(map[string]struct{}),
+		staticLog:  log,
+		staticOpts: opts,
+	}, nil
+}
+
+// OpenFile opens a file and wraps it to apply the syncer's SyncOptions.
+func (sd *syncDependencies) OpenFile(path string, flag int, perm os.FileMode) (modules.File, error) {
+	f, err := sd.ProductionDependencies.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &syncFile{
+		File:         f,
+		staticPath:   path,
+		staticSyncer: sd.staticSyncer,
+	}, nil
+}
+
+// Sync syncs the file according to the syncer's SyncOptions.
+func (sf *syncFile) Sync() error {
+	return sf.staticSyncer.managedSync(sf.staticPath, sf.File)
+}
+
+// Flush syncs all the dirty files of the syncer.
+func (ms *metadataSyncer) Flush() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.flush()
+}
+
+// managedSync handles a call to Sync on a file opened through the
+// syncDependencies.
+func (ms *metadataSyncer) managedSync(path string, f modules.File) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	switch ms.staticOpts.Mode {
+	case SyncModeNone:
+		return nil
+	case SyncModeDefault:
+		if strings.HasSuffix(path, modules.SiaDirExtension) {
+			return nil
+		}
+		ms.numSyncs++
+		return f.Sync()
+	case SyncModeBatched:
+		ms.dirty[path] = struct{}{}
+		ms.numWrites++
+		if ms.numWrites >= ms.staticOpts.BatchWrites || ms.closed {
+			return ms.flush()
+		}
+		if ms.timer == nil {
+			ms.timer = time.AfterFunc(ms.staticOpts.BatchInterval, ms.threadedFlush)
+		}
+		return nil
+	default:
+		ms.numSyncs++
+		return f.Sync()
+	}
+}
+
+// flush syncs all dirty files. Files which no longer exist are skipped since
+// they were either deleted or renamed.
+func (ms *metadataSyncer) flush() error {
+	if ms.timer != nil {
+		ms.timer.Stop()
+		ms.timer = nil
+	}
+	var errs []error
+	for path := range ms.dirty {
+		ms.numSyncs++
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, f.Sync(), f.Close())
+	}
+	ms.dirty = make(map[string]struct{})
+	ms.numWrites = 0
+	return errors.AddContext(errors.Compose(errs...), "failed to flush metadata")
+}
+
+// managedClose flushes the dirty files and stops the syncer's timer.
+func (ms *metadataSyncer) managedClose() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.closed = true
+	return ms.flush()
+}
+
+// threadedFlush is called by the syncer's timer to flush the dirty files.
+func (ms *metadataSyncer) threadedFlush() {
+	if err := ms.Flush(); err != nil {
+		ms.staticLog.Println("WARN: failed to flush metadata:", err)
+	}
+}
+
+// Flush syncs all metadata writes which haven't been synced yet. This is only
+// necessary when using SyncModeBatched.
+func (fs *FileSystem) Flush() error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.staticSyncer.Flush()
+}
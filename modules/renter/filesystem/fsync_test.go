@@ -0,0 +1,211 @@
+package filesystem
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+// numSyncs is a helper to return the number of syncs performed by the
+// filesystem's syncer.
+func (fs *FileSystem) numSyncs() uint64 {
+	fs.staticSyncer.mu.Lock()
+	defer fs.staticSyncer.mu.Unlock()
+	return fs.staticSyncer.numSyncs
+}
+
+// updateRootMetadata is a helper which rewrites the metadata of the root dir
+// n times.
+func updateRootMetadata(fs *FileSystem, n int) error {
+	dir, err := fs.OpenSiaDir(modules.RootSiaPath())
+	if err != nil {
+		return err
+	}
+	md, err := dir.Metadata()
+	if err != nil {
+		return errors.Compose(err, dir.Close())
+	}
+	for i := 0; i < n; i++ {
+		if err := dir.UpdateMetadata(md); err != nil {
+			return errors.Compose(err, dir.Close())
+		}
+	}
+	return dir.Close()
+}
+
+// TestSyncModes tests the different sync modes of the FileSystem.
+func TestSyncModes(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	t.Run("Default", testSyncModeDefault)
+	t.Run("Full", testSyncModeFull)
+	t.Run("None", testSyncModeNone)
+	t.Run("Batched", testSyncModeBatched)
+	t.Run("BatchedTimer", testSyncModeBatchedTimer)
+	t.Run("BatchedClose", testSyncModeBatchedClose)
+	t.Run("Unknown", testSyncModeUnknown)
+}
+
+// testSyncModeDefault tests that .siadir writes aren't synced by default.
+func testSyncModeDefault(t *testing.T) {
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	before := fs.numSyncs()
+	if err := updateRootMetadata(fs, 10); err != nil {
+		t.Fatal(err)
+	}
+	if syncs := fs.numSyncs() - before; syncs != 0 {
+		t.Fatal("expected 0 syncs but got", syncs)
+	}
+}
+
+// testSyncModeFull tests that every write is synced in full mode.
+func testSyncModeFull(t *testing.T) {
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystemWithSyncOptions(root, SyncOptions{Mode: SyncModeFull})
+	before := fs.numSyncs()
+	if err := updateRootMetadata(fs, 10); err != nil {
+		t.Fatal(err)
+	}
+	if syncs := fs.numSyncs() - before; syncs != 10 {
+		t.Fatal("expected 10 syncs but got", syncs)
+	}
+}
+
+// testSyncModeNone tests that no write is synced when syncing is disabled.
+func testSyncModeNone(t *testing.T) {
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystemWithSyncOptions(root, SyncOptions{Mode: SyncModeNone})
+	if err := updateRootMetadata(fs, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if syncs := fs.numSyncs(); syncs != 0 {
+		t.Fatal("expected 0 syncs but got", syncs)
+	}
+}
+
+// testSyncModeBatched tests that batched mode coalesces syncs and that Flush
+// forces a sync.
+func testSyncModeBatched(t *testing.T) {
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystemWithSyncOptions(root, SyncOptions{
+		Mode:          SyncModeBatched,
+		BatchWrites:   20,
+		BatchInterval: time.Hour,
+	})
+	if err := fs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	before := fs.numSyncs()
+
+	// Update the same file a couple of times. Nothing should be synced.
+	if err := updateRootMetadata(fs, 10); err != nil {
+		t.Fatal(err)
+	}
+	if syncs := fs.numSyncs() - before; syncs != 0 {
+		t.Fatal("expected 0 syncs but got", syncs)
+	}
+
+	// Flush. The writes should be coalesced into a single sync.
+	if err := fs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if syncs := fs.numSyncs() - before; syncs != 1 {
+		t.Fatal("expected 1 sync but got", syncs)
+	}
+
+	// Flushing again shouldn't sync anything.
+	if err := fs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if syncs := fs.numSyncs() - before; syncs != 1 {
+		t.Fatal("expected 1 sync but got", syncs)
+	}
+
+	// Reaching the batch size should trigger a sync without calling Flush.
+	if err := updateRootMetadata(fs, 20); err != nil {
+		t.Fatal(err)
+	}
+	if syncs := fs.numSyncs() - before; syncs != 2 {
+		t.Fatal("expected 2 syncs but got", syncs)
+	}
+}
+
+// testSyncModeBatchedTimer tests that batched mode syncs dirty files after
+// the batch interval.
+func testSyncModeBatchedTimer(t *testing.T) {
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystemWithSyncOptions(root, SyncOptions{
+		Mode:          SyncModeBatched,
+		BatchWrites:   1000,
+		BatchInterval: 100 * time.Millisecond,
+	})
+	if err := fs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	before := fs.numSyncs()
+	if err := updateRootMetadata(fs, 5); err != nil {
+		t.Fatal(err)
+	}
+	err := build.Retry(100, 10*time.Millisecond, func() error {
+		if syncs := fs.numSyncs() - before; syncs != 1 {
+			return fmt.Errorf("expected 1 sync but got %v", syncs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSyncModeBatchedClose tests that closing the FileSystem flushes the dirty
+// files and stops the timer.
+func testSyncModeBatchedClose(t *testing.T) {
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystemWithSyncOptions(root, SyncOptions{
+		Mode:          SyncModeBatched,
+		BatchWrites:   1000,
+		BatchInterval: time.Hour,
+	})
+	if err := fs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	before := fs.numSyncs()
+	if err := updateRootMetadata(fs, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if syncs := fs.numSyncs() - before; syncs != 1 {
+		t.Fatal("expected 1 sync but got", syncs)
+	}
+	fs.staticSyncer.mu.Lock()
+	timer := fs.staticSyncer.timer
+	fs.staticSyncer.mu.Unlock()
+	if timer != nil {
+		t.Fatal("timer wasn't stopped")
+	}
+}
+
+// testSyncModeUnknown tests that creating a FileSystem with an unknown sync
+// mode fails.
+func testSyncModeUnknown(t *testing.T) {
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	wal, _ := newTestWAL()
+	_, err := New(root, nil, wal, SyncOptions{Mode: SyncModeNone + 1})
+	if !errors.Contains(err, errUnknownSyncMode) {
+		t.Fatal("expected errUnknownSyncMode but got", err)
+	}
+}
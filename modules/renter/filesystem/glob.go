@@ -0,0 +1,113 @@
+package filesystem
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+type (
+	// readDirFunc is the signature of FileSystem.ReadDir.
+	readDirFunc func(modules.SiaPath) ([]os.FileInfo, error)
+)
+
+// Glob returns the SiaPaths of all files and directories which match the
+// pattern. The pattern uses the syntax of path.Match and is matched against
+// the components of a SiaPath separately. That means that wildcards never
+// match a '/'. To stay efficient, only the directories which can contain a
+// match are read.
+func (fs *FileSystem) Glob(pattern string) ([]modules.SiaPath, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedGlob(pattern, fs.ReadDir)
+}
+
+// managedGlob implements Glob using the provided function to read dirs.
+func (fs *FileSystem) managedGlob(pattern string, readDir readDirFunc) ([]modules.SiaPath, error) {
+	// Validate the pattern.
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errors.AddContext(err, "invalid pattern")
+	}
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return nil, nil
+	}
+	var matches []modules.SiaPath
+	err := fs.managedGlobDir(modules.RootSiaPath(), strings.Split(pattern, "/"), readDir, &matches)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].String() < matches[j].String()
+	})
+	return matches, nil
+}
+
+// managedGlobDir matches the first component of the pattern against the
+// entries of dir and either adds them to the matches or recurses into them if
+// there are components left.
+func (fs *FileSystem) managedGlobDir(dir modules.SiaPath, components []string, readDir readDirFunc, matches *[]modules.SiaPath) error {
+	component := components[0]
+	last := len(components) == 1
+
+	// If the component doesn't contain a wildcard, there is no need to read
+	// the whole dir. Instead we only check the matching entries.
+	var fis []os.FileInfo
+	if !strings.ContainsAny(component, `*?[\`) {
+		sp, err := dir.Join(component)
+		if err != nil {
+			return nil // not a valid name
+		}
+		for _, sysPath := range []string{sp.SiaDirSysPath(fs.managedAbsPath()), sp.SiaFileSysPath(fs.managedAbsPath())} {
+			fi, err := os.Stat(sysPath)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			fis = append(fis, fi)
+		}
+	} else {
+		var err error
+		fis, err = readDir(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.AddContext(err, "failed to read dir")
+		}
+	}
+
+	for _, fi := range fis {
+		name := fi.Name()
+		isDir := fi.IsDir()
+		if !isDir {
+			if !strings.HasSuffix(name, modules.SiaFileExtension) {
+				continue // ignore metadata and other files
+			}
+			name = strings.TrimSuffix(name, modules.SiaFileExtension)
+		}
+		if match, _ := path.Match(component, name); !match {
+			continue
+		}
+		sp, err := dir.Join(name)
+		if err != nil {
+			continue
+		}
+		if last {
+			*matches = append(*matches, sp)
+		} else if isDir {
+			if err := fs.managedGlobDir(sp, components[1:], readDir, matches); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+// TestGlob tests matching SiaPaths against glob patterns.
+func TestGlob(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Add some files.
+	for _, path := range []string{
+		"backups/a/2023-01.tar",
+		"backups/a/2022-12.tar",
+		"backups/b/2023-02.tar",
+		"backups/b/c/2023-03.tar",
+		"other/a/2023-04.tar",
+		"file1",
+		"file2",
+		"file10",
+	} {
+		fs.addTestSiaFile(newSiaPath(path))
+	}
+
+	// toSiaPaths is a helper to convert strings to SiaPaths.
+	toSiaPaths := func(paths ...string) []modules.SiaPath {
+		var sps []modules.SiaPath
+		for _, path := range paths {
+			sps = append(sps, newSiaPath(path))
+		}
+		return sps
+	}
+
+	tests := []struct {
+		pattern string
+		matches []modules.SiaPath
+	}{
+		{"backups/*/2023-*.tar", toSiaPaths("backups/a/2023-01.tar", "backups/b/2023-02.tar")},
+		{"*/a/*", toSiaPaths("backups/a/2022-12.tar", "backups/a/2023-01.tar", "other/a/2023-04.tar")},
+		{"file?", toSiaPaths("file1", "file2")},
+		{"backups/*", toSiaPaths("backups/a", "backups/b")},
+		{"backups/b/c/2023-03.tar", toSiaPaths("backups/b/c/2023-03.tar")},
+		{"backups/d/*", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		matches, err := fs.Glob(test.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(matches, test.matches) {
+			t.Fatalf("pattern %v: expected %v but got %v", test.pattern, test.matches, matches)
+		}
+	}
+
+	// Invalid patterns should return an error.
+	if _, err := fs.Glob("backups/[a"); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+
+	// Only the dirs which can contain matches should be read. The 'other'
+	// subtree should be pruned.
+	var read []string
+	readDir := func(sp modules.SiaPath) ([]os.FileInfo, error) {
+		read = append(read, sp.String())
+		return fs.ReadDir(sp)
+	}
+	matches, err := fs.managedGlob("backups/*/2023-*.tar", readDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatal("expected 2 matches but got", len(matches))
+	}
+	sort.Strings(read)
+	if !reflect.DeepEqual(read, []string{"backups", "backups/a", "backups/b"}) {
+		t.Fatal("unexpected dirs read", read)
+	}
+}
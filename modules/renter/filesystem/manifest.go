@@ -0,0 +1,150 @@
+package filesystem
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// manifestWalkConcurrency is the number of workers used by Manifest to walk
+// the filesystem.
+const manifestWalkConcurrency = 4
+
+type (
+	// ManifestEntry describes a single file of a manifest created by
+	// Manifest.
+	ManifestEntry struct {
+		SiaPath      modules.SiaPath `json:"siapath"`
+		Size         uint64          `json:"size"`
+		MetadataHash crypto.Hash     `json:"metadatahash"`
+	}
+
+	// manifestCustomMetadata is a single custom metadata entry of a file. It
+	// is used to hash the custom metadata in a deterministic order.
+	manifestCustomMetadata struct {
+		Key   string
+		Value string
+	}
+)
+
+// Manifest returns a manifest of all the files within the dir at siaPath and
+// its subdirs. The SiaPaths of the entries are relative to siaPath and the
+// entries are sorted by SiaPath which makes manifests of identical trees
+// identical. Files and dirs whose name starts with a '.' are hidden and not
+// part of the manifest.
+func (fs *FileSystem) Manifest(siaPath modules.SiaPath) ([]ManifestEntry, error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedManifest(siaPath)
+}
+
+// managedManifest is Manifest without the threadgroup guard. It is used by the
+// FileSystem's methods which are already tracked by the threadgroup.
+func (fs *FileSystem) managedManifest(siaPath modules.SiaPath) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	var mu sync.Mutex
+	err := fs.managedWalkFilesParallel(siaPath, manifestWalkConcurrency, func(sp modules.SiaPath, file *FileNode) error {
+		relPath, err := sp.Rebase(siaPath, modules.RootSiaPath())
+		if err != nil {
+			return err
+		}
+		if isHiddenSiaPath(relPath) {
+			return nil
+		}
+		entry := ManifestEntry{
+			SiaPath:      relPath,
+			Size:         file.Size(),
+			MetadataHash: manifestMetadataHash(file),
+		}
+		mu.Lock()
+		entries = append(entries, entry)
+		mu.Unlock()
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SiaPath.String() < entries[j].SiaPath.String()
+	})
+	return entries, nil
+}
+
+// manifestMetadataHash returns the hash of the metadata of a file which is
+// used by Manifest. Only the fields which describe the file's content and
+// layout are hashed. Fields which differ between renters for identical files,
+// like the file's unique id, keys or timestamps, and fields which change with
+// the file's health are ignored.
+func manifestMetadataHash(file *FileNode) crypto.Hash {
+	md := file.Metadata()
+	var custom []manifestCustomMetadata
+	for key, value := range md.CustomMetadata {
+		custom = append(custom, manifestCustomMetadata{Key: key, Value: value})
+	}
+	sort.Slice(custom, func(i, j int) bool {
+		return custom[i].Key < custom[j].Key
+	})
+	return crypto.HashAll(
+		md.FileSize,
+		md.StaticPieceSize,
+		uint32(md.Mode),
+		md.StaticMasterKeyType,
+		file.ErasureCode().Identifier(),
+		custom,
+	)
+}
+
+// isHiddenSiaPath returns true if any element of siaPath starts with a '.'.
+func isHiddenSiaPath(siaPath modules.SiaPath) bool {
+	for _, elem := range strings.Split(siaPath.String(), "/") {
+		if strings.HasPrefix(elem, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffManifest compares the current files within the dir at siaPath to a
+// manifest previously created by Manifest. added contains the files which
+// exist but are missing from the manifest, removed contains the manifest's
+// entries for files which no longer exist and changed contains the files
+// whose size or metadata hash differ from their manifest entry. All entries
+// describe the current state of a file except for removed ones, and all of
+// them are sorted by SiaPath.
+func (fs *FileSystem) DiffManifest(siaPath modules.SiaPath, manifest []ManifestEntry) (added, removed, changed []ManifestEntry, err error) {
+	if err := fs.tg.Add(); err != nil {
+		return nil, nil, nil, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	current, err := fs.managedManifest(siaPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	expected := make(map[modules.SiaPath]ManifestEntry, len(manifest))
+	for _, entry := range manifest {
+		expected[entry.SiaPath] = entry
+	}
+	for _, entry := range current {
+		old, exists := expected[entry.SiaPath]
+		if !exists {
+			added = append(added, entry)
+			continue
+		}
+		delete(expected, entry.SiaPath)
+		if old.Size != entry.Size || old.MetadataHash != entry.MetadataHash {
+			changed = append(changed, entry)
+		}
+	}
+	for _, entry := range expected {
+		removed = append(removed, entry)
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].SiaPath.String() < removed[j].SiaPath.String()
+	})
+	return added, removed, changed, nil
+}
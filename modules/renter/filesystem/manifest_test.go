@@ -0,0 +1,140 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+// TestManifest tests that identical trees result in identical manifests and
+// that changing a file only changes its own entry.
+func TestManifest(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create the same tree within two filesystems. The files use different
+	// keys.
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := testDir(t.Name())
+	files := []string{"tree/b", "tree/a", "tree/dir/c", "tree/dir/sub/d", "tree/.hidden", "tree/.hdir/e", "other/f"}
+	newFS := func(name string) *FileSystem {
+		fs := newTestFileSystem(filepath.Join(root, name))
+		for i, file := range files {
+			err := fs.NewSiaFile(newSiaPath(file), "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), uint64(i*10), persist.DefaultDiskPermissionsTest, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return fs
+	}
+	fs1, fs2 := newFS("fs1"), newFS("fs2")
+
+	m1, err := fs1.Manifest(newSiaPath("tree"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := fs2.Manifest(newSiaPath("tree"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m1, m2) {
+		t.Fatal("manifests of identical trees don't match", m1, m2)
+	}
+
+	// The hidden files and files outside of the tree are excluded and the
+	// remaining ones are sorted.
+	expected := []string{"a", "b", "dir/c", "dir/sub/d"}
+	if len(m1) != len(expected) {
+		t.Fatalf("expected %v entries but got %v", len(expected), len(m1))
+	}
+	for i, entry := range m1 {
+		if entry.SiaPath.String() != expected[i] {
+			t.Fatalf("expected %v but got %v", expected[i], entry.SiaPath)
+		}
+	}
+	if m1[0].Size != 10 || m1[1].Size != 0 {
+		t.Fatal("wrong sizes", m1[0].Size, m1[1].Size)
+	}
+
+	// Change the metadata of a single file. Only its entry should change.
+	if err := fs2.SetFileMetadata(newSiaPath("tree/dir/c"), "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	m2, err = fs2.Manifest(newSiaPath("tree"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m2) != len(m1) {
+		t.Fatal("manifests have different lengths", len(m1), len(m2))
+	}
+	for i := range m1 {
+		changed := !reflect.DeepEqual(m1[i], m2[i])
+		if changed != (m1[i].SiaPath.String() == "dir/c") {
+			t.Fatal("unexpected change of entry", m1[i], m2[i])
+		}
+	}
+}
+
+// TestDiffManifest tests comparing a tree to a manifest which is missing a
+// file, contains an extra file and contains a file with a different size.
+func TestDiffManifest(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a tree.
+	fs := newTestFileSystem(filepath.Join(testDir(t.Name()), "fs-root"))
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, file := range []string{"tree/a", "tree/b", "tree/dir/c", "tree/dir/d"} {
+		err := fs.NewSiaFile(newSiaPath(file), "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), uint64(i*10), persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest, err := fs.Manifest(newSiaPath("tree"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An unchanged tree has no diff.
+	added, removed, changed, err := fs.DiffManifest(newSiaPath("tree"), manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 0 || len(removed) != 0 || len(changed) != 0 {
+		t.Fatal("unexpected diff", added, removed, changed)
+	}
+
+	// Drop "a" from the manifest, add an extra file and change the size of
+	// "dir/c".
+	extra := ManifestEntry{SiaPath: newSiaPath("extra"), Size: 1}
+	modified := []ManifestEntry{manifest[1], manifest[2], manifest[3], extra}
+	modified[1].Size++
+	added, removed, changed, err = fs.DiffManifest(newSiaPath("tree"), modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || !reflect.DeepEqual(added[0], manifest[0]) {
+		t.Fatal("wrong added entries", added)
+	}
+	if len(removed) != 1 || !reflect.DeepEqual(removed[0], extra) {
+		t.Fatal("wrong removed entries", removed)
+	}
+	if len(changed) != 1 || !reflect.DeepEqual(changed[0], manifest[2]) {
+		t.Fatal("wrong changed entries", changed)
+	}
+}
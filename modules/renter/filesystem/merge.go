@@ -0,0 +1,205 @@
+package filesystem
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// ConflictKeepDest keeps the file in the destination dir if both dirs
+	// contain a file with the same path. The source file is deleted.
+	ConflictKeepDest ConflictPolicy = iota
+
+	// ConflictKeepSrc replaces the file in the destination dir with the
+	// source file if both dirs contain a file with the same path.
+	ConflictKeepSrc
+)
+
+var (
+	// errMergeNested is returned by MergeSiaDir if one of the dirs contains
+	// the other one.
+	errMergeNested = errors.New("can't merge a dir with one of its ancestors or descendants")
+
+	// errMergeRoot is returned by MergeSiaDir if the source dir is the root.
+	errMergeRoot = errors.New("can't merge the root dir into another dir")
+
+	// errUnknownConflictPolicy is returned by MergeSiaDir if the conflict
+	// policy is unknown.
+	errUnknownConflictPolicy = errors.New("unknown conflict policy")
+)
+
+type (
+	// ConflictPolicy determines which file is kept by MergeSiaDir if both
+	// dirs contain a file with the same path.
+	ConflictPolicy int
+
+	// mergeRename is a rename performed by MergeSiaDir which needs to be
+	// reverted if the merge fails.
+	mergeRename struct {
+		from modules.SiaPath
+		to   modules.SiaPath
+	}
+)
+
+// MergeSiaDir moves the files of the dir at src and its subdirs into the dir at
+// dest and deletes src afterwards. Files which exist in both dirs are handled
+// according to onConflict. Every file is moved using RenameFile which makes
+// every single move safe against concurrent opens. If a move fails, all
+// previous moves are reverted. Empty subdirs of src are not recreated within
+// dest. Neither of the dirs may contain the other one.
+func (fs *FileSystem) MergeSiaDir(src, dest modules.SiaPath, onConflict ConflictPolicy) (err error) {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	if onConflict != ConflictKeepDest && onConflict != ConflictKeepSrc {
+		return errUnknownConflictPolicy
+	}
+	if src.IsRoot() {
+		return errMergeRoot
+	}
+	if isSiaPathAncestor(src, dest) || isSiaPathAncestor(dest, src) {
+		return errMergeNested
+	}
+	unlock, err := fs.managedLockWritable(src, dest)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for _, sp := range []modules.SiaPath{src, dest} {
+		exists, err := fs.managedDirExists(sp)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.AddContext(ErrNotExist, sp.String())
+		}
+	}
+
+	// Collect the files to move. They are sorted to make the merge
+	// deterministic.
+	var files []modules.SiaPath
+	err = fs.managedWalkFilesParallel(src, 1, func(siaPath modules.SiaPath, _ *FileNode) error {
+		files = append(files, siaPath)
+		return nil
+	}, nil)
+	if err != nil {
+		return errors.AddContext(err, "failed to collect files to merge")
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].String() < files[j].String()
+	})
+
+	// Move the files. Dest files which are replaced are renamed to a
+	// temporary path first and only deleted once all files were moved
+	// successfully.
+	var renames []mergeRename
+	var replaced []modules.SiaPath
+	var createdDirs []modules.SiaPath
+	defer func() {
+		if err == nil {
+			return
+		}
+		// Revert the renames in reverse order and delete the dirs which
+		// were created by the merge.
+		for i := len(renames) - 1; i >= 0; i-- {
+			err = errors.Compose(err, fs.managedRenameSiaFile(renames[i].to, renames[i].from))
+		}
+		for _, dir := range createdDirs {
+			err = errors.Compose(err, fs.managedDeleteSiaDir(dir))
+		}
+	}()
+	for _, srcFile := range files {
+		destFile, err := srcFile.Rebase(src, dest)
+		if err != nil {
+			return err
+		}
+		exists, err := fs.managedFileExists(destFile)
+		if err != nil {
+			return err
+		}
+		if exists && onConflict == ConflictKeepDest {
+			continue // src file is deleted together with src
+		}
+		if exists {
+			tmp, err := fs.managedMergeTempPath(destFile)
+			if err != nil {
+				return err
+			}
+			if err := fs.managedRenameSiaFile(destFile, tmp); err != nil {
+				return errors.AddContext(err, fmt.Sprintf("failed to move %v out of the way", destFile))
+			}
+			renames = append(renames, mergeRename{from: destFile, to: tmp})
+			replaced = append(replaced, tmp)
+		}
+		// Remember the topmost dir which doesn't exist yet to be able to
+		// delete it on failure.
+		missingDir, err := fs.managedTopmostMissingDir(destFile)
+		if err != nil {
+			return err
+		}
+		if err := fs.managedRenameSiaFile(srcFile, destFile); err != nil {
+			return errors.AddContext(err, fmt.Sprintf("failed to move %v to %v", srcFile, destFile))
+		}
+		renames = append(renames, mergeRename{from: srcFile, to: destFile})
+		if !missingDir.IsEmpty() {
+			createdDirs = append(createdDirs, missingDir)
+		}
+	}
+
+	// All files were moved. Delete the replaced files and the source dir.
+	// From this point on the merge can't be reverted anymore.
+	var deleteErr error
+	for _, tmp := range replaced {
+		deleteErr = errors.Compose(deleteErr, fs.managedDeleteSiaFile(tmp))
+	}
+	deleteErr = errors.Compose(deleteErr, fs.managedDeleteSiaDir(src))
+	return errors.AddContext(deleteErr, "merge succeeded but cleaning up failed")
+}
+
+// managedMergeTempPath returns an unused path next to siaPath.
+func (fs *FileSystem) managedMergeTempPath(siaPath modules.SiaPath) (modules.SiaPath, error) {
+	for {
+		tmp := siaPath.AddSuffix(uint(fastrand.Uint64n(1 << 32)))
+		exists, err := fs.managedFileExists(tmp)
+		if err != nil {
+			return modules.SiaPath{}, err
+		}
+		if !exists {
+			return tmp, nil
+		}
+	}
+}
+
+// managedTopmostMissingDir returns the topmost ancestor dir of siaPath which
+// doesn't exist. If all of them exist, an empty SiaPath is returned.
+func (fs *FileSystem) managedTopmostMissingDir(siaPath modules.SiaPath) (modules.SiaPath, error) {
+	var missing modules.SiaPath
+	dir, err := siaPath.Dir()
+	for err == nil && !dir.IsRoot() {
+		exists, existsErr := fs.managedDirExists(dir)
+		if existsErr != nil {
+			return modules.SiaPath{}, existsErr
+		}
+		if exists {
+			break
+		}
+		missing = dir
+		dir, err = dir.Dir()
+	}
+	return missing, err
+}
+
+// isSiaPathAncestor returns true if ancestor is an ancestor of or equal to
+// siaPath.
+func isSiaPathAncestor(ancestor, siaPath modules.SiaPath) bool {
+	if ancestor.IsRoot() || ancestor.Equals(siaPath) {
+		return true
+	}
+	return strings.HasPrefix(siaPath.String(), ancestor.String()+"/")
+}
@@ -0,0 +1,195 @@
+package filesystem
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+)
+
+// TestMergeSiaDir tests merging dirs with and without collisions under both
+// conflict policies.
+func TestMergeSiaDir(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// uid is a helper to get the UID of a file.
+	uid := func(fs *FileSystem, sp modules.SiaPath) siafile.SiafileUID {
+		t.Helper()
+		sf, err := fs.OpenSiaFile(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := sf.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		return sf.UID()
+	}
+
+	// setup creates a filesystem with a src and dest dir. Both dirs contain a
+	// unique file and if collide is true also a file with the same path.
+	setup := func(name string, collide bool) (*FileSystem, map[string]siafile.SiafileUID) {
+		root := filepath.Join(testDir(t.Name()), name, "fs-root")
+		fs := newTestFileSystem(root)
+		paths := []string{"src/a", "src/sub/b", "dest/c"}
+		if collide {
+			paths = append(paths, "src/sub/conflict", "dest/sub/conflict")
+		}
+		uids := make(map[string]siafile.SiafileUID)
+		for _, path := range paths {
+			sp := newSiaPath(path)
+			fs.addTestSiaFile(sp)
+			uids[path] = uid(fs, sp)
+		}
+		return fs, uids
+	}
+
+	// checkMerged checks that the merge moved the files and deleted src.
+	checkMerged := func(fs *FileSystem, uids map[string]siafile.SiafileUID, expected map[string]string) {
+		t.Helper()
+		for destPath, srcPath := range expected {
+			if uid(fs, newSiaPath(destPath)) != uids[srcPath] {
+				t.Fatalf("%v doesn't contain %v", destPath, srcPath)
+			}
+		}
+		exists, err := fs.DirExists(newSiaPath("src"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Fatal("src should have been deleted")
+		}
+		var n int
+		err = fs.WalkFilesParallel(newSiaPath("dest"), 1, func(modules.SiaPath, *FileNode) error {
+			n++
+			return nil
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(expected) {
+			t.Fatalf("expected %v files in dest but got %v", len(expected), n)
+		}
+	}
+
+	// Merge without collisions. The policy doesn't matter.
+	for i, policy := range []ConflictPolicy{ConflictKeepDest, ConflictKeepSrc} {
+		fs, uids := setup(fmt.Sprint("nocollision", i), false)
+		if err := fs.MergeSiaDir(newSiaPath("src"), newSiaPath("dest"), policy); err != nil {
+			t.Fatal(err)
+		}
+		checkMerged(fs, uids, map[string]string{
+			"dest/a":     "src/a",
+			"dest/sub/b": "src/sub/b",
+			"dest/c":     "dest/c",
+		})
+	}
+
+	// Merge with collisions keeping the dest file.
+	fs, uids := setup("keepdest", true)
+	if err := fs.MergeSiaDir(newSiaPath("src"), newSiaPath("dest"), ConflictKeepDest); err != nil {
+		t.Fatal(err)
+	}
+	checkMerged(fs, uids, map[string]string{
+		"dest/a":            "src/a",
+		"dest/sub/b":        "src/sub/b",
+		"dest/c":            "dest/c",
+		"dest/sub/conflict": "dest/sub/conflict",
+	})
+
+	// Merge with collisions keeping the src file.
+	fs, uids = setup("keepsrc", true)
+	if err := fs.MergeSiaDir(newSiaPath("src"), newSiaPath("dest"), ConflictKeepSrc); err != nil {
+		t.Fatal(err)
+	}
+	checkMerged(fs, uids, map[string]string{
+		"dest/a":            "src/a",
+		"dest/sub/b":        "src/sub/b",
+		"dest/c":            "dest/c",
+		"dest/sub/conflict": "src/sub/conflict",
+	})
+
+	// Invalid merges.
+	fs, _ = setup("invalid", false)
+	if err := fs.MergeSiaDir(newSiaPath("src"), newSiaPath("src/sub"), ConflictKeepSrc); !errors.Contains(err, errMergeNested) {
+		t.Fatal("expected errMergeNested", err)
+	}
+	if err := fs.MergeSiaDir(newSiaPath("src/sub"), newSiaPath("src"), ConflictKeepSrc); !errors.Contains(err, errMergeNested) {
+		t.Fatal("expected errMergeNested", err)
+	}
+	if err := fs.MergeSiaDir(modules.RootSiaPath(), newSiaPath("dest"), ConflictKeepSrc); !errors.Contains(err, errMergeRoot) {
+		t.Fatal("expected errMergeRoot", err)
+	}
+	if err := fs.MergeSiaDir(newSiaPath("missing"), newSiaPath("dest"), ConflictKeepSrc); !errors.Contains(err, ErrNotExist) {
+		t.Fatal("expected ErrNotExist", err)
+	}
+	if err := fs.MergeSiaDir(newSiaPath("src"), newSiaPath("dest"), ConflictPolicy(2)); !errors.Contains(err, errUnknownConflictPolicy) {
+		t.Fatal("expected errUnknownConflictPolicy", err)
+	}
+}
+
+// TestMergeSiaDirRollback tests that a failed merge is reverted.
+func TestMergeSiaDirRollback(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create a src dir with a few files. The last file collides with a dir
+	// in dest which causes the merge to fail after the other files were
+	// moved.
+	srcFiles := []string{"src/a", "src/new/b", "src/x"}
+	for _, path := range srcFiles {
+		fs.addTestSiaFile(newSiaPath(path))
+	}
+	fs.addTestSiaFile(newSiaPath("dest/x/c"))
+
+	if err := fs.MergeSiaDir(newSiaPath("src"), newSiaPath("dest"), ConflictKeepSrc); err == nil {
+		t.Fatal("merge should fail")
+	}
+
+	// All files should be back in src.
+	for _, path := range srcFiles {
+		exists, err := fs.FileExists(newSiaPath(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Fatalf("%v wasn't restored", path)
+		}
+	}
+	// Dest should be unchanged.
+	for _, path := range []string{"dest/a", "dest/new/b"} {
+		exists, err := fs.FileExists(newSiaPath(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Fatalf("%v wasn't removed", path)
+		}
+	}
+	exists, err := fs.DirExists(newSiaPath("dest/new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("dir created by the merge wasn't removed")
+	}
+	exists, err = fs.FileExists(newSiaPath("dest/x/c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("dest file is missing")
+	}
+}
@@ -0,0 +1,327 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siadir"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+)
+
+const (
+	// fileMigrationVersionKey is the custom metadata key which stores the
+	// version of the last migration applied by MigrateAll.
+	fileMigrationVersionKey = "siad-migration-version"
+
+	// metadataVersionField is the name of the version field within the json
+	// encoded metadata of dirs and files.
+	metadataVersionField = "version"
+)
+
+var (
+	// fileMigrationDelay is the time MigrateAll waits after migrating a file
+	// to avoid starving foreground work of IO.
+	fileMigrationDelay = build.Select(build.Var{
+		Dev:      10 * time.Millisecond,
+		Standard: 50 * time.Millisecond,
+		Testnet:  50 * time.Millisecond,
+		Testing:  time.Millisecond,
+	}).(time.Duration)
+
+	// fileMigrationLogInterval is the number of files after which MigrateAll
+	// logs its progress.
+	fileMigrationLogInterval = build.Select(build.Var{
+		Dev:      100,
+		Standard: 1000,
+		Testnet:  1000,
+		Testing:  10,
+	}).(int)
+)
+
+var (
+	// errMigrationExists is returned when registering a migration for a
+	// version that already has one.
+	errMigrationExists = errors.New("a migration for this version is already registered")
+
+	// errMigrationCycle is returned if the registered migrations result in a
+	// cycle.
+	errMigrationCycle = errors.New("migrations result in a cycle")
+
+	// errEmptyMigrationVersion is returned by MigrateAll if no version is
+	// provided.
+	errEmptyMigrationVersion = errors.New("migration version can't be empty")
+
+	// errFileVersionTooLong is returned when registering a file migration
+	// with a version that doesn't fit the version field of a file.
+	errFileVersionTooLong = errors.New("file versions can't be longer than 16 bytes")
+)
+
+type (
+	// RawMetadata is the json encoded metadata of a dir or file by field
+	// name. Version migrations operate on the raw metadata since metadata of
+	// an older version might not be parsable by the current version.
+	RawMetadata map[string]json.RawMessage
+
+	// RawMetadataMigration upgrades the raw metadata of a dir or file from one
+	// version to the next one. The version field is updated by the
+	// FileSystem.
+	RawMetadataMigration func(md RawMetadata) error
+
+	// FileMetadataMigration migrates the metadata of a file. It is applied by
+	// MigrateAll.
+	FileMetadataMigration func(siaPath modules.SiaPath, md *siafile.Metadata) error
+
+	// MigrationReport is the result of MigrateAll.
+	MigrationReport struct {
+		// Migrated is the number of files which were migrated and Skipped the
+		// number of files which were already migrated by a previous run.
+		Migrated uint64
+		Skipped  uint64
+
+		// Failed contains the errors of the files which failed to migrate.
+		Failed map[modules.SiaPath]error
+	}
+
+	// versionMigration is a registered RawMetadataMigration and the version
+	// it upgrades to.
+	versionMigration struct {
+		toVersion string
+		migrate   RawMetadataMigration
+	}
+
+	// metadataMigrations contains the version migrations of a FileSystem by
+	// the version they upgrade from.
+	metadataMigrations struct {
+		dirMigrations  map[string]versionMigration
+		fileMigrations map[string]versionMigration
+		mu             sync.Mutex
+	}
+)
+
+// newMetadataMigrations creates an empty set of migrations.
+func newMetadataMigrations() *metadataMigrations {
+	return &metadataMigrations{
+		dirMigrations:  make(map[string]versionMigration),
+		fileMigrations: make(map[string]versionMigration),
+	}
+}
+
+// RegisterDirMigration registers a migration which upgrades the metadata of
+// dirs from fromVersion to toVersion. Migrations are applied lazily before a
+// dir's metadata is parsed for the first time. Multiple migrations are chained
+// if the upgraded version has a migration as well. The upgraded metadata,
+// including the new version, is persisted atomically before the dir is used.
+func (fs *FileSystem) RegisterDirMigration(fromVersion, toVersion string, migrate RawMetadataMigration) error {
+	return fs.staticMigrations.managedRegister(fs.staticMigrations.dirMigrations, fromVersion, toVersion, migrate)
+}
+
+// RegisterFileMigration works like RegisterDirMigration but registers a
+// migration for the metadata of files. File versions can't be longer than 16
+// bytes.
+func (fs *FileSystem) RegisterFileMigration(fromVersion, toVersion string, migrate RawMetadataMigration) error {
+	if len(fromVersion) > len(siafile.Metadata{}.StaticVersion) || len(toVersion) > len(siafile.Metadata{}.StaticVersion) {
+		return errFileVersionTooLong
+	}
+	return fs.staticMigrations.managedRegister(fs.staticMigrations.fileMigrations, fromVersion, toVersion, migrate)
+}
+
+// managedRegister adds a migration to the provided migrations.
+func (mm *metadataMigrations) managedRegister(migrations map[string]versionMigration, fromVersion, toVersion string, migrate RawMetadataMigration) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if _, exists := migrations[fromVersion]; exists {
+		return errMigrationExists
+	}
+	migrations[fromVersion] = versionMigration{
+		toVersion: toVersion,
+		migrate:   migrate,
+	}
+	return nil
+}
+
+// managedChain returns the migrations which upgrade metadata of the provided
+// version in the order they need to be applied.
+func (mm *metadataMigrations) managedChain(migrations map[string]versionMigration, version string) ([]versionMigration, error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	var chain []versionMigration
+	visited := make(map[string]struct{})
+	for {
+		m, exists := migrations[version]
+		if !exists {
+			return chain, nil
+		}
+		if _, cycle := visited[version]; cycle {
+			return nil, errMigrationCycle
+		}
+		visited[version] = struct{}{}
+		chain = append(chain, m)
+		version = m.toVersion
+	}
+}
+
+// managedEmpty returns whether no migrations were registered for the provided
+// migrations.
+func (mm *metadataMigrations) managedEmpty(migrations map[string]versionMigration) bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return len(migrations) == 0
+}
+
+// managedMigrateDir applies all the migrations which apply to the metadata of
+// the dir at path. It needs to be called before the metadata is parsed.
+func (mm *metadataMigrations) managedMigrateDir(path string, deps modules.Dependencies) error {
+	if mm.managedEmpty(mm.dirMigrations) {
+		return nil // nothing to do
+	}
+	return siadir.MigrateRawMetadata(path, deps, func(raw []byte) ([]byte, error) {
+		return mm.managedMigrateRaw(mm.dirMigrations, raw, decodeDirVersion, encodeDirVersion)
+	})
+}
+
+// managedMigrateFile applies all the migrations which apply to the metadata
+// of the file at path. It needs to be called before the file is loaded.
+func (mm *metadataMigrations) managedMigrateFile(path string, wal *writeaheadlog.WAL, deps modules.Dependencies) error {
+	if mm.managedEmpty(mm.fileMigrations) {
+		return nil // nothing to do
+	}
+	return siafile.MigrateRawMetadata(path, wal, deps, func(raw []byte) ([]byte, error) {
+		return mm.managedMigrateRaw(mm.fileMigrations, raw, decodeFileVersion, encodeFileVersion)
+	})
+}
+
+// managedMigrateRaw applies the chain of migrations for the version of the
+// raw metadata. If no migration applies, nil is returned.
+func (mm *metadataMigrations) managedMigrateRaw(migrations map[string]versionMigration, raw []byte, decode func(json.RawMessage) (string, error), encode func(string) (json.RawMessage, error)) ([]byte, error) {
+	var md RawMetadata
+	if err := json.Unmarshal(raw, &md); err != nil {
+		return nil, errors.AddContext(err, "failed to unmarshal raw metadata")
+	}
+	version, err := decode(md[metadataVersionField])
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to decode version")
+	}
+	chain, err := mm.managedChain(migrations, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, nil // nothing to do
+	}
+	for _, m := range chain {
+		if err := m.migrate(md); err != nil {
+			return nil, errors.AddContext(err, "migration from version "+version+" failed")
+		}
+		version = m.toVersion
+		md[metadataVersionField], err = encode(version)
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to encode version")
+		}
+	}
+	return json.Marshal(md)
+}
+
+// decodeDirVersion decodes the version field of a dir's raw metadata.
+func decodeDirVersion(raw json.RawMessage) (string, error) {
+	var version string
+	if len(raw) == 0 {
+		return version, nil
+	}
+	err := json.Unmarshal(raw, &version)
+	return version, err
+}
+
+// encodeDirVersion encodes the version field of a dir's raw metadata.
+func encodeDirVersion(version string) (json.RawMessage, error) {
+	return json.Marshal(version)
+}
+
+// decodeFileVersion decodes the version field of a file's raw metadata.
+func decodeFileVersion(raw json.RawMessage) (string, error) {
+	var version [16]byte
+	if len(raw) == 0 {
+		return "", nil
+	}
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(version[:], "\x00")), nil
+}
+
+// encodeFileVersion encodes the version field of a file's raw metadata.
+func encodeFileVersion(version string) (json.RawMessage, error) {
+	var v [16]byte
+	if len(version) > len(v) {
+		return nil, errFileVersionTooLong
+	}
+	copy(v[:], version)
+	return json.Marshal(v)
+}
+
+// MigrateAll applies migrate to the metadata of every file within the
+// filesystem. Every file is migrated atomically together with a marker
+// containing version. Files which already carry the marker are skipped which
+// makes it possible to resume an interrupted migration by calling MigrateAll
+// with the same version again. Files which fail to migrate are reported and
+// don't stop the migration. To avoid starving foreground work, MigrateAll
+// waits for a short time after every migrated file. If progress isn't nil, it
+// is called periodically with the number of processed files.
+func (fs *FileSystem) MigrateAll(version string, migrate FileMetadataMigration, progress ProgressFunc) (MigrationReport, error) {
+	if version == "" {
+		return MigrationReport{}, errEmptyMigrationVersion
+	}
+	if err := fs.tg.Add(); err != nil {
+		return MigrationReport{}, err
+	}
+	defer fs.tg.Done()
+
+	report := MigrationReport{
+		Failed: make(map[modules.SiaPath]error),
+	}
+	var processed int
+	err := fs.managedWalkFilesParallel(modules.RootSiaPath(), 1, func(siaPath modules.SiaPath, file *FileNode) error {
+		processed++
+		if processed%fileMigrationLogInterval == 0 {
+			fs.staticLog.Printf("INFO: migration to version %v processed %v files, %v migrated, %v skipped, %v failed", version, processed, report.Migrated, report.Skipped, len(report.Failed))
+		}
+		if v, _ := file.CustomMetadata(fileMigrationVersionKey); v == version {
+			report.Skipped++
+			return nil
+		}
+		err := file.MigrateMetadata(func(md *siafile.Metadata) error {
+			if err := migrate(siaPath, md); err != nil {
+				return err
+			}
+			cm := make(map[string]string, len(md.CustomMetadata)+1)
+			for k, v := range md.CustomMetadata {
+				cm[k] = v
+			}
+			cm[fileMigrationVersionKey] = version
+			md.CustomMetadata = cm
+			return nil
+		})
+		if err != nil {
+			report.Failed[siaPath] = err
+			fs.staticLog.Printf("WARN: failed to migrate file '%v' to version %v: %v", siaPath, version, err)
+		} else {
+			report.Migrated++
+		}
+		select {
+		case <-fs.tg.StopChan():
+			return errors.New("migration interrupted by shutdown")
+		case <-time.After(fileMigrationDelay):
+		}
+		return nil
+	}, progress)
+	if err != nil {
+		return report, errors.AddContext(err, "failed to migrate all files")
+	}
+	fs.staticLog.Printf("INFO: migration to version %v finished, %v migrated, %v skipped, %v failed", version, report.Migrated, report.Skipped, len(report.Failed))
+	return report, nil
+}
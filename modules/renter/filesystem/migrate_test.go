@@ -0,0 +1,341 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siadir"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/persist"
+)
+
+// rewriteDirMetadata is a helper which applies rewrite to the raw json
+// encoded metadata of the dir at siaPath and writes it back to disk with a
+// valid checksum. The metadata isn't parsed, so rewrite can produce metadata
+// which the current version can't parse.
+func rewriteDirMetadata(fs *FileSystem, siaPath modules.SiaPath, rewrite func(md RawMetadata)) error {
+	mdPath := filepath.Join(fs.DirPath(siaPath), modules.SiaDirExtension)
+	fileBytes, err := ioutil.ReadFile(mdPath)
+	if err != nil {
+		return err
+	}
+	var md RawMetadata
+	if err := json.Unmarshal(fileBytes[crypto.HashSize:], &md); err != nil {
+		return err
+	}
+	rewrite(md)
+	mdBytes, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	checksum := crypto.HashBytes(mdBytes)
+	return ioutil.WriteFile(mdPath, append(checksum[:], mdBytes...), persist.DefaultDiskPermissionsTest)
+}
+
+// TestDirMigration tests that opening a dir with an old metadata version
+// migrates the metadata before it is parsed.
+func TestDirMigration(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create a dir and remember the current version.
+	sp := newSiaPath("dir")
+	if err := fs.NewSiaDir(sp, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	dn, err := fs.OpenSiaDir(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := dn.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentVersion := md.Version
+	if err := dn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate an old version of the metadata which stored the quota as a
+	// string. The current version can't parse that.
+	err = rewriteDirMetadata(fs, sp, func(md RawMetadata) {
+		md["version"] = json.RawMessage(`"0.8"`)
+		md["quota"] = json.RawMessage(`"42"`)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := siadir.LoadSiaDir(fs.DirPath(sp), modules.ProdDependencies); err == nil {
+		t.Fatal("old metadata shouldn't be parsable")
+	}
+
+	// Register migrations from 0.8 to 0.9 and from 0.9 to the current
+	// version. The second one converts the quota.
+	var numMigrations int
+	err = fs.RegisterDirMigration("0.8", "0.9", func(md RawMetadata) error {
+		numMigrations++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fs.RegisterDirMigration("0.9", currentVersion, func(md RawMetadata) error {
+		numMigrations++
+		var quota string
+		if err := json.Unmarshal(md["quota"], &quota); err != nil {
+			return err
+		}
+		md["quota"] = json.RawMessage(quota)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Registering a migration for the same version again should fail.
+	err = fs.RegisterDirMigration("0.9", currentVersion, func(md RawMetadata) error {
+		return nil
+	})
+	if !errors.Contains(err, errMigrationExists) {
+		t.Fatal("expected errMigrationExists but got", err)
+	}
+
+	// Opening the dir should run both migrations.
+	dn, err = fs.OpenSiaDir(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err = dn.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if numMigrations != 2 {
+		t.Fatal("expected 2 migrations but got", numMigrations)
+	}
+	if md.Version != currentVersion || md.Quota != 42 {
+		t.Fatal("metadata wasn't migrated", md.Version, md.Quota)
+	}
+
+	// The migrated metadata should be on disk.
+	sd, err := siadir.LoadSiaDir(fs.DirPath(sp), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md := sd.Metadata(); md.Version != currentVersion || md.Quota != 42 {
+		t.Fatal("migrated metadata wasn't persisted", md.Version, md.Quota)
+	}
+
+	// Opening the dir again shouldn't run the migrations again.
+	dn, err = fs.OpenSiaDir(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dn.Metadata(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if numMigrations != 2 {
+		t.Fatal("migrations shouldn't run again", numMigrations)
+	}
+}
+
+// TestFileMigration tests that opening a file with an old metadata version
+// migrates the metadata before the file is loaded.
+func TestFileMigration(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create a file. Files created by the current version have an empty
+	// version.
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := newSiaPath("dir/file")
+	err = fs.NewSiaFile(sp, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 10, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Versions that don't fit the version field are rejected.
+	err = fs.RegisterFileMigration("", "a version which is too long", func(md RawMetadata) error {
+		return nil
+	})
+	if !errors.Contains(err, errFileVersionTooLong) {
+		t.Fatal("expected errFileVersionTooLong but got", err)
+	}
+
+	// Register a migration which sets the local path. The local path is longer
+	// than a page which requires moving the pubKeyTable and the chunks.
+	localPath := strings.Repeat("a", 8192)
+	var numMigrations int
+	err = fs.RegisterFileMigration("", "v2", func(md RawMetadata) error {
+		numMigrations++
+		raw, err := json.Marshal(localPath)
+		md["localpath"] = raw
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening the file should run the migration.
+	sf, err := fs.OpenSiaFile(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if numMigrations != 1 {
+		t.Fatal("expected 1 migration but got", numMigrations)
+	}
+	if sf.LocalPath() != localPath {
+		t.Fatal("metadata wasn't migrated")
+	}
+
+	// The migrated metadata should be on disk and the file should still be
+	// intact.
+	md, err := siafile.LoadSiaFileMetadata(fs.FilePath(sp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version [16]byte
+	copy(version[:], "v2")
+	if md.StaticVersion != version || md.LocalPath != localPath {
+		t.Fatal("migrated metadata wasn't persisted", md.StaticVersion)
+	}
+	sf, err = fs.OpenSiaFile(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sf.Pieces(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if numMigrations != 1 {
+		t.Fatal("migration shouldn't run again", numMigrations)
+	}
+}
+
+// TestMigrateAll tests that MigrateAll migrates every file and that an
+// interrupted migration can be resumed.
+func TestMigrateAll(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Create some files in different dirs.
+	ec, err := modules.NewRSSubCode(10, 20, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siaPaths := []modules.SiaPath{
+		newSiaPath("file1"),
+		newSiaPath("dir1/file2"),
+		newSiaPath("dir1/file3"),
+		newSiaPath("dir1/dir2/file4"),
+	}
+	for _, sp := range siaPaths {
+		err := fs.NewSiaFile(sp, "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 10, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The migration bumps the metadata version of every file. It fails for
+	// one of the files to simulate an interrupted migration.
+	failPath := siaPaths[2]
+	errFail := errors.New("migration failed")
+	bumpVersion := func(fail bool) FileMetadataMigration {
+		return func(sp modules.SiaPath, md *siafile.Metadata) error {
+			if md.CustomMetadata == nil {
+				md.CustomMetadata = make(map[string]string)
+			}
+			md.CustomMetadata["format"] = "2"
+			if fail && sp.Equals(failPath) {
+				return errFail
+			}
+			return nil
+		}
+	}
+	// assertVersion checks the version of the file on disk.
+	assertVersion := func(sp modules.SiaPath, version string) {
+		t.Helper()
+		sf, err := fs.OpenSiaFile(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := sf.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		if v, _ := sf.CustomMetadata("format"); v != version {
+			t.Fatalf("%v: expected version '%v' but got '%v'", sp, version, v)
+		}
+	}
+
+	// Run the failing migration.
+	report, err := fs.MigrateAll("v2", bumpVersion(true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Migrated != 3 || report.Skipped != 0 || len(report.Failed) != 1 {
+		t.Fatal("wrong report", report)
+	}
+	if err := report.Failed[failPath]; !errors.Contains(err, errFail) {
+		t.Fatal("expected errFail but got", err)
+	}
+	for _, sp := range siaPaths {
+		if sp.Equals(failPath) {
+			assertVersion(sp, "")
+		} else {
+			assertVersion(sp, "2")
+		}
+	}
+
+	// Resume the migration. Only the failed file should be migrated.
+	report, err = fs.MigrateAll("v2", bumpVersion(false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Migrated != 1 || report.Skipped != 3 || len(report.Failed) != 0 {
+		t.Fatal("wrong report", report)
+	}
+	for _, sp := range siaPaths {
+		assertVersion(sp, "2")
+	}
+
+	// An empty version is invalid.
+	if _, err := fs.MigrateAll("", bumpVersion(false), nil); !errors.Contains(err, errEmptyMigrationVersion) {
+		t.Fatal("expected errEmptyMigrationVersion but got", err)
+	}
+}
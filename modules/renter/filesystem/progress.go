@@ -0,0 +1,74 @@
+package filesystem
+
+import (
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/build"
+)
+
+var (
+	// progressReportInterval is the minimum time between two reports of a
+	// ProgressFunc. The first and the final report are always made.
+	progressReportInterval = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: 5 * time.Second,
+		Testnet:  5 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+)
+
+type (
+	// ProgressFunc is called periodically by long running operations of the
+	// FileSystem. processed is the number of files processed so far and total
+	// an estimate of the number of files the operation will process. A total
+	// of 0 means that the total is unknown. The estimate is based on the
+	// bubbled metadata and might be off if the metadata is outdated. Calls of
+	// a ProgressFunc are never concurrent.
+	ProgressFunc func(processed, total uint64)
+
+	// progressReporter throttles the calls of a ProgressFunc.
+	progressReporter struct {
+		processed  uint64
+		lastReport time.Time
+
+		staticTotal uint64
+		staticFn    ProgressFunc
+		mu          sync.Mutex
+	}
+)
+
+// newProgressReporter creates a reporter for fn. If fn is nil, the reporter
+// doesn't report anything.
+func newProgressReporter(fn ProgressFunc, total uint64) *progressReporter {
+	return &progressReporter{
+		staticTotal: total,
+		staticFn:    fn,
+	}
+}
+
+// managedProcessed records that a file was processed and reports the progress
+// if the last report is old enough.
+func (pr *progressReporter) managedProcessed() {
+	if pr.staticFn == nil {
+		return
+	}
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.processed++
+	if time.Since(pr.lastReport) < progressReportInterval {
+		return
+	}
+	pr.lastReport = time.Now()
+	pr.staticFn(pr.processed, pr.staticTotal)
+}
+
+// managedDone reports the final progress.
+func (pr *progressReporter) managedDone() {
+	if pr.staticFn == nil {
+		return
+	}
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.staticFn(pr.processed, pr.staticTotal)
+}
@@ -0,0 +1,102 @@
+package filesystem
+
+import (
+	"fmt"
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+type (
+	// quotaReservations tracks the bytes of the files which are currently
+	// being created within the sub trees of dirs with a quota.
+	quotaReservations struct {
+		reserved map[modules.SiaPath]uint64
+		mu       sync.Mutex
+	}
+)
+
+// newQuotaReservations creates an empty quotaReservations object.
+func newQuotaReservations() *quotaReservations {
+	return &quotaReservations{
+		reserved: make(map[modules.SiaPath]uint64),
+	}
+}
+
+// SetDirSoftQuota sets the soft quota of the directory at siaPath. The quota
+// limits the number of bytes the files within the directory's sub tree may
+// hold. Every quota of a directory and its ancestors applies. A quota of 0
+// removes the quota.
+//
+// The quota is soft since the size of a sub tree is taken from its bubbled
+// AggregateSize. Files which were created since the last bubble aren't
+// accounted for, which allows for exceeding the quota until the next bubble.
+func (fs *FileSystem) SetDirSoftQuota(siaPath modules.SiaPath, quota uint64) (err error) {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	dir, err := fs.managedOpenSiaDir(siaPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Compose(err, dir.Close())
+	}()
+	return dir.SetQuota(quota)
+}
+
+// managedReserveQuota checks whether adding a file of the given size to the
+// directory at siaPath would exceed the soft quota of the directory or any of
+// its ancestors. The size is counted against the quotas until the returned
+// function is called, which prevents concurrent creations from exceeding a
+// quota together.
+func (fs *FileSystem) managedReserveQuota(siaPath modules.SiaPath, size uint64) (func(), error) {
+	qr := fs.staticQuotaReservations
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+
+	// Check the quotas of all the dirs from siaPath up to the root.
+	var quotaDirs []modules.SiaPath
+	for {
+		dir, err := fs.managedOpenSiaDir(siaPath)
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to open dir to check quota")
+		}
+		md, err := dir.Metadata()
+		err = errors.Compose(err, dir.Close())
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to get metadata to check quota")
+		}
+		if md.Quota > 0 {
+			used := md.AggregateSize + qr.reserved[siaPath]
+			if used+size > md.Quota {
+				return nil, errors.AddContext(ErrQuotaExceeded, fmt.Sprintf("dir '%v' has a quota of %v bytes and holds %v bytes", siaPath, md.Quota, used))
+			}
+			quotaDirs = append(quotaDirs, siaPath)
+		}
+		if siaPath.IsRoot() {
+			break
+		}
+		siaPath, err = siaPath.Dir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Reserve the size.
+	for _, sp := range quotaDirs {
+		qr.reserved[sp] += size
+	}
+	return func() {
+		qr.mu.Lock()
+		defer qr.mu.Unlock()
+		for _, sp := range quotaDirs {
+			qr.reserved[sp] -= size
+			if qr.reserved[sp] == 0 {
+				delete(qr.reserved, sp)
+			}
+		}
+	}, nil
+}
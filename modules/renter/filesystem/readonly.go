@@ -0,0 +1,224 @@
+package filesystem
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siadir"
+)
+
+type (
+	// readOnlyFlags caches the read-only flags of dirs and makes sure that a
+	// flag can't change while a write within the dir's sub tree is in
+	// progress.
+	readOnlyFlags struct {
+		// cached contains the flags of the dirs which were checked before.
+		// The flags of dirs which aren't cached are loaded from disk.
+		cached map[modules.SiaPath]bool
+
+		// generation is incremented whenever cached flags are dropped. A
+		// flag which was loaded from disk is only cached if the generation
+		// didn't change while loading it. That way a concurrent drop can't
+		// be undone by a stale flag.
+		generation uint64
+		cacheMu    sync.Mutex
+
+		// mu is read-locked by writes for their whole duration and locked by
+		// changes of the flags.
+		mu sync.RWMutex
+	}
+)
+
+// newReadOnlyFlags creates an empty readOnlyFlags object.
+func newReadOnlyFlags() *readOnlyFlags {
+	return &readOnlyFlags{
+		cached: make(map[modules.SiaPath]bool),
+	}
+}
+
+// managedDrop removes the cached flags of the dirs at siaPaths and of all of
+// their descendants. It is called after dirs were deleted or renamed.
+func (ro *readOnlyFlags) managedDrop(siaPaths ...modules.SiaPath) {
+	ro.cacheMu.Lock()
+	defer ro.cacheMu.Unlock()
+	for sp := range ro.cached {
+		for _, dropped := range siaPaths {
+			if dropped.IsRoot() || sp.Equals(dropped) || strings.HasPrefix(sp.String(), dropped.String()+"/") {
+				delete(ro.cached, sp)
+				break
+			}
+		}
+	}
+	ro.generation++
+}
+
+// managedSet caches the flag of the dir at siaPath.
+func (ro *readOnlyFlags) managedSet(siaPath modules.SiaPath, readOnly bool) {
+	ro.cacheMu.Lock()
+	defer ro.cacheMu.Unlock()
+	ro.cached[siaPath] = readOnly
+}
+
+// SetDirReadOnly sets the read-only flag of the directory at siaPath. While it
+// is set, files and directories can't be created, renamed or deleted within
+// the directory's sub tree. Reading is still possible. Setting the flag waits
+// for the writes which are currently in progress.
+func (fs *FileSystem) SetDirReadOnly(siaPath modules.SiaPath, readOnly bool) (err error) {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedUpdateReadOnly(siaPath, func(dir *DirNode) error {
+		return dir.SetReadOnly(readOnly)
+	})
+}
+
+// UpdateDirMetadata updates the metadata of a SiaDir. Only updates which change
+// the read-only flag wait for the writes which are currently in progress.
+func (fs *FileSystem) UpdateDirMetadata(siaPath modules.SiaPath, metadata siadir.Metadata) (err error) {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+
+	// If the flag doesn't change, the update can run concurrently with
+	// writes. Holding the read lock prevents the flag from changing in
+	// between.
+	ro := fs.staticReadOnlyFlags
+	ro.mu.RLock()
+	readOnly, err := fs.managedReadOnly(siaPath)
+	if err != nil {
+		ro.mu.RUnlock()
+		return err
+	}
+	if readOnly == metadata.ReadOnly {
+		defer ro.mu.RUnlock()
+		dir, err := fs.managedOpenSiaDir(siaPath)
+		if err != nil {
+			return err
+		}
+		return errors.Compose(dir.UpdateMetadata(metadata), dir.Close())
+	}
+	ro.mu.RUnlock()
+
+	return fs.managedUpdateReadOnly(siaPath, func(dir *DirNode) error {
+		return dir.UpdateMetadata(metadata)
+	})
+}
+
+// managedUpdateReadOnly applies an update which might change the read-only
+// flag of the dir at siaPath. The update is applied while no writes are in
+// progress and the cached flag is updated afterwards.
+func (fs *FileSystem) managedUpdateReadOnly(siaPath modules.SiaPath, update func(*DirNode) error) (err error) {
+	ro := fs.staticReadOnlyFlags
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+	dir, err := fs.managedOpenSiaDir(siaPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Compose(err, dir.Close())
+	}()
+	err = update(dir)
+	if err != nil {
+		ro.managedDrop(siaPath)
+		return err
+	}
+	md, err := dir.Metadata()
+	if err != nil {
+		ro.managedDrop(siaPath)
+		return err
+	}
+	ro.managedSet(siaPath, md.ReadOnly)
+	return nil
+}
+
+// managedLockWritable returns ErrReadOnlyDir if any of the dirs at siaPaths or
+// any of their ancestors is read-only. Otherwise the flags are locked against
+// changes until the returned function is called. The caller must not hold the
+// lock already.
+func (fs *FileSystem) managedLockWritable(siaPaths ...modules.SiaPath) (func(), error) {
+	ro := fs.staticReadOnlyFlags
+	ro.mu.RLock()
+	for _, sp := range siaPaths {
+		if err := fs.managedCheckWritable(sp); err != nil {
+			ro.mu.RUnlock()
+			return nil, err
+		}
+	}
+	return ro.mu.RUnlock, nil
+}
+
+// managedLockWritableParents is a wrapper for managedLockWritable which checks
+// the parent dirs of the files at siaPaths.
+func (fs *FileSystem) managedLockWritableParents(siaPaths ...modules.SiaPath) (func(), error) {
+	dirs := make([]modules.SiaPath, 0, len(siaPaths))
+	for _, sp := range siaPaths {
+		dir, err := sp.Dir()
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, dir)
+	}
+	return fs.managedLockWritable(dirs...)
+}
+
+// managedCheckWritable returns ErrReadOnlyDir if the directory at siaPath or
+// any of its ancestors is read-only. Directories which don't exist yet are
+// skipped. The caller needs to hold the lock of the read-only flags.
+func (fs *FileSystem) managedCheckWritable(siaPath modules.SiaPath) error {
+	for {
+		readOnly, err := fs.managedReadOnly(siaPath)
+		if err != nil {
+			return err
+		}
+		if readOnly {
+			return errors.AddContext(ErrReadOnlyDir, fmt.Sprintf("dir '%v' is read-only", siaPath))
+		}
+		if siaPath.IsRoot() {
+			return nil
+		}
+		siaPath, err = siaPath.Dir()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// managedReadOnly returns the read-only flag of the dir at siaPath. The flag
+// is loaded from disk and cached if it isn't cached yet. Dirs which don't
+// exist are not read-only.
+func (fs *FileSystem) managedReadOnly(siaPath modules.SiaPath) (bool, error) {
+	ro := fs.staticReadOnlyFlags
+	ro.cacheMu.Lock()
+	readOnly, cached := ro.cached[siaPath]
+	generation := ro.generation
+	ro.cacheMu.Unlock()
+	if cached {
+		return readOnly, nil
+	}
+
+	dir, err := fs.managedOpenSiaDir(siaPath)
+	if errors.Contains(err, ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.AddContext(err, "failed to open dir to check read-only flag")
+	}
+	md, err := dir.Metadata()
+	err = errors.Compose(err, dir.Close())
+	if err != nil {
+		return false, errors.AddContext(err, "failed to get metadata to check read-only flag")
+	}
+
+	ro.cacheMu.Lock()
+	if ro.generation == generation {
+		ro.cached[siaPath] = md.ReadOnly
+	}
+	ro.cacheMu.Unlock()
+	return md.ReadOnly, nil
+}
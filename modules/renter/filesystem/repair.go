@@ -0,0 +1,56 @@
+package filesystem
+
+import (
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+// repairEstimateConcurrency is the number of dirs RepairEstimate processes in
+// parallel.
+const repairEstimateConcurrency = 4
+
+// RepairEstimate estimates the repair work within a subtree of the
+// filesystem. A chunk needs to be repaired if its health is at or above
+// modules.RepairThreshold, which is the same threshold the repair loop uses.
+type RepairEstimate struct {
+	// FilesNeedingRepair is the number of files with at least one chunk that
+	// needs to be repaired.
+	FilesNeedingRepair uint64
+
+	// ChunksNeedingRepair is the total number of chunks that need to be
+	// repaired.
+	ChunksNeedingRepair uint64
+}
+
+// RepairEstimate walks the files within the dir at siaPath and its subdirs and
+// estimates how much repair work is required to bring them back to full
+// health. The chunks of every file are only read once. The health of the
+// chunks is computed using the provided host maps.
+func (fs *FileSystem) RepairEstimate(siaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool) (RepairEstimate, error) {
+	if err := fs.tg.Add(); err != nil {
+		return RepairEstimate{}, ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	var estimate RepairEstimate
+	var mu sync.Mutex
+	err := fs.managedWalkFilesParallel(siaPath, repairEstimateConcurrency, func(fileSiaPath modules.SiaPath, n *FileNode) error {
+		numChunks, err := n.NumChunksNeedingRepair(offline, goodForRenew)
+		if err != nil {
+			return errors.AddContext(err, fileSiaPath.String())
+		}
+		if numChunks == 0 {
+			return nil
+		}
+		mu.Lock()
+		estimate.FilesNeedingRepair++
+		estimate.ChunksNeedingRepair += numChunks
+		mu.Unlock()
+		return nil
+	}, nil)
+	if err != nil {
+		return RepairEstimate{}, errors.AddContext(err, "failed to estimate repair work")
+	}
+	return estimate, nil
+}
@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/types"
+)
+
+// TestRepairEstimate tests that RepairEstimate only counts the degraded files
+// within the subtree.
+func TestRepairEstimate(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a healthy and a degraded file within /sub and another degraded
+	// file outside of it. Every file has a single chunk.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	ec, err := modules.NewRSSubCode(1, 2, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"sub/healthy", "sub/degraded", "degraded"} {
+		err := fs.NewSiaFile(newSiaPath(file), "", ec, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 100, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Upload all the pieces of the healthy file to good hosts.
+	offline := make(map[string]bool)
+	goodForRenew := make(map[string]bool)
+	healthy, err := fs.OpenSiaFile(newSiaPath("sub/healthy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < ec.NumPieces(); i++ {
+		spk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i)}}
+		offline[spk.String()] = false
+		goodForRenew[spk.String()] = true
+		if err := healthy.AddPiece(spk, 0, uint64(i), crypto.Hash{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := healthy.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the degraded file within /sub should need repairs.
+	estimate, err := fs.RepairEstimate(newSiaPath("sub"), offline, goodForRenew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := RepairEstimate{FilesNeedingRepair: 1, ChunksNeedingRepair: 1}
+	if estimate != expected {
+		t.Fatalf("expected %v but got %v", expected, estimate)
+	}
+
+	// The root contains both degraded files.
+	estimate, err = fs.RepairEstimate(modules.RootSiaPath(), offline, goodForRenew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = RepairEstimate{FilesNeedingRepair: 2, ChunksNeedingRepair: 2}
+	if estimate != expected {
+		t.Fatalf("expected %v but got %v", expected, estimate)
+	}
+}
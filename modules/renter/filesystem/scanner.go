@@ -0,0 +1,241 @@
+package filesystem
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// scannerContentionBackoff is the amount of time the metadata scanner
+	// waits before retrying to update a dir which is in use.
+	scannerContentionBackoff = 100 * time.Millisecond
+
+	// scannerMaxContentionRetries is the number of times the metadata scanner
+	// retries to update a dir which is in use before skipping it until the
+	// next scan.
+	scannerMaxContentionRetries = 10
+)
+
+var (
+	// errScannerRunning is returned when starting the metadata scanner while
+	// it is already running.
+	errScannerRunning = errors.New("metadata scanner is already running")
+
+	// errScannerStopped is returned by a scan if the scanner was stopped.
+	errScannerStopped = errors.New("metadata scanner was stopped")
+
+	// errScannerZeroRate is returned when starting the metadata scanner
+	// without a rate limit.
+	errScannerZeroRate = errors.New("metadata scanner needs a rate limit greater than 0")
+
+	// errDirInUse is returned when the metadata scanner skips a dir which is
+	// in use.
+	errDirInUse = errors.New("dir is in use")
+)
+
+type (
+	// BubbleFunc updates the aggregate metadata of the dir at siaPath from
+	// the metadata of its files and subdirs. It should block until the dir
+	// was updated. The scanner accounts for one read per file and one write
+	// of the dir's metadata. Any work the bubble does beyond that, e.g.
+	// updating the parent dirs, isn't rate limited by the scanner.
+	BubbleFunc func(siaPath modules.SiaPath) error
+
+	// metadataScanner periodically walks the FileSystem in the background and
+	// bubbles the dirs to update their aggregate metadata.
+	metadataScanner struct {
+		lastScan time.Time
+		stopChan chan struct{}
+		doneChan chan struct{}
+
+		// opInterval is the min amount of time between two reads or writes
+		// of a file's or dir's metadata.
+		opInterval time.Duration
+		lastOp     time.Time
+
+		mu sync.Mutex
+	}
+)
+
+// StartMetadataScanner starts a background thread which walks the FileSystem
+// every interval and bubbles every dir bottom-up using the provided bubble.
+// The scanner and the bubbles it runs read and write at most maxOpsPerSecond
+// files and dirs per second. Dirs which are in use by other threads are
+// retried a few times and skipped until the next scan if they remain in use.
+func (fs *FileSystem) StartMetadataScanner(interval time.Duration, maxOpsPerSecond uint64, bubble BubbleFunc) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	if maxOpsPerSecond == 0 {
+		return errScannerZeroRate
+	}
+	ms := fs.staticScanner
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.stopChan != nil {
+		return errScannerRunning
+	}
+	ms.stopChan = make(chan struct{})
+	ms.doneChan = make(chan struct{})
+	ms.opInterval = time.Second / time.Duration(maxOpsPerSecond)
+	go fs.threadedScanMetadata(interval, bubble, ms.stopChan, ms.doneChan)
+	return nil
+}
+
+// StopMetadataScanner stops the background metadata scanner and waits for it
+// to exit. It is a no-op if the scanner isn't running.
+func (fs *FileSystem) StopMetadataScanner() {
+	ms := fs.staticScanner
+	ms.mu.Lock()
+	stopChan, doneChan := ms.stopChan, ms.doneChan
+	ms.stopChan, ms.doneChan = nil, nil
+	ms.mu.Unlock()
+	if stopChan == nil {
+		return
+	}
+	close(stopChan)
+	<-doneChan
+}
+
+// LastMetadataScan returns the time at which the metadata scanner last
+// completed a full scan. It is zero if no scan was completed yet.
+func (fs *FileSystem) LastMetadataScan() time.Time {
+	ms := fs.staticScanner
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.lastScan
+}
+
+// threadedScanMetadata scans the FileSystem every interval until stopChan is
+// closed.
+func (fs *FileSystem) threadedScanMetadata(interval time.Duration, bubble BubbleFunc, stopChan, doneChan chan struct{}) {
+	defer close(doneChan)
+	for {
+		err := fs.managedScanDir(modules.RootSiaPath(), bubble, stopChan)
+		if errors.Contains(err, errScannerStopped) {
+			return
+		}
+		// A root which is in use is skipped like any other dir.
+		if err != nil && !errors.Contains(err, errDirInUse) {
+			fs.staticLog.Println("WARN: metadata scan failed:", err)
+		} else {
+			fs.staticScanner.mu.Lock()
+			fs.staticScanner.lastScan = time.Now()
+			fs.staticScanner.mu.Unlock()
+		}
+		select {
+		case <-stopChan:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// managedScanDir bubbles the dir at siaPath after scanning its subdirs.
+func (fs *FileSystem) managedScanDir(siaPath modules.SiaPath, bubble BubbleFunc, stopChan <-chan struct{}) error {
+	if err := fs.staticScanner.managedThrottle(stopChan); err != nil {
+		return err
+	}
+	fis, err := fs.ReadDir(siaPath)
+	if os.IsNotExist(err) {
+		return ErrNotExist // dir was deleted
+	}
+	if err != nil {
+		return err
+	}
+
+	// Scan the subdirs. Subdirs which are deleted or in use are skipped.
+	var numFiles uint64
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			if strings.HasSuffix(fi.Name(), modules.SiaFileExtension) {
+				numFiles++
+			}
+			continue
+		}
+		sp, err := siaPath.Join(fi.Name())
+		if err != nil {
+			return err
+		}
+		err = fs.managedScanDir(sp, bubble, stopChan)
+		if err != nil && !errors.Contains(err, ErrNotExist) && !errors.Contains(err, errDirInUse) {
+			return err
+		}
+	}
+
+	// Bubble the dir. If it's in use, back off to avoid slowing down other
+	// threads. Since the bubble computes the metadata itself, the check is
+	// only a heuristic and the dir can still be used concurrently.
+	for retry := 0; ; retry++ {
+		inUse, err := fs.managedDirInUse(siaPath)
+		if err != nil {
+			return err
+		}
+		if !inUse {
+			// The bubble reads the metadata of every file and writes the
+			// dir's metadata which counts towards the rate limit.
+			if err := fs.staticScanner.managedThrottleN(numFiles+1, stopChan); err != nil {
+				return err
+			}
+			return bubble(siaPath)
+		}
+		if retry == scannerMaxContentionRetries {
+			return errDirInUse
+		}
+		select {
+		case <-stopChan:
+			return errScannerStopped
+		case <-time.After(scannerContentionBackoff):
+		}
+	}
+}
+
+// managedDirInUse returns whether another thread has the dir at siaPath open.
+func (fs *FileSystem) managedDirInUse(siaPath modules.SiaPath) (_ bool, err error) {
+	dir, err := fs.OpenSiaDir(siaPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		err = errors.Compose(err, dir.Close())
+	}()
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+	return len(dir.threads) > 1, nil
+}
+
+// managedThrottle blocks until the scanner may read from disk again.
+func (ms *metadataScanner) managedThrottle(stopChan <-chan struct{}) error {
+	return ms.managedThrottleN(1, stopChan)
+}
+
+// managedThrottleN blocks until the scanner may access the disk again and
+// reserves n operations. The operation following them is delayed until all n
+// operations would have been allowed to run.
+func (ms *metadataScanner) managedThrottleN(n uint64, stopChan <-chan struct{}) error {
+	ms.mu.Lock()
+	wait := time.Until(ms.lastOp.Add(ms.opInterval))
+	ms.mu.Unlock()
+	if wait > 0 {
+		select {
+		case <-stopChan:
+			return errScannerStopped
+		case <-time.After(wait):
+		}
+	}
+	select {
+	case <-stopChan:
+		return errScannerStopped
+	default:
+	}
+	ms.mu.Lock()
+	ms.lastOp = time.Now().Add(time.Duration(n-1) * ms.opInterval)
+	ms.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,160 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+// TestMetadataScanner tests that the metadata scanner bubbles the dirs
+// bottom-up, skips dirs in use and stops when told to.
+func TestMetadataScanner(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	files := []string{"a/f1", "a/f2", "a/b/f3", "c/f4", "f5"}
+	for _, f := range files {
+		fs.addTestSiaFile(newSiaPath(f))
+	}
+
+	// Record the bubbled dirs.
+	var bubbled []modules.SiaPath
+	var mu sync.Mutex
+	bubble := func(sp modules.SiaPath) error {
+		mu.Lock()
+		defer mu.Unlock()
+		bubbled = append(bubbled, sp)
+		return nil
+	}
+	// bubbledAt returns the index of the first bubble of sp since the last
+	// reset or -1.
+	bubbledAt := func(sp modules.SiaPath) int {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, b := range bubbled {
+			if b.Equals(sp) {
+				return i
+			}
+		}
+		return -1
+	}
+	reset := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		bubbled = nil
+	}
+	// waitForScan waits for a scan to be completed which was started after
+	// calling waitForScan.
+	waitForScan := func() {
+		start := fs.LastMetadataScan()
+		for i := 0; i < 2; i++ {
+			err := build.Retry(100, 100*time.Millisecond, func() error {
+				if !fs.LastMetadataScan().After(start) {
+					return errors.New("no scan completed yet")
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			start = fs.LastMetadataScan()
+		}
+	}
+
+	// A rate limit is required.
+	if err := fs.StartMetadataScanner(time.Second, 0, bubble); !errors.Contains(err, errScannerZeroRate) {
+		t.Fatal("wrong error", err)
+	}
+
+	// Start the scanner and wait for a scan to complete.
+	if err := fs.StartMetadataScanner(100*time.Millisecond, 1000, bubble); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.StartMetadataScanner(100*time.Millisecond, 1000, bubble); !errors.Contains(err, errScannerRunning) {
+		t.Fatal("wrong error", err)
+	}
+	waitForScan()
+
+	// Every dir should be bubbled after its subdirs.
+	rootIdx := bubbledAt(modules.RootSiaPath())
+	aIdx := bubbledAt(newSiaPath("a"))
+	bIdx := bubbledAt(newSiaPath("a/b"))
+	cIdx := bubbledAt(newSiaPath("c"))
+	if rootIdx == -1 || aIdx == -1 || bIdx == -1 || cIdx == -1 {
+		t.Fatal("not all dirs were bubbled", rootIdx, aIdx, bIdx, cIdx)
+	}
+	if bIdx > aIdx || aIdx > rootIdx || cIdx > rootIdx {
+		t.Fatal("dirs weren't bubbled bottom-up", rootIdx, aIdx, bIdx, cIdx)
+	}
+
+	// Keep dir 'c' open. The following scans bubble 'a' but skip 'c'.
+	dir, err := fs.OpenSiaDir(newSiaPath("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset()
+	waitForScan()
+	if bubbledAt(newSiaPath("a")) == -1 {
+		t.Fatal("dir wasn't bubbled")
+	}
+	if bubbledAt(newSiaPath("c")) != -1 {
+		t.Fatal("dir in use was bubbled")
+	}
+	if err := dir.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reset()
+	waitForScan()
+	if bubbledAt(newSiaPath("c")) == -1 {
+		t.Fatal("dir wasn't bubbled after it was closed")
+	}
+
+	// Stop the scanner. No more scans should complete afterwards.
+	fs.StopMetadataScanner()
+	lastScan := fs.LastMetadataScan()
+	time.Sleep(500 * time.Millisecond)
+	if !fs.LastMetadataScan().Equal(lastScan) {
+		t.Fatal("scanner still running after being stopped")
+	}
+	fs.StopMetadataScanner() // no-op
+}
+
+// TestMetadataScannerThrottle tests that reserving multiple operations delays
+// the following operation accordingly.
+func TestMetadataScannerThrottle(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	ms := &metadataScanner{opInterval: 50 * time.Millisecond}
+	stopChan := make(chan struct{})
+	start := time.Now()
+	if err := ms.managedThrottleN(4, stopChan); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.managedThrottle(stopChan); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 4*ms.opInterval {
+		t.Fatal("operations weren't throttled", elapsed)
+	}
+
+	// A stopped scanner doesn't wait.
+	if err := ms.managedThrottleN(100, stopChan); err != nil {
+		t.Fatal(err)
+	}
+	close(stopChan)
+	if err := ms.managedThrottle(stopChan); !errors.Contains(err, errScannerStopped) {
+		t.Fatal("wrong error", err)
+	}
+}
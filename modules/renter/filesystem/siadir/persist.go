@@ -54,10 +54,10 @@ var (
 // directory that matches the siaPath provided
 //
 // NOTE: the fullPath is expected to include the rootPath. The rootPath is used
-// to determine when to stop recursively creating siadir metadata.
-func New(fullPath, rootPath string, mode os.FileMode) (*SiaDir, error) {
+// to determine when to stop recursively creating siadir metadata. The deps are
+// used for all disk access of the SiaDir.
+func New(fullPath, rootPath string, mode os.FileMode, deps modules.Dependencies) (*SiaDir, error) {
 	// Create path to directory and ensure path contains all metadata
-	deps := modules.ProdDependencies
 	err := createDirMetadataAll(fullPath, rootPath, mode, deps)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to create metadatas for parent directories")
@@ -149,10 +149,45 @@ func (sd *SiaDir) UpdateBubbledMetadata(metadata Metadata) error {
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 	metadata.Mode = sd.metadata.Mode
+	metadata.Quota = sd.metadata.Quota
+	metadata.ReadOnly = sd.metadata.ReadOnly
 	metadata.Version = sd.metadata.Version
 	return sd.updateMetadata(metadata)
 }
 
+// SetQuota sets the quota of the SiaDir and saves the change to disk.
+func (sd *SiaDir) SetQuota(quota uint64) error {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	md := sd.metadata
+	md.Quota = quota
+	return sd.updateMetadata(md)
+}
+
+// AdvanceAggregateModTime sets the AggregateModTime of the SiaDir to modTime
+// and saves the change to disk if modTime is more recent. It returns whether
+// the AggregateModTime was changed.
+func (sd *SiaDir) AdvanceAggregateModTime(modTime time.Time) (bool, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if !sd.metadata.AggregateModTime.Before(modTime) {
+		return false, nil
+	}
+	md := sd.metadata
+	md.AggregateModTime = modTime
+	return true, sd.updateMetadata(md)
+}
+
+// SetReadOnly sets the read-only flag of the SiaDir and saves the change to
+// disk.
+func (sd *SiaDir) SetReadOnly(readOnly bool) error {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	md := sd.metadata
+	md.ReadOnly = readOnly
+	return sd.updateMetadata(md)
+}
+
 // UpdateLastHealthCheckTime updates the SiaDir LastHealthCheckTime and
 // AggregateLastHealthCheckTime and saves the changes to disk
 func (sd *SiaDir) UpdateLastHealthCheckTime(aggregateLastHealthCheckTime, lastHealthCheckTime time.Time) error {
@@ -171,6 +206,48 @@ func (sd *SiaDir) UpdateMetadata(metadata Metadata) error {
 	return sd.updateMetadata(metadata)
 }
 
+// MigrateRawMetadata applies migrate to the raw json encoded metadata of the
+// SiaDir at path and atomically replaces the metadata on disk with the result.
+// If migrate returns nil, the metadata is left unchanged. It is meant to be
+// called before LoadSiaDir since metadata of an older version might not be
+// parsable by the current version. Corrupted metadata is skipped and left for
+// LoadSiaDir to reset.
+func MigrateRawMetadata(path string, deps modules.Dependencies, migrate func(raw []byte) ([]byte, error)) error {
+	mdPath := filepath.Join(path, SiaDirExtension)
+	fileBytes, err := readMetadataFile(mdPath, deps)
+	if err != nil {
+		return err
+	}
+	// Verify the checksum.
+	if len(fileBytes) < crypto.HashSize {
+		return nil
+	}
+	checksum := fileBytes[:crypto.HashSize]
+	mdBytes := fileBytes[crypto.HashSize:]
+	fileChecksum := crypto.HashBytes(mdBytes)
+	if !bytes.Equal(checksum, fileChecksum[:]) {
+		return nil
+	}
+	// Migrate the metadata.
+	migrated, err := migrate(mdBytes)
+	if err != nil {
+		return errors.AddContext(err, "failed to migrate metadata")
+	}
+	if migrated == nil {
+		return nil
+	}
+	// Write the migrated metadata to a temporary file first and then replace
+	// the original.
+	tmpPath := mdPath + "_temp"
+	if err := writeMetadataFile(tmpPath, migrated, deps); err != nil {
+		return errors.AddContext(err, "failed to save migrated metadata")
+	}
+	if err := os.Rename(tmpPath, mdPath); err != nil {
+		return errors.AddContext(err, "failed to replace metadata")
+	}
+	return nil
+}
+
 // rename renames the SiaDir to targetPath.
 func (sd *SiaDir) rename(targetPath string) error {
 	err := os.Rename(sd.path, targetPath)
@@ -219,6 +296,8 @@ func (sd *SiaDir) updateMetadata(metadata Metadata) error {
 	sd.metadata.NumFiles = metadata.NumFiles
 	sd.metadata.NumStuckChunks = metadata.NumStuckChunks
 	sd.metadata.NumSubDirs = metadata.NumSubDirs
+	sd.metadata.Quota = metadata.Quota
+	sd.metadata.ReadOnly = metadata.ReadOnly
 	sd.metadata.RemoteHealth = metadata.RemoteHealth
 	sd.metadata.RepairSize = metadata.RepairSize
 	sd.metadata.Size = metadata.Size
@@ -250,21 +329,30 @@ func (sd *SiaDir) updateMetadata(metadata Metadata) error {
 	return sd.saveDir()
 }
 
-// callLoadSiaDirMetadata loads the directory metadata from disk.
-func callLoadSiaDirMetadata(path string, deps modules.Dependencies) (md Metadata, err error) {
+// readMetadataFile reads the raw content of the .siadir file at the provided
+// path.
+func readMetadataFile(path string, deps modules.Dependencies) (_ []byte, err error) {
 	// Open the file.
 	file, err := deps.Open(path)
 	if err != nil {
-		return Metadata{}, err
+		return nil, err
 	}
 	defer func() {
 		err = errors.Compose(err, file.Close())
 	}()
+	fileBytes, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to read bytes from file")
+	}
+	return fileBytes, nil
+}
 
+// callLoadSiaDirMetadata loads the directory metadata from disk.
+func callLoadSiaDirMetadata(path string, deps modules.Dependencies) (md Metadata, err error) {
 	// Read the file
-	fileBytes, err := ioutil.ReadAll(file)
+	fileBytes, err := readMetadataFile(path, deps)
 	if err != nil {
-		return Metadata{}, errors.AddContext(err, "unable to read bytes from file")
+		return Metadata{}, err
 	}
 
 	// Verify there is enough data for a checksum
@@ -367,9 +455,26 @@ func newMetadata() Metadata {
 }
 
 // saveDir saves the metadata to disk at the provided path.
-func saveDir(path string, md Metadata, deps modules.Dependencies) (err error) {
+func saveDir(path string, md Metadata, deps modules.Dependencies) error {
+	return saveMetadataFile(filepath.Join(path, SiaDirExtension), md, deps)
+}
+
+// saveMetadataFile saves the metadata to the .siadir file at the provided
+// path.
+func saveMetadataFile(mdPath string, md Metadata, deps modules.Dependencies) error {
+	// Marshal metadata
+	data, err := json.Marshal(md)
+	if err != nil {
+		return errors.AddContext(err, "unable to marshal metadata")
+	}
+	return writeMetadataFile(mdPath, data, deps)
+}
+
+// writeMetadataFile writes the json encoded metadata together with its
+// checksum to the .siadir file at the provided path.
+func writeMetadataFile(mdPath string, data []byte, deps modules.Dependencies) (err error) {
 	// Open .siadir file
-	f, err := deps.OpenFile(filepath.Join(path, SiaDirExtension), os.O_RDWR|os.O_CREATE, modules.DefaultFilePerm)
+	f, err := deps.OpenFile(mdPath, os.O_RDWR|os.O_CREATE, modules.DefaultFilePerm)
 	if err != nil {
 		return errors.AddContext(err, "unable to open file")
 	}
@@ -377,12 +482,6 @@ func saveDir(path string, md Metadata, deps modules.Dependencies) (err error) {
 		err = errors.Compose(err, f.Close())
 	}()
 
-	// Marshal metadata
-	data, err := json.Marshal(md)
-	if err != nil {
-		return errors.AddContext(err, "unable to marshal metadata")
-	}
-
 	// Generate checksum
 	checksum := crypto.HashBytes(data)
 
@@ -405,5 +504,11 @@ func saveDir(path string, md Metadata, deps modules.Dependencies) (err error) {
 	if err != nil {
 		return errors.AddContext(err, "unable to truncate file")
 	}
+
+	// Sync the file
+	err = f.Sync()
+	if err != nil {
+		return errors.AddContext(err, "unable to sync file")
+	}
 	return nil
 }
@@ -50,6 +50,13 @@ type (
 		//
 		// NumSubDirs is the number of sub-siadirs in a siadir
 		//
+		// Quota is the soft limit of bytes the siafiles of the sub tree may
+		// hold. The quotas of all ancestors apply as well. A Quota of 0 means
+		// that the siadir doesn't have a quota.
+		//
+		// ReadOnly prevents the creation, renaming and deletion of files and
+		// dirs within the sub tree of the siadir.
+		//
 		// Size is the total amount of data stored in the siafiles of the siadir
 		//
 		// StuckHealth is the health of the most in need siafile in the siadir,
@@ -81,6 +88,8 @@ type (
 		NumFiles            uint64      `json:"numfiles"`
 		NumStuckChunks      uint64      `json:"numstuckchunks"`
 		NumSubDirs          uint64      `json:"numsubdirs"`
+		Quota               uint64      `json:"quota"`
+		ReadOnly            bool        `json:"readonly"`
 		RemoteHealth        float64     `json:"remotehealth"`
 		RepairSize          uint64      `json:"repairsize"`
 		Size                uint64      `json:"size"`
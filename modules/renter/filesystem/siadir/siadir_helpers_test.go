@@ -106,6 +106,12 @@ func equalMetadatas(md, md2 Metadata) error {
 	if md.NumSubDirs != md2.NumSubDirs {
 		return fmt.Errorf("NumSubDirs not equal, %v and %v", md.NumSubDirs, md2.NumSubDirs)
 	}
+	if md.Quota != md2.Quota {
+		return fmt.Errorf("Quotas not equal, %v and %v", md.Quota, md2.Quota)
+	}
+	if md.ReadOnly != md2.ReadOnly {
+		return fmt.Errorf("ReadOnly not equal, %v and %v", md.ReadOnly, md2.ReadOnly)
+	}
 	if md.RemoteHealth != md2.RemoteHealth {
 		return fmt.Errorf("RemoteHealth not equal, %v and %v", md.RemoteHealth, md2.RemoteHealth)
 	}
@@ -158,6 +164,8 @@ func randomMetadata() Metadata {
 		NumFiles:            fastrand.Uint64n(100),
 		NumStuckChunks:      fastrand.Uint64n(100),
 		NumSubDirs:          fastrand.Uint64n(100),
+		Quota:               fastrand.Uint64n(100),
+		ReadOnly:            fastrand.Intn(2) == 0,
 		RemoteHealth:        float64(fastrand.Intn(100)),
 		RepairSize:          fastrand.Uint64n(100),
 		Size:                fastrand.Uint64n(100),
@@ -183,5 +191,5 @@ func newTestDir(rootDir string) (*SiaDir, error) {
 	if err != nil {
 		return nil, err
 	}
-	return New(modules.RandomSiaPath().SiaDirSysPath(rootPath), rootPath, modules.DefaultDirPerm)
+	return New(modules.RandomSiaPath().SiaDirSysPath(rootPath), rootPath, modules.DefaultDirPerm, modules.ProdDependencies)
 }
@@ -34,7 +34,7 @@ func testSiaDirBasic(t *testing.T) {
 	topDir := filepath.Join(testDir, "TestDir")
 	subDir := "SubDir"
 	path := filepath.Join(topDir, subDir)
-	siaDir, err := New(path, testDir, persist.DefaultDiskPermissionsTest)
+	siaDir, err := New(path, testDir, persist.DefaultDiskPermissionsTest, modules.ProdDependencies)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -114,7 +114,7 @@ func testSiaDirDelete(t *testing.T) {
 		t.Fatal(err)
 	}
 	siaDirSysPath := siaPath.SiaDirSysPath(rootDir)
-	siaDir, err := New(siaDirSysPath, rootDir, modules.DefaultDirPerm)
+	siaDir, err := New(siaDirSysPath, rootDir, modules.DefaultDirPerm, modules.ProdDependencies)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -174,7 +174,7 @@ func testUpdateMetadata(t *testing.T) {
 		t.Fatal(err)
 	}
 	siaDirSysPath := siaPath.SiaDirSysPath(rootDir)
-	siaDir, err := New(siaDirSysPath, rootDir, modules.DefaultDirPerm)
+	siaDir, err := New(siaDirSysPath, rootDir, modules.DefaultDirPerm, modules.ProdDependencies)
 	if err != nil {
 		t.Fatal(err)
 	}
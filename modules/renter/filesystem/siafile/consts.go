@@ -38,6 +38,14 @@ const (
 	// pubKeyTablePruneThreshold is the number of unused hosts a SiaFile can
 	// store in its host key table before it is pruned.
 	pubKeyTablePruneThreshold = 50
+
+	// MaxCustomMetadataSize is the max total size of the keys and values of
+	// a SiaFile's custom metadata.
+	MaxCustomMetadataSize = 4096
+
+	// replaceTempSuffix is appended to the path of a SiaFile to get the path
+	// of the temporary file used by ReplaceFromReader.
+	replaceTempSuffix = "_replace"
 )
 
 // Constants to indicate which part of the partial upload the combined chunk is
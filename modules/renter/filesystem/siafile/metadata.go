@@ -164,6 +164,12 @@ type (
 		StaticErasureCodeType   [4]byte              `json:"erasurecodetype"`
 		StaticErasureCodeParams [8]byte              `json:"erasurecodeparams"`
 		staticErasureCode       modules.ErasureCoder // not persisted, exists for convenience
+
+		// CustomMetadata contains arbitrary key/value pairs set by the user.
+		// The map is never modified in place but replaced on every change.
+		// The total size of the keys and values is limited by
+		// MaxCustomMetadataSize.
+		CustomMetadata map[string]string `json:"custommetadata,omitempty"`
 	}
 
 	// BubbledMetadata is the metadata of a siafile that gets bubbled
@@ -212,7 +218,9 @@ func (sf *SiaFile) CreateTime() time.Time {
 
 // ChunkSize returns the size of a single chunk of the file.
 func (sf *SiaFile) ChunkSize() uint64 {
-	return sf.staticChunkSize()
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return sf.chunkSize()
 }
 
 // HasPartialChunk returns whether this file is supposed to have a partial chunk
@@ -223,6 +231,14 @@ func (sf *SiaFile) HasPartialChunk() bool {
 	return sf.staticMetadata.HasPartialChunk
 }
 
+// CustomMetadata returns the value of the custom metadata with the given key.
+func (sf *SiaFile) CustomMetadata(key string) (string, bool) {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	value, exists := sf.staticMetadata.CustomMetadata[key]
+	return value, exists
+}
+
 // LastHealthCheckTime returns the LastHealthCheckTime timestamp of the file
 func (sf *SiaFile) LastHealthCheckTime() time.Time {
 	sf.mu.RLock()
@@ -239,7 +255,9 @@ func (sf *SiaFile) LocalPath() string {
 
 // MasterKey returns the masterkey used to encrypt the file.
 func (sf *SiaFile) MasterKey() crypto.CipherKey {
-	return sf.staticMasterKey()
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return sf.masterKey()
 }
 
 // Metadata returns the SiaFile's metadata, resolving any fields related to
@@ -276,6 +294,8 @@ func (sf *SiaFile) NumStuckChunks() uint64 {
 
 // PieceSize returns the size of a single piece of the file.
 func (sf *SiaFile) PieceSize() uint64 {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
 	return sf.staticMetadata.StaticPieceSize
 }
 
@@ -337,6 +357,12 @@ func (md Metadata) backup() (b Metadata) {
 	b.GroupID = md.GroupID
 	b.ChunkOffset = md.ChunkOffset
 	b.PubKeyTableOffset = md.PubKeyTableOffset
+	if md.CustomMetadata != nil {
+		b.CustomMetadata = make(map[string]string, len(md.CustomMetadata))
+		for k, v := range md.CustomMetadata {
+			b.CustomMetadata[k] = v
+		}
+	}
 	// Special handling for slice since reflect.DeepEqual is false when
 	// comparing empty slice to nil.
 	if md.PartialChunks == nil {
@@ -389,6 +415,7 @@ func (md *Metadata) restore(b Metadata) {
 	md.GroupID = b.GroupID
 	md.ChunkOffset = b.ChunkOffset
 	md.PubKeyTableOffset = b.PubKeyTableOffset
+	md.CustomMetadata = b.CustomMetadata
 	// If the backup was successful it should match the backup.
 	if build.Release == "testing" && !md.equals(b) {
 		fmt.Println("md:\n", md)
@@ -530,6 +557,84 @@ func (sf *SiaFile) SetLocalPath(path string) (err error) {
 	return sf.createAndApplyTransaction(updates...)
 }
 
+// SetCustomMetadata sets the custom metadata with the given key to value and
+// persists it. An empty value removes the key.
+func (sf *SiaFile) SetCustomMetadata(key, value string) (err error) {
+	if key == "" {
+		return ErrEmptyCustomMetadataKey
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.deleted {
+		return errors.AddContext(ErrDeleted, "can't set custom metadata of deleted file")
+	}
+	// Create a copy of the map with the change applied and check its size.
+	cm := make(map[string]string, len(sf.staticMetadata.CustomMetadata)+1)
+	size := 0
+	for k, v := range sf.staticMetadata.CustomMetadata {
+		if k == key {
+			continue
+		}
+		cm[k] = v
+		size += len(k) + len(v)
+	}
+	if value != "" {
+		cm[key] = value
+		size += len(key) + len(value)
+	}
+	if size > MaxCustomMetadataSize {
+		return errors.AddContext(ErrCustomMetadataTooLarge, fmt.Sprintf("%v > %v", size, MaxCustomMetadataSize))
+	}
+	if len(cm) == 0 {
+		cm = nil
+	}
+
+	// backup the changed metadata before changing it. Revert the change on
+	// error.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+	sf.staticMetadata.CustomMetadata = cm
+	sf.staticMetadata.ChangeTime = time.Now()
+
+	// Save changes to metadata to disk.
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	return sf.createAndApplyTransaction(updates...)
+}
+
+// MigrateMetadata applies migrate to the file's metadata and persists the
+// result atomically. If migrate or persisting the metadata fails, the metadata
+// is left unchanged. Fields which describe the file's chunks shouldn't be
+// changed by migrate.
+func (sf *SiaFile) MigrateMetadata(migrate func(md *Metadata) error) (err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.deleted {
+		return errors.AddContext(ErrDeleted, "can't migrate the metadata of a deleted file")
+	}
+	// backup the metadata before migrating it. Revert the migration on error.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+	if err := migrate(&sf.staticMetadata); err != nil {
+		return errors.AddContext(err, "failed to migrate metadata")
+	}
+
+	// Save changes to metadata to disk.
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	return sf.createAndApplyTransaction(updates...)
+}
+
 // Size returns the file's size.
 func (sf *SiaFile) Size() uint64 {
 	sf.mu.RLock()
@@ -579,13 +684,16 @@ func (sf *SiaFile) numStuckChunks() uint64 {
 	return numStuckChunks
 }
 
-// staticChunkSize returns the size of a single chunk of the file.
-func (sf *SiaFile) staticChunkSize() uint64 {
+// chunkSize returns the size of a single chunk of the file. The erasure code
+// and piece size can change when the file is replaced which is why the caller
+// needs to hold the file's lock.
+func (sf *SiaFile) chunkSize() uint64 {
 	return sf.staticMetadata.StaticPieceSize * uint64(sf.staticMetadata.staticErasureCode.MinPieces())
 }
 
-// staticMasterKey returns the masterkey used to encrypt the file.
-func (sf *SiaFile) staticMasterKey() crypto.CipherKey {
+// masterKey returns the masterkey used to encrypt the file. The caller needs to
+// hold the file's lock.
+func (sf *SiaFile) masterKey() crypto.CipherKey {
 	sk, err := crypto.NewSiaKey(sf.staticMetadata.StaticMasterKeyType, sf.staticMetadata.StaticMasterKey)
 	if err != nil {
 		// This should never happen since the constructor of the SiaFile takes
@@ -141,6 +141,7 @@ func TestBackupRestoreMetadata(t *testing.T) {
 		sf.staticMetadata.GroupID = int32(fastrand.Intn(100))
 		sf.staticMetadata.ChunkOffset = int64(fastrand.Uint64n(100))
 		sf.staticMetadata.PubKeyTableOffset = int64(fastrand.Uint64n(100))
+		sf.staticMetadata.CustomMetadata = map[string]string{"key": string(fastrand.Bytes(10))}
 
 		// Error occurred after changing the fields.
 		return errors.New("")
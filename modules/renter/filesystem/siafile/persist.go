@@ -37,6 +37,12 @@ func LoadSiaFile(path string, wal *writeaheadlog.WAL) (*SiaFile, error) {
 	return loadSiaFile(path, wal, modules.ProdDependencies)
 }
 
+// LoadSiaFileWithDeps loads a SiaFile from disk using the provided
+// dependencies for all future disk access.
+func LoadSiaFileWithDeps(path string, wal *writeaheadlog.WAL, deps modules.Dependencies) (*SiaFile, error) {
+	return loadSiaFile(path, wal, deps)
+}
+
 // LoadSiaFileFromReader allows loading a SiaFile from a different location that
 // directly from disk as long as the source satisfies the SiaFileSource
 // interface.
@@ -76,6 +82,72 @@ func LoadSiaFileMetadata(path string) (Metadata, error) {
 	return loadSiaFileMetadata(path, modules.ProdDependencies)
 }
 
+// MigrateRawMetadata applies migrate to the raw json encoded metadata of the
+// SiaFile at path and atomically replaces the header of the file with the
+// result. If migrate returns nil, the file is left unchanged. It is meant to
+// be called before loading the SiaFile since metadata of an older version
+// might not be parsable by the current version. The migrated metadata needs
+// to be parsable and migrate shouldn't change the fields which describe the
+// layout of the file.
+func MigrateRawMetadata(path string, wal *writeaheadlog.WAL, deps modules.Dependencies, migrate func(raw []byte) ([]byte, error)) (err error) {
+	// Open the file.
+	f, err := deps.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	// Read the raw metadata.
+	var raw json.RawMessage
+	if err := json.NewDecoder(f).Decode(&raw); err != nil {
+		return errors.AddContext(err, "failed to decode raw metadata")
+	}
+	// Migrate it.
+	migrated, err := migrate(raw)
+	if err != nil {
+		return errors.AddContext(err, "failed to migrate metadata")
+	}
+	if migrated == nil {
+		return nil
+	}
+	// Parse the migrated metadata.
+	sf := &SiaFile{
+		deps:        deps,
+		siaFilePath: path,
+		wal:         wal,
+	}
+	if err := json.Unmarshal(migrated, &sf.staticMetadata); err != nil {
+		return errors.AddContext(err, "failed to unmarshal migrated metadata")
+	}
+	sf.staticMetadata.staticErasureCode, err = unmarshalErasureCoder(sf.staticMetadata.StaticErasureCodeType, sf.staticMetadata.StaticErasureCodeParams)
+	if err != nil {
+		return err
+	}
+	// Load the pubKeyTable which is written together with the metadata.
+	pubKeyTableLen := sf.staticMetadata.ChunkOffset - sf.staticMetadata.PubKeyTableOffset
+	if pubKeyTableLen < 0 {
+		return fmt.Errorf("pubKeyTableLen is %v, can't migrate file", pubKeyTableLen)
+	}
+	rawPubKeyTable := make([]byte, pubKeyTableLen)
+	if _, err := f.Seek(sf.staticMetadata.PubKeyTableOffset, io.SeekStart); err != nil {
+		return errors.AddContext(err, "failed to seek to pubKeyTable")
+	}
+	if _, err := io.ReadFull(f, rawPubKeyTable); err != nil {
+		return errors.AddContext(err, "failed to read pubKeyTable from disk")
+	}
+	sf.pubKeyTable, err = unmarshalPubKeyTable(rawPubKeyTable)
+	if err != nil {
+		return errors.AddContext(err, "failed to unmarshal pubKeyTable")
+	}
+	// Replace the header.
+	updates, err := sf.saveHeaderUpdates()
+	if err != nil {
+		return err
+	}
+	return sf.createAndApplyTransaction(updates...)
+}
+
 // SetPartialChunks informs the SiaFile about a partial chunk that has been
 // saved by the partial chunk set. As such it should be exclusively called by
 // the partial chunk set. It updates the metadata of the SiaFile and also adds a
@@ -97,7 +169,7 @@ func (sf *SiaFile) SetPartialChunks(combinedChunks []modules.PartialChunk, updat
 	for _, cc := range combinedChunks {
 		totalLength += int64(cc.Length)
 	}
-	expectedLength := sf.staticMetadata.FileSize % int64(sf.staticChunkSize())
+	expectedLength := sf.staticMetadata.FileSize % int64(sf.chunkSize())
 	if totalLength != expectedLength {
 		return fmt.Errorf("expect partial chunk length to be %v but was %v", expectedLength, totalLength)
 	}
@@ -301,8 +373,8 @@ func loadSiaFileFromReader(r io.ReadSeeker, path string, wal *writeaheadlog.WAL,
 		return nil, errors.New("chunkOff is not page aligned")
 	}
 	// Set numChunks field.
-	numChunks := sf.staticMetadata.FileSize / int64(sf.staticChunkSize())
-	if sf.staticMetadata.FileSize%int64(sf.staticChunkSize()) != 0 || numChunks == 0 {
+	numChunks := sf.staticMetadata.FileSize / int64(sf.chunkSize())
+	if sf.staticMetadata.FileSize%int64(sf.chunkSize()) != 0 || numChunks == 0 {
 		numChunks++
 	}
 	sf.numChunks = int(numChunks)
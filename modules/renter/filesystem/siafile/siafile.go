@@ -26,6 +26,12 @@ var (
 	// ErrUnknownThread is an error when a SiaFile is trying to be closed by a
 	// thread that is not in the threadMap
 	ErrUnknownThread = errors.New("thread should not be calling Close(), does not have control of the siafile")
+	// ErrCustomMetadataTooLarge is returned when the total size of a file's
+	// custom metadata would exceed MaxCustomMetadataSize.
+	ErrCustomMetadataTooLarge = errors.New("custom metadata exceeds the size limit")
+	// ErrEmptyCustomMetadataKey is returned when trying to set custom
+	// metadata with an empty key.
+	ErrEmptyCustomMetadataKey = errors.New("custom metadata key can't be empty")
 	// ErrDeleted is returned when an operation failed due to the siafile being
 	// deleted already.
 	ErrDeleted = errors.New("files was deleted")
@@ -232,15 +238,15 @@ func New(siaFilePath, source string, wal *writeaheadlog.WAL, erasureCode modules
 		wal:             wal,
 	}
 	// Init chunks.
-	numChunks := fileSize / file.staticChunkSize()
-	if fileSize%file.staticChunkSize() != 0 && partialsSiaFile != nil && !disablePartialUpload {
+	numChunks := fileSize / file.chunkSize()
+	if fileSize%file.chunkSize() != 0 && partialsSiaFile != nil && !disablePartialUpload {
 		// This file has a partial chunk
 		file.staticMetadata.HasPartialChunk = true
 		numChunks++
-	} else if fileSize%file.staticChunkSize() != 0 && disablePartialUpload {
+	} else if fileSize%file.chunkSize() != 0 && disablePartialUpload {
 		// This file does have a partial chunk but we treat it as a full chunk.
 		numChunks++
-	} else if fileSize%file.staticChunkSize() != 0 && partialsSiaFile == nil {
+	} else if fileSize%file.chunkSize() != 0 && partialsSiaFile == nil {
 		return nil, errors.New("can't create a file with a partial chunk without assigning a partialsSiaFile")
 	}
 	file.numChunks = int(numChunks)
@@ -309,8 +315,8 @@ func (sf *SiaFile) SetFileSize(fileSize uint64) (err error) {
 	}(sf.staticMetadata.backup())
 	// Make sure that SetFileSize doesn't affect the number of total chunks within
 	// the file.
-	newNumChunks := fileSize / sf.staticChunkSize()
-	if fileSize%sf.staticChunkSize() != 0 {
+	newNumChunks := fileSize / sf.chunkSize()
+	if fileSize%sf.chunkSize() != 0 {
 		newNumChunks++
 	}
 	if uint64(sf.numChunks) != newNumChunks {
@@ -320,7 +326,7 @@ func (sf *SiaFile) SetFileSize(fileSize uint64) (err error) {
 	// Update filesize.
 	sf.staticMetadata.FileSize = int64(fileSize)
 	// Check if the file changed from not having a partial chunk to having one.
-	if !sf.staticMetadata.DisablePartialChunk && uint64(sf.staticMetadata.FileSize)%sf.staticChunkSize() != 0 {
+	if !sf.staticMetadata.DisablePartialChunk && uint64(sf.staticMetadata.FileSize)%sf.chunkSize() != 0 {
 		if sf.numChunks > 0 {
 			// Last fullChunk is replaced by a partial chunk so we remove it.
 			if err := sf.removeLastChunk(); err != nil {
@@ -477,6 +483,31 @@ func (sf *SiaFile) chunkHealth(chunk chunk, offlineMap map[string]bool, goodForR
 	return chunkHealth, chunkHealth, repairBytes, nil
 }
 
+// NumChunksNeedingRepair returns the number of chunks of the file which need
+// to be repaired according to modules.NeedsRepair. Deleted and empty files
+// don't have any chunks which need to be repaired.
+func (sf *SiaFile) NumChunksNeedingRepair(offline map[string]bool, goodForRenew map[string]bool) (n uint64, err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.deleted || sf.staticMetadata.FileSize == 0 {
+		return 0, nil
+	}
+	err = sf.iterateChunksReadonly(func(c chunk) error {
+		chunkHealth, _, _, err := sf.chunkHealth(c, offline, goodForRenew)
+		if err != nil {
+			return err
+		}
+		if modules.NeedsRepair(chunkHealth) {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.AddContext(err, "failed to iterate over chunks")
+	}
+	return n, nil
+}
+
 // ChunkHealth returns the health of the chunk which is defined as the percent
 // of parity pieces remaining.
 func (sf *SiaFile) ChunkHealth(index int, offlineMap map[string]bool, goodForRenewMap map[string]bool) (float64, float64, uint64, error) {
@@ -519,6 +550,8 @@ func (sf *SiaFile) Deleted() bool {
 
 // ErasureCode returns the erasure coder used by the file.
 func (sf *SiaFile) ErasureCode() modules.ErasureCoder {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
 	return sf.staticMetadata.staticErasureCode
 }
 
@@ -544,6 +577,50 @@ func (sf *SiaFile) SaveWithChunks(chunks Chunks) (err error) {
 	return sf.createAndApplyTransaction(updates...)
 }
 
+// ReplaceFromReader atomically replaces the file's metadata, pubKeyTable and
+// chunks with those of the SiaFile read from r. The new file is written to a
+// temporary file next to the current one which is then renamed over it. The
+// file keeps its UniqueID. Since the in-memory state is swapped while holding
+// the file's lock, readers will either see the old or the new file but never
+// a mix of both. This includes the erasure code, piece size and master key
+// which is why their accessors acquire the lock too.
+func (sf *SiaFile) ReplaceFromReader(r io.ReadSeeker) (err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.deleted {
+		return errors.AddContext(ErrDeleted, "can't replace deleted file")
+	}
+	// Load the new file.
+	tmpPath := sf.siaFilePath + replaceTempSuffix
+	newSF, chunks, err := LoadSiaFileFromReaderWithChunks(r, tmpPath, sf.wal)
+	if err != nil {
+		return errors.AddContext(err, "failed to load replacement file")
+	}
+	if len(newSF.staticMetadata.PartialChunks) > 0 {
+		return errors.New("can't replace a file with a file that has a partial chunk")
+	}
+	newSF.deps = sf.deps
+	newSF.staticMetadata.UniqueID = sf.staticMetadata.UniqueID
+	newSF.staticMetadata.ChangeTime = time.Now()
+	// Remove leftovers of a previous replacement before writing the new file
+	// to the temporary location.
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return errors.AddContext(err, "failed to remove old temporary file")
+	}
+	if err := newSF.SaveWithChunks(chunks); err != nil {
+		return errors.Compose(errors.AddContext(err, "failed to save replacement file"), os.Remove(tmpPath))
+	}
+	// Atomically move the new file over the old one.
+	if err := os.Rename(tmpPath, sf.siaFilePath); err != nil {
+		return errors.Compose(errors.AddContext(err, "failed to rename replacement file"), os.Remove(tmpPath))
+	}
+	// Swap the in-memory state.
+	sf.staticMetadata = newSF.staticMetadata
+	sf.pubKeyTable = newSF.pubKeyTable
+	sf.numChunks = newSF.numChunks
+	return nil
+}
+
 // SaveHeader saves the file's header to disk.
 func (sf *SiaFile) SaveHeader() (err error) {
 	sf.mu.Lock()
@@ -1321,7 +1398,7 @@ func (sf *SiaFile) growNumChunks(numChunks uint64) (updates []writeaheadlog.Upda
 	if uint64(sf.numChunks) >= numChunks {
 		// Handle edge case where file has 1 chunk but has a size of 0. When we grow
 		// such a file to 1 chunk we want to increment the size to >0.
-		sf.staticMetadata.FileSize = int64(sf.staticChunkSize() * uint64(sf.numChunks))
+		sf.staticMetadata.FileSize = int64(sf.chunkSize() * uint64(sf.numChunks))
 		return nil, nil
 	}
 	// Backup the changed metadata before changing it. Revert the change on
@@ -1344,7 +1421,7 @@ func (sf *SiaFile) growNumChunks(numChunks uint64) (updates []writeaheadlog.Upda
 		newChunks = append(newChunks, newChunk)
 	}
 	// Update the fileSize.
-	sf.staticMetadata.FileSize = int64(sf.staticChunkSize() * uint64(sf.numChunks))
+	sf.staticMetadata.FileSize = int64(sf.chunkSize() * uint64(sf.numChunks))
 	mdu, err := sf.saveMetadataUpdates()
 	if err != nil {
 		return nil, err
@@ -216,7 +216,7 @@ func (sf *SiaFile) readlockChunks(min, max int) ([]chunk, error) {
 
 // readlockSnapshot creates a snapshot of the SiaFile.
 func (sf *SiaFile) readlockSnapshot(sp modules.SiaPath, chunks []chunk) (*Snapshot, error) {
-	mk := sf.staticMasterKey()
+	mk := sf.masterKey()
 
 	// Copy PubKeyTable.
 	pkt := make([]HostPublicKey, len(sf.pubKeyTable))
@@ -314,9 +314,9 @@ func (sf *SiaFile) SnapshotRange(sp modules.SiaPath, offset, length uint64) (*Sn
 	sf.mu.RLock()
 	defer sf.mu.RUnlock()
 
-	minChunk := int(offset / sf.staticChunkSize())
-	maxChunk := int((offset + length) / sf.staticChunkSize())
-	maxChunkOffset := (offset + length) % sf.staticChunkSize()
+	minChunk := int(offset / sf.chunkSize())
+	maxChunk := int((offset + length) / sf.chunkSize())
+	maxChunkOffset := (offset + length) % sf.chunkSize()
 	if maxChunk > 0 && maxChunkOffset == 0 {
 		maxChunk--
 	}
@@ -0,0 +1,191 @@
+package filesystem
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// errInvalidConcurrency is returned by WalkFilesParallel if the
+	// concurrency is smaller than 1.
+	errInvalidConcurrency = errors.New("concurrency needs to be at least 1")
+)
+
+type (
+	// WalkFilesFunc is the type of the function called by WalkFilesParallel
+	// for every file. The file is closed after the function returns.
+	WalkFilesFunc func(siaPath modules.SiaPath, file *FileNode) error
+
+	// parallelWalk contains the state shared between the workers of
+	// WalkFilesParallel.
+	parallelWalk struct {
+		// queue contains the dirs which still need to be processed. active is
+		// the number of dirs which are currently being processed by a
+		// worker. Once both are empty, the walk is done.
+		queue  []modules.SiaPath
+		active int
+
+		// err is the first error returned by the walk. Once it is set, the
+		// workers stop processing dirs.
+		err error
+
+		staticWalkFn WalkFilesFunc
+		cond         *sync.Cond
+		mu           sync.Mutex
+	}
+)
+
+// WalkFilesParallel calls fn for every file within the dir at siaPath and its
+// subdirs. The dirs are processed by concurrency workers in parallel which
+// means fn is called from multiple goroutines at once and needs to be
+// thread-safe. Files are visited in no particular order. The walk stops at the
+// first error and that error is returned. Files and dirs which are deleted
+// during the walk are skipped. If progress isn't nil, it is called
+// periodically with the number of visited files and once more after the walk
+// finished.
+func (fs *FileSystem) WalkFilesParallel(siaPath modules.SiaPath, concurrency int, fn WalkFilesFunc, progress ProgressFunc) error {
+	if err := fs.tg.Add(); err != nil {
+		return ErrShuttingDown
+	}
+	defer fs.tg.Done()
+	return fs.managedWalkFilesParallel(siaPath, concurrency, fn, progress)
+}
+
+// managedWalkFilesParallel is WalkFilesParallel without the threadgroup guard.
+// It is used by the FileSystem's methods which are already tracked by the
+// threadgroup.
+func (fs *FileSystem) managedWalkFilesParallel(siaPath modules.SiaPath, concurrency int, fn WalkFilesFunc, progress ProgressFunc) error {
+	if concurrency < 1 {
+		return errInvalidConcurrency
+	}
+	exists, err := fs.managedDirExists(siaPath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotExist
+	}
+
+	// Estimate the number of files from the bubbled metadata.
+	var total uint64
+	if progress != nil {
+		di, err := fs.managedDirInfo(siaPath)
+		if err != nil {
+			return err
+		}
+		total = di.AggregateNumFiles
+	}
+	pr := newProgressReporter(progress, total)
+	defer pr.managedDone()
+
+	pw := &parallelWalk{
+		queue: []modules.SiaPath{siaPath},
+		staticWalkFn: func(siaPath modules.SiaPath, file *FileNode) error {
+			if err := fn(siaPath, file); err != nil {
+				return err
+			}
+			pr.managedProcessed()
+			return nil
+		},
+	}
+	pw.cond = sync.NewCond(&pw.mu)
+
+	// Spin up the workers and wait for them to finish.
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fs.threadedWalkDirs(pw)
+		}()
+	}
+	wg.Wait()
+	return pw.err
+}
+
+// threadedWalkDirs processes dirs from the walk's queue until there are no
+// more dirs left or an error occurred.
+func (fs *FileSystem) threadedWalkDirs(pw *parallelWalk) {
+	for {
+		// Wait for a dir to process.
+		pw.mu.Lock()
+		for len(pw.queue) == 0 && pw.active > 0 && pw.err == nil {
+			pw.cond.Wait()
+		}
+		if pw.err != nil || len(pw.queue) == 0 {
+			// Wake up the other workers to let them know that the walk is
+			// done.
+			pw.mu.Unlock()
+			pw.cond.Broadcast()
+			return
+		}
+		siaPath := pw.queue[len(pw.queue)-1]
+		pw.queue = pw.queue[:len(pw.queue)-1]
+		pw.active++
+		pw.mu.Unlock()
+
+		// Process the dir.
+		dirs, err := fs.managedWalkDir(siaPath, pw.staticWalkFn)
+
+		// Queue the subdirs.
+		pw.mu.Lock()
+		pw.active--
+		if err != nil && pw.err == nil {
+			pw.err = errors.AddContext(err, "failed to walk dir "+siaPath.String())
+		}
+		pw.queue = append(pw.queue, dirs...)
+		pw.mu.Unlock()
+		pw.cond.Broadcast()
+	}
+}
+
+// managedWalkDir calls fn for every file within the dir at siaPath and returns
+// the paths of its subdirs.
+func (fs *FileSystem) managedWalkDir(siaPath modules.SiaPath, fn WalkFilesFunc) ([]modules.SiaPath, error) {
+	fis, err := fs.managedReadDir(siaPath)
+	if os.IsNotExist(err) {
+		return nil, nil // dir was deleted
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dirs []modules.SiaPath
+	for _, fi := range fis {
+		// Skip metadata and other files.
+		if !fi.IsDir() && !strings.HasSuffix(fi.Name(), modules.SiaFileExtension) {
+			continue
+		}
+		sp, err := siaPath.Join(strings.TrimSuffix(fi.Name(), modules.SiaFileExtension))
+		if err != nil {
+			return nil, err
+		}
+		if fi.IsDir() {
+			dirs = append(dirs, sp)
+			continue
+		}
+		if err := fs.managedWalkFile(sp, fn); err != nil {
+			return nil, err
+		}
+	}
+	return dirs, nil
+}
+
+// managedWalkFile opens the file at siaPath, calls fn on it and closes it
+// again.
+func (fs *FileSystem) managedWalkFile(siaPath modules.SiaPath, fn WalkFilesFunc) (err error) {
+	sf, err := fs.managedOpenSiaFile(siaPath)
+	if errors.Contains(err, ErrNotExist) {
+		return nil // file was deleted
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Compose(err, sf.Close())
+	}()
+	return fn(siaPath, sf)
+}
@@ -0,0 +1,117 @@
+package filesystem
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+// TestWalkFilesParallel tests that walking the filesystem in parallel visits
+// every file exactly once.
+func TestWalkFilesParallel(t *testing.T) {
+	if testing.Short() && !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// Create filesystem.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+
+	// Add files to multiple levels of dirs.
+	files := make(map[string]struct{})
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 4; j++ {
+			for _, path := range []string{
+				fmt.Sprintf("file%v_%v", i, j),
+				fmt.Sprintf("dir%v/file%v", i, j),
+				fmt.Sprintf("dir%v/sub%v/file", i, j),
+			} {
+				fs.addTestSiaFile(newSiaPath(path))
+				files[path] = struct{}{}
+			}
+		}
+	}
+
+	// Walk the filesystem.
+	var mu sync.Mutex
+	visited := make(map[string]int)
+	var reports []uint64
+	err := fs.WalkFilesParallel(modules.RootSiaPath(), 4, func(sp modules.SiaPath, sf *FileNode) error {
+		if !fs.FileSiaPath(sf).Equals(sp) {
+			return fmt.Errorf("wrong file for path %v", sp)
+		}
+		mu.Lock()
+		visited[sp.String()]++
+		mu.Unlock()
+		return nil
+	}, func(processed, total uint64) {
+		if len(reports) > 0 && processed < reports[len(reports)-1] {
+			t.Error("processed files decreased", processed, reports)
+		}
+		reports = append(reports, processed)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != len(files) {
+		t.Fatalf("expected %v files to be visited but got %v", len(files), len(visited))
+	}
+	// The progress should have been reported during the walk and after it.
+	if len(reports) < 2 {
+		t.Fatal("expected at least 2 progress reports but got", len(reports))
+	}
+	if reports[len(reports)-1] != uint64(len(files)) {
+		t.Fatalf("expected final report of %v files but got %v", len(files), reports[len(reports)-1])
+	}
+	for path, n := range visited {
+		if _, exists := files[path]; !exists {
+			t.Fatal("unknown file was visited", path)
+		}
+		if n != 1 {
+			t.Fatalf("file %v was visited %v times", path, n)
+		}
+	}
+
+	// Walking a subdir should only visit the files within it.
+	var numVisited int
+	err = fs.WalkFilesParallel(newSiaPath("dir0"), 2, func(sp modules.SiaPath, sf *FileNode) error {
+		mu.Lock()
+		numVisited++
+		mu.Unlock()
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numVisited != 8 {
+		t.Fatal("expected 8 files to be visited but got", numVisited)
+	}
+
+	// The first error should be returned.
+	errWalk := errors.New("walk failed")
+	err = fs.WalkFilesParallel(modules.RootSiaPath(), 4, func(sp modules.SiaPath, sf *FileNode) error {
+		return errWalk
+	}, nil)
+	if !errors.Contains(err, errWalk) {
+		t.Fatal("expected errWalk but got", err)
+	}
+
+	// Invalid arguments should be rejected.
+	err = fs.WalkFilesParallel(modules.RootSiaPath(), 0, func(sp modules.SiaPath, sf *FileNode) error {
+		return nil
+	}, nil)
+	if !errors.Contains(err, errInvalidConcurrency) {
+		t.Fatal("expected errInvalidConcurrency but got", err)
+	}
+	err = fs.WalkFilesParallel(newSiaPath("dir10"), 1, func(sp modules.SiaPath, sf *FileNode) error {
+		return nil
+	}, nil)
+	if !errors.Contains(err, ErrNotExist) {
+		t.Fatal("expected ErrNotExist but got", err)
+	}
+}
@@ -60,6 +60,8 @@ type (
 		MaxUploadSpeed   int64
 		UploadedBackups  []modules.UploadedBackup
 		SyncedContracts  []types.FileContractID
+
+		RegistryUpdateCooldowns modules.RegistryUpdateCooldowns
 	}
 )
 
@@ -169,10 +171,13 @@ func (r *Renter) managedInitPersist() error {
 	}
 
 	// Create the filesystem.
-	fs, err := filesystem.New(fsRoot, r.log, wal)
+	fs, err := filesystem.New(fsRoot, r.log, wal, filesystem.DefaultSyncOptions)
 	if err != nil {
 		return err
 	}
+	if err := r.tg.AfterStop(fs.Close); err != nil {
+		return err
+	}
 
 	// Initialize the wal, staticFileSet and the staticDirSet. With the
 	// staticDirSet finish the initialization of the files directory
@@ -3,6 +3,7 @@ package renter
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -48,6 +49,10 @@ var (
 	// was successful.
 	ErrRegistryUpdateNoSuccessfulUpdates = errors.New("all registry updates failed")
 
+	// ErrNoWorkerForHost is returned by UpdateRegistryOnHost if the host isn't
+	// part of the renter's worker pool.
+	ErrNoWorkerForHost = errors.New("no worker for host in worker pool")
+
 	// ErrRegistryUpdateTimeout is returned when updating the registry was
 	// aborted before reaching MinUpdateRegistrySucesses.
 	ErrRegistryUpdateTimeout = errors.New("registry update timed out before reaching the minimum amount of updated hosts")
@@ -287,6 +292,91 @@ func (r *Renter) UpdateRegistry(spk types.SiaPublicKey, srv modules.SignedRegist
 	return err
 }
 
+// UpdateRegistryOnHost updates the registry of a single host with the given
+// registry value. Unlike UpdateRegistry, the host isn't picked automatically
+// which is useful for testing and debugging a specific host.
+func (r *Renter) UpdateRegistryOnHost(ctx context.Context, hostPubKey types.SiaPublicKey, spk types.SiaPublicKey, srv modules.SignedRegistryValue) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	// Verify the signature before updating the host.
+	if err := srv.Verify(spk.ToPublicKey()); err != nil {
+		return errors.AddContext(err, "UpdateRegistryOnHost: failed to verify signature of entry")
+	}
+
+	// Get the worker for the host.
+	w, err := r.staticWorkerPool.callWorker(hostPubKey)
+	if err != nil {
+		return errors.AddContext(ErrNoWorkerForHost, hostPubKey.String())
+	}
+	return w.UpdateRegistry(ctx, spk, srv)
+}
+
+// UpdateRegistrySkipRedundant works like UpdateRegistry but skips updating
+// the hosts if the exact registry value is already known to be stored on
+// enough hosts to satisfy MinUpdateRegistrySuccesses. That's the case if the
+// renter recently updated, read or received the value through a subscription.
+// If the update is skipped, no error is returned and skipped is true.
+func (r *Renter) UpdateRegistrySkipRedundant(spk types.SiaPublicKey, srv modules.SignedRegistryValue, timeout time.Duration) (skipped bool, err error) {
+	if err := r.tg.Add(); err != nil {
+		return false, err
+	}
+	defer r.tg.Done()
+	if err := srv.Verify(spk.ToPublicKey()); err != nil {
+		return false, errors.AddContext(err, "UpdateRegistrySkipRedundant: failed to verify signature of entry")
+	}
+	if r.managedRegistryValueKnown(spk, srv) {
+		return true, nil
+	}
+	return false, r.UpdateRegistry(spk, srv, timeout)
+}
+
+// InvalidateRegistryCache removes the cached revision of the entry with the
+// provided public key and tweak from the registry caches of all workers. This
+// is useful for callers which know that the entry was changed without the
+// renter noticing. Afterwards, hosts returning a lower revision of the entry
+// are no longer punished for it and updates of the entry are no longer
+// skipped as redundant.
+func (r *Renter) InvalidateRegistryCache(spk types.SiaPublicKey, tweak crypto.Hash) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	for _, w := range r.staticWorkerPool.callWorkers() {
+		w.staticRegistryCache.Invalidate(spk, tweak)
+	}
+	return nil
+}
+
+// callRegistryWorkers returns the workers to use for a registry operation.
+// This is every worker of the worker pool in no particular order unless the
+// PinRegistryWorker dependency is used, which allows tests to pin the
+// operation to the worker of the host with the lowest public key.
+func (r *Renter) callRegistryWorkers() []*worker {
+	workers := r.staticWorkerPool.callWorkers()
+	if len(workers) > 0 && r.deps.Disrupt("PinRegistryWorker") {
+		sort.Slice(workers, func(i, j int) bool {
+			return workers[i].staticHostPubKeyStr < workers[j].staticHostPubKeyStr
+		})
+		workers = workers[:1]
+	}
+	return workers
+}
+
+// managedRegistryValueKnown returns whether the registry caches of at least
+// MinUpdateRegistrySuccesses workers contain the exact registry value.
+func (r *Renter) managedRegistryValueKnown(spk types.SiaPublicKey, srv modules.SignedRegistryValue) bool {
+	known := 0
+	for _, w := range r.staticWorkerPool.callWorkers() {
+		if w.staticRegistryCache.Contains(spk, srv) {
+			known++
+		}
+	}
+	return known >= MinUpdateRegistrySuccesses
+}
+
 // managedReadRegistry starts a registry lookup on all available workers. The
 // jobs have 'timeout' amount of time to finish their jobs and return a
 // response. Otherwise the response with the highest revision number will be
@@ -301,14 +391,14 @@ func (r *Renter) managedReadRegistry(ctx context.Context, spk types.SiaPublicKey
 	// results from the workers. The channel is buffered with one slot per
 	// worker, so that the workers do not have to block when returning the
 	// result of the job, even if this thread is not listening.
-	workers := r.staticWorkerPool.callWorkers()
+	workers := r.callRegistryWorkers()
 	staticResponseChan := make(chan *jobReadRegistryResponse, len(workers))
 
 	// Filter out hosts that don't support the registry.
 	numRegistryWorkers := 0
 	for _, worker := range workers {
 		cache := worker.staticCache()
-		if build.VersionCmp(cache.staticHostVersion, minRegistryVersion) < 0 {
+		if !worker.staticRegistryCapabilities().Read {
 			continue
 		}
 
@@ -441,7 +531,7 @@ func (r *Renter) managedUpdateRegistry(ctx context.Context, spk types.SiaPublicK
 	// results from the workers. The channel is buffered with one slot per
 	// worker, so that the workers do not have to block when returning the
 	// result of the job, even if this thread is not listening.
-	workers := r.staticWorkerPool.callWorkers()
+	workers := r.callRegistryWorkers()
 	staticResponseChan := make(chan *jobUpdateRegistryResponse, len(workers))
 
 	// Create a context to continue updating registry values in the background.
@@ -459,7 +549,7 @@ func (r *Renter) managedUpdateRegistry(ctx context.Context, spk types.SiaPublicK
 	numRegistryWorkers := 0
 	for _, worker := range workers {
 		cache := worker.staticCache()
-		if build.VersionCmp(cache.staticHostVersion, minRegistryVersion) < 0 {
+		if !worker.staticRegistryCapabilities().Write {
 			continue
 		}
 
@@ -4,6 +4,13 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/siatest/dependencies"
+	"go.sia.tech/siad/types"
 )
 
 // TestReadResponseSet is a unit test for the readResponseSet.
@@ -96,3 +103,116 @@ func TestReadResponseSet(t *testing.T) {
 		t.Fatal("resps should be empty", resps)
 	}
 }
+
+// TestUpdateRegistrySkipRedundant tests that updating the registry with a value
+// that is known to be stored on enough hosts is a no-op.
+func TestUpdateRegistrySkipRedundant(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Add some workers without hosts to the pool. If they were used to
+	// update the registry, the test would panic.
+	var workers []*worker
+	r.staticWorkerPool.mu.Lock()
+	for i := 0; i < MinUpdateRegistrySuccesses; i++ {
+		w := &worker{
+			staticRegistryCache: newRegistryCache(registryCacheSize),
+		}
+		r.staticWorkerPool.workers[fmt.Sprint(i)] = w
+		workers = append(workers, w)
+	}
+	r.staticWorkerPool.mu.Unlock()
+
+	// Create a registry value.
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	spk := types.Ed25519PublicKey(pk)
+	srv := modules.NewRegistryValue(tweak, fastrand.Bytes(10), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+
+	// The value is not known by any worker.
+	if r.managedRegistryValueKnown(spk, srv) {
+		t.Fatal("value shouldn't be known")
+	}
+
+	// Add it to all caches but one.
+	for _, w := range workers[1:] {
+		w.staticRegistryCache.Set(spk, srv, false)
+	}
+	if r.managedRegistryValueKnown(spk, srv) {
+		t.Fatal("value shouldn't be known by enough workers")
+	}
+
+	// Add a different value with the same revision to the last cache. That
+	// shouldn't be enough either.
+	srvOther := modules.NewRegistryValue(tweak, fastrand.Bytes(10), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	workers[0].staticRegistryCache.Set(spk, srvOther, false)
+	if r.managedRegistryValueKnown(spk, srv) {
+		t.Fatal("value shouldn't be known by enough workers")
+	}
+
+	// Add the right value to the last cache. Now the update should be
+	// skipped.
+	workers[0].staticRegistryCache.Set(spk, srv, true)
+	skipped, err := r.UpdateRegistrySkipRedundant(spk, srv, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skipped {
+		t.Fatal("redundant update wasn't skipped")
+	}
+
+	// An invalid signature should still be rejected.
+	srv.Signature = crypto.Signature{}
+	if _, err := r.UpdateRegistrySkipRedundant(spk, srv, time.Second); err == nil {
+		t.Fatal("expected invalid signature to be rejected")
+	}
+}
+
+// TestCallRegistryWorkersPinned tests that a dependency can pin the worker
+// used for registry operations.
+func TestCallRegistryWorkersPinned(t *testing.T) {
+	t.Parallel()
+
+	// Create a renter with a worker pool of a few workers.
+	wp := &workerPool{workers: make(map[string]*worker)}
+	for i := 0; i < 10; i++ {
+		hostKey := fmt.Sprintf("host%v", i)
+		wp.workers[hostKey] = &worker{staticHostPubKeyStr: hostKey}
+	}
+	r := &Renter{
+		deps:             modules.ProdDependencies,
+		staticWorkerPool: wp,
+	}
+
+	// Without the dependency all workers are returned.
+	if workers := r.callRegistryWorkers(); len(workers) != len(wp.workers) {
+		t.Fatalf("expected %v workers but got %v", len(wp.workers), len(workers))
+	}
+
+	// Pin the worker. The worker of the host with the lowest key should be
+	// returned every time.
+	r.deps = dependencies.NewDependencyPinRegistryWorker()
+	for i := 0; i < 100; i++ {
+		workers := r.callRegistryWorkers()
+		if len(workers) != 1 {
+			t.Fatal("wrong number of workers", len(workers))
+		}
+		if workers[0].staticHostPubKeyStr != "host0" {
+			t.Fatal("wrong worker", workers[0].staticHostPubKeyStr)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package renter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// ErrNoConsensus is returned by ReadRegistryConsensus if not enough hosts
+	// agreed on a registry value.
+	ErrNoConsensus = errors.New("not enough hosts agree on the registry value")
+
+	// errInvalidConsensusParams is returned by ReadRegistryConsensus if the
+	// number of hosts to query or the threshold are invalid.
+	errInvalidConsensusParams = errors.New("invalid consensus parameters")
+)
+
+type (
+	// RegistryValueTally is the number of hosts that returned the same
+	// registry value.
+	RegistryValueTally struct {
+		Value modules.SignedRegistryValue
+		Hosts int
+	}
+
+	// registryValueKey identifies a registry value by its revision and data
+	// for grouping the responses of multiple hosts.
+	registryValueKey struct {
+		revision uint64
+		dataHash crypto.Hash
+	}
+)
+
+// ReadRegistryConsensus reads the registry entry identified by spk and tweak
+// from up to m hosts and only returns the value if at least t of them agree on
+// it. Responses are grouped by their revision and data and responses with an
+// invalid signature are not counted. If no value reaches the threshold,
+// ErrNoConsensus is returned. The tally is returned in both cases.
+func (r *Renter) ReadRegistryConsensus(ctx context.Context, spk types.SiaPublicKey, tweak crypto.Hash, m, t int) (modules.SignedRegistryValue, []RegistryValueTally, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.SignedRegistryValue{}, nil, err
+	}
+	defer r.tg.Done()
+	if t <= 0 || m < t {
+		return modules.SignedRegistryValue{}, nil, errors.AddContext(errInvalidConsensusParams, fmt.Sprintf("m: %v, t: %v", m, t))
+	}
+
+	// Block until there is memory available, and then ensure the memory gets
+	// returned.
+	if !r.registryMemoryManager.Request(ctx, readRegistryMemory, memoryPriorityHigh) {
+		return modules.SignedRegistryValue{}, nil, errors.New("timeout while waiting in job queue - server is busy")
+	}
+	defer r.registryMemoryManager.Return(readRegistryMemory)
+
+	// Launch a read job on up to m workers.
+	workers := r.callRegistryWorkers()
+	staticResponseChan := make(chan *jobReadRegistryResponse, len(workers))
+	numWorkers := 0
+	for _, worker := range workers {
+		if numWorkers == m {
+			break
+		}
+		if !worker.staticRegistryCapabilities().Read {
+			continue
+		}
+		// check for price gouging
+		pt := worker.staticPriceTable().staticPriceTable
+		err := checkProjectDownloadGouging(pt, worker.staticCache().staticRenterAllowance)
+		if err != nil {
+			r.log.Debugf("price gouging detected in worker %v, err: %v\n", worker.staticHostPubKeyStr, err)
+			continue
+		}
+		jrr := worker.newJobReadRegistry(ctx, staticResponseChan, spk, tweak)
+		if !worker.staticJobReadRegistryQueue.callAdd(jrr) {
+			continue
+		}
+		numWorkers++
+	}
+	if numWorkers < t {
+		return modules.SignedRegistryValue{}, nil, errors.AddContext(modules.ErrNotEnoughWorkersInWorkerPool, fmt.Sprintf("cannot perform ReadRegistryConsensus with %v workers and a threshold of %v", numWorkers, t))
+	}
+
+	// Wait for all responses and tally them.
+	resps := newReadResponseSet(staticResponseChan, numWorkers).collect(ctx)
+	return tallyRegistryResponses(spk, tweak, resps, t)
+}
+
+// tallyRegistryResponses groups the responses by revision and data and returns
+// the value with the most agreeing hosts if it is backed by at least t hosts
+// and no other value is backed by the same number of hosts.
+// The returned tally is sorted by the number of hosts in descending order.
+func tallyRegistryResponses(spk types.SiaPublicKey, tweak crypto.Hash, resps []*jobReadRegistryResponse, t int) (modules.SignedRegistryValue, []RegistryValueTally, error) {
+	indices := make(map[registryValueKey]int)
+	var tally []RegistryValueTally
+	for _, resp := range resps {
+		if resp.staticErr != nil || resp.staticSignedRegistryValue == nil {
+			continue
+		}
+		srv := *resp.staticSignedRegistryValue
+		// Don't count values for a different entry or values with an invalid
+		// signature.
+		if srv.Tweak != tweak || srv.Verify(spk.ToPublicKey()) != nil {
+			continue
+		}
+		key := registryValueKey{
+			revision: srv.Revision,
+			dataHash: crypto.HashBytes(srv.Data),
+		}
+		i, exists := indices[key]
+		if !exists {
+			i = len(tally)
+			indices[key] = i
+			tally = append(tally, RegistryValueTally{Value: srv})
+		}
+		tally[i].Hosts++
+	}
+	sort.SliceStable(tally, func(i, j int) bool {
+		return tally[i].Hosts > tally[j].Hosts
+	})
+	// A tie between the top values is treated like a failure to reach the
+	// threshold since we can't tell which value to trust.
+	tie := len(tally) > 1 && tally[0].Hosts == tally[1].Hosts
+	if len(tally) == 0 || tally[0].Hosts < t || tie {
+		return modules.SignedRegistryValue{}, tally, errors.AddContext(ErrNoConsensus, fmt.Sprintf("%v responses, tally: %v", len(resps), tallyString(tally)))
+	}
+	return tally[0].Value, tally, nil
+}
+
+// tallyString returns a human-readable representation of a tally.
+func tallyString(tally []RegistryValueTally) string {
+	s := "["
+	for i, vt := range tally {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("rev %v: %v hosts", vt.Value.Revision, vt.Hosts)
+	}
+	return s + "]"
+}
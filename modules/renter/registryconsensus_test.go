@@ -0,0 +1,82 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestTallyRegistryResponses is a unit test for tallyRegistryResponses.
+func TestTallyRegistryResponses(t *testing.T) {
+	t.Parallel()
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+
+	// Create a few values.
+	data := fastrand.Bytes(10)
+	srv1 := modules.NewRegistryValue(tweak, data, 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	srv2 := modules.NewRegistryValue(tweak, data, 2, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	srv2Other := modules.NewRegistryValue(tweak, fastrand.Bytes(10), 2, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	srvBadSig := srv2
+	srvBadSig.Signature[0]++
+	var otherTweak crypto.Hash
+	fastrand.Read(otherTweak[:])
+	srvOtherTweak := modules.NewRegistryValue(otherTweak, data, 2, modules.RegistryTypeWithoutPubkey).Sign(sk)
+
+	resp := func(srv modules.SignedRegistryValue) *jobReadRegistryResponse {
+		return &jobReadRegistryResponse{staticSignedRegistryValue: &srv}
+	}
+	errResp := &jobReadRegistryResponse{staticErr: errors.New("failed")}
+	notFoundResp := &jobReadRegistryResponse{}
+
+	// Agreement is reached. 3 hosts agree on srv2, 1 on srv1 and the invalid
+	// responses are ignored.
+	resps := []*jobReadRegistryResponse{
+		resp(srv1), resp(srv2), errResp, resp(srv2), resp(srvBadSig), notFoundResp, resp(srvOtherTweak), resp(srv2),
+	}
+	srv, tally, err := tallyRegistryResponses(spk, tweak, resps, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Revision != srv2.Revision || string(srv.Data) != string(srv2.Data) {
+		t.Fatal("wrong value returned", srv)
+	}
+	if len(tally) != 2 || tally[0].Hosts != 3 || tally[1].Hosts != 1 {
+		t.Fatal("wrong tally", tally)
+	}
+
+	// Split between values with the same revision but different data. 2 hosts
+	// each is not enough for a threshold of 3.
+	resps = []*jobReadRegistryResponse{
+		resp(srv2), resp(srv2Other), resp(srv2), resp(srv2Other), resp(srvBadSig), resp(srvBadSig),
+	}
+	_, tally, err = tallyRegistryResponses(spk, tweak, resps, 3)
+	if !errors.Contains(err, ErrNoConsensus) {
+		t.Fatal("expected ErrNoConsensus", err)
+	}
+	if len(tally) != 2 || tally[0].Hosts != 2 || tally[1].Hosts != 2 {
+		t.Fatal("wrong tally", tally)
+	}
+
+	// With a lower threshold the split is a tie which also fails.
+	_, _, err = tallyRegistryResponses(spk, tweak, resps, 2)
+	if !errors.Contains(err, ErrNoConsensus) {
+		t.Fatal("expected ErrNoConsensus", err)
+	}
+
+	// No valid responses at all.
+	_, tally, err = tallyRegistryResponses(spk, tweak, []*jobReadRegistryResponse{errResp, notFoundResp}, 1)
+	if !errors.Contains(err, ErrNoConsensus) {
+		t.Fatal("expected ErrNoConsensus", err)
+	}
+	if len(tally) != 0 {
+		t.Fatal("tally should be empty", tally)
+	}
+}
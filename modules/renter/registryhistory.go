@@ -0,0 +1,119 @@
+package renter
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// errInvalidRegistryHistoryLength is returned when trying to set a
+	// negative registry history length.
+	errInvalidRegistryHistoryLength = errors.New("registry history length can't be negative")
+)
+
+type (
+	// RegistryHistoryEntry is a registry value the renter read from or wrote
+	// to a host.
+	RegistryHistoryEntry struct {
+		Value     modules.SignedRegistryValue
+		Host      types.SiaPublicKey
+		Timestamp time.Time
+
+		// Written is true if the renter wrote the value to the host and false
+		// if it read the value from the host.
+		Written bool
+	}
+
+	// registryHistory is an in-memory, append-only log of the registry
+	// values the renter observed per entry. It only keeps the most recent
+	// maxEntriesPerKey values of every entry. A maxEntriesPerKey of 0
+	// disables the history.
+	registryHistory struct {
+		entries          map[modules.RegistryEntryID][]RegistryHistoryEntry
+		maxEntriesPerKey int
+		mu               sync.Mutex
+	}
+)
+
+// newRegistryHistory creates a new, disabled registry history.
+func newRegistryHistory() *registryHistory {
+	return &registryHistory{
+		entries: make(map[modules.RegistryEntryID][]RegistryHistoryEntry),
+	}
+}
+
+// Add appends a value to the history of the entry it belongs to. If the
+// history of the entry is full, its oldest value is dropped.
+func (rh *registryHistory) Add(spk types.SiaPublicKey, host types.SiaPublicKey, srv modules.SignedRegistryValue, written bool) {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	if rh.maxEntriesPerKey == 0 {
+		return
+	}
+	key := modules.DeriveRegistryEntryID(spk, srv.Tweak)
+	entries := append(rh.entries[key], RegistryHistoryEntry{
+		Value:     srv,
+		Host:      host,
+		Timestamp: time.Now(),
+		Written:   written,
+	})
+	if len(entries) > rh.maxEntriesPerKey {
+		entries = append([]RegistryHistoryEntry{}, entries[len(entries)-rh.maxEntriesPerKey:]...)
+	}
+	rh.entries[key] = entries
+}
+
+// History returns the recorded values of an entry from oldest to newest.
+func (rh *registryHistory) History(spk types.SiaPublicKey, tweak crypto.Hash) []RegistryHistoryEntry {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	key := modules.DeriveRegistryEntryID(spk, tweak)
+	return append([]RegistryHistoryEntry{}, rh.entries[key]...)
+}
+
+// SetMaxEntriesPerKey updates the max number of values kept per entry. Shorter
+// histories are truncated right away. Setting it to 0 disables the history
+// and drops all recorded values.
+func (rh *registryHistory) SetMaxEntriesPerKey(n int) {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	rh.maxEntriesPerKey = n
+	for key, entries := range rh.entries {
+		if n == 0 {
+			delete(rh.entries, key)
+		} else if len(entries) > n {
+			rh.entries[key] = append([]RegistryHistoryEntry{}, entries[len(entries)-n:]...)
+		}
+	}
+}
+
+// RegistryHistory returns the values of the registry entry identified by spk
+// and tweak which the renter read from or wrote to hosts, from oldest to
+// newest. The history is only recorded if it was enabled with
+// SetRegistryHistoryLength.
+func (r *Renter) RegistryHistory(spk types.SiaPublicKey, tweak crypto.Hash) ([]RegistryHistoryEntry, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticRegistryHistory.History(spk, tweak), nil
+}
+
+// SetRegistryHistoryLength enables the registry history and sets the max number
+// of values kept per registry entry. A length of 0 disables the history.
+func (r *Renter) SetRegistryHistoryLength(n int) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if n < 0 {
+		return errInvalidRegistryHistoryLength
+	}
+	r.staticRegistryHistory.SetMaxEntriesPerKey(n)
+	return nil
+}
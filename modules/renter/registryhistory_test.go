@@ -0,0 +1,87 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestRegistryHistory is a unit test for the registryHistory.
+func TestRegistryHistory(t *testing.T) {
+	t.Parallel()
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak, otherTweak crypto.Hash
+	fastrand.Read(tweak[:])
+	fastrand.Read(otherTweak[:])
+	host1 := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	host2 := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	value := func(tweak crypto.Hash, revision uint64) modules.SignedRegistryValue {
+		return modules.NewRegistryValue(tweak, fastrand.Bytes(10), revision, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	}
+
+	// The history is disabled by default.
+	rh := newRegistryHistory()
+	rh.Add(spk, host1, value(tweak, 0), true)
+	if h := rh.History(spk, tweak); len(h) != 0 {
+		t.Fatal("history should be empty", len(h))
+	}
+
+	// Enable it and add a few values to two entries.
+	rh.SetMaxEntriesPerKey(3)
+	rh.Add(spk, host1, value(tweak, 1), true)
+	rh.Add(spk, host2, value(otherTweak, 1), false)
+	rh.Add(spk, host2, value(tweak, 2), false)
+	h := rh.History(spk, tweak)
+	if len(h) != 2 {
+		t.Fatal("wrong length", len(h))
+	}
+	if h[0].Value.Revision != 1 || !h[0].Written || !h[0].Host.Equals(host1) {
+		t.Fatal("wrong first entry", h[0])
+	}
+	if h[1].Value.Revision != 2 || h[1].Written || !h[1].Host.Equals(host2) {
+		t.Fatal("wrong second entry", h[1])
+	}
+	if h[1].Timestamp.Before(h[0].Timestamp) {
+		t.Fatal("entries are not ordered")
+	}
+	if h := rh.History(spk, otherTweak); len(h) != 1 {
+		t.Fatal("wrong length", len(h))
+	}
+
+	// Modifying the returned history shouldn't affect the stored one.
+	h[0].Value.Revision = 100
+	if rh.History(spk, tweak)[0].Value.Revision != 1 {
+		t.Fatal("history was modified")
+	}
+
+	// Exceed the limit. The oldest values should be dropped.
+	for rev := uint64(3); rev <= 5; rev++ {
+		rh.Add(spk, host1, value(tweak, rev), true)
+	}
+	h = rh.History(spk, tweak)
+	if len(h) != 3 {
+		t.Fatal("wrong length", len(h))
+	}
+	for i, entry := range h {
+		if entry.Value.Revision != uint64(i+3) {
+			t.Fatal("wrong revision", i, entry.Value.Revision)
+		}
+	}
+
+	// Shrinking the limit truncates the history.
+	rh.SetMaxEntriesPerKey(1)
+	if h := rh.History(spk, tweak); len(h) != 1 || h[0].Value.Revision != 5 {
+		t.Fatal("history wasn't truncated", h)
+	}
+
+	// Disabling the history drops all values.
+	rh.SetMaxEntriesPerKey(0)
+	if h := rh.History(spk, otherTweak); len(h) != 0 {
+		t.Fatal("history should be empty", len(h))
+	}
+}
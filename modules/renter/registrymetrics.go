@@ -0,0 +1,166 @@
+package renter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// registryMetricsOpRead and registryMetricsOpUpdate are the values of
+	// the op label of the exported registry metrics.
+	registryMetricsOpRead   = "read"
+	registryMetricsOpUpdate = "update"
+)
+
+// prometheusLabelEscaper escapes label values for the Prometheus text format.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type (
+	// registryJobMetrics counts the results of the registry jobs of a queue
+	// since the worker was created.
+	registryJobMetrics struct {
+		counters registryJobCounters
+		mu       sync.Mutex
+	}
+
+	// registryJobCounters are the counters of registryJobMetrics. Failures
+	// are split into the same classes as the cooldowns of the queues.
+	// Revision errors are responses with ErrSameRevNum or ErrLowerRevNum
+	// which aren't the host's fault. TotalLatency is the sum of the durations
+	// of the successful jobs.
+	registryJobCounters struct {
+		Successes       uint64
+		SignatureErrors uint64
+		NetworkErrors   uint64
+		RevisionErrors  uint64
+		Cooldowns       uint64
+		TotalLatency    time.Duration
+	}
+
+	// registryMetricsSample is a snapshot of the metrics of a single
+	// registry queue of a worker.
+	registryMetricsSample struct {
+		host       string
+		op         string
+		queueDepth int
+		counters   registryJobCounters
+	}
+)
+
+// newRegistryJobMetrics creates new, empty metrics.
+func newRegistryJobMetrics() *registryJobMetrics {
+	return &registryJobMetrics{}
+}
+
+// AddSuccess records a successful job.
+func (m *registryJobMetrics) AddSuccess(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters.Successes++
+	m.counters.TotalLatency += latency
+}
+
+// AddRevisionError records a job which failed due to the host already
+// storing a higher or equal revision.
+func (m *registryJobMetrics) AddRevisionError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters.RevisionErrors++
+}
+
+// AddFailure records a job which failed and put the queue on a cooldown.
+func (m *registryJobMetrics) AddFailure(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if isRegistrySignatureErr(err) {
+		m.counters.SignatureErrors++
+	} else {
+		m.counters.NetworkErrors++
+	}
+	m.counters.Cooldowns++
+}
+
+// Counters returns a copy of the current counters.
+func (m *registryJobMetrics) Counters() registryJobCounters {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters
+}
+
+// WriteRegistryMetrics writes the metrics of the registry queues of all
+// workers to w using the Prometheus text format.
+func (r *Renter) WriteRegistryMetrics(w io.Writer) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	var samples []registryMetricsSample
+	for _, worker := range r.staticWorkerPool.callWorkers() {
+		samples = append(samples, registryMetricsSample{
+			host:       worker.staticHostPubKeyStr,
+			op:         registryMetricsOpRead,
+			queueDepth: worker.staticJobReadRegistryQueue.callLen(),
+			counters:   worker.staticJobReadRegistryQueue.staticMetrics.Counters(),
+		}, registryMetricsSample{
+			host:       worker.staticHostPubKeyStr,
+			op:         registryMetricsOpUpdate,
+			queueDepth: worker.staticJobUpdateRegistryQueue.callLen(),
+			counters:   worker.staticJobUpdateRegistryQueue.staticMetrics.Counters(),
+		})
+	}
+	return writeRegistryMetrics(w, samples)
+}
+
+// writeRegistryMetrics writes the samples to w using the Prometheus text
+// format. The samples are sorted by host and op.
+func writeRegistryMetrics(w io.Writer, samples []registryMetricsSample) error {
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].host != samples[j].host {
+			return samples[i].host < samples[j].host
+		}
+		return samples[i].op < samples[j].op
+	})
+	labels := func(s registryMetricsSample) string {
+		return fmt.Sprintf(`host="%v",op="%v"`, prometheusLabelEscaper.Replace(s.host), s.op)
+	}
+
+	var sb strings.Builder
+	writeHeader := func(name, typ, help string) {
+		fmt.Fprintf(&sb, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, typ)
+	}
+	writeHeader("siad_renter_registry_queue_depth", "gauge", "Number of queued registry jobs.")
+	for _, s := range samples {
+		fmt.Fprintf(&sb, "siad_renter_registry_queue_depth{%v} %v\n", labels(s), s.queueDepth)
+	}
+	writeHeader("siad_renter_registry_jobs_total", "counter", "Number of completed registry jobs by result.")
+	for _, s := range samples {
+		results := []struct {
+			name  string
+			value uint64
+		}{
+			{"success", s.counters.Successes},
+			{"signature_error", s.counters.SignatureErrors},
+			{"network_error", s.counters.NetworkErrors},
+			{"revision_error", s.counters.RevisionErrors},
+		}
+		for _, r := range results {
+			fmt.Fprintf(&sb, "siad_renter_registry_jobs_total{%v,result=\"%v\"} %v\n", labels(s), r.name, r.value)
+		}
+	}
+	writeHeader("siad_renter_registry_cooldowns_total", "counter", "Number of times a registry queue was put on cooldown.")
+	for _, s := range samples {
+		fmt.Fprintf(&sb, "siad_renter_registry_cooldowns_total{%v} %v\n", labels(s), s.counters.Cooldowns)
+	}
+	writeHeader("siad_renter_registry_job_duration_seconds", "summary", "Duration of successful registry jobs.")
+	for _, s := range samples {
+		fmt.Fprintf(&sb, "siad_renter_registry_job_duration_seconds_sum{%v} %v\n", labels(s), s.counters.TotalLatency.Seconds())
+		fmt.Fprintf(&sb, "siad_renter_registry_job_duration_seconds_count{%v} %v\n", labels(s), s.counters.Successes)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
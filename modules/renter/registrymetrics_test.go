@@ -0,0 +1,59 @@
+package renter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/crypto"
+)
+
+// TestRegistryMetrics tests that the exported registry metrics reflect the
+// recorded job results.
+func TestRegistryMetrics(t *testing.T) {
+	t.Parallel()
+
+	// Simulate two successes and a signature error.
+	m := newRegistryJobMetrics()
+	m.AddSuccess(time.Second)
+	m.AddSuccess(2 * time.Second)
+	m.AddFailure(crypto.ErrInvalidSignature)
+
+	counters := m.Counters()
+	if counters.Successes != 2 || counters.SignatureErrors != 1 || counters.NetworkErrors != 0 || counters.Cooldowns != 1 {
+		t.Fatalf("wrong counters %+v", counters)
+	}
+
+	// Export them.
+	var buf bytes.Buffer
+	samples := []registryMetricsSample{
+		{host: "host", op: registryMetricsOpUpdate, queueDepth: 3, counters: counters},
+		{host: "host", op: registryMetricsOpRead},
+	}
+	if err := writeRegistryMetrics(&buf, samples); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	expected := []string{
+		`# TYPE siad_renter_registry_jobs_total counter`,
+		`siad_renter_registry_queue_depth{host="host",op="update"} 3`,
+		`siad_renter_registry_jobs_total{host="host",op="update",result="success"} 2`,
+		`siad_renter_registry_jobs_total{host="host",op="update",result="signature_error"} 1`,
+		`siad_renter_registry_jobs_total{host="host",op="update",result="network_error"} 0`,
+		`siad_renter_registry_jobs_total{host="host",op="read",result="success"} 0`,
+		`siad_renter_registry_cooldowns_total{host="host",op="update"} 1`,
+		`siad_renter_registry_job_duration_seconds_sum{host="host",op="update"} 3`,
+		`siad_renter_registry_job_duration_seconds_count{host="host",op="update"} 2`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing line %q in\n%v", line, out)
+		}
+	}
+
+	// Samples are sorted by op.
+	if strings.Index(out, `op="read"`) > strings.Index(out, `op="update"`) {
+		t.Fatal("samples aren't sorted")
+	}
+}
@@ -0,0 +1,131 @@
+package renter
+
+import (
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/threadgroup"
+	"go.sia.tech/siad/build"
+)
+
+var (
+	// defaultRegistryReadConcurrency is the default max number of ReadRegistry
+	// jobs executed at the same time across all workers.
+	defaultRegistryReadConcurrency = build.Select(build.Var{
+		Dev:      32,
+		Standard: 64,
+		Testnet:  64,
+		Testing:  16,
+	}).(int)
+
+	// errInvalidRegistryReadConcurrency is returned when trying to set a max
+	// concurrency below 1.
+	errInvalidRegistryReadConcurrency = errors.New("registry read concurrency must be at least 1")
+)
+
+type (
+	// registryReadPool bounds the number of ReadRegistry jobs which are
+	// executed at the same time across all workers. A worker needs to acquire
+	// a slot before taking a job from its queue. That way jobs which can't be
+	// executed yet stay in the worker's queue instead of being queued by the
+	// pool while their bandwidth is already accounted for. Workers which
+	// didn't get a slot are woken once a slot is released.
+	registryReadPool struct {
+		maxThreads int
+		threads    int
+		waiting    map[*worker]struct{}
+		mu         sync.Mutex
+
+		staticTG *threadgroup.ThreadGroup
+	}
+)
+
+// newRegistryReadPool creates a new pool which runs at most maxThreads jobs at
+// once.
+func newRegistryReadPool(tg *threadgroup.ThreadGroup, maxThreads int) *registryReadPool {
+	return &registryReadPool{
+		maxThreads: maxThreads,
+		waiting:    make(map[*worker]struct{}),
+		staticTG:   tg,
+	}
+}
+
+// callTryAcquire reserves a slot for a job of w. If all slots are taken,
+// false is returned and w is woken once a slot becomes available. A reserved
+// slot needs to be either passed to callLaunch or released with callRelease.
+func (p *registryReadPool) callTryAcquire(w *worker) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.threads >= p.maxThreads {
+		p.waiting[w] = struct{}{}
+		return false
+	}
+	p.threads++
+	return true
+}
+
+// callRelease releases a slot reserved with callTryAcquire.
+func (p *registryReadPool) callRelease() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.threads--
+	p.wakeWaiting()
+}
+
+// callLaunch runs fn on a new thread using a slot reserved with
+// callTryAcquire. The slot is released once fn returns or if the thread can't
+// be launched.
+func (p *registryReadPool) callLaunch(fn func()) error {
+	err := p.staticTG.Launch(func() {
+		defer p.callRelease()
+		fn()
+	})
+	if err != nil {
+		p.callRelease()
+	}
+	return err
+}
+
+// callSetMaxThreads updates the max number of threads. If the limit is raised,
+// the waiting workers are woken to use the new slots. If it is lowered,
+// running jobs finish but no new slots are handed out until the number of
+// threads drops below the limit.
+func (p *registryReadPool) callSetMaxThreads(maxThreads int) error {
+	if maxThreads < 1 {
+		return errInvalidRegistryReadConcurrency
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxThreads = maxThreads
+	if p.threads < p.maxThreads {
+		p.wakeWaiting()
+	}
+	return nil
+}
+
+// callStatus returns the number of running threads and waiting workers.
+func (p *registryReadPool) callStatus() (threads, waiting int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.threads, len(p.waiting)
+}
+
+// wakeWaiting wakes all the workers which are waiting for a slot. It needs to
+// be called while holding the lock.
+func (p *registryReadPool) wakeWaiting() {
+	for w := range p.waiting {
+		w.staticWake()
+		delete(p.waiting, w)
+	}
+}
+
+// SetRegistryReadConcurrency sets the max number of ReadRegistry jobs the
+// renter executes at the same time across all workers. Additional jobs are
+// queued until a running one finishes.
+func (r *Renter) SetRegistryReadConcurrency(n int) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.staticRegistryReadPool.callSetMaxThreads(n)
+}
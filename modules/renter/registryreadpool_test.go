@@ -0,0 +1,106 @@
+package renter
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/threadgroup"
+)
+
+// TestRegistryReadPool tests that the pool never runs more jobs at once than
+// allowed and that workers which don't get a slot are woken once a slot
+// becomes available.
+func TestRegistryReadPool(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	var tg threadgroup.ThreadGroup
+	maxThreads := 3
+	p := newRegistryReadPool(&tg, maxThreads)
+
+	// Simulate a large fan-out of workers which only launch a job if they
+	// get a slot and otherwise wait to be woken.
+	var running, maxRunning, done int64
+	var wg sync.WaitGroup
+	numWorkers := 100
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		w := &worker{wakeChan: make(chan struct{}, 1)}
+		go func() {
+			defer wg.Done()
+			for !p.callTryAcquire(w) {
+				<-w.wakeChan
+			}
+			err := p.callLaunch(func() {
+				n := atomic.AddInt64(&running, 1)
+				for {
+					max := atomic.LoadInt64(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt64(&maxRunning, max, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&running, -1)
+				atomic.AddInt64(&done, 1)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for atomic.LoadInt64(&done) != int64(numWorkers) {
+		time.Sleep(time.Millisecond)
+	}
+	if maxRunning > int64(maxThreads) {
+		t.Fatalf("max concurrency of %v exceeded: %v", maxThreads, maxRunning)
+	}
+
+	// Take all the slots. The next worker should be denied and registered
+	// as waiting.
+	for i := 0; i < maxThreads; i++ {
+		if !p.callTryAcquire(&worker{wakeChan: make(chan struct{}, 1)}) {
+			t.Fatal("failed to acquire free slot")
+		}
+	}
+	w := &worker{wakeChan: make(chan struct{}, 1)}
+	if p.callTryAcquire(w) {
+		t.Fatal("acquired slot of full pool")
+	}
+	if threads, waiting := p.callStatus(); threads != maxThreads || waiting != 1 {
+		t.Fatalf("expected %v threads and 1 waiting worker but got %v and %v", maxThreads, threads, waiting)
+	}
+
+	// Raising the limit wakes the waiting worker.
+	if err := p.callSetMaxThreads(maxThreads + 1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.wakeChan:
+	default:
+		t.Fatal("waiting worker wasn't woken")
+	}
+	if !p.callTryAcquire(w) {
+		t.Fatal("failed to acquire new slot")
+	}
+
+	// A failed launch releases the slot.
+	if err := tg.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.callLaunch(func() {}); err == nil {
+		t.Fatal("launch after stop should fail")
+	}
+	if threads, _ := p.callStatus(); threads != maxThreads {
+		t.Fatalf("expected %v threads but got %v", maxThreads, threads)
+	}
+
+	// The limit must be at least 1.
+	if err := p.callSetMaxThreads(0); err != errInvalidRegistryReadConcurrency {
+		t.Fatal("expected errInvalidRegistryReadConcurrency", err)
+	}
+}
@@ -225,6 +225,14 @@ type Renter struct {
 	// read registry stats
 	staticRRS *readRegistryStats
 
+	// staticRegistryHistory records the registry values read from and
+	// written to hosts if enabled.
+	staticRegistryHistory *registryHistory
+
+	// staticRegistryReadPool executes the ReadRegistry jobs of all workers
+	// with a bounded number of threads.
+	staticRegistryReadPool *registryReadPool
+
 	// Memory management
 	//
 	// registryMemoryManager is used for updating registry entries and reading
@@ -604,6 +612,9 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	if s.MaxDownloadSpeed < 0 || s.MaxUploadSpeed < 0 {
 		return errors.New("bandwidth limits cannot be negative")
 	}
+	if s.RegistryUpdateCooldowns.SignatureErr < 0 || s.RegistryUpdateCooldowns.NetworkErr < 0 {
+		return errors.New("registry update cooldowns cannot be negative")
+	}
 
 	// Set allowance.
 	err := r.hostContractor.SetAllowance(s.Allowance)
@@ -624,12 +635,16 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	id := r.mu.Lock()
 	r.persist.MaxDownloadSpeed = s.MaxDownloadSpeed
 	r.persist.MaxUploadSpeed = s.MaxUploadSpeed
+	r.persist.RegistryUpdateCooldowns = s.RegistryUpdateCooldowns
 	err = r.saveSync()
 	r.mu.Unlock(id)
 	if err != nil {
 		return err
 	}
 
+	// Set the registry update cooldowns of the workers.
+	r.staticWorkerPool.callSetRegistryUpdateCooldowns(s.RegistryUpdateCooldowns)
+
 	// Update the worker pool so that the changes are immediately apparent to
 	// users.
 	r.staticWorkerPool.callUpdate()
@@ -817,11 +832,15 @@ func (r *Renter) Settings() (modules.RenterSettings, error) {
 		return modules.RenterSettings{}, errors.AddContext(err, "error getting IPViolationsCheck:")
 	}
 	paused, endTime := r.uploadHeap.managedPauseStatus()
+	id := r.mu.RLock()
+	cooldowns := r.persist.RegistryUpdateCooldowns
+	r.mu.RUnlock(id)
 	return modules.RenterSettings{
-		Allowance:        r.hostContractor.Allowance(),
-		IPViolationCheck: enabled,
-		MaxDownloadSpeed: download,
-		MaxUploadSpeed:   upload,
+		Allowance:               r.hostContractor.Allowance(),
+		IPViolationCheck:        enabled,
+		MaxDownloadSpeed:        download,
+		MaxUploadSpeed:          upload,
+		RegistryUpdateCooldowns: cooldowns,
 		UploadsStatus: modules.UploadsStatus{
 			Paused:       paused,
 			PauseEndTime: endTime,
@@ -929,6 +948,8 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	r.staticStreamBufferSet = newStreamBufferSet(&r.tg)
 	r.staticUploadChunkDistributionQueue = newUploadChunkDistributionQueue(r)
 	r.staticRRS = newReadRegistryStats(ReadRegistryBackgroundTimeout, readRegistryStatsInterval, readRegistryStatsDecay, readRegistryStatsPercentile)
+	r.staticRegistryHistory = newRegistryHistory()
+	r.staticRegistryReadPool = newRegistryReadPool(&r.tg, defaultRegistryReadConcurrency)
 	close(r.uploadHeap.pauseChan)
 
 	// Seed the rrs.
@@ -1005,6 +1026,15 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	// for bubble updates are processed.
 	go r.staticBubbleScheduler.callThreadedProcessBubbleUpdates()
 
+	// Stop the metadata scanner on shutdown since it depends on the bubble.
+	err = r.tg.OnStop(func() error {
+		r.staticFileSystem.StopMetadataScanner()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Unsubscribe on shutdown.
 	err = r.tg.OnStop(func() error {
 		cs.Unsubscribe(r)
@@ -79,14 +79,15 @@ type (
 		staticHostPubKeyStr string
 
 		// Job queues for the worker.
-		staticJobDownloadSnapshotQueue *jobDownloadSnapshotQueue
-		staticJobHasSectorQueue        *jobHasSectorQueue
-		staticJobReadQueue             *jobReadQueue
-		staticJobLowPrioReadQueue      *jobReadQueue
-		staticJobReadRegistryQueue     *jobReadRegistryQueue
-		staticJobRenewQueue            *jobRenewQueue
-		staticJobUpdateRegistryQueue   *jobUpdateRegistryQueue
-		staticJobUploadSnapshotQueue   *jobUploadSnapshotQueue
+		staticJobDownloadSnapshotQueue  *jobDownloadSnapshotQueue
+		staticJobEnumerateRegistryQueue *jobEnumerateRegistryQueue
+		staticJobHasSectorQueue         *jobHasSectorQueue
+		staticJobReadQueue              *jobReadQueue
+		staticJobLowPrioReadQueue       *jobReadQueue
+		staticJobReadRegistryQueue      *jobReadRegistryQueue
+		staticJobRenewQueue             *jobRenewQueue
+		staticJobUpdateRegistryQueue    *jobUpdateRegistryQueue
+		staticJobUploadSnapshotQueue    *jobUploadSnapshotQueue
 
 		// Upload variables.
 		unprocessedChunks         *uploadChunks // Yet unprocessed work items.
@@ -116,6 +117,14 @@ type (
 		// registry entries.
 		staticRegistryCache *registryRevisionCache
 
+		// staticRegistryCapabilitiesCache caches the registry features
+		// supported by the worker's host.
+		staticRegistryCapabilitiesCache *registryCapabilitiesCache
+
+		// staticRegistryIdempotencyCache remembers recently completed
+		// registry updates by their idempotency key.
+		staticRegistryIdempotencyCache *registryIdempotencyCache
+
 		// staticSetInitialEstimates is an object that ensures the initial queue
 		// estimates of the HS and RJ queues are only set once.
 		staticSetInitialEstimates sync.Once
@@ -205,7 +214,7 @@ func (w *worker) staticWake() {
 }
 
 // newWorker will create and return a worker that is ready to receive jobs.
-func (r *Renter) newWorker(hostPubKey types.SiaPublicKey) (*worker, error) {
+func (r *Renter) newWorker(hostPubKey types.SiaPublicKey, cooldowns modules.RegistryUpdateCooldowns) (*worker, error) {
 	_, ok, err := r.hostDB.Host(hostPubKey)
 	if err != nil {
 		return nil, errors.AddContext(err, "could not find host entry")
@@ -236,7 +245,9 @@ func (r *Renter) newWorker(hostPubKey types.SiaPublicKey) (*worker, error) {
 		staticAccount:       account,
 		staticBalanceTarget: balanceTarget,
 
-		staticRegistryCache: newRegistryCache(registryCacheSize),
+		staticRegistryCache:             newRegistryCache(registryCacheSize),
+		staticRegistryCapabilitiesCache: newRegistryCapabilitiesCache(registryCapabilitiesTTL),
+		staticRegistryIdempotencyCache:  newRegistryIdempotencyCache(registryIdempotencyMaxEntries, registryIdempotencyTTL),
 
 		staticSubscriptionInfo: &subscriptionInfos{
 			subscriptions:  make(map[modules.RegistryEntryID]*subscription),
@@ -263,7 +274,8 @@ func (r *Renter) newWorker(hostPubKey types.SiaPublicKey) (*worker, error) {
 	w.initJobRenewQueue()
 	w.initJobDownloadSnapshotQueue()
 	w.initJobReadRegistryQueue()
-	w.initJobUpdateRegistryQueue()
+	w.initJobUpdateRegistryQueue(cooldowns)
+	w.initJobEnumerateRegistryQueue()
 	w.initJobUploadSnapshotQueue()
 
 	// Close the worker when the renter is stopped.
@@ -196,7 +196,8 @@ func TestManagedAsyncReady(t *testing.T) {
 	w.initJobReadQueue()
 	w.initJobLowPrioReadQueue()
 	w.initJobReadRegistryQueue()
-	w.initJobUpdateRegistryQueue()
+	w.initJobUpdateRegistryQueue(defaultRegistryUpdateCooldowns)
+	w.initJobEnumerateRegistryQueue()
 
 	timeInFuture := time.Now().Add(time.Hour)
 	timeInPast := time.Now().Add(-time.Hour)
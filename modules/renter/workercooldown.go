@@ -43,3 +43,22 @@ func cooldownUntil(consecutiveFailures uint64) time.Time {
 	}
 	return time.Now().Add(randCooldown)
 }
+
+// cooldownUntilWithBase returns the next time a job should be attempted given
+// the number of consecutive failures and a base cooldown. The base cooldown is
+// doubled for each consecutive failure. If the base is zero, the randomized
+// default of cooldownUntil is used.
+func cooldownUntilWithBase(consecutiveFailures uint64, base time.Duration) time.Time {
+	if base == 0 {
+		return cooldownUntil(consecutiveFailures)
+	}
+	// Cap the number of consecutive failures to 10.
+	if consecutiveFailures > cooldownMaxConsecutiveFailures {
+		consecutiveFailures = cooldownMaxConsecutiveFailures
+	}
+	cooldown := base
+	for i := uint64(0); i < consecutiveFailures; i++ {
+		cooldown *= 2
+	}
+	return time.Now().Add(cooldown)
+}
@@ -0,0 +1,263 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// jobEnumerateRegistryPerformanceDecay defines how much the average
+	// performance is decayed each time a new datapoint is added. The jobs use
+	// an exponential weighted average.
+	jobEnumerateRegistryPerformanceDecay = 0.9
+)
+
+// ErrEnumerationUnsupported is returned by EnumerateRegistry if the host
+// doesn't support enumerating the registry entries of a public key.
+var ErrEnumerationUnsupported = errors.New("host doesn't support registry enumeration")
+
+// errTooManyEnumeratedEntries is returned if a host returns more entries than
+// allowed by the protocol.
+var errTooManyEnumeratedEntries = errors.New("host returned too many registry entries")
+
+type (
+	// jobEnumerateRegistry contains information about an EnumerateRegistry
+	// query.
+	jobEnumerateRegistry struct {
+		staticSiaPublicKey types.SiaPublicKey
+		staticStart        crypto.Hash
+		staticLimit        uint64
+
+		staticResponseChan chan *jobEnumerateRegistryResponse // Channel to send a response down
+
+		*jobGeneric
+	}
+
+	// jobEnumerateRegistryQueue is a list of EnumerateRegistry jobs that have
+	// been assigned to the worker.
+	jobEnumerateRegistryQueue struct {
+		// These variables contain an exponential weighted average of the
+		// worker's recent performance for jobEnumerateRegistryQueue.
+		weightedJobTime float64
+
+		*jobGenericQueue
+	}
+
+	// jobEnumerateRegistryResponse contains the result of an
+	// EnumerateRegistry query.
+	jobEnumerateRegistryResponse struct {
+		staticEntries []modules.RegistryEnumerateEntry
+		staticMore    bool
+		staticNext    crypto.Hash
+		staticErr     error
+	}
+)
+
+// newJobEnumerateRegistry is a helper method to create a new EnumerateRegistry
+// job.
+func (w *worker) newJobEnumerateRegistry(ctx context.Context, responseChan chan *jobEnumerateRegistryResponse, spk types.SiaPublicKey, start crypto.Hash, limit uint64) *jobEnumerateRegistry {
+	return &jobEnumerateRegistry{
+		staticSiaPublicKey: spk,
+		staticStart:        start,
+		staticLimit:        limit,
+		staticResponseChan: responseChan,
+		jobGeneric:         newJobGeneric(ctx, w.staticJobEnumerateRegistryQueue, nil),
+	}
+}
+
+// callDiscard will discard a job, sending the provided error.
+func (j *jobEnumerateRegistry) callDiscard(err error) {
+	j.staticSendResponse(&jobEnumerateRegistryResponse{
+		staticErr: errors.Extend(err, ErrJobDiscarded),
+	})
+}
+
+// staticSendResponse sends the result of the job asynchronously.
+func (j *jobEnumerateRegistry) staticSendResponse(response *jobEnumerateRegistryResponse) {
+	w := j.staticQueue.staticWorker()
+	errLaunch := w.renter.tg.Launch(func() {
+		select {
+		case j.staticResponseChan <- response:
+		case <-j.staticCtx.Done():
+		case <-w.renter.tg.StopChan():
+		}
+	})
+	if errLaunch != nil {
+		w.renter.log.Debugln("staticSendResponse: launch failed", response.staticErr)
+	}
+}
+
+// callExecute will run the EnumerateRegistry job.
+func (j *jobEnumerateRegistry) callExecute() {
+	start := time.Now()
+
+	resp, err := j.managedEnumerateRegistry()
+	if err != nil {
+		j.staticSendResponse(&jobEnumerateRegistryResponse{staticErr: err})
+		j.staticQueue.callReportFailure(err)
+		return
+	}
+	jobTime := time.Since(start)
+
+	// Send the response and report success.
+	j.staticSendResponse(&jobEnumerateRegistryResponse{
+		staticEntries: resp.Entries,
+		staticMore:    resp.More,
+		staticNext:    resp.Next,
+	})
+	j.staticQueue.callReportSuccess()
+
+	// Update the performance stats on the queue.
+	jq := j.staticQueue.(*jobEnumerateRegistryQueue)
+	jq.mu.Lock()
+	jq.weightedJobTime = expMovingAvg(jq.weightedJobTime, float64(jobTime), jobEnumerateRegistryPerformanceDecay)
+	jq.mu.Unlock()
+}
+
+// callExpectedBandwidth returns the bandwidth that is expected to be consumed
+// by the job.
+func (j *jobEnumerateRegistry) callExpectedBandwidth() (ul, dl uint64) {
+	return enumerateRegistryJobExpectedBandwidth()
+}
+
+// managedEnumerateRegistry performs the RegistryEnumerate RPC on the host and
+// returns a page of the entries the host stores for the job's public key.
+func (j *jobEnumerateRegistry) managedEnumerateRegistry() (_ modules.RPCRegistryEnumerateResponse, err error) {
+	w := j.staticQueue.staticWorker()
+
+	// Defer a function that schedules a price table update in case we received
+	// an error that indicates the host deems our price table invalid.
+	defer func() {
+		if modules.IsPriceTableInvalidErr(err) {
+			w.staticTryForcePriceTableUpdate()
+		}
+	}()
+
+	// Compute the cost including the bandwidth. We pay for a full page and
+	// the host refunds everything exceeding the cost of the returned entries.
+	pt := w.staticPriceTable().staticPriceTable
+	ulBandwidth, dlBandwidth := j.callExpectedBandwidth()
+	cost := modules.RegistryEnumerateCost(&pt, j.staticLimit).Add(modules.MDMBandwidthCost(pt, ulBandwidth, dlBandwidth))
+
+	// track the withdrawal
+	var refund types.Currency
+	w.staticAccount.managedTrackWithdrawal(cost)
+	defer func() {
+		w.staticAccount.managedCommitWithdrawal(categoryRegistryRead, cost.Sub(refund), refund, err == nil)
+	}()
+
+	// create a new stream
+	stream, err := w.staticNewStream()
+	if err != nil {
+		return modules.RPCRegistryEnumerateResponse{}, errors.AddContext(err, "Unable to create a new stream")
+	}
+	defer func() {
+		if err := stream.Close(); err != nil {
+			w.renter.log.Println("ERROR: failed to close stream", err)
+		}
+	}()
+
+	// Write the specifier, price table uid, payment and request at once.
+	buffer := bytes.NewBuffer(nil)
+	err = modules.RPCWrite(buffer, modules.RPCRegistryEnumerate)
+	if err != nil {
+		return modules.RPCRegistryEnumerateResponse{}, err
+	}
+	err = modules.RPCWrite(buffer, pt.UID)
+	if err != nil {
+		return modules.RPCRegistryEnumerateResponse{}, err
+	}
+	err = modules.RPCWrite(buffer, modules.RPCRegistryEnumerateRequest{
+		PubKey: j.staticSiaPublicKey,
+		Start:  j.staticStart,
+		Limit:  j.staticLimit,
+	})
+	if err != nil {
+		return modules.RPCRegistryEnumerateResponse{}, err
+	}
+	err = w.staticAccount.ProvidePayment(buffer, cost, pt.HostBlockHeight)
+	if err != nil {
+		return modules.RPCRegistryEnumerateResponse{}, err
+	}
+	_, err = buffer.WriteTo(stream)
+	if err != nil {
+		return modules.RPCRegistryEnumerateResponse{}, err
+	}
+
+	// Read the response.
+	var resp modules.RPCRegistryEnumerateResponse
+	err = modules.RPCRead(stream, &resp)
+	if err != nil {
+		return modules.RPCRegistryEnumerateResponse{}, errors.AddContext(err, "failed to read RegistryEnumerateResponse")
+	}
+	if uint64(len(resp.Entries)) > j.staticLimit {
+		return modules.RPCRegistryEnumerateResponse{}, errTooManyEnumeratedEntries
+	}
+	refund = cost.Sub(modules.RegistryEnumerateCost(&pt, uint64(len(resp.Entries))))
+	return resp, nil
+}
+
+// initJobEnumerateRegistryQueue will init the queue for the EnumerateRegistry
+// jobs.
+func (w *worker) initJobEnumerateRegistryQueue() {
+	// Sanity check that there is no existing job queue.
+	if w.staticJobEnumerateRegistryQueue != nil {
+		w.renter.log.Critical("incorret call on initJobEnumerateRegistryQueue")
+		return
+	}
+
+	w.staticJobEnumerateRegistryQueue = &jobEnumerateRegistryQueue{
+		jobGenericQueue: newJobGenericQueue(w),
+	}
+}
+
+// EnumerateRegistry is a helper method to run an EnumerateRegistry job on a
+// worker. It returns a page of the tweaks and revisions of the registry
+// entries the worker's host stores for the provided public key. Only entries
+// with a tweak larger than or equal to start are returned and the page
+// contains at most limit entries. A limit of 0 or a limit larger than
+// modules.MaxRegistryEnumerateEntries is capped to the latter. If the host
+// stores more entries, more is true and the next page starts at next.
+// ErrEnumerationUnsupported is returned if the host doesn't support
+// enumerating entries.
+func (w *worker) EnumerateRegistry(ctx context.Context, spk types.SiaPublicKey, start crypto.Hash, limit uint64) (entries []modules.RegistryEnumerateEntry, next crypto.Hash, more bool, err error) {
+	// Check if the host supports enumerating the registry.
+	if !w.staticRegistryCapabilities().Enumeration {
+		return nil, crypto.Hash{}, false, ErrEnumerationUnsupported
+	}
+	if limit == 0 || limit > modules.MaxRegistryEnumerateEntries {
+		limit = modules.MaxRegistryEnumerateEntries
+	}
+
+	enumerateRegistryRespChan := make(chan *jobEnumerateRegistryResponse)
+	jer := w.newJobEnumerateRegistry(ctx, enumerateRegistryRespChan, spk, start, limit)
+
+	// Add the job to the queue.
+	if !w.staticJobEnumerateRegistryQueue.callAdd(jer) {
+		return nil, crypto.Hash{}, false, errors.New("worker unavailable")
+	}
+
+	// Wait for the response.
+	var resp *jobEnumerateRegistryResponse
+	select {
+	case <-ctx.Done():
+		return nil, crypto.Hash{}, false, errors.New("EnumerateRegistry interrupted")
+	case resp = <-enumerateRegistryRespChan:
+	}
+	return resp.staticEntries, resp.staticNext, resp.staticMore, resp.staticErr
+}
+
+// enumerateRegistryJobExpectedBandwidth is a helper function that returns the
+// expected bandwidth consumption of an EnumerateRegistry job. The response is
+// bounded by modules.MaxRegistryEnumerateEntries which fits within a few
+// frames.
+func enumerateRegistryJobExpectedBandwidth() (ul, dl uint64) {
+	return ethernetMTU, 3 * ethernetMTU
+}
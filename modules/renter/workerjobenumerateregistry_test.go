@@ -0,0 +1,98 @@
+package renter
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestEnumerateRegistryJob tests enumerating the registry entries of a public
+// key on a host.
+func TestEnumerateRegistryJob(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Enumerating a key without entries should return nothing.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	entries, _, more, err := wt.EnumerateRegistry(context.Background(), spk, crypto.Hash{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 || more {
+		t.Fatal("expected no entries", entries, more)
+	}
+
+	// Store a few entries on the host.
+	expected := make(map[crypto.Hash]uint64)
+	for i := 0; i < 3; i++ {
+		var tweak crypto.Hash
+		fastrand.Read(tweak[:])
+		rev := fastrand.Uint64n(1000)
+		rv := modules.NewRegistryValue(tweak, fastrand.Bytes(10), rev, modules.RegistryTypeWithoutPubkey).Sign(sk)
+		err = wt.UpdateRegistry(context.Background(), spk, rv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[tweak] = rev
+	}
+
+	// Enumerate them.
+	entries, _, more, err = wt.EnumerateRegistry(context.Background(), spk, crypto.Hash{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if more {
+		t.Fatal("there shouldn't be more entries")
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %v entries but got %v", len(expected), len(entries))
+	}
+	for _, entry := range entries {
+		if rev, exists := expected[entry.Tweak]; !exists || rev != entry.Revision {
+			t.Fatal("wrong entry", entry)
+		}
+	}
+
+	// Enumerate them one page at a time.
+	var start crypto.Hash
+	for i := 0; i < len(expected); i++ {
+		page, next, more, err := wt.EnumerateRegistry(context.Background(), spk, start, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 1 || page[0] != entries[i] {
+			t.Fatal("wrong page", page)
+		}
+		if more != (i < len(expected)-1) {
+			t.Fatal("wrong value for more", i, more)
+		}
+		start = next
+	}
+
+	// Pretend that the host doesn't support enumeration.
+	caps := wt.staticRegistryCapabilities()
+	caps.Enumeration = false
+	wt.staticRegistryCapabilitiesCache.Set(caps)
+	_, _, _, err = wt.EnumerateRegistry(context.Background(), spk, crypto.Hash{}, 0)
+	if !errors.Contains(err, ErrEnumerationUnsupported) {
+		t.Fatal("expected ErrEnumerationUnsupported", err)
+	}
+}
@@ -199,7 +199,12 @@ func (jq *jobGenericQueue) callLen() int {
 func (jq *jobGenericQueue) callNext() workerJob {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
+	return jq.next()
+}
 
+// next returns the next job in the worker queue. If there is no job in the
+// queue, 'nil' will be returned.
+func (jq *jobGenericQueue) next() workerJob {
 	// Loop through the jobs, looking for the first job that hasn't yet been
 	// canceled. Remove jobs from the queue along the way.
 	for job := jq.jobs.Front(); job != nil; job = job.Next() {
@@ -230,12 +235,19 @@ func (jq *jobGenericQueue) callOnCooldown() bool {
 // cause all remaining jobs in the queue to be discarded, and will put the queue
 // on cooldown.
 func (jq *jobGenericQueue) callReportFailure(err error) {
+	jq.callReportFailureWithCooldown(err, 0)
+}
+
+// callReportFailureWithCooldown works like callReportFailure but uses the
+// provided base cooldown instead of the default one. A base of zero uses the
+// default cooldown.
+func (jq *jobGenericQueue) callReportFailureWithCooldown(err error, base time.Duration) {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
 
 	err = errors.AddContext(err, "discarding all jobs in this queue and going on cooldown")
 	jq.discardAll(err)
-	jq.cooldownUntil = cooldownUntil(jq.consecutiveFailures)
+	jq.cooldownUntil = cooldownUntilWithBase(jq.consecutiveFailures, base)
 	jq.consecutiveFailures++
 	jq.recentErr = err
 	jq.recentErrTime = time.Now()
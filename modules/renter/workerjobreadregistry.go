@@ -26,7 +26,47 @@ const (
 	jobReadRegistryPerformanceDecay = 0.9
 )
 
+var (
+	// readRegistryJobTimeout is the default amount of time after which a
+	// ReadRegistry job is aborted if the host doesn't respond. A job is
+	// aborted earlier if its context has an earlier deadline.
+	readRegistryJobTimeout = build.Select(build.Var{
+		Dev:      30 * time.Second,
+		Standard: time.Minute,
+		Testnet:  time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
+	// errReadRegistryJobTimeout is returned by a ReadRegistry job if the host
+	// didn't respond in time.
+	errReadRegistryJobTimeout = errors.New("ReadRegistry job timed out")
+)
+
+const (
+	// registryEntryPresent indicates that the host returned a registry entry
+	// with a valid signature.
+	registryEntryPresent registryEntryStatus = iota
+
+	// registryEntryAbsentUnverified indicates that the host claims that the
+	// entry doesn't exist without providing a proof. None of the current
+	// host versions support proving the absence of an entry, so this is
+	// the only status returned for missing entries at the moment.
+	registryEntryAbsentUnverified
+)
+
 type (
+	// registryEntryStatus describes how trustworthy the result of a registry
+	// read is.
+	registryEntryStatus int
+
+	// registryReadWithProof is the result of ReadRegistryWithProof.
+	registryReadWithProof struct {
+		// Value is the entry returned by the host. It is only set if Status
+		// is registryEntryPresent.
+		Value  *modules.SignedRegistryValue
+		Status registryEntryStatus
+	}
+
 	// jobReadRegistry contains information about a ReadRegistry query.
 	jobReadRegistry struct {
 		staticSiaPublicKey types.SiaPublicKey
@@ -44,6 +84,13 @@ type (
 		// worker's recent performance for jobReadRegistryQueue.
 		weightedJobTime float64
 
+		// timeout is the amount of time after which a job is aborted if its
+		// context doesn't have an earlier deadline.
+		timeout time.Duration
+
+		// staticMetrics counts the results of the queue's jobs.
+		staticMetrics *registryJobMetrics
+
 		*jobGenericQueue
 	}
 
@@ -84,6 +131,13 @@ func parseSignedRegistryValueResponse(resp []byte, needPKAndTweak bool, version
 
 // lookupsRegistry looks up a registry on the host and verifies its signature.
 func lookupRegistry(w *worker, spk types.SiaPublicKey, tweak crypto.Hash) (*modules.SignedRegistryValue, error) {
+	return lookupRegistryWithDeadline(w, spk, tweak, time.Time{})
+}
+
+// lookupRegistryWithDeadline looks up a registry on the host like
+// lookupRegistry but aborts the lookup once the deadline is reached. A zero
+// deadline uses the default RPC deadline.
+func lookupRegistryWithDeadline(w *worker, spk types.SiaPublicKey, tweak crypto.Hash, deadline time.Time) (*modules.SignedRegistryValue, error) {
 	// Create the program.
 	pt := w.staticPriceTable().staticPriceTable
 	pb := modules.NewProgramBuilder(&pt, 0) // 0 duration since ReadRegistry doesn't depend on it.
@@ -108,7 +162,7 @@ func lookupRegistry(w *worker, spk types.SiaPublicKey, tweak crypto.Hash) (*modu
 	cost = cost.Add(bandwidthCost)
 
 	// Execute the program and parse the responses.
-	responses, _, err := w.managedExecuteProgram(program, programData, types.FileContractID{}, categoryRegistryRead, cost)
+	responses, _, err := w.managedExecuteProgramWithDeadline(program, programData, types.FileContractID{}, categoryRegistryRead, cost, deadline)
 	if err != nil {
 		return nil, errors.AddContext(err, "Unable to execute program")
 	}
@@ -198,14 +252,40 @@ func (j *jobReadRegistry) callExecute() {
 		}
 	}
 
+	// Determine the deadline of the lookup. A caller can shorten the queue's
+	// timeout by passing a context with an earlier deadline.
+	jq := j.staticQueue.(*jobReadRegistryQueue)
+	deadline := start.Add(jq.callTimeout())
+	ctxDeadline, ok := j.staticCtx.Deadline()
+	callerDeadline := ok && ctxDeadline.Before(deadline)
+	if callerDeadline {
+		deadline = ctxDeadline
+	}
+
 	// Read the value.
-	srv, err := lookupRegistry(w, j.staticSiaPublicKey, j.staticTweak)
+	srv, err := lookupRegistryWithDeadline(w, j.staticSiaPublicKey, j.staticTweak, deadline)
+	if err != nil && !time.Now().Before(deadline) {
+		err = errors.Compose(errReadRegistryJobTimeout, err)
+		if callerDeadline {
+			// The caller gave up on the job. That's not the host's fault so
+			// we don't report a failure.
+			sendResponse(nil, err)
+			return
+		}
+	}
 	if err != nil {
 		sendResponse(nil, err)
 		j.staticQueue.callReportFailure(err)
+		jq.staticMetrics.AddFailure(err)
 		return
 	}
 
+	// Record the value before checking it against the cache to also keep
+	// track of hosts returning outdated values.
+	if srv != nil {
+		w.renter.staticRegistryHistory.Add(j.staticSiaPublicKey, w.staticHostPubKey, *srv, false)
+	}
+
 	// Check if we have a cached version of the looked up entry. If the new entry
 	// has a higher revision number we update it. If it has a lower one we know that
 	// the host should be punished for losing it or trying to cheat us.
@@ -216,6 +296,7 @@ func (j *jobReadRegistry) callExecute() {
 		if cached && cachedRevision > srv.Revision {
 			sendResponse(nil, errHostLowerRevisionThanCache)
 			j.staticQueue.callReportFailure(errHostLowerRevisionThanCache)
+			jq.staticMetrics.AddFailure(errHostLowerRevisionThanCache)
 			w.staticRegistryCache.Set(j.staticSiaPublicKey, *srv, true) // adjust the cache
 			return
 		} else if !cached || srv.Revision > cachedRevision {
@@ -229,9 +310,9 @@ func (j *jobReadRegistry) callExecute() {
 	// Send the response and report success.
 	sendResponse(srv, nil)
 	j.staticQueue.callReportSuccess()
+	jq.staticMetrics.AddSuccess(jobTime)
 
 	// Update the performance stats on the queue.
-	jq := j.staticQueue.(*jobReadRegistryQueue)
 	jq.mu.Lock()
 	jq.weightedJobTime = expMovingAvg(jq.weightedJobTime, float64(jobTime), jobReadRegistryPerformanceDecay)
 	jq.mu.Unlock()
@@ -252,12 +333,35 @@ func (w *worker) initJobReadRegistryQueue() {
 	}
 
 	w.staticJobReadRegistryQueue = &jobReadRegistryQueue{
+		timeout:         readRegistryJobTimeout,
+		staticMetrics:   newRegistryJobMetrics(),
 		jobGenericQueue: newJobGenericQueue(w),
 	}
 }
 
+// callSetTimeout sets the amount of time after which jobs are aborted if their
+// context doesn't have an earlier deadline.
+func (jq *jobReadRegistryQueue) callSetTimeout(timeout time.Duration) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	jq.timeout = timeout
+}
+
+// callTimeout returns the amount of time after which jobs are aborted if their
+// context doesn't have an earlier deadline.
+func (jq *jobReadRegistryQueue) callTimeout() time.Duration {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	return jq.timeout
+}
+
 // ReadRegistry is a helper method to run a ReadRegistry job on a worker.
 func (w *worker) ReadRegistry(ctx context.Context, spk types.SiaPublicKey, tweak crypto.Hash) (*modules.SignedRegistryValue, error) {
+	// Check if the host supports registry reads.
+	if !w.staticRegistryCapabilities().Read {
+		return nil, errRegistryUnsupported
+	}
+
 	readRegistryRespChan := make(chan *jobReadRegistryResponse)
 	jur := w.newJobReadRegistry(ctx, readRegistryRespChan, spk, tweak)
 
@@ -281,6 +385,26 @@ func (w *worker) ReadRegistry(ctx context.Context, spk types.SiaPublicKey, tweak
 	return resp.staticSignedRegistryValue, resp.staticErr
 }
 
+// ReadRegistryWithProof reads a registry entry from the worker's host like
+// ReadRegistry but returns the result together with the evidence backing it.
+// A present entry is backed by its signature. If the host claims that the
+// entry doesn't exist, the result is marked as unverified since hosts can't
+// prove the absence of an entry. If the host couldn't answer, an error is
+// returned.
+func (w *worker) ReadRegistryWithProof(ctx context.Context, spk types.SiaPublicKey, tweak crypto.Hash) (registryReadWithProof, error) {
+	srv, err := w.ReadRegistry(ctx, spk, tweak)
+	if err != nil {
+		return registryReadWithProof{}, err
+	}
+	if srv == nil {
+		return registryReadWithProof{Status: registryEntryAbsentUnverified}, nil
+	}
+	return registryReadWithProof{
+		Value:  srv,
+		Status: registryEntryPresent,
+	}, nil
+}
+
 // readRegistryJobExpectedBandwidth is a helper function that returns the
 // expected bandwidth consumption of a ReadRegistry job. This helper function
 // enables getting at the expected bandwidth without having to instantiate a
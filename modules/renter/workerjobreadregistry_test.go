@@ -234,3 +234,127 @@ func TestReadRegistryCachedUpdated(t *testing.T) {
 		t.Fatal("invalid cached value")
 	}
 }
+
+// TestReadRegistryWithProof tests ReadRegistryWithProof for present and absent
+// entries.
+func TestReadRegistryWithProof(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a registry value.
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	data := fastrand.Bytes(modules.RegistryDataSize)
+	rev := fastrand.Uint64n(1000)
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	rv := modules.NewRegistryValue(tweak, data, rev, modules.RegistryTypeWithoutPubkey).Sign(sk)
+
+	// Read the entry before it exists. It should be an unverified absence.
+	res, err := wt.ReadRegistryWithProof(context.Background(), spk, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != registryEntryAbsentUnverified || res.Value != nil {
+		t.Fatal("expected unverified absence", res.Status, res.Value)
+	}
+
+	// Update the registry.
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the entry again. It should be present.
+	res, err = wt.ReadRegistryWithProof(context.Background(), spk, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != registryEntryPresent || res.Value == nil {
+		t.Fatal("expected present entry", res.Status, res.Value)
+	}
+	if !reflect.DeepEqual(*res.Value, rv) {
+		t.Fatal("entries don't match")
+	}
+}
+
+// TestReadRegistryJobTimeout tests that ReadRegistry jobs are aborted if the
+// host doesn't respond in time.
+func TestReadRegistryJobTimeout(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	deps := dependencies.NewDependencyHostBlockRPC()
+	deps.Disable()
+	wt, err := newWorkerTesterCustomDependency(t.Name(), modules.ProdDependencies, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		deps.Disable()
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Make the host unresponsive.
+	deps.Enable()
+
+	_, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	jq := wt.staticJobReadRegistryQueue
+	recentErr := func() error {
+		jq.mu.Lock()
+		defer jq.mu.Unlock()
+		return jq.recentErr
+	}
+
+	// Run a job with a context that expires before the queue's timeout. The
+	// job should return once the context expires without reporting a failure.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	wt.newJobReadRegistry(ctx, make(chan *jobReadRegistryResponse, 1), spk, tweak).callExecute()
+	if elapsed := time.Since(start); elapsed > readRegistryJobTimeout {
+		t.Fatal("job didn't respect the context's deadline", elapsed)
+	}
+	if err := recentErr(); err != nil {
+		t.Fatal("caller timeout shouldn't be reported as failure", err)
+	}
+
+	// Run a job without a deadline but a short timeout on the queue. The
+	// job should time out and report the failure.
+	jq.callSetTimeout(time.Second)
+	responseChan := make(chan *jobReadRegistryResponse, 1)
+	start = time.Now()
+	wt.newJobReadRegistry(context.Background(), responseChan, spk, tweak).callExecute()
+	if elapsed := time.Since(start); elapsed > readRegistryJobTimeout {
+		t.Fatal("job didn't respect the queue's timeout", elapsed)
+	}
+	resp := <-responseChan
+	if !errors.Contains(resp.staticErr, errReadRegistryJobTimeout) {
+		t.Fatal("wrong error", resp.staticErr)
+	}
+	if err := recentErr(); !errors.Contains(err, errReadRegistryJobTimeout) {
+		t.Fatal("wrong recent error", err)
+	}
+}
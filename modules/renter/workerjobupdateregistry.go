@@ -2,14 +2,18 @@ package renter
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"strings"
 	"time"
 
 	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 
 	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/threadgroup"
 )
 
 const (
@@ -17,6 +21,10 @@ const (
 	// performance is decayed each time a new datapoint is added. The jobs use
 	// an exponential weighted average.
 	jobUpdateRegistryPerformanceDecay = 0.9
+
+	// updateRegistryBumpMaxRetries is the number of times UpdateRegistryBump
+	// retries an update after losing a race against another writer.
+	updateRegistryBumpMaxRetries = 3
 )
 
 // errHostOutdatedProof is returned if the host provides a proof that has a
@@ -28,6 +36,18 @@ var errHostOutdatedProof = errors.New("host returned proof with invalid revision
 // supposed to have according to the cache.
 var errHostLowerRevisionThanCache = errors.New("host claims that the latest revision it knows is lower than the one in the cache")
 
+// errRegistryBumpRetriesExhausted is returned by UpdateRegistryBump if the
+// update kept failing due to concurrent updates of the same entry.
+var errRegistryBumpRetriesExhausted = errors.New("failed to bump registry entry due to concurrent updates")
+
+// ErrRegistryDataTooLargeForHost is returned when updating a registry entry on
+// a host which supports less data per entry than the entry contains.
+var ErrRegistryDataTooLargeForHost = errors.New("registry data exceeds the host's max registry data size")
+
+// defaultRegistryUpdateCooldowns are the cooldowns used by the workers of the
+// renter. They use the default cooldown for every error class.
+var defaultRegistryUpdateCooldowns = modules.RegistryUpdateCooldowns{}
+
 type (
 	// jobUpdateRegistry contains information about a UpdateRegistry query.
 	jobUpdateRegistry struct {
@@ -36,6 +56,22 @@ type (
 
 		staticResponseChan chan *jobUpdateRegistryResponse // Channel to send a response down
 
+		// staticCallback is called with the result of the job instead of
+		// sending a response down staticResponseChan if it is set.
+		staticCallback func(error)
+
+		// staticCreationTime is used to determine which jobs can be batched
+		// together.
+		staticCreationTime time.Time
+
+		// staticBatchID is set by callAddBatch. Jobs with the same non-zero
+		// batch id are always executed together within a single program.
+		staticBatchID uint64
+
+		// staticRequestedRetention is the min number of blocks the renter
+		// asks the host to keep the entry for. 0 means no preference.
+		staticRequestedRetention types.BlockHeight
+
 		*jobGeneric
 	}
 
@@ -46,14 +82,56 @@ type (
 		// worker's recent performance for jobUpdateRegistryQueue.
 		weightedJobTime float64
 
+		// cooldowns are the base cooldowns for failed jobs. Errors caused by
+		// the host providing a valid proof of a higher revision never result
+		// in a cooldown.
+		cooldowns modules.RegistryUpdateCooldowns
+
+		// batchWindow is the amount of time jobs are held back to be batched
+		// with jobs created shortly after them. 0 disables batching.
+		// batchWakeTime is the time at which the worker is woken to execute
+		// the currently held back jobs and inFlight is the number of batches
+		// currently being executed.
+		batchWindow   time.Duration
+		batchWakeTime time.Time
+		inFlight      uint64
+
+		// lastBatchID is the batch id which was assigned to the jobs of the
+		// most recent callAddBatch.
+		lastBatchID uint64
+
+		// programsExecuted is the number of programs executed by the queue's
+		// jobs.
+		programsExecuted uint64
+
+		// staticMetrics counts the results of the queue's jobs.
+		staticMetrics *registryJobMetrics
+
 		*jobGenericQueue
 	}
 
+	// updateRegistryResult is the result of a single update within a program.
+	updateRegistryResult struct {
+		srv      modules.SignedRegistryValue
+		err      error
+		executed bool
+	}
+
 	// jobUpdateRegistryResponse contains the result of a UpdateRegistry query.
 	jobUpdateRegistryResponse struct {
 		srv       *modules.SignedRegistryValue // only sent on ErrLowerRevNum and ErrSameRevNum
+		retention registryRetention
 		staticErr error
 	}
+
+	// registryRetention describes how the host handled the retention the
+	// renter requested for an updated entry. If the host ignored the hint,
+	// Accepted is the unchanged requested retention.
+	registryRetention struct {
+		Requested   types.BlockHeight
+		Accepted    types.BlockHeight
+		HintIgnored bool
+	}
 )
 
 // newJobUpdateRegistry is a helper method to create a new UpdateRegistry job.
@@ -62,17 +140,31 @@ func (w *worker) newJobUpdateRegistry(ctx context.Context, responseChan chan *jo
 		staticSiaPublicKey:        spk,
 		staticSignedRegistryValue: srv,
 		staticResponseChan:        responseChan,
+		staticCreationTime:        time.Now(),
 		jobGeneric:                newJobGeneric(ctx, w.staticJobUpdateRegistryQueue, nil),
 	}
 }
 
 // callDiscard will discard a job, sending the provided error.
 func (j *jobUpdateRegistry) callDiscard(err error) {
+	j.staticSendResponse(nil, errors.Extend(err, ErrJobDiscarded))
+}
+
+// staticSendResponse sends the result of the job asynchronously. If the job
+// has a callback, the callback is called instead of sending the response down
+// the response channel. The callback is also called if the renter is shutting
+// down.
+func (j *jobUpdateRegistry) staticSendResponse(srv *modules.SignedRegistryValue, err error) {
 	w := j.staticQueue.staticWorker()
 	errLaunch := w.renter.tg.Launch(func() {
+		if j.staticCallback != nil {
+			j.staticCallback(err)
+			return
+		}
 		response := &jobUpdateRegistryResponse{
-			srv:       nil,
-			staticErr: errors.Extend(err, ErrJobDiscarded),
+			srv:       srv,
+			retention: j.staticRetention(w.staticRegistryCapabilities()),
+			staticErr: err,
 		}
 		select {
 		case j.staticResponseChan <- response:
@@ -81,7 +173,21 @@ func (j *jobUpdateRegistry) callDiscard(err error) {
 		}
 	})
 	if errLaunch != nil {
-		w.renter.log.Debugln("callDiscard: launch failed", err)
+		w.renter.log.Debugln("staticSendResponse: launch failed", err)
+		if j.staticCallback != nil {
+			go j.staticCallback(errors.Compose(err, errLaunch))
+		}
+	}
+}
+
+// staticRetention returns how the host handled the job's retention hint.
+// Hosts without RevisionRetention support always keep updated entries for
+// types.BlocksPerYear blocks, so the hint is reported as ignored for them.
+func (j *jobUpdateRegistry) staticRetention(caps registryCapabilities) registryRetention {
+	return registryRetention{
+		Requested:   j.staticRequestedRetention,
+		Accepted:    j.staticRequestedRetention,
+		HintIgnored: !caps.RevisionRetention,
 	}
 }
 
@@ -90,44 +196,44 @@ func (j *jobUpdateRegistry) callExecute() {
 	start := time.Now()
 	w := j.staticQueue.staticWorker()
 
-	// Prepare a method to send a response asynchronously.
-	sendResponse := func(srv *modules.SignedRegistryValue, err error) {
-		errLaunch := w.renter.tg.Launch(func() {
-			response := &jobUpdateRegistryResponse{
-				srv:       srv,
-				staticErr: err,
-			}
-			select {
-			case j.staticResponseChan <- response:
-			case <-j.staticCtx.Done():
-			case <-w.renter.tg.StopChan():
-			}
-		})
-		if errLaunch != nil {
-			w.renter.log.Debugln("callExececute: launch failed", err)
-		}
+	// Make sure the host supports the size of the data. This isn't the host's
+	// fault so it is not reported as a failure.
+	err := checkRegistryDataSize(w.staticPriceTable().staticPriceTable, j.staticSignedRegistryValue)
+	if err != nil {
+		j.staticSendResponse(nil, err)
+		return
 	}
 
-	// update the rv. We ignore ErrSameRevNum and ErrLowerRevNum to not put the
-	// host on a cooldown for something that's not necessarily its fault. We
-	// might want to add another argument to the job that disables this behavior
-	// in the future in case we are certain that a host can't contain those
-	// errors.
+	// Update the rv.
 	rv, err := j.managedUpdateRegistry()
+	j.managedHandleResult(start, rv, err)
+}
+
+// managedHandleResult handles the result of updating the job's registry entry
+// on the host. It sends the response, reports success or failure to the queue
+// and updates the registry cache.
+func (j *jobUpdateRegistry) managedHandleResult(start time.Time, rv modules.SignedRegistryValue, err error) {
+	w := j.staticQueue.staticWorker()
+	jq := j.staticQueue.(*jobUpdateRegistryQueue)
+
+	// We ignore ErrSameRevNum and ErrLowerRevNum to not put the host on a
+	// cooldown for something that's not necessarily its fault. We might want
+	// to add another argument to the job that disables this behavior in the
+	// future in case we are certain that a host can't contain those errors.
 	if modules.IsRegistryEntryExistErr(err) {
 		// Report the failure if the host can't provide a signed registry entry
 		// with the error.
 		if err := rv.Verify(j.staticSiaPublicKey.ToPublicKey()); err != nil {
-			sendResponse(nil, err)
-			j.staticQueue.callReportFailure(err)
+			j.staticSendResponse(nil, err)
+			jq.callReportRegistryFailure(err)
 			return
 		}
 		// If the entry is valid, check if our suggested can actually not be
 		// used to update rv.
 		shouldUpdate, shouldUpdateErr := rv.ShouldUpdateWith(&j.staticSignedRegistryValue.RegistryValue, w.staticHostPubKey)
 		if shouldUpdate {
-			sendResponse(nil, errHostOutdatedProof)
-			j.staticQueue.callReportFailure(errHostOutdatedProof)
+			j.staticSendResponse(nil, errHostOutdatedProof)
+			jq.callReportRegistryFailure(errHostOutdatedProof)
 			return
 		}
 		// If the entry is valid and the revision is also valid, check if we
@@ -136,35 +242,37 @@ func (j *jobUpdateRegistry) callExecute() {
 		// number for verifying the pow.
 		cachedRevision, cached := w.staticRegistryCache.Get(j.staticSiaPublicKey, j.staticSignedRegistryValue.Tweak)
 		if cached && cachedRevision > rv.Revision {
-			sendResponse(nil, errHostLowerRevisionThanCache)
-			j.staticQueue.callReportFailure(errHostLowerRevisionThanCache)
+			j.staticSendResponse(nil, errHostLowerRevisionThanCache)
+			jq.callReportRegistryFailure(errHostLowerRevisionThanCache)
 			w.staticRegistryCache.Set(j.staticSiaPublicKey, rv, true) // adjust the cache
 			return
 		}
 		// If the entry is the same as as the one we want to set, consider this
 		// a success. Otherwise return the error.
 		if !errors.Contains(shouldUpdateErr, modules.ErrSameRevNum) {
-			sendResponse(&rv, err)
+			j.staticSendResponse(&rv, err)
+			jq.staticMetrics.AddRevisionError()
 			return
 		}
 	} else if err != nil {
-		sendResponse(nil, err)
-		j.staticQueue.callReportFailure(err)
+		j.staticSendResponse(nil, err)
+		jq.callReportRegistryFailure(err)
 		return
 	}
 
 	// Success. We either confirmed the latest revision or updated the host successfully.
 	jobTime := time.Since(start)
 
-	// Update the registry cache.
+	// Update the registry cache and history.
 	w.staticRegistryCache.Set(j.staticSiaPublicKey, j.staticSignedRegistryValue, false)
+	w.renter.staticRegistryHistory.Add(j.staticSiaPublicKey, w.staticHostPubKey, j.staticSignedRegistryValue, true)
 
 	// Send the response and report success.
-	sendResponse(nil, nil)
+	j.staticSendResponse(nil, nil)
 	j.staticQueue.callReportSuccess()
+	jq.staticMetrics.AddSuccess(jobTime)
 
 	// Update the performance stats on the queue.
-	jq := j.staticQueue.(*jobUpdateRegistryQueue)
 	jq.mu.Lock()
 	jq.weightedJobTime = expMovingAvg(jq.weightedJobTime, float64(jobTime), jobUpdateRegistryPerformanceDecay)
 	jq.mu.Unlock()
@@ -181,23 +289,42 @@ func (j *jobUpdateRegistry) callExpectedBandwidth() (ul, dl uint64) {
 // as proof.
 func (j *jobUpdateRegistry) managedUpdateRegistry() (modules.SignedRegistryValue, error) {
 	w := j.staticQueue.staticWorker()
+	results, err := w.managedExecuteUpdateRegistryProgram([]*jobUpdateRegistry{j})
+	if err != nil {
+		return modules.SignedRegistryValue{}, err
+	}
+	return results[0].srv, results[0].err
+}
+
+// managedExecuteUpdateRegistryProgram updates the registry entries of the
+// provided jobs on the host using a single program. The host stops executing
+// the program at the first failing instruction. The results of the jobs whose
+// instructions weren't executed have executed set to false. If the error is
+// ErrLowerRevNum or ErrSameRevNum, the result should contain a signed
+// registry value as proof.
+func (w *worker) managedExecuteUpdateRegistryProgram(jobs []*jobUpdateRegistry) ([]updateRegistryResult, error) {
 	// Create the program.
 	pt := w.staticPriceTable().staticPriceTable
 	pb := modules.NewProgramBuilder(&pt, 0) // 0 duration since UpdateRegistry doesn't depend on it.
 	version := modules.ReadRegistryVersionNoType
-	if build.VersionCmp(w.staticCache().staticHostVersion, "1.5.5") < 0 {
-		pb.V154AddUpdateRegistryInstruction(j.staticSiaPublicKey, j.staticSignedRegistryValue)
-	} else if build.VersionCmp(w.staticCache().staticHostVersion, "1.5.6") < 0 {
-		pb.V156AddUpdateRegistryInstruction(j.staticSiaPublicKey, j.staticSignedRegistryValue)
-	} else {
-		version = modules.ReadRegistryVersionWithType
-		pb.AddUpdateRegistryInstruction(j.staticSiaPublicKey, j.staticSignedRegistryValue)
+	var ulBandwidth, dlBandwidth uint64
+	for _, j := range jobs {
+		if build.VersionCmp(w.staticCache().staticHostVersion, "1.5.5") < 0 {
+			pb.V154AddUpdateRegistryInstruction(j.staticSiaPublicKey, j.staticSignedRegistryValue)
+		} else if build.VersionCmp(w.staticCache().staticHostVersion, "1.5.6") < 0 {
+			pb.V156AddUpdateRegistryInstruction(j.staticSiaPublicKey, j.staticSignedRegistryValue)
+		} else {
+			version = modules.ReadRegistryVersionWithType
+			pb.AddUpdateRegistryInstruction(j.staticSiaPublicKey, j.staticSignedRegistryValue)
+		}
+		ul, dl := j.callExpectedBandwidth()
+		ulBandwidth += ul
+		dlBandwidth += dl
 	}
 	program, programData := pb.Program()
 	cost, _, _ := pb.Cost(true)
 
 	// take into account bandwidth costs
-	ulBandwidth, dlBandwidth := j.callExpectedBandwidth()
 	bandwidthCost := modules.MDMBandwidthCost(pt, ulBandwidth, dlBandwidth)
 	cost = cost.Add(bandwidthCost)
 
@@ -205,9 +332,18 @@ func (j *jobUpdateRegistry) managedUpdateRegistry() (modules.SignedRegistryValue
 	var responses []programResponse
 	responses, _, err := w.managedExecuteProgram(program, programData, types.FileContractID{}, categoryRegistryWrite, cost)
 	if err != nil {
-		return modules.SignedRegistryValue{}, errors.AddContext(err, "Unable to execute program")
+		return nil, errors.AddContext(err, "Unable to execute program")
 	}
-	for _, resp := range responses {
+	w.staticJobUpdateRegistryQueue.mu.Lock()
+	w.staticJobUpdateRegistryQueue.programsExecuted++
+	w.staticJobUpdateRegistryQueue.mu.Unlock()
+
+	results := make([]updateRegistryResult, len(jobs))
+	for i, resp := range responses {
+		if i >= len(jobs) {
+			break
+		}
+		results[i].executed = true
 		// If a revision related error was returned, we try to parse the
 		// signed registry value from the response.
 		err = resp.Error
@@ -224,22 +360,67 @@ func (j *jobUpdateRegistry) managedUpdateRegistry() (modules.SignedRegistryValue
 		if modules.IsRegistryEntryExistErr(err) {
 			// Parse the proof.
 			_, _, data, revision, sig, entryType, parseErr := parseSignedRegistryValueResponse(resp.Output, false, version)
-			rv := modules.NewSignedRegistryValue(j.staticSignedRegistryValue.Tweak, data, revision, sig, entryType)
-			return rv, errors.Compose(err, parseErr)
+			results[i].srv = modules.NewSignedRegistryValue(jobs[i].staticSignedRegistryValue.Tweak, data, revision, sig, entryType)
+			results[i].err = errors.Compose(err, parseErr)
+			return results, nil
 		}
 		if err != nil {
-			return modules.SignedRegistryValue{}, errors.AddContext(resp.Error, "Output error")
+			results[i].err = errors.AddContext(resp.Error, "Output error")
+			return results, nil
 		}
-		break
 	}
 	if len(responses) != len(program) {
-		return modules.SignedRegistryValue{}, errors.New("received invalid number of responses but no error")
+		return nil, errors.New("received invalid number of responses but no error")
+	}
+	return results, nil
+}
+
+// checkRegistryDataSize checks whether the data of a registry value fits within
+// the max registry data size advertised in the host's price table. Hosts which
+// don't advertise a max size are assumed to support modules.RegistryDataSize.
+func checkRegistryDataSize(pt modules.RPCPriceTable, srv modules.SignedRegistryValue) error {
+	maxSize := pt.MaxRegistryDataSize
+	if maxSize == 0 {
+		maxSize = modules.RegistryDataSize
+	}
+	if uint64(len(srv.Data)) > maxSize {
+		return errors.AddContext(ErrRegistryDataTooLargeForHost, fmt.Sprintf("%v > %v", len(srv.Data), maxSize))
 	}
-	return modules.SignedRegistryValue{}, nil
+	return nil
+}
+
+// callReportRegistryFailure reports a failed job to the queue and puts it on
+// the cooldown configured for the class of the error.
+func (jq *jobUpdateRegistryQueue) callReportRegistryFailure(err error) {
+	jq.mu.Lock()
+	cooldown := jq.cooldowns.NetworkErr
+	if isRegistrySignatureErr(err) {
+		cooldown = jq.cooldowns.SignatureErr
+	}
+	jq.mu.Unlock()
+	jq.callReportFailureWithCooldown(err, cooldown)
+	jq.staticMetrics.AddFailure(err)
+}
+
+// callSetCooldowns updates the base cooldowns used for future failures of the
+// queue.
+func (jq *jobUpdateRegistryQueue) callSetCooldowns(cooldowns modules.RegistryUpdateCooldowns) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	jq.cooldowns = cooldowns
+}
+
+// isRegistrySignatureErr returns whether an error indicates that the host
+// returned an invalid signature or proof.
+func isRegistrySignatureErr(err error) bool {
+	return errors.Contains(err, crypto.ErrInvalidSignature) ||
+		errors.Contains(err, modules.ErrUnknownRegistryEntryType) ||
+		errors.Contains(err, errHostOutdatedProof) ||
+		errors.Contains(err, errHostLowerRevisionThanCache)
 }
 
 // initJobUpdateRegistryQueue will init the queue for the UpdateRegistry jobs.
-func (w *worker) initJobUpdateRegistryQueue() {
+func (w *worker) initJobUpdateRegistryQueue(cooldowns modules.RegistryUpdateCooldowns) {
 	// Sanity check that there is no existing job queue.
 	if w.staticJobUpdateRegistryQueue != nil {
 		w.renter.log.Critical("incorret call on initJobUpdateRegistryQueue")
@@ -247,28 +428,148 @@ func (w *worker) initJobUpdateRegistryQueue() {
 	}
 
 	w.staticJobUpdateRegistryQueue = &jobUpdateRegistryQueue{
+		cooldowns:       cooldowns,
+		staticMetrics:   newRegistryJobMetrics(),
 		jobGenericQueue: newJobGenericQueue(w),
 	}
 }
 
 // UpdateRegistry is a helper method to run a UpdateRegistry job on a worker.
 func (w *worker) UpdateRegistry(ctx context.Context, spk types.SiaPublicKey, rv modules.SignedRegistryValue) error {
+	_, err := w.UpdateRegistryWithRetention(ctx, spk, rv, 0)
+	return err
+}
+
+// UpdateRegistryWithRetention runs a UpdateRegistry job on a worker which asks
+// the host to keep the entry for at least retention blocks. The returned
+// registryRetention reports whether the host honored the hint.
+func (w *worker) UpdateRegistryWithRetention(ctx context.Context, spk types.SiaPublicKey, rv modules.SignedRegistryValue, retention types.BlockHeight) (registryRetention, error) {
+	// Check if the host supports registry updates.
+	if !w.staticRegistryCapabilities().Write {
+		return registryRetention{}, errRegistryUnsupported
+	}
+
 	updateRegistryRespChan := make(chan *jobUpdateRegistryResponse)
 	jur := w.newJobUpdateRegistry(ctx, updateRegistryRespChan, spk, rv)
+	jur.staticRequestedRetention = retention
 
 	// Add the job to the queue.
 	if !w.staticJobUpdateRegistryQueue.callAdd(jur) {
-		return errors.New("worker unavailable")
+		return registryRetention{}, errors.New("worker unavailable")
 	}
 
 	// Wait for the response.
 	var resp *jobUpdateRegistryResponse
 	select {
 	case <-ctx.Done():
-		return errors.New("UpdateRegistry interrupted")
+		return registryRetention{}, errors.New("UpdateRegistry interrupted")
 	case resp = <-updateRegistryRespChan:
 	}
-	return resp.staticErr
+	return resp.retention, resp.staticErr
+}
+
+// UpdateRegistryAsync adds a UpdateRegistry job to the worker's queue without
+// waiting for the result. Once the job is done, cb is called with the result.
+// cb is always called exactly once, even if the job can't be added to the
+// queue or the renter is shutting down, in which case the error contains
+// threadgroup.ErrStopped.
+func (w *worker) UpdateRegistryAsync(spk types.SiaPublicKey, rv modules.SignedRegistryValue, cb func(error)) {
+	// Check if the host supports registry updates.
+	if !w.staticRegistryCapabilities().Write {
+		go cb(errRegistryUnsupported)
+		return
+	}
+
+	// Give the job the same amount of time as the jobs of the renter's
+	// UpdateRegistry. The context is closed once the callback was called.
+	ctx, cancel := context.WithTimeout(w.renter.tg.StopCtx(), updateRegistryBackgroundTimeout)
+	jur := w.newJobUpdateRegistry(ctx, nil, spk, rv)
+	jur.staticCallback = func(err error) {
+		cancel()
+		cb(err)
+	}
+
+	// Add the job to the queue.
+	if !w.staticJobUpdateRegistryQueue.callAdd(jur) {
+		err := errors.New("worker unavailable")
+		select {
+		case <-w.renter.tg.StopChan():
+			err = errors.Compose(err, threadgroup.ErrStopped)
+		default:
+		}
+		go jur.staticCallback(err)
+	}
+}
+
+// UpdateRegistryBump reads the latest revision of a registry entry from the
+// worker's host and updates it with the provided data and a revision number
+// that is one higher. If another writer updates the entry in between, the
+// revision is read again and the update is retried up to
+// updateRegistryBumpMaxRetries times. The type of the existing entry is kept.
+// New entries are created with RegistryTypeWithoutPubkey. The signed value that
+// was written is returned.
+func (w *worker) UpdateRegistryBump(ctx context.Context, spk types.SiaPublicKey, tweak crypto.Hash, sk crypto.SecretKey, data []byte) (modules.SignedRegistryValue, error) {
+	return w.UpdateRegistryTransform(ctx, spk, tweak, sk, func(old *modules.SignedRegistryValue) (modules.RegistryValue, error) {
+		entryType := modules.RegistryTypeWithoutPubkey
+		if old != nil {
+			entryType = old.Type
+		}
+		return modules.NewRegistryValue(tweak, data, 0, entryType), nil
+	})
+}
+
+// UpdateRegistryTransform reads the latest revision of a registry entry from
+// the worker's host, passes it to transform and updates the entry with the
+// returned value. old is nil if the host doesn't know the entry yet. The
+// tweak of the returned value is replaced with the provided one and its
+// revision number with one that is higher than the one of old. That way the
+// update only succeeds if the entry didn't change since it was read. If
+// another writer updates the entry in between, the entry is read and
+// transformed again up to updateRegistryBumpMaxRetries times. The signed value
+// that was written is returned.
+func (w *worker) UpdateRegistryTransform(ctx context.Context, spk types.SiaPublicKey, tweak crypto.Hash, sk crypto.SecretKey, transform func(old *modules.SignedRegistryValue) (modules.RegistryValue, error)) (modules.SignedRegistryValue, error) {
+	var err error
+	for i := 0; i <= updateRegistryBumpMaxRetries; i++ {
+		// Read the latest revision.
+		var srv *modules.SignedRegistryValue
+		srv, err = w.ReadRegistry(ctx, spk, tweak)
+		if err != nil {
+			return modules.SignedRegistryValue{}, errors.AddContext(err, "failed to read latest revision")
+		}
+		var rev uint64
+		if srv != nil {
+			if srv.Revision == math.MaxUint64 {
+				return modules.SignedRegistryValue{}, errors.AddContext(modules.ErrLowerRevNum, "entry already has the max revision")
+			}
+			rev = srv.Revision + 1
+		}
+
+		// Transform the entry.
+		var rv modules.RegistryValue
+		rv, err = transform(srv)
+		if err != nil {
+			return modules.SignedRegistryValue{}, errors.AddContext(err, "failed to transform registry value")
+		}
+		rv.Tweak = tweak
+		rv.Revision = rev
+
+		// Allow the dependencies to interfere between reading and updating
+		// the entry.
+		w.renter.deps.Disrupt("UpdateRegistryBumpRace")
+
+		// Update the entry. If the update lost a race against another
+		// writer, try again.
+		signed := rv.Sign(sk)
+		err = w.UpdateRegistry(ctx, spk, signed)
+		if modules.IsRegistryEntryExistErr(err) {
+			continue
+		}
+		if err != nil {
+			return modules.SignedRegistryValue{}, err
+		}
+		return signed, nil
+	}
+	return modules.SignedRegistryValue{}, errors.Compose(err, errRegistryBumpRetriesExhausted)
 }
 
 // updateRegistryUpdateJobExpectedBandwidth is a helper function that returns
@@ -1,8 +1,11 @@
 package renter
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -33,6 +36,12 @@ func TestUpdateRegistryJob(t *testing.T) {
 		}
 	}()
 
+	// Use a custom cooldown for signature errors.
+	signatureErrCooldown := time.Hour
+	wt.staticJobUpdateRegistryQueue.callSetCooldowns(modules.RegistryUpdateCooldowns{
+		SignatureErr: signatureErrCooldown,
+	})
+
 	// Create a registry value.
 	sk, pk := crypto.GenerateKeyPair()
 	var tweak crypto.Hash
@@ -118,6 +127,9 @@ func TestUpdateRegistryJob(t *testing.T) {
 	if wt.staticJobUpdateRegistryQueue.cooldownUntil == (time.Time{}) {
 		t.Fatal("coolDown not set")
 	}
+	if cooldown := time.Until(wt.staticJobUpdateRegistryQueue.cooldownUntil); cooldown > signatureErrCooldown || cooldown < signatureErrCooldown-time.Minute {
+		t.Fatal("custom cooldown wasn't applied", cooldown)
+	}
 	wt.staticJobUpdateRegistryQueue.cooldownUntil = time.Time{}
 	wt.staticJobUpdateRegistryQueue.recentErr = nil
 	wt.staticJobUpdateRegistryQueue.mu.Unlock()
@@ -335,3 +347,542 @@ func TestUpdateRegistryInvalidCached(t *testing.T) {
 	}
 	wt.staticJobUpdateRegistryQueue.mu.Unlock()
 }
+
+// dependencyUpdateRegistryBumpRace is a dependency which updates a registry
+// entry with a higher revision the first time UpdateRegistryBump reads it.
+type dependencyUpdateRegistryBumpRace struct {
+	modules.ProductionDependencies
+
+	// races is the number of times the dependency raced UpdateRegistryBump.
+	races uint64
+
+	// raceFn is called when the dependency races UpdateRegistryBump.
+	raceFn func() error
+
+	mu sync.Mutex
+}
+
+// Disrupt will race UpdateRegistryBump once.
+func (d *dependencyUpdateRegistryBumpRace) Disrupt(s string) bool {
+	if s != "UpdateRegistryBumpRace" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.raceFn == nil || d.races > 0 {
+		return false
+	}
+	d.races++
+	if err := d.raceFn(); err != nil {
+		panic(err)
+	}
+	return true
+}
+
+// TestUpdateRegistryBump tests that UpdateRegistryBump retries an update if it
+// loses a race against another writer.
+func TestUpdateRegistryBump(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	deps := &dependencyUpdateRegistryBumpRace{}
+	wt, err := newWorkerTesterCustomDependency(t.Name(), deps, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a registry entry.
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+
+	// Bump the entry without it existing. It should be created with revision
+	// 0.
+	data := fastrand.Bytes(modules.RegistryDataSize)
+	rv, err := wt.UpdateRegistryBump(context.Background(), spk, tweak, sk, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv.Revision != 0 || !bytes.Equal(rv.Data, data) {
+		t.Fatal("wrong entry", rv.Revision, rv.Data)
+	}
+
+	// Bump again. This time, another writer races the bump with a higher
+	// revision and changes the type of the entry.
+	racedRevision := uint64(10)
+	deps.mu.Lock()
+	deps.raceFn = func() error {
+		rvRace := modules.NewRegistryValue(tweak, fastrand.Bytes(modules.RegistryDataSize), racedRevision, modules.RegistryTypeWithPubkey).Sign(sk)
+		return wt.UpdateRegistry(context.Background(), spk, rvRace)
+	}
+	deps.mu.Unlock()
+	data = fastrand.Bytes(modules.RegistryDataSize)
+	rv, err = wt.UpdateRegistryBump(context.Background(), spk, tweak, sk, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps.mu.Lock()
+	races := deps.races
+	deps.mu.Unlock()
+	if races != 1 {
+		t.Fatal("expected 1 race but got", races)
+	}
+
+	// The bump should have been retried on top of the raced revision and kept
+	// its type.
+	if rv.Revision != racedRevision+1 || !bytes.Equal(rv.Data, data) {
+		t.Fatal("wrong entry", rv.Revision, rv.Data)
+	}
+	if rv.Type != modules.RegistryTypeWithPubkey {
+		t.Fatal("type of the entry wasn't kept", rv.Type)
+	}
+	lookedUpRV, err := lookupRegistry(wt.worker, spk, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*lookedUpRV, rv) {
+		t.Fatal("entries don't match")
+	}
+}
+
+// TestUpdateRegistryOnHost tests updating the registry of a specific host
+// through the renter.
+func TestUpdateRegistryOnHost(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// Create a registry value.
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	data := fastrand.Bytes(modules.RegistryDataSize)
+	rev := fastrand.Uint64n(1000) + 1
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	rv := modules.NewRegistryValue(tweak, data, rev, modules.RegistryTypeWithoutPubkey).Sign(sk)
+
+	// Update the registry on the worker's host.
+	err = r.UpdateRegistryOnHost(context.Background(), wt.staticHostPubKey, spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The entry should be on the host.
+	lookedUpRV, err := lookupRegistry(wt.worker, spk, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*lookedUpRV, rv) {
+		t.Fatal("entries don't match")
+	}
+
+	// Updating the registry on an unknown host should fail.
+	_, unknownPK := crypto.GenerateKeyPair()
+	unknownHost := types.Ed25519PublicKey(unknownPK)
+	err = r.UpdateRegistryOnHost(context.Background(), unknownHost, spk, rv)
+	if !errors.Contains(err, ErrNoWorkerForHost) {
+		t.Fatal("expected ErrNoWorkerForHost but got", err)
+	}
+}
+
+// TestUpdateRegistryAsync tests that the callback of an asynchronous registry
+// update is called for both successful and conflicting updates.
+func TestUpdateRegistryAsync(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a registry value.
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	data := fastrand.Bytes(modules.RegistryDataSize)
+	rev := fastrand.Uint64n(1000) + 1
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	rv := modules.NewRegistryValue(tweak, data, rev, modules.RegistryTypeWithoutPubkey).Sign(sk)
+
+	// updateAsync is a helper which waits for the callback.
+	updateAsync := func(rv modules.SignedRegistryValue) error {
+		errChan := make(chan error, 1)
+		wt.UpdateRegistryAsync(spk, rv, func(err error) {
+			errChan <- err
+		})
+		select {
+		case err := <-errChan:
+			return err
+		case <-time.After(time.Minute):
+			t.Fatal("callback wasn't called")
+		}
+		return nil
+	}
+
+	// The first update should succeed.
+	if err := updateAsync(rv); err != nil {
+		t.Fatal(err)
+	}
+	lookedUpRV, err := lookupRegistry(wt.worker, spk, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*lookedUpRV, rv) {
+		t.Fatal("entries don't match")
+	}
+
+	// An update with a lower revision should fail.
+	rvLowerRev := modules.NewRegistryValue(tweak, data, rev-1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	if err := updateAsync(rvLowerRev); !errors.Contains(err, modules.ErrLowerRevNum) {
+		t.Fatal("expected ErrLowerRevNum but got", err)
+	}
+}
+
+// TestRegistryUpdateCooldowns is a unit test for the cooldowns of the
+// UpdateRegistry queue.
+func TestRegistryUpdateCooldowns(t *testing.T) {
+	t.Parallel()
+
+	// assertCooldown checks that the queue's cooldown is within the provided
+	// range.
+	assertCooldown := func(jq *jobUpdateRegistryQueue, min, max time.Duration) {
+		t.Helper()
+		jq.mu.Lock()
+		cooldown := time.Until(jq.cooldownUntil)
+		jq.mu.Unlock()
+		if cooldown < min-time.Second || cooldown > max {
+			t.Fatalf("cooldown %v not within [%v, %v]", cooldown, min, max)
+		}
+	}
+
+	// Create a queue with a custom signature error cooldown.
+	w := new(worker)
+	w.initJobUpdateRegistryQueue(modules.RegistryUpdateCooldowns{
+		SignatureErr: time.Hour,
+	})
+	jq := w.staticJobUpdateRegistryQueue
+
+	// A signature error should use the custom cooldown.
+	jq.callReportRegistryFailure(crypto.ErrInvalidSignature)
+	assertCooldown(jq, time.Hour, time.Hour)
+
+	// A network error should use the default cooldown which is doubled
+	// after the first failure.
+	jq.callReportRegistryFailure(errors.New("connection refused"))
+	assertCooldown(jq, 2*time.Second*cooldownBaseMinMilliseconds/1e3, 2*time.Second*cooldownBaseMaxMilliseconds/1e3)
+
+	// Another signature error should double the custom cooldown.
+	jq.callReportRegistryFailure(errHostOutdatedProof)
+	assertCooldown(jq, 4*time.Hour, 4*time.Hour)
+
+	// A custom network error cooldown should be applied too.
+	w = new(worker)
+	w.initJobUpdateRegistryQueue(modules.RegistryUpdateCooldowns{
+		NetworkErr: time.Minute,
+	})
+	jq = w.staticJobUpdateRegistryQueue
+	jq.callReportRegistryFailure(errors.New("connection refused"))
+	assertCooldown(jq, time.Minute, time.Minute)
+}
+
+// TestSetRegistryUpdateCooldowns makes sure that setting the cooldowns on the
+// worker pool updates the queues of the existing workers and is used for new
+// workers.
+func TestSetRegistryUpdateCooldowns(t *testing.T) {
+	t.Parallel()
+
+	w := new(worker)
+	w.initJobUpdateRegistryQueue(defaultRegistryUpdateCooldowns)
+	wp := &workerPool{
+		workers: map[string]*worker{"host": w},
+	}
+
+	// Set the cooldowns.
+	cooldowns := modules.RegistryUpdateCooldowns{
+		SignatureErr: time.Hour,
+		NetworkErr:   time.Minute,
+	}
+	wp.callSetRegistryUpdateCooldowns(cooldowns)
+
+	// The existing worker should use them.
+	jq := w.staticJobUpdateRegistryQueue
+	jq.mu.Lock()
+	queueCooldowns := jq.cooldowns
+	jq.mu.Unlock()
+	if queueCooldowns != cooldowns {
+		t.Fatal("wrong cooldowns", queueCooldowns, cooldowns)
+	}
+
+	// New workers are created with the pool's cooldowns.
+	wp.mu.Lock()
+	poolCooldowns := wp.registryUpdateCooldowns
+	wp.mu.Unlock()
+	if poolCooldowns != cooldowns {
+		t.Fatal("wrong cooldowns", poolCooldowns, cooldowns)
+	}
+}
+
+// TestCheckRegistryDataSize is a unit test for checkRegistryDataSize.
+func TestCheckRegistryDataSize(t *testing.T) {
+	t.Parallel()
+
+	sk, _ := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	newValue := func(size int) modules.SignedRegistryValue {
+		return modules.NewRegistryValue(tweak, fastrand.Bytes(size), 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	}
+
+	// A host without a max size supports the default size.
+	var pt modules.RPCPriceTable
+	if err := checkRegistryDataSize(pt, newValue(modules.RegistryDataSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRegistryDataSize(pt, newValue(modules.RegistryDataSize+1)); !errors.Contains(err, ErrRegistryDataTooLargeForHost) {
+		t.Fatal("expected ErrRegistryDataTooLargeForHost", err)
+	}
+
+	// A host with a smaller max size.
+	pt.MaxRegistryDataSize = 10
+	if err := checkRegistryDataSize(pt, newValue(10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRegistryDataSize(pt, newValue(11)); !errors.Contains(err, ErrRegistryDataTooLargeForHost) {
+		t.Fatal("expected ErrRegistryDataTooLargeForHost", err)
+	}
+}
+
+// TestUpdateRegistryJobDataTooLarge tests that an UpdateRegistry job fails
+// without putting the worker on a cooldown if the host advertises a smaller
+// max registry data size than the size of the data.
+func TestUpdateRegistryJobDataTooLarge(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Simulate a host which advertises a smaller max than the default.
+	wpt := *wt.staticPriceTable()
+	wpt.staticPriceTable.MaxRegistryDataSize = modules.RegistryDataSize / 2
+	wt.staticSetPriceTable(&wpt)
+
+	// Create a registry value with the default size.
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	spk := types.Ed25519PublicKey(pk)
+	rv := modules.NewRegistryValue(tweak, fastrand.Bytes(modules.RegistryDataSize), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+
+	// The update should fail.
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if !errors.Contains(err, ErrRegistryDataTooLargeForHost) {
+		t.Fatal("expected ErrRegistryDataTooLargeForHost", err)
+	}
+	if wt.staticJobUpdateRegistryQueue.callOnCooldown() {
+		t.Fatal("worker shouldn't be on cooldown")
+	}
+
+	// A value that fits should work.
+	rv = modules.NewRegistryValue(tweak, fastrand.Bytes(modules.RegistryDataSize/2), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	if err := wt.UpdateRegistry(context.Background(), spk, rv); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUpdateRegistryWithRetention tests that the requested retention is
+// passed to the job and that the response reports whether it was honored.
+func TestUpdateRegistryWithRetention(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The job should carry the requested retention.
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	rv := modules.NewRegistryValue(tweak, fastrand.Bytes(10), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	retention := types.BlocksPerMonth
+	jur := wt.newJobUpdateRegistry(context.Background(), nil, spk, rv)
+	jur.staticRequestedRetention = retention
+	expected := registryRetention{
+		Requested:   retention,
+		Accepted:    retention,
+		HintIgnored: true,
+	}
+	if r := jur.staticRetention(registryCapabilities{}); r != expected {
+		t.Fatalf("expected %v but got %v", expected, r)
+	}
+	honored := expected
+	honored.HintIgnored = false
+	if r := jur.staticRetention(registryCapabilities{RevisionRetention: true}); r != honored {
+		t.Fatalf("expected %v but got %v", honored, r)
+	}
+
+	// The host doesn't honor the hint. The update should succeed and the
+	// retention should be returned unchanged.
+	r, err := wt.UpdateRegistryWithRetention(context.Background(), spk, rv, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != expected {
+		t.Fatalf("expected %v but got %v", expected, r)
+	}
+}
+
+// TestUpdateRegistryTransform tests that a transform which races another
+// writer is retried on top of the other writer's value.
+func TestUpdateRegistryTransform(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	deps := &dependencyUpdateRegistryBumpRace{}
+	wt, err := newWorkerTesterCustomDependency(t.Name(), deps, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+
+	// increment increments the counter stored in the entry's data.
+	increment := func(old *modules.SignedRegistryValue) (modules.RegistryValue, error) {
+		var counter uint64
+		if old != nil {
+			counter = binary.LittleEndian.Uint64(old.Data)
+		}
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint64(data, counter+1)
+		return modules.NewRegistryValue(tweak, data, 0, modules.RegistryTypeWithoutPubkey), nil
+	}
+
+	// Increment the counter without it existing.
+	srv, err := wt.UpdateRegistryTransform(context.Background(), spk, tweak, sk, increment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Revision != 0 || binary.LittleEndian.Uint64(srv.Data) != 1 {
+		t.Fatal("wrong entry", srv.Revision, srv.Data)
+	}
+
+	// Increment again. This time, another writer increments the counter in
+	// between reading and writing it.
+	deps.mu.Lock()
+	deps.raceFn = func() error {
+		old, err := lookupRegistry(wt.worker, spk, tweak)
+		if err != nil {
+			return err
+		}
+		rv, err := increment(old)
+		if err != nil {
+			return err
+		}
+		rv.Revision = old.Revision + 1
+		return wt.UpdateRegistry(context.Background(), spk, rv.Sign(sk))
+	}
+	deps.mu.Unlock()
+	srv, err = wt.UpdateRegistryTransform(context.Background(), spk, tweak, sk, increment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps.mu.Lock()
+	races := deps.races
+	deps.mu.Unlock()
+	if races != 1 {
+		t.Fatal("expected 1 race but got", races)
+	}
+
+	// No increment should be lost.
+	lookedUpRV, err := lookupRegistry(wt.worker, spk, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*lookedUpRV, srv) {
+		t.Fatal("entries don't match")
+	}
+	if counter := binary.LittleEndian.Uint64(srv.Data); counter != 3 || srv.Revision != 2 {
+		t.Fatalf("expected counter 3 at revision 2 but got %v at %v", counter, srv.Revision)
+	}
+
+	// A failing transform should abort the update.
+	errTransform := errors.New("transform failed")
+	_, err = wt.UpdateRegistryTransform(context.Background(), spk, tweak, sk, func(*modules.SignedRegistryValue) (modules.RegistryValue, error) {
+		return modules.RegistryValue{}, errTransform
+	})
+	if !errors.Contains(err, errTransform) {
+		t.Fatal("expected transform error but got", err)
+	}
+}
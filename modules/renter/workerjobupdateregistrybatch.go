@@ -0,0 +1,234 @@
+package renter
+
+import (
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// updateRegistryMaxBatchSize is the max number of UpdateRegistry jobs
+	// which are executed using a single program.
+	updateRegistryMaxBatchSize = 16
+)
+
+type (
+	// jobUpdateRegistryBatch is a set of UpdateRegistry jobs which are
+	// executed using a single program. Every job still receives its own
+	// result.
+	jobUpdateRegistryBatch struct {
+		staticJobs  []*jobUpdateRegistry
+		staticQueue *jobUpdateRegistryQueue
+	}
+)
+
+// callDiscard discards all the jobs of the batch.
+func (b *jobUpdateRegistryBatch) callDiscard(err error) {
+	for _, j := range b.staticJobs {
+		j.callDiscard(err)
+	}
+	b.staticQueue.callBatchDone()
+}
+
+// callExecute executes the jobs of the batch using a single program.
+func (b *jobUpdateRegistryBatch) callExecute() {
+	defer b.staticQueue.callBatchDone()
+	if len(b.staticJobs) == 1 {
+		b.staticJobs[0].callExecute()
+		return
+	}
+	start := time.Now()
+	w := b.staticQueue.staticWorker()
+
+	// Make sure the host supports the size of the data. This isn't the host's
+	// fault so it is not reported as a failure.
+	pt := w.staticPriceTable().staticPriceTable
+	jobs := make([]*jobUpdateRegistry, 0, len(b.staticJobs))
+	for _, j := range b.staticJobs {
+		if err := checkRegistryDataSize(pt, j.staticSignedRegistryValue); err != nil {
+			j.staticSendResponse(nil, err)
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	// Update the rvs. If the program couldn't be executed, the failure is
+	// only reported once for the whole batch.
+	results, err := w.managedExecuteUpdateRegistryProgram(jobs)
+	if err != nil {
+		for _, j := range jobs {
+			j.staticSendResponse(nil, err)
+		}
+		b.staticQueue.callReportRegistryFailure(err)
+		return
+	}
+	for i, j := range jobs {
+		// The host stops executing the program at the first failed update.
+		// The remaining updates are executed individually.
+		if !results[i].executed {
+			j.callExecute()
+			continue
+		}
+		j.managedHandleResult(start, results[i].srv, results[i].err)
+	}
+}
+
+// callExpectedBandwidth returns the bandwidth that is expected to be consumed
+// by the batch.
+func (b *jobUpdateRegistryBatch) callExpectedBandwidth() (ul, dl uint64) {
+	for _, j := range b.staticJobs {
+		jobUL, jobDL := j.callExpectedBandwidth()
+		ul += jobUL
+		dl += jobDL
+	}
+	return ul, dl
+}
+
+// staticGetMetadata returns the metadata of the batch. Batches don't have
+// metadata.
+func (b *jobUpdateRegistryBatch) staticGetMetadata() interface{} {
+	return nil
+}
+
+// staticCanceled returns true if all the jobs of the batch were canceled.
+func (b *jobUpdateRegistryBatch) staticCanceled() bool {
+	for _, j := range b.staticJobs {
+		if !j.staticCanceled() {
+			return false
+		}
+	}
+	return true
+}
+
+// callBatchDone is called once a batch returned by callNextBatch was executed
+// or discarded.
+func (jq *jobUpdateRegistryQueue) callBatchDone() {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	jq.inFlight--
+}
+
+// callAddBatch adds jobs to the queue which are executed as a single batch.
+// There must not be more than updateRegistryMaxBatchSize of them. Either all
+// jobs are added or none.
+func (jq *jobUpdateRegistryQueue) callAddBatch(jobs []*jobUpdateRegistry) bool {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	if jq.killed || jq.onCooldown() {
+		return false
+	}
+	jq.lastBatchID++
+	for _, j := range jobs {
+		j.staticBatchID = jq.lastBatchID
+		jq.jobs.PushBack(j)
+	}
+	jq.staticWorkerObj.staticWake()
+	return true
+}
+
+// callNextBatch returns the next job of the queue. If batching is enabled, the
+// jobs which were created within the batch window after the first queued job
+// are returned as a single batch. The jobs are held back until the window is
+// over unless the batch is full. Jobs are never held back if no other jobs of
+// the queue are queued or being executed. The jobs added by callAddBatch are
+// always returned as a batch of their own, even if batching is disabled.
+func (jq *jobUpdateRegistryQueue) callNextBatch() workerJob {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	if front := jq.jobs.Front(); front != nil && front.Value.(*jobUpdateRegistry).staticBatchID != 0 {
+		return jq.nextAddedBatch(front.Value.(*jobUpdateRegistry).staticBatchID)
+	}
+	if jq.batchWindow == 0 {
+		return jq.next()
+	}
+	if jq.jobs.Len() == 1 && jq.inFlight == 0 {
+		j := jq.next()
+		if j == nil {
+			return nil
+		}
+		jq.inFlight++
+		return &jobUpdateRegistryBatch{
+			staticJobs:  []*jobUpdateRegistry{j.(*jobUpdateRegistry)},
+			staticQueue: jq,
+		}
+	}
+	front := jq.jobs.Front()
+	if front == nil {
+		return nil
+	}
+
+	// Hold back the jobs until the window is over and wake the worker
+	// afterwards.
+	batchEnd := front.Value.(*jobUpdateRegistry).staticCreationTime.Add(jq.batchWindow)
+	if jq.jobs.Len() < updateRegistryMaxBatchSize && time.Now().Before(batchEnd) {
+		if !jq.batchWakeTime.Equal(batchEnd) {
+			jq.batchWakeTime = batchEnd
+			time.AfterFunc(time.Until(batchEnd), jq.staticWorker().staticWake)
+		}
+		return nil
+	}
+
+	// Collect the jobs of the batch.
+	var jobs []*jobUpdateRegistry
+	for e := jq.jobs.Front(); e != nil && len(jobs) < updateRegistryMaxBatchSize; {
+		next := e.Next()
+		j := e.Value.(*jobUpdateRegistry)
+		if j.staticCreationTime.After(batchEnd) || j.staticBatchID != 0 {
+			break
+		}
+		jq.jobs.Remove(e)
+		e = next
+		if j.staticCanceled() {
+			j.callDiscard(errors.New("callNextBatch: skipping and discarding already canceled job"))
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+	jq.inFlight++
+	return &jobUpdateRegistryBatch{
+		staticJobs:  jobs,
+		staticQueue: jq,
+	}
+}
+
+// nextAddedBatch removes the jobs with the provided batch id from the front
+// of the queue and returns them as a batch. Canceled jobs are discarded.
+func (jq *jobUpdateRegistryQueue) nextAddedBatch(batchID uint64) workerJob {
+	var jobs []*jobUpdateRegistry
+	for e := jq.jobs.Front(); e != nil; {
+		next := e.Next()
+		j := e.Value.(*jobUpdateRegistry)
+		if j.staticBatchID != batchID {
+			break
+		}
+		jq.jobs.Remove(e)
+		e = next
+		if j.staticCanceled() {
+			j.callDiscard(errors.New("callNextBatch: skipping and discarding already canceled job"))
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+	jq.inFlight++
+	return &jobUpdateRegistryBatch{
+		staticJobs:  jobs,
+		staticQueue: jq,
+	}
+}
+
+// callSetBatchWindow sets the batch window of the queue. A window of 0
+// disables batching.
+func (jq *jobUpdateRegistryQueue) callSetBatchWindow(window time.Duration) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	jq.batchWindow = window
+}
@@ -0,0 +1,139 @@
+package renter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestUpdateRegistryBatch tests that updates which are created within the
+// batch window are executed using fewer programs.
+func TestUpdateRegistryBatch(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	jq := wt.staticJobUpdateRegistryQueue
+	jq.callSetBatchWindow(50 * time.Millisecond)
+
+	// Run a few updates of different entries at the same time.
+	numUpdates := 10
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	rvs := make([]modules.SignedRegistryValue, numUpdates)
+	for i := range rvs {
+		var tweak crypto.Hash
+		fastrand.Read(tweak[:])
+		rvs[i] = modules.NewRegistryValue(tweak, fastrand.Bytes(10), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	}
+	var wg sync.WaitGroup
+	var errs []error
+	var errsMu sync.Mutex
+	for _, rv := range rvs {
+		wg.Add(1)
+		go func(rv modules.SignedRegistryValue) {
+			defer wg.Done()
+			err := wt.UpdateRegistry(context.Background(), spk, rv)
+			errsMu.Lock()
+			errs = append(errs, err)
+			errsMu.Unlock()
+		}(rv)
+	}
+	wg.Wait()
+	if err := errors.Compose(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every entry should be on the host.
+	for _, rv := range rvs {
+		lookedUpRV, err := lookupRegistry(wt.worker, spk, rv.Tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lookedUpRV.Revision != rv.Revision {
+			t.Fatal("wrong revision", lookedUpRV.Revision, rv.Revision)
+		}
+	}
+
+	// Fewer programs than updates should have been executed.
+	jq.mu.Lock()
+	programs := jq.programsExecuted
+	jq.mu.Unlock()
+	if programs >= uint64(numUpdates) {
+		t.Fatalf("expected fewer than %v programs but got %v", numUpdates, programs)
+	}
+}
+
+// TestUpdateRegistryAddedBatch tests that the jobs of callAddBatch are
+// returned as a batch of their own even if batching is disabled.
+func TestUpdateRegistryAddedBatch(t *testing.T) {
+	t.Parallel()
+
+	w := &worker{wakeChan: make(chan struct{}, 1)}
+	jq := &jobUpdateRegistryQueue{jobGenericQueue: newJobGenericQueue(w)}
+	newJobs := func(n int) []*jobUpdateRegistry {
+		jobs := make([]*jobUpdateRegistry, n)
+		for i := range jobs {
+			jobs[i] = &jobUpdateRegistry{
+				staticCreationTime: time.Now(),
+				jobGeneric:         newJobGeneric(context.Background(), jq, nil),
+			}
+		}
+		return jobs
+	}
+
+	// Add two batches and a single job in between. The jobs of a batch
+	// don't share their creation time.
+	batch1 := newJobs(3)
+	single := newJobs(1)[0]
+	batch2 := newJobs(2)
+	if !jq.callAddBatch(batch1) || !jq.callAdd(single) || !jq.callAddBatch(batch2) {
+		t.Fatal("failed to add jobs")
+	}
+	if batch1[0].staticBatchID == 0 || batch1[0].staticBatchID == batch2[0].staticBatchID {
+		t.Fatal("wrong batch ids", batch1[0].staticBatchID, batch2[0].staticBatchID)
+	}
+
+	// The jobs should be returned in three parts.
+	checkBatch := func(expected []*jobUpdateRegistry) {
+		t.Helper()
+		b, ok := jq.callNextBatch().(*jobUpdateRegistryBatch)
+		if !ok || len(b.staticJobs) != len(expected) {
+			t.Fatal("wrong batch", b)
+		}
+		for i, j := range b.staticJobs {
+			if j != expected[i] {
+				t.Fatal("wrong job", i)
+			}
+		}
+		b.staticQueue.callBatchDone()
+	}
+	checkBatch(batch1)
+	if j := jq.callNextBatch(); j != single {
+		t.Fatal("expected the single job", j)
+	}
+	checkBatch(batch2)
+	if j := jq.callNextBatch(); j != nil {
+		t.Fatal("queue should be empty", j)
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"go.sia.tech/siad/build"
-
 	"gitlab.com/NebulousLabs/errors"
 )
 
@@ -144,6 +142,12 @@ func (w *worker) externTryLaunchSerialJob() {
 // to retrieve a job and launch it. The bandwidth consumption will be updated as
 // the job starts and finishes.
 func (w *worker) externLaunchAsyncJob(job workerJob) bool {
+	return w.externLaunchAsyncJobWith(job, w.renter.tg.Launch)
+}
+
+// externLaunchAsyncJobWith launches a job like externLaunchAsyncJob but uses
+// the provided launch function to run the job.
+func (w *worker) externLaunchAsyncJobWith(job workerJob, launch func(func()) error) bool {
 	// Add the resource requirements to the worker loop state. Also add this
 	// thread to the number of jobs running.
 	uploadBandwidth, downloadBandwidth := job.callExpectedBandwidth()
@@ -161,14 +165,16 @@ func (w *worker) externLaunchAsyncJob(job workerJob) bool {
 		// blocked / ignored because there was not enough bandwidth available.
 		w.staticWake()
 	}
-	err := w.renter.tg.Launch(fn)
+	err := launch(fn)
 	if err != nil {
 		// Renter has closed, but we want to represent that the work was
 		// processed anyway - returning true indicates that the worker should
-		// continue processing jobs.
+		// continue processing jobs. The job itself fails with the launch
+		// error.
 		atomic.AddUint64(&w.staticLoopState.atomicReadDataOutstanding, -downloadBandwidth)
 		atomic.AddUint64(&w.staticLoopState.atomicWriteDataOutstanding, -uploadBandwidth)
 		atomic.AddUint64(&w.staticLoopState.atomicAsyncJobsRunning, ^uint64(0)) // subtract 1
+		job.callDiscard(errors.AddContext(err, "failed to launch job"))
 		return true
 	}
 	return true
@@ -233,15 +239,31 @@ func (w *worker) externTryLaunchAsyncJob() bool {
 		w.externLaunchAsyncJob(job)
 		return true
 	}
-	// Check if registry jobs are supported.
-	cache := w.staticCache()
-	if build.VersionCmp(cache.staticHostVersion, minRegistryVersion) >= 0 {
-		job = w.staticJobUpdateRegistryQueue.callNext()
+	// Check if registry jobs are supported. Queued jobs which aren't
+	// supported anymore are discarded.
+	caps := w.staticRegistryCapabilities()
+	w.managedDiscardUnsupportedRegistryJobs(caps)
+	if caps.Write {
+		job = w.staticJobUpdateRegistryQueue.callNextBatch()
 		if job != nil {
 			w.externLaunchAsyncJob(job)
 			return true
 		}
+	}
+	// ReadRegistry jobs are run by the renter's pool to bound the number of
+	// concurrent reads across all workers. A job is only taken from the queue
+	// once the pool has a free slot for it.
+	pool := w.renter.staticRegistryReadPool
+	if caps.Read && w.staticJobReadRegistryQueue.callLen() > 0 && pool.callTryAcquire(w) {
 		job = w.staticJobReadRegistryQueue.callNext()
+		if job != nil {
+			w.externLaunchAsyncJobWith(job, pool.callLaunch)
+			return true
+		}
+		pool.callRelease()
+	}
+	if caps.Enumeration {
+		job = w.staticJobEnumerateRegistryQueue.callNext()
 		if job != nil {
 			w.externLaunchAsyncJob(job)
 			return true
@@ -283,6 +305,7 @@ func (w *worker) managedDiscardAsyncJobs(err error) {
 	w.staticJobHasSectorQueue.callDiscardAll(err)
 	w.staticJobUpdateRegistryQueue.callDiscardAll(err)
 	w.staticJobReadRegistryQueue.callDiscardAll(err)
+	w.staticJobEnumerateRegistryQueue.callDiscardAll(err)
 	w.staticJobReadQueue.callDiscardAll(err)
 	w.staticJobLowPrioReadQueue.callDiscardAll(err)
 }
@@ -303,6 +326,7 @@ func (w *worker) threadedWorkLoop() {
 	defer w.staticJobLowPrioReadQueue.callKill()
 	defer w.staticJobHasSectorQueue.callKill()
 	defer w.staticJobUpdateRegistryQueue.callKill()
+	defer w.staticJobEnumerateRegistryQueue.callKill()
 	defer w.staticJobReadQueue.callKill()
 	defer w.staticJobDownloadSnapshotQueue.callKill()
 	defer w.staticJobUploadSnapshotQueue.callKill()
@@ -26,8 +26,13 @@ import (
 // information around.
 type workerPool struct {
 	workers map[string]*worker // The string is the host's public key.
-	mu      sync.RWMutex
-	renter  *Renter
+
+	// registryUpdateCooldowns are the cooldowns used by the UpdateRegistry
+	// queues of the workers.
+	registryUpdateCooldowns modules.RegistryUpdateCooldowns
+
+	mu     sync.RWMutex
+	renter *Renter
 }
 
 // callStatus returns the status of the workers in the worker pool.
@@ -92,7 +97,7 @@ func (wp *workerPool) callUpdate() {
 		}
 
 		// Create a new worker and add it to the map
-		w, err := wp.renter.newWorker(contract.HostPublicKey)
+		w, err := wp.renter.newWorker(contract.HostPublicKey, wp.registryUpdateCooldowns)
 		if err != nil {
 			wp.renter.log.Println((errors.AddContext(err, fmt.Sprintf("could not create a new worker for host %v", contract.HostPublicKey))))
 			continue
@@ -123,6 +128,9 @@ func (wp *workerPool) callUpdate() {
 		_, exists := contractMap[id]
 		if !exists {
 			delete(wp.workers, id)
+			// Evict the host's cached registry entries. Jobs which are
+			// still in flight shouldn't use them anymore.
+			worker.staticRegistryCache.Clear()
 			// Kill the worker in a goroutine. This avoids locking issues, as
 			// wp.mu is currently locked.
 			go worker.managedKill()
@@ -180,11 +188,27 @@ func (wp *workerPool) callNumWorkers() int {
 	return l
 }
 
+// callSetRegistryUpdateCooldowns updates the cooldowns used by the
+// UpdateRegistry queues of both the existing and future workers.
+func (wp *workerPool) callSetRegistryUpdateCooldowns(cooldowns modules.RegistryUpdateCooldowns) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.registryUpdateCooldowns = cooldowns
+	for _, w := range wp.workers {
+		w.staticJobUpdateRegistryQueue.callSetCooldowns(cooldowns)
+	}
+}
+
 // newWorkerPool will initialize and return a worker pool.
 func (r *Renter) newWorkerPool() *workerPool {
+	id := r.mu.RLock()
+	cooldowns := r.persist.RegistryUpdateCooldowns
+	r.mu.RUnlock(id)
+
 	wp := &workerPool{
-		workers: make(map[string]*worker),
-		renter:  r,
+		workers:                 make(map[string]*worker),
+		registryUpdateCooldowns: cooldowns,
+		renter:                  r,
 	}
 	wp.renter.tg.OnStop(func() error {
 		wp.mu.RLock()
@@ -23,14 +23,15 @@ type (
 	// cachedEntry describes a single cached entry. To make sure we can cache as
 	// many entries as possible, this only contains the necessary information.
 	cachedEntry struct {
-		key      crypto.Hash
-		revision uint64
+		key       crypto.Hash
+		revision  uint64
+		valueHash crypto.Hash
 	}
 )
 
 // cachedEntryEstimatedSize is the estimated size of a cachedEntry in memory.
-// hash + revision + overhead of 2 pointers
-const cachedEntryEstimatedSize = 32 + 8 + 16
+// hash + revision + value hash + overhead of 2 pointers
+const cachedEntryEstimatedSize = 32 + 8 + 32 + 16
 
 // newRegistryCache creates a new registry cache.
 func newRegistryCache(size uint64) *registryRevisionCache {
@@ -54,6 +55,20 @@ func (rc *registryRevisionCache) Get(pubKey types.SiaPublicKey, tweak crypto.Has
 	return cachedEntry.revision, true
 }
 
+// Contains returns whether the cached entry for the provided registry value
+// matches the value exactly.
+func (rc *registryRevisionCache) Contains(pubKey types.SiaPublicKey, rv modules.SignedRegistryValue) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	mapKey := crypto.HashAll(pubKey, rv.Tweak)
+	cachedEntry, exists := rc.entryMap[mapKey]
+	if !exists {
+		return false
+	}
+	return cachedEntry.revision == rv.Revision && cachedEntry.valueHash == crypto.HashObject(rv)
+}
+
 // Set sets an entry in the registry. When 'force' is false, settings a lower
 // revision number will be a no-op.
 func (rc *registryRevisionCache) Set(pubKey types.SiaPublicKey, rv modules.SignedRegistryValue, force bool) {
@@ -67,6 +82,7 @@ func (rc *registryRevisionCache) Set(pubKey types.SiaPublicKey, rv modules.Signe
 	// If it does, update the revision.
 	if exists && (rv.Revision > ce.revision || force) {
 		ce.revision = rv.Revision
+		ce.valueHash = crypto.HashObject(rv)
 		return
 	} else if exists {
 		return
@@ -74,8 +90,9 @@ func (rc *registryRevisionCache) Set(pubKey types.SiaPublicKey, rv modules.Signe
 
 	// If it doesn't, create a new one.
 	ce = &cachedEntry{
-		key:      mapKey,
-		revision: rv.Revision,
+		key:       mapKey,
+		revision:  rv.Revision,
+		valueHash: crypto.HashObject(rv),
 	}
 	rc.entryMap[mapKey] = ce
 	rc.entryList = append(rc.entryList, ce)
@@ -94,3 +111,32 @@ func (rc *registryRevisionCache) Set(pubKey types.SiaPublicKey, rv modules.Signe
 		rc.entryList = rc.entryList[:len(rc.entryList)-1]
 	}
 }
+
+// Invalidate removes the entry for the provided key and tweak from the cache.
+func (rc *registryRevisionCache) Invalidate(pubKey types.SiaPublicKey, tweak crypto.Hash) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	mapKey := crypto.HashAll(pubKey, tweak)
+
+	entry, exists := rc.entryMap[mapKey]
+	if !exists {
+		return
+	}
+	delete(rc.entryMap, mapKey)
+	for idx := range rc.entryList {
+		if rc.entryList[idx] != entry {
+			continue
+		}
+		rc.entryList[idx] = rc.entryList[len(rc.entryList)-1]
+		rc.entryList = rc.entryList[:len(rc.entryList)-1]
+		break
+	}
+}
+
+// Clear removes all entries from the cache.
+func (rc *registryRevisionCache) Clear() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entryMap = make(map[crypto.Hash]*cachedEntry)
+	rc.entryList = nil
+}
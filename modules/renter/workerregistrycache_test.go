@@ -16,23 +16,7 @@ import (
 // Delete deltes an entry from the cache without replacing it. Should only be
 // used in testing.
 func (rc *registryRevisionCache) Delete(pubKey types.SiaPublicKey, rv modules.SignedRegistryValue) {
-	rc.mu.Lock()
-	defer rc.mu.Unlock()
-	mapKey := crypto.HashAll(pubKey, rv.Tweak)
-
-	entry, exists := rc.entryMap[mapKey]
-	if !exists {
-		return
-	}
-	delete(rc.entryMap, mapKey)
-	for idx := range rc.entryList {
-		if rc.entryList[idx] != entry {
-			continue
-		}
-		rc.entryList[idx] = rc.entryList[len(rc.entryList)-1]
-		rc.entryList = rc.entryList[:len(rc.entryList)-1]
-		break
-	}
+	rc.Invalidate(pubKey, rv.Tweak)
 }
 
 // TestRegistryCache tests the in-memory registry type.
@@ -142,3 +126,58 @@ func TestRegistryCache(t *testing.T) {
 		t.Fatal("get returned wrong value", exists, readRev)
 	}
 }
+
+// TestRegistryCacheEviction tests that cached entries can be invalidated and
+// that the entries of a host are evicted when it leaves the worker set.
+func TestRegistryCacheEviction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Cache a registry value.
+	sk, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	rv := modules.NewRegistryValue(tweak, fastrand.Bytes(modules.RegistryDataSize), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	wt.staticRegistryCache.Set(spk, rv, false)
+	if _, cached := wt.staticRegistryCache.Get(spk, tweak); !cached {
+		t.Fatal("entry should be cached")
+	}
+
+	// Invalidate it.
+	if err := wt.rt.renter.InvalidateRegistryCache(spk, tweak); err != nil {
+		t.Fatal(err)
+	}
+	if _, cached := wt.staticRegistryCache.Get(spk, tweak); cached {
+		t.Fatal("entry should have been invalidated")
+	}
+
+	// Cache it again and remove the host from the worker set by cancelling
+	// its contract.
+	wt.staticRegistryCache.Set(spk, rv, false)
+	if err := wt.rt.renter.CancelContract(wt.staticCache().staticContractID); err != nil {
+		t.Fatal(err)
+	}
+	wt.rt.renter.staticWorkerPool.callUpdate()
+	if workers := wt.rt.renter.staticWorkerPool.callWorkers(); len(workers) != 0 {
+		t.Fatal("expected no workers but got", len(workers))
+	}
+	if _, cached := wt.staticRegistryCache.Get(spk, tweak); cached {
+		t.Fatal("entry should have been evicted")
+	}
+}
@@ -0,0 +1,227 @@
+package renter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// minRegistryEIDVersion is the min version required for a host to support
+	// reading registry entries by their entry id. Reading by entry id is what
+	// allows for reading multiple entries within a single program.
+	minRegistryEIDVersion = "1.5.5"
+
+	// minRegistryEntryTypeVersion is the min version required for a host to
+	// support registry entries with an entry type.
+	minRegistryEntryTypeVersion = "1.5.6"
+
+	// minRegistryEnumerationVersion is the min version required for a host
+	// to support enumerating the registry entries of a public key.
+	minRegistryEnumerationVersion = "1.5.10"
+)
+
+var (
+	// registryCapabilitiesTTL is the amount of time the registry capabilities
+	// of a host are cached for before they are derived again.
+	registryCapabilitiesTTL = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 10 * time.Minute,
+		Testnet:  10 * time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
+	// errRegistryUnsupported is returned if a registry operation is attempted
+	// on a host which doesn't support it.
+	errRegistryUnsupported = errors.New("host doesn't support the requested registry operation")
+)
+
+type (
+	// registryCapabilities describes which registry features a host supports.
+	registryCapabilities struct {
+		// Read and Write indicate whether the host supports reading from and
+		// writing to the registry at all.
+		Read  bool
+		Write bool
+
+		// BatchRead indicates that the host supports reading entries by
+		// entry id which allows for batching multiple reads into a single
+		// program.
+		BatchRead bool
+
+		// BatchWrite indicates that the host supports executing multiple
+		// registry updates within a single program. It requires the same
+		// version as BatchRead.
+		BatchWrite bool
+
+		// EntryType indicates that the host supports typed registry entries.
+		EntryType bool
+
+		// Subscription indicates that the host supports the registry
+		// subscription protocol.
+		Subscription bool
+
+		// Enumeration indicates that the host supports enumerating the
+		// entries stored for a public key.
+		Enumeration bool
+
+		// DebugNeighbors indicates that an entry may be read together with
+		// its neighbors by tweak ordering. It requires Enumeration and is
+		// only enabled in debug builds since it's only meant for
+		// investigating host-side storage bugs.
+		DebugNeighbors bool
+
+		// Deletion and RevisionRetention are not supported by any host
+		// version at the moment. Entries can only expire and hosts only ever
+		// store the latest revision of an entry. They are part of the struct
+		// to allow callers to make that decision explicitly.
+		Deletion          bool
+		RevisionRetention bool
+
+		// EntriesLeft and EntriesTotal are copied from the host's price
+		// table.
+		EntriesLeft  uint64
+		EntriesTotal uint64
+
+		// Probed indicates whether the Read capability was confirmed by
+		// probing the host rather than derived from its settings.
+		Probed bool
+	}
+
+	// registryCapabilitiesCache caches the registry capabilities of a host
+	// for registryCapabilitiesTTL.
+	registryCapabilitiesCache struct {
+		capabilities registryCapabilities
+		expiry       time.Time
+		valid        bool
+
+		// staticTTL is the duration for which fetched capabilities are
+		// considered valid.
+		staticTTL time.Duration
+
+		mu sync.Mutex
+	}
+)
+
+// newRegistryCapabilitiesCache creates a new, empty capabilities cache.
+func newRegistryCapabilitiesCache(ttl time.Duration) *registryCapabilitiesCache {
+	return &registryCapabilitiesCache{
+		staticTTL: ttl,
+	}
+}
+
+// Get returns the cached capabilities if they haven't expired yet.
+func (c *registryCapabilitiesCache) Get() (registryCapabilities, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.valid || time.Now().After(c.expiry) {
+		return registryCapabilities{}, false
+	}
+	return c.capabilities, true
+}
+
+// Set updates the cached capabilities and resets the TTL.
+func (c *registryCapabilitiesCache) Set(caps registryCapabilities) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capabilities = caps
+	c.expiry = time.Now().Add(c.staticTTL)
+	c.valid = true
+}
+
+// registryCapabilitiesFromSettings derives the registry capabilities of a host
+// from its version and price table. If the price table wasn't fetched yet, the
+// host is assumed to accept writes as long as its version supports the
+// registry.
+func registryCapabilitiesFromSettings(version string, pt modules.RPCPriceTable) registryCapabilities {
+	if build.VersionCmp(version, minRegistryVersion) < 0 {
+		return registryCapabilities{}
+	}
+	ptFetched := pt.UID != (modules.UniqueID{})
+	write := !ptFetched || pt.RegistryEntriesTotal > 0
+	batch := build.VersionCmp(version, minRegistryEIDVersion) >= 0
+	enumeration := build.VersionCmp(version, minRegistryEnumerationVersion) >= 0
+	return registryCapabilities{
+		Read:           true,
+		Write:          write,
+		BatchRead:      batch,
+		BatchWrite:     write && batch,
+		EntryType:      build.VersionCmp(version, minRegistryEntryTypeVersion) >= 0,
+		Subscription:   build.VersionCmp(version, minSubscriptionVersion) >= 0,
+		Enumeration:    enumeration,
+		DebugNeighbors: build.DEBUG && enumeration,
+		EntriesLeft:    pt.RegistryEntriesLeft,
+		EntriesTotal:   pt.RegistryEntriesTotal,
+	}
+}
+
+// staticRegistryCapabilities returns the registry capabilities of the worker's
+// host without probing it. If the capabilities are cached, the cached value is
+// returned. Otherwise they are derived from the host's settings. This is cheap
+// enough to be called by the job scheduling code.
+func (w *worker) staticRegistryCapabilities() registryCapabilities {
+	if caps, ok := w.staticRegistryCapabilitiesCache.Get(); ok {
+		return caps
+	}
+	return registryCapabilitiesFromSettings(w.staticCache().staticHostVersion, w.staticPriceTable().staticPriceTable)
+}
+
+// RegistryCapabilities returns the registry capabilities of the worker's host.
+// The capabilities are derived from the host's version and price table. If the
+// price table doesn't report any registry capacity, the host is probed with a
+// read of a random entry to confirm that reading from the registry works. The
+// result is cached for registryCapabilitiesTTL.
+func (w *worker) RegistryCapabilities(ctx context.Context) (registryCapabilities, error) {
+	if caps, ok := w.staticRegistryCapabilitiesCache.Get(); ok {
+		return caps, nil
+	}
+	caps := registryCapabilitiesFromSettings(w.staticCache().staticHostVersion, w.staticPriceTable().staticPriceTable)
+
+	// If the host claims to support the registry but doesn't report any
+	// capacity, it might be an older host which doesn't fill out the fields
+	// or a host with a disabled registry. Probe it to find out if reads work.
+	if caps.Read && caps.EntriesTotal == 0 {
+		var spk types.SiaPublicKey
+		spk.Algorithm = types.SignatureEd25519
+		spk.Key = fastrand.Bytes(crypto.PublicKeySize)
+		var tweak crypto.Hash
+		fastrand.Read(tweak[:])
+		_, err := w.ReadRegistry(ctx, spk, tweak)
+		if err != nil {
+			return registryCapabilities{}, errors.AddContext(err, "failed to probe host registry")
+		}
+		caps.Probed = true
+	}
+	w.managedSetRegistryCapabilities(caps)
+	return caps, nil
+}
+
+// managedSetRegistryCapabilities caches the registry capabilities of the
+// worker's host and discards the queued registry jobs which the host doesn't
+// support anymore.
+func (w *worker) managedSetRegistryCapabilities(caps registryCapabilities) {
+	w.staticRegistryCapabilitiesCache.Set(caps)
+	w.managedDiscardUnsupportedRegistryJobs(caps)
+}
+
+// managedDiscardUnsupportedRegistryJobs discards the queued registry jobs
+// which aren't supported according to caps. Otherwise they would never be
+// launched and only fail once their context expires.
+func (w *worker) managedDiscardUnsupportedRegistryJobs(caps registryCapabilities) {
+	if !caps.Write && w.staticJobUpdateRegistryQueue.callLen() > 0 {
+		w.staticJobUpdateRegistryQueue.callDiscardAll(errRegistryUnsupported)
+	}
+	if !caps.Read && w.staticJobReadRegistryQueue.callLen() > 0 {
+		w.staticJobReadRegistryQueue.callDiscardAll(errRegistryUnsupported)
+	}
+	if !caps.Enumeration && w.staticJobEnumerateRegistryQueue.callLen() > 0 {
+		w.staticJobEnumerateRegistryQueue.callDiscardAll(errRegistryUnsupported)
+	}
+}
@@ -0,0 +1,251 @@
+package renter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestRegistryCapabilitiesFromSettings tests that the registry capabilities are
+// correctly derived from a host's version and price table.
+func TestRegistryCapabilitiesFromSettings(t *testing.T) {
+	t.Parallel()
+
+	var pt modules.RPCPriceTable
+	fastrand.Read(pt.UID[:])
+	pt.RegistryEntriesLeft = 10
+	pt.RegistryEntriesTotal = 100
+
+	tests := []struct {
+		version string
+		pt      modules.RPCPriceTable
+		result  registryCapabilities
+	}{
+		// Host without registry support.
+		{
+			version: "1.5.0",
+			pt:      pt,
+			result:  registryCapabilities{},
+		},
+		// Host with basic registry support.
+		{
+			version: "1.5.4",
+			pt:      pt,
+			result: registryCapabilities{
+				Read:         true,
+				Write:        true,
+				EntriesLeft:  10,
+				EntriesTotal: 100,
+			},
+		},
+		// Host with subscription support.
+		{
+			version: "1.5.5",
+			pt:      pt,
+			result: registryCapabilities{
+				Read:         true,
+				Write:        true,
+				BatchRead:    true,
+				BatchWrite:   true,
+				Subscription: true,
+				EntriesLeft:  10,
+				EntriesTotal: 100,
+			},
+		},
+		// Latest host.
+		{
+			version: "1.5.6",
+			pt:      pt,
+			result: registryCapabilities{
+				Read:         true,
+				Write:        true,
+				BatchRead:    true,
+				BatchWrite:   true,
+				EntryType:    true,
+				Subscription: true,
+				EntriesLeft:  10,
+				EntriesTotal: 100,
+			},
+		},
+		// Host with enumeration support.
+		{
+			version: "1.5.10",
+			pt:      pt,
+			result: registryCapabilities{
+				Read:           true,
+				Write:          true,
+				BatchRead:      true,
+				BatchWrite:     true,
+				EntryType:      true,
+				Subscription:   true,
+				Enumeration:    true,
+				DebugNeighbors: build.DEBUG,
+				EntriesLeft:    10,
+				EntriesTotal:   100,
+			},
+		},
+		// Host with a disabled registry.
+		{
+			version: "1.5.6",
+			pt:      modules.RPCPriceTable{UID: pt.UID},
+			result: registryCapabilities{
+				Read:         true,
+				BatchRead:    true,
+				EntryType:    true,
+				Subscription: true,
+			},
+		},
+		// Host without price table.
+		{
+			version: "1.5.6",
+			pt:      modules.RPCPriceTable{},
+			result: registryCapabilities{
+				Read:         true,
+				Write:        true,
+				BatchRead:    true,
+				BatchWrite:   true,
+				EntryType:    true,
+				Subscription: true,
+			},
+		},
+	}
+	for i, test := range tests {
+		caps := registryCapabilitiesFromSettings(test.version, test.pt)
+		if caps != test.result {
+			t.Errorf("%v: wrong capabilities %+v != %+v", i, caps, test.result)
+		}
+	}
+}
+
+// TestRegistryCapabilitiesCache tests the TTL of the registry capabilities
+// cache.
+func TestRegistryCapabilitiesCache(t *testing.T) {
+	t.Parallel()
+
+	ttl := 100 * time.Millisecond
+	c := newRegistryCapabilitiesCache(ttl)
+
+	// Empty cache.
+	if _, ok := c.Get(); ok {
+		t.Fatal("empty cache shouldn't return capabilities")
+	}
+
+	// Set capabilities.
+	caps := registryCapabilities{Read: true, Write: true, EntriesTotal: 10}
+	c.Set(caps)
+	cached, ok := c.Get()
+	if !ok {
+		t.Fatal("capabilities should be cached")
+	}
+	if cached != caps {
+		t.Fatal("wrong capabilities", cached, caps)
+	}
+
+	// Wait for the entry to expire.
+	time.Sleep(ttl)
+	if _, ok := c.Get(); ok {
+		t.Fatal("capabilities should have expired")
+	}
+}
+
+// TestRegistryCapabilitiesWorker tests fetching the registry capabilities from
+// a worker.
+func TestRegistryCapabilitiesWorker(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The capabilities shouldn't be cached yet.
+	if _, ok := wt.staticRegistryCapabilitiesCache.Get(); ok {
+		t.Fatal("capabilities shouldn't be cached yet")
+	}
+
+	// Fetch them.
+	caps, err := wt.RegistryCapabilities(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.Read || !caps.Write || !caps.BatchRead || !caps.EntryType || !caps.Subscription {
+		t.Fatal("host should support all registry features", caps)
+	}
+	if caps.Deletion || caps.RevisionRetention {
+		t.Fatal("host shouldn't support deletion or revision retention", caps)
+	}
+	if caps.EntriesTotal == 0 {
+		t.Fatal("host should report registry capacity")
+	}
+
+	// They should be cached now.
+	cached, ok := wt.staticRegistryCapabilitiesCache.Get()
+	if !ok {
+		t.Fatal("capabilities should be cached")
+	}
+	if cached != caps {
+		t.Fatal("cached capabilities don't match", cached, caps)
+	}
+}
+
+// TestRegistryCapabilitiesDiscardUnsupported tests that queued registry jobs
+// are discarded once the cached capabilities don't support them anymore.
+func TestRegistryCapabilitiesDiscardUnsupported(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Queue an update without waking the worker.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	srv := modules.NewRegistryValue(crypto.Hash{1}, fastrand.Bytes(10), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	respChan := make(chan *jobUpdateRegistryResponse)
+	j := wt.newJobUpdateRegistry(context.Background(), respChan, spk, srv)
+	jq := wt.staticJobUpdateRegistryQueue
+	jq.mu.Lock()
+	jq.jobs.PushBack(j)
+	jq.mu.Unlock()
+
+	// The host stops supporting writes. The job is discarded right away.
+	wt.managedSetRegistryCapabilities(registryCapabilities{Read: true})
+	select {
+	case resp := <-respChan:
+		if !errors.Contains(resp.staticErr, errRegistryUnsupported) {
+			t.Fatal("expected errRegistryUnsupported", resp.staticErr)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("job wasn't discarded")
+	}
+	if jq.callLen() != 0 {
+		t.Fatal("queue should be empty")
+	}
+}
@@ -0,0 +1,99 @@
+package renter
+
+import (
+	"context"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// errTooManyFanTweaks is returned by UpdateRegistryFanTweaks if more than
+// updateRegistryMaxBatchSize tweaks are provided.
+var errTooManyFanTweaks = errors.New("too many tweaks to update at once")
+
+// registryFanResult is the result of updating a single tweak with
+// UpdateRegistryFanTweaks.
+type registryFanResult struct {
+	// Tweak is the updated tweak and Value the signed value which was
+	// written under it.
+	Tweak crypto.Hash
+	Value modules.SignedRegistryValue
+
+	// Err is the result of the update. If it is ErrSameRevNum or
+	// ErrLowerRevNum, Proof contains the host's entry.
+	Err   error
+	Proof *modules.SignedRegistryValue
+}
+
+// UpdateRegistryFanTweaks writes the same registry value under multiple tweaks
+// of the worker's host. The value is signed separately for every tweak. If
+// the host supports the BatchWrite capability, the updates are queued together
+// and the worker executes them within a single program. The host stops
+// executing the program at the first failed update and the remaining ones are
+// executed individually. Otherwise the tweaks are updated one after another.
+//
+// The updates are best-effort. They are not atomic and updates which
+// succeeded are not rolled back if others fail, so every tweak receives its
+// own result which needs to be checked.
+func (w *worker) UpdateRegistryFanTweaks(ctx context.Context, spk types.SiaPublicKey, tweaks []crypto.Hash, value modules.RegistryValue, sk crypto.SecretKey) ([]registryFanResult, error) {
+	caps := w.staticRegistryCapabilities()
+	if !caps.Write {
+		return nil, errRegistryUnsupported
+	}
+	if len(tweaks) > updateRegistryMaxBatchSize {
+		return nil, errTooManyFanTweaks
+	}
+
+	// Sign the value for every tweak and create the jobs.
+	results := make([]registryFanResult, len(tweaks))
+	jobs := make([]*jobUpdateRegistry, len(tweaks))
+	respChans := make([]chan *jobUpdateRegistryResponse, len(tweaks))
+	for i, tweak := range tweaks {
+		rv := value
+		rv.Tweak = tweak
+		results[i].Tweak = tweak
+		results[i].Value = rv.Sign(sk)
+		respChans[i] = make(chan *jobUpdateRegistryResponse)
+		jobs[i] = w.newJobUpdateRegistry(ctx, respChans[i], spk, results[i].Value)
+	}
+
+	// awaitResult waits for the response of the i-th job.
+	awaitResult := func(i int) error {
+		select {
+		case <-ctx.Done():
+			return errors.New("UpdateRegistryFanTweaks interrupted")
+		case resp := <-respChans[i]:
+			results[i].Err = resp.staticErr
+			results[i].Proof = resp.srv
+		}
+		return nil
+	}
+
+	// Fall back to sequential updates.
+	jq := w.staticJobUpdateRegistryQueue
+	if !caps.BatchWrite {
+		for i, j := range jobs {
+			if !jq.callAdd(j) {
+				results[i].Err = errors.New("worker unavailable")
+				continue
+			}
+			if err := awaitResult(i); err != nil {
+				return nil, err
+			}
+		}
+		return results, nil
+	}
+
+	// Queue the jobs together. The worker picks them up as a single batch.
+	if !jq.callAddBatch(jobs) {
+		return nil, errors.New("worker unavailable")
+	}
+	for i := range jobs {
+		if err := awaitResult(i); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
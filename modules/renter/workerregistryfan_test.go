@@ -0,0 +1,96 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestUpdateRegistryFanTweaks tests writing the same data under three tweaks.
+func TestUpdateRegistryFanTweaks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if !wt.staticRegistryCapabilities().BatchWrite {
+		t.Fatal("host should support batched writes")
+	}
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	tweaks := []crypto.Hash{{1}, {2}, {3}}
+	data := fastrand.Bytes(10)
+	value := modules.NewRegistryValue(crypto.Hash{}, data, 1, modules.RegistryTypeWithoutPubkey)
+
+	// Write the value under all tweaks using a single program.
+	jq := wt.staticJobUpdateRegistryQueue
+	jq.mu.Lock()
+	programs := jq.programsExecuted
+	jq.mu.Unlock()
+	results, err := wt.UpdateRegistryFanTweaks(context.Background(), spk, tweaks, value, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jq.mu.Lock()
+	programs = jq.programsExecuted - programs
+	jq.mu.Unlock()
+	if programs != 1 {
+		t.Fatal("expected a single program but got", programs)
+	}
+	if len(results) != len(tweaks) {
+		t.Fatal("wrong number of results", len(results))
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if res.Tweak != tweaks[i] || res.Value.Tweak != tweaks[i] {
+			t.Fatal("wrong tweak", res.Tweak, res.Value.Tweak, tweaks[i])
+		}
+		srv, err := lookupRegistry(wt.worker, spk, tweaks[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(srv.Data, data) || srv.Revision != 1 {
+			t.Fatal("wrong entry on host", srv.Data, srv.Revision)
+		}
+	}
+
+	// Writing a lower revision fails for every tweak and comes with the
+	// host's entry as proof.
+	value.Revision = 0
+	results, err = wt.UpdateRegistryFanTweaks(context.Background(), spk, tweaks, value, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		if !errors.Contains(res.Err, modules.ErrLowerRevNum) {
+			t.Fatal("expected ErrLowerRevNum", res.Err)
+		}
+		if res.Proof == nil || res.Proof.Revision != 1 {
+			t.Fatal("expected proof with revision 1", res.Proof)
+		}
+	}
+
+	// Too many tweaks are rejected.
+	_, err = wt.UpdateRegistryFanTweaks(context.Background(), spk, make([]crypto.Hash, updateRegistryMaxBatchSize+1), value, sk)
+	if !errors.Contains(err, errTooManyFanTweaks) {
+		t.Fatal("expected errTooManyFanTweaks", err)
+	}
+}
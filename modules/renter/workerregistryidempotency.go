@@ -0,0 +1,135 @@
+package renter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// registryIdempotencyMaxEntries is the max number of completed updates a
+	// worker remembers. Once the limit is reached, the oldest ones are
+	// forgotten first.
+	registryIdempotencyMaxEntries = 1000
+)
+
+var (
+	// registryIdempotencyTTL is the amount of time a worker remembers a
+	// completed update by its idempotency key.
+	registryIdempotencyTTL = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 5 * time.Minute,
+		Testnet:  5 * time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
+	// errIdempotencyKeyReused is returned if an idempotency key is reused for
+	// a different update while the previous one is still remembered.
+	errIdempotencyKeyReused = errors.New("idempotency key was already used for a different update")
+)
+
+type (
+	// registryIdempotencyCache remembers recently completed registry updates
+	// by their idempotency key.
+	registryIdempotencyCache struct {
+		entries map[string]*completedUpdate
+		order   []*completedUpdate
+
+		staticMaxEntries int
+		staticTTL        time.Duration
+		mu               sync.Mutex
+	}
+
+	// completedUpdate is a registry update which completed successfully.
+	completedUpdate struct {
+		key       string
+		entryHash crypto.Hash
+		expiry    time.Time
+	}
+)
+
+// newRegistryIdempotencyCache creates a new, empty cache.
+func newRegistryIdempotencyCache(maxEntries int, ttl time.Duration) *registryIdempotencyCache {
+	return &registryIdempotencyCache{
+		entries:          make(map[string]*completedUpdate),
+		staticMaxEntries: maxEntries,
+		staticTTL:        ttl,
+	}
+}
+
+// Get returns whether the update for the key was completed recently. If the
+// key was used for a different entry, errIdempotencyKeyReused is returned.
+func (c *registryIdempotencyCache) Get(key string, entryHash crypto.Hash) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneExpired()
+	cu, exists := c.entries[key]
+	if !exists {
+		return false, nil
+	}
+	if cu.entryHash != entryHash {
+		return false, errIdempotencyKeyReused
+	}
+	return true, nil
+}
+
+// Set remembers the completed update for the key.
+func (c *registryIdempotencyCache) Set(key string, entryHash crypto.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneExpired()
+	if _, exists := c.entries[key]; exists {
+		return // only remember the first completion
+	}
+	for len(c.order) >= c.staticMaxEntries {
+		delete(c.entries, c.order[0].key)
+		c.order = c.order[1:]
+	}
+	cu := &completedUpdate{
+		key:       key,
+		entryHash: entryHash,
+		expiry:    time.Now().Add(c.staticTTL),
+	}
+	c.entries[key] = cu
+	c.order = append(c.order, cu)
+}
+
+// pruneExpired removes the expired updates. Since all updates share the same
+// TTL, they expire in the order they were added.
+func (c *registryIdempotencyCache) pruneExpired() {
+	now := time.Now()
+	for len(c.order) > 0 && now.After(c.order[0].expiry) {
+		delete(c.entries, c.order[0].key)
+		c.order = c.order[1:]
+	}
+}
+
+// UpdateRegistryIdempotent runs a UpdateRegistry job on a worker unless an
+// update with the same idempotency key completed within the last
+// registryIdempotencyTTL. In that case the cached success is returned without
+// contacting the host. Failed updates are not remembered and can be retried
+// with the same key. An empty key disables the check.
+func (w *worker) UpdateRegistryIdempotent(ctx context.Context, spk types.SiaPublicKey, rv modules.SignedRegistryValue, key string) error {
+	if key == "" {
+		return w.UpdateRegistry(ctx, spk, rv)
+	}
+	entryHash := crypto.HashAll(spk, rv)
+	completed, err := w.staticRegistryIdempotencyCache.Get(key, entryHash)
+	if err != nil {
+		return err
+	}
+	if completed {
+		return nil
+	}
+	if err := w.UpdateRegistry(ctx, spk, rv); err != nil {
+		return err
+	}
+	w.staticRegistryIdempotencyCache.Set(key, entryHash)
+	return nil
+}
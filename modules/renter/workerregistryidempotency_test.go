@@ -0,0 +1,107 @@
+package renter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestRegistryIdempotencyCache is a unit test for the registryIdempotencyCache.
+func TestRegistryIdempotencyCache(t *testing.T) {
+	t.Parallel()
+
+	ttl := 100 * time.Millisecond
+	c := newRegistryIdempotencyCache(3, ttl)
+	h1, h2 := crypto.Hash{1}, crypto.Hash{2}
+
+	// Unknown keys are not completed.
+	if completed, err := c.Get("a", h1); err != nil || completed {
+		t.Fatal("unexpected result", completed, err)
+	}
+	c.Set("a", h1)
+	if completed, err := c.Get("a", h1); err != nil || !completed {
+		t.Fatal("unexpected result", completed, err)
+	}
+
+	// Reusing a key for a different entry fails.
+	if _, err := c.Get("a", h2); err != errIdempotencyKeyReused {
+		t.Fatal("expected errIdempotencyKeyReused", err)
+	}
+
+	// The cache is bounded. The oldest key is evicted first.
+	for i := 0; i < 3; i++ {
+		c.Set(fmt.Sprint(i), h1)
+	}
+	if len(c.entries) != 3 || len(c.order) != 3 {
+		t.Fatal("cache isn't bounded", len(c.entries), len(c.order))
+	}
+	if completed, _ := c.Get("a", h1); completed {
+		t.Fatal("oldest key wasn't evicted")
+	}
+
+	// The keys expire.
+	time.Sleep(2 * ttl)
+	if completed, _ := c.Get("2", h1); completed {
+		t.Fatal("key didn't expire")
+	}
+	if len(c.entries) != 0 || len(c.order) != 0 {
+		t.Fatal("expired keys weren't pruned", len(c.entries), len(c.order))
+	}
+}
+
+// TestUpdateRegistryIdempotent tests that retrying an update with the same
+// idempotency key returns the cached success without contacting the host.
+func TestUpdateRegistryIdempotent(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a registry value.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	rv := modules.NewRegistryValue(tweak, fastrand.Bytes(modules.RegistryDataSize), 1, modules.RegistryTypeWithoutPubkey).Sign(sk)
+
+	// Update the entry.
+	key := "key"
+	if err := wt.UpdateRegistryIdempotent(context.Background(), spk, rv, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// Retry the update with a closed context. Since the update completed,
+	// the cached success is returned without running a job.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := wt.UpdateRegistryIdempotent(ctx, spk, rv, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a key the update is attempted and interrupted.
+	if err := wt.UpdateRegistryIdempotent(ctx, spk, rv, ""); err == nil {
+		t.Fatal("update without key should have been interrupted")
+	}
+
+	// Reusing the key for another entry fails.
+	rv2 := modules.NewRegistryValue(tweak, rv.Data, 2, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	if err := wt.UpdateRegistryIdempotent(context.Background(), spk, rv2, key); err != errIdempotencyKeyReused {
+		t.Fatal("expected errIdempotencyKeyReused", err)
+	}
+}
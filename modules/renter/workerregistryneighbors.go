@@ -0,0 +1,96 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+type (
+	// registryNeighbor is an entry next to the requested one by tweak
+	// ordering. Enumerated is what the host reported when enumerating the
+	// entries and Value is what it returned when reading the entry. Value is
+	// nil if the host enumerated an entry it couldn't return.
+	registryNeighbor struct {
+		Enumerated modules.RegistryEnumerateEntry
+		Value      *modules.SignedRegistryValue
+	}
+
+	// registryNeighbors is the result of ReadRegistryNeighbors.
+	registryNeighbors struct {
+		// Entry is the requested entry. It is nil if the host doesn't store
+		// it.
+		Entry *modules.SignedRegistryValue
+
+		// Prev and Next are the entries with the closest lower and higher
+		// tweak. They are nil if there is no such entry.
+		Prev *registryNeighbor
+		Next *registryNeighbor
+	}
+)
+
+// ReadRegistryNeighbors reads the entry with the provided tweak from the
+// worker's host together with the entries whose tweaks precede and follow it.
+// This is a debugging tool to spot off-by-one bugs in a host's registry
+// storage and is only available if the host supports the DebugNeighbors
+// capability, which requires a debug build.
+func (w *worker) ReadRegistryNeighbors(ctx context.Context, spk types.SiaPublicKey, tweak crypto.Hash) (registryNeighbors, error) {
+	if !w.staticRegistryCapabilities().DebugNeighbors {
+		return registryNeighbors{}, errRegistryUnsupported
+	}
+
+	// Page through the entries, which are sorted by tweak, until the entry
+	// following the tweak is found.
+	var prev, next *modules.RegistryEnumerateEntry
+	var start crypto.Hash
+	for next == nil {
+		entries, nextStart, more, err := w.EnumerateRegistry(ctx, spk, start, modules.MaxRegistryEnumerateEntries)
+		if err != nil {
+			return registryNeighbors{}, errors.AddContext(err, "failed to enumerate entries")
+		}
+		for i := range entries {
+			cmp := bytes.Compare(entries[i].Tweak[:], tweak[:])
+			if cmp < 0 {
+				prev = &entries[i]
+			} else if cmp > 0 {
+				next = &entries[i]
+				break
+			}
+		}
+		if !more {
+			break
+		}
+		start = nextStart
+	}
+
+	readNeighbor := func(entry modules.RegistryEnumerateEntry) (*registryNeighbor, error) {
+		srv, err := w.ReadRegistry(ctx, spk, entry.Tweak)
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to read neighbor")
+		}
+		return &registryNeighbor{Enumerated: entry, Value: srv}, nil
+	}
+	var result registryNeighbors
+	var err error
+	result.Entry, err = w.ReadRegistry(ctx, spk, tweak)
+	if err != nil {
+		return registryNeighbors{}, errors.AddContext(err, "failed to read entry")
+	}
+	if prev != nil {
+		result.Prev, err = readNeighbor(*prev)
+		if err != nil {
+			return registryNeighbors{}, err
+		}
+	}
+	if next != nil {
+		result.Next, err = readNeighbor(*next)
+		if err != nil {
+			return registryNeighbors{}, err
+		}
+	}
+	return result, nil
+}
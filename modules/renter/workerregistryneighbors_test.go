@@ -0,0 +1,98 @@
+package renter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestReadRegistryNeighbors tests reading entries together with their
+// neighbors from a host which stores several entries.
+func TestReadRegistryNeighbors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Store entries with the tweaks 2, 4 and 6 on the host.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	values := make(map[byte]modules.SignedRegistryValue)
+	for _, b := range []byte{2, 4, 6} {
+		tweak := crypto.Hash{b}
+		rv := modules.NewRegistryValue(tweak, fastrand.Bytes(10), uint64(b), modules.RegistryTypeWithoutPubkey).Sign(sk)
+		if err := wt.UpdateRegistry(context.Background(), spk, rv); err != nil {
+			t.Fatal(err)
+		}
+		values[b] = rv
+	}
+
+	checkNeighbor := func(name string, n *registryNeighbor, b byte) {
+		t.Helper()
+		if b == 0 {
+			if n != nil {
+				t.Fatalf("%v: expected no neighbor but got %v", name, n.Enumerated)
+			}
+			return
+		}
+		expected := values[b]
+		if n == nil || n.Value == nil {
+			t.Fatalf("%v: expected neighbor %v", name, b)
+		}
+		if n.Enumerated.Tweak != expected.Tweak || n.Enumerated.Revision != expected.Revision {
+			t.Fatalf("%v: wrong enumerated entry %v", name, n.Enumerated)
+		}
+		if !reflect.DeepEqual(*n.Value, expected) {
+			t.Fatalf("%v: wrong value", name)
+		}
+	}
+	tests := []struct {
+		tweak      byte
+		stored     bool
+		prev, next byte
+	}{
+		{tweak: 4, stored: true, prev: 2, next: 6},  // stored entry in the middle
+		{tweak: 2, stored: true, prev: 0, next: 4},  // first entry
+		{tweak: 6, stored: true, prev: 4, next: 0},  // last entry
+		{tweak: 5, stored: false, prev: 4, next: 6}, // missing entry
+	}
+	for _, test := range tests {
+		n, err := wt.ReadRegistryNeighbors(context.Background(), spk, crypto.Hash{test.tweak})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.stored && (n.Entry == nil || !reflect.DeepEqual(*n.Entry, values[test.tweak])) {
+			t.Fatalf("%v: wrong entry", test.tweak)
+		}
+		if !test.stored && n.Entry != nil {
+			t.Fatalf("%v: entry shouldn't exist", test.tweak)
+		}
+		checkNeighbor("prev", n.Prev, test.prev)
+		checkNeighbor("next", n.Next, test.next)
+	}
+
+	// Without the debug capability the neighbors can't be read.
+	caps := wt.staticRegistryCapabilities()
+	caps.DebugNeighbors = false
+	wt.staticRegistryCapabilitiesCache.Set(caps)
+	_, err = wt.ReadRegistryNeighbors(context.Background(), spk, crypto.Hash{4})
+	if !errors.Contains(err, errRegistryUnsupported) {
+		t.Fatal("expected errRegistryUnsupported", err)
+	}
+}
@@ -0,0 +1,70 @@
+package renter
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// errRegistryPingInterrupted is returned by RegistryPing if the context
+	// is closed before the host responded.
+	errRegistryPingInterrupted = errors.New("RegistryPing interrupted")
+)
+
+// RegistryPing measures the round trip time of a minimal registry RPC to the
+// worker's host. It looks up a random entry which the host is not expected to
+// store. The lookup bypasses the job queues to avoid skewing their
+// performance stats with probes.
+func (w *worker) RegistryPing(ctx context.Context) (time.Duration, error) {
+	// Check if the host supports registry reads.
+	if !w.staticRegistryCapabilities().Read {
+		return 0, errRegistryUnsupported
+	}
+	select {
+	case <-ctx.Done():
+		return 0, errRegistryPingInterrupted
+	default:
+	}
+
+	// Create a random entry to look up.
+	_, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+
+	// Abort the lookup once the context's deadline is reached.
+	deadline, _ := ctx.Deadline()
+
+	// Perform the lookup in a separate goroutine to be able to return right
+	// away if the context is closed.
+	type pingResult struct {
+		rtt time.Duration
+		err error
+	}
+	resultChan := make(chan pingResult, 1)
+	err := w.renter.tg.Launch(func() {
+		start := time.Now()
+		_, err := lookupRegistryWithDeadline(w, spk, tweak, deadline)
+		resultChan <- pingResult{
+			rtt: time.Since(start),
+			err: err,
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case <-ctx.Done():
+		return 0, errRegistryPingInterrupted
+	case res := <-resultChan:
+		if res.err != nil {
+			return 0, errors.AddContext(res.err, "RegistryPing failed")
+		}
+		return res.rtt, nil
+	}
+}
@@ -0,0 +1,87 @@
+package renter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+)
+
+// TestRegistryPing tests that RegistryPing measures the round trip time to a
+// live host and fails for a closed one.
+func TestRegistryPing(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hostClosed bool
+	defer func() {
+		if hostClosed {
+			if err := wt.rt.Close(); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Allow the worker some time to fetch a PT and fund its EA.
+	w := wt.worker
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if w.staticAccount.managedMinExpectedBalance().IsZero() {
+			return errors.New("account not funded yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ping the live host.
+	jq := w.staticJobReadRegistryQueue
+	jq.mu.Lock()
+	weightedJobTime := jq.weightedJobTime
+	jq.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	rtt, err := w.RegistryPing(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtt <= 0 {
+		t.Fatal("expected positive rtt but got", rtt)
+	}
+
+	// The ping shouldn't affect the stats of the registry queue.
+	jq.mu.Lock()
+	weightedJobTimeAfter := jq.weightedJobTime
+	jq.mu.Unlock()
+	if weightedJobTimeAfter != weightedJobTime {
+		t.Fatal("ping was added to the queue's stats", weightedJobTime, weightedJobTimeAfter)
+	}
+
+	// A closed context should interrupt the ping.
+	closedCtx, closedCancel := context.WithCancel(context.Background())
+	closedCancel()
+	if _, err := w.RegistryPing(closedCtx); !errors.Contains(err, errRegistryPingInterrupted) {
+		t.Fatal("expected errRegistryPingInterrupted but got", err)
+	}
+
+	// Close the host. The ping should fail.
+	if err := wt.host.Close(); err != nil {
+		t.Fatal(err)
+	}
+	hostClosed = true
+	if _, err := w.RegistryPing(ctx); err == nil {
+		t.Fatal("ping to closed host succeeded")
+	}
+}
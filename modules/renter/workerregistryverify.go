@@ -0,0 +1,133 @@
+package renter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// registryVerifyMaxConcurrency is the max number of lookups
+	// VerifyRegistryEntries performs in parallel.
+	registryVerifyMaxConcurrency = 4
+)
+
+const (
+	// registryVerifyPresentEqual indicates that the host stores the entry
+	// with the same revision as the written one.
+	registryVerifyPresentEqual registryVerifyStatus = iota
+
+	// registryVerifyPresentNewer indicates that the host stores the entry
+	// with a higher revision than the written one.
+	registryVerifyPresentNewer
+
+	// registryVerifyPresentOlder indicates that the host stores the entry
+	// with a lower revision than the written one.
+	registryVerifyPresentOlder
+
+	// registryVerifyMissing indicates that the host doesn't store the entry.
+	registryVerifyMissing
+)
+
+var (
+	// errRegistryVerifyInterrupted is returned for the entries which weren't
+	// looked up before the context of VerifyRegistryEntries was closed.
+	errRegistryVerifyInterrupted = errors.New("VerifyRegistryEntries interrupted")
+)
+
+type (
+	// registryVerifyStatus describes how the entry stored by a host relates
+	// to the one that was written to it.
+	registryVerifyStatus int
+
+	// registryVerifyEntry is an entry which was written to a host together
+	// with the key it was written for.
+	registryVerifyEntry struct {
+		SPK   types.SiaPublicKey
+		Value modules.SignedRegistryValue
+	}
+
+	// registryVerifyResult is the result of verifying a single entry. Status
+	// and Stored are only set if Err is nil. Stored is the entry returned by
+	// the host and nil if the entry is missing.
+	registryVerifyResult struct {
+		Status registryVerifyStatus
+		Stored *modules.SignedRegistryValue
+		Err    error
+	}
+)
+
+// VerifyRegistryEntries checks whether the worker's host still stores the
+// provided entries which were previously written to it. The stored revision
+// of every entry is compared to the written one. The results are returned in
+// the same order as the entries. Like RegistryPing, the lookups bypass the job
+// queues.
+func (w *worker) VerifyRegistryEntries(ctx context.Context, entries []registryVerifyEntry) ([]registryVerifyResult, error) {
+	// Check if the host supports registry reads.
+	if !w.staticRegistryCapabilities().Read {
+		return nil, errRegistryUnsupported
+	}
+
+	// Abort the lookups once the context's deadline is reached.
+	deadline, _ := ctx.Deadline()
+
+	results := make([]registryVerifyResult, len(entries))
+	sem := make(chan struct{}, registryVerifyMaxConcurrency)
+	var wg sync.WaitGroup
+	for i := range entries {
+		// Check the context first since select doesn't prefer it over a
+		// free slot.
+		if ctx.Err() != nil {
+			results[i].Err = errRegistryVerifyInterrupted
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			results[i].Err = errRegistryVerifyInterrupted
+			continue
+		case sem <- struct{}{}:
+		}
+		entry, result := entries[i], &results[i]
+		wg.Add(1)
+		err := w.renter.tg.Launch(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			*result = verifyRegistryEntry(w, entry, deadline)
+		})
+		if err != nil {
+			result.Err = err
+			<-sem
+			wg.Done()
+		}
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// verifyRegistryEntry looks up a single entry on the worker's host and compares
+// it to the written one.
+func verifyRegistryEntry(w *worker, entry registryVerifyEntry, deadline time.Time) registryVerifyResult {
+	srv, err := lookupRegistryWithDeadline(w, entry.SPK, entry.Value.Tweak, deadline)
+	if err != nil {
+		return registryVerifyResult{Err: errors.AddContext(err, "failed to look up entry")}
+	}
+	if srv == nil {
+		return registryVerifyResult{Status: registryVerifyMissing}
+	}
+	result := registryVerifyResult{Stored: srv}
+	switch {
+	case srv.Revision > entry.Value.Revision:
+		result.Status = registryVerifyPresentNewer
+	case srv.Revision < entry.Value.Revision:
+		result.Status = registryVerifyPresentOlder
+	default:
+		result.Status = registryVerifyPresentEqual
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package renter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestVerifyRegistryEntries tests verifying a set of entries where one is
+// unchanged, one was overwritten with a higher revision, one is missing and
+// one was written with a lower revision than the expected one.
+func TestVerifyRegistryEntries(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	newEntry := func(rev uint64) registryVerifyEntry {
+		var tweak crypto.Hash
+		fastrand.Read(tweak[:])
+		data := fastrand.Bytes(modules.RegistryDataSize)
+		return registryVerifyEntry{
+			SPK:   spk,
+			Value: modules.NewRegistryValue(tweak, data, rev, modules.RegistryTypeWithoutPubkey).Sign(sk),
+		}
+	}
+	update := func(entry registryVerifyEntry) {
+		t.Helper()
+		if err := wt.UpdateRegistry(context.Background(), spk, entry.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Write the entries except for the missing one.
+	unchanged := newEntry(1)
+	overwritten := newEntry(5)
+	missing := newEntry(1)
+	older := newEntry(3)
+	update(unchanged)
+	update(overwritten)
+	update(older)
+
+	// Overwrite one entry with a higher revision and expect a higher
+	// revision for another one.
+	newer := overwritten
+	newer.Value = modules.NewRegistryValue(overwritten.Value.Tweak, overwritten.Value.Data, 6, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	update(newer)
+	older.Value.Revision++
+
+	results, err := wt.VerifyRegistryEntries(context.Background(), []registryVerifyEntry{unchanged, overwritten, missing, older})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []registryVerifyStatus{registryVerifyPresentEqual, registryVerifyPresentNewer, registryVerifyMissing, registryVerifyPresentOlder}
+	if len(results) != len(expected) {
+		t.Fatal("wrong number of results", len(results))
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatal(i, res.Err)
+		}
+		if res.Status != expected[i] {
+			t.Fatalf("%v: expected status %v but got %v", i, expected[i], res.Status)
+		}
+	}
+	if !reflect.DeepEqual(*results[0].Stored, unchanged.Value) {
+		t.Fatal("wrong stored value for unchanged entry")
+	}
+	if !reflect.DeepEqual(*results[1].Stored, newer.Value) {
+		t.Fatal("wrong stored value for overwritten entry")
+	}
+	if results[2].Stored != nil {
+		t.Fatal("missing entry shouldn't have a stored value")
+	}
+
+	// A closed context interrupts the verification.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = wt.VerifyRegistryEntries(ctx, []registryVerifyEntry{unchanged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != errRegistryVerifyInterrupted {
+		t.Fatal("expected errRegistryVerifyInterrupted", results[0].Err)
+	}
+}
@@ -48,6 +48,13 @@ type programResponse struct {
 
 // managedExecuteProgram performs the ExecuteProgramRPC on the host
 func (w *worker) managedExecuteProgram(p modules.Program, data []byte, fcid types.FileContractID, category spendingCategory, cost types.Currency) (responses []programResponse, limit mux.BandwidthLimit, err error) {
+	return w.managedExecuteProgramWithDeadline(p, data, fcid, category, cost, time.Time{})
+}
+
+// managedExecuteProgramWithDeadline performs the ExecuteProgramRPC on the host.
+// If the deadline is not zero, it replaces the default deadline of the stream
+// used for the RPC.
+func (w *worker) managedExecuteProgramWithDeadline(p modules.Program, data []byte, fcid types.FileContractID, category spendingCategory, cost types.Currency, deadline time.Time) (responses []programResponse, limit mux.BandwidthLimit, err error) {
 	// Defer a function that schedules a price table update in case we received
 	// an error that indicates the host deems our price table invalid.
 	defer func() {
@@ -76,6 +83,15 @@ func (w *worker) managedExecuteProgram(p modules.Program, data []byte, fcid type
 		}
 	}()
 
+	// override the default deadline if necessary
+	if !deadline.IsZero() {
+		err = stream.SetDeadline(deadline)
+		if err != nil {
+			err = errors.AddContext(err, "Unable to set deadline on stream")
+			return
+		}
+	}
+
 	// set the limit return var.
 	limit = stream.Limit()
 
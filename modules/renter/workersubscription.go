@@ -82,6 +82,10 @@ type (
 		// work is available.
 		staticWakeChan chan struct{}
 
+		// sessionActive indicates whether the worker currently has an open
+		// subscription session with the host.
+		sessionActive bool
+
 		// stats
 		atomicExtensions uint64
 
@@ -103,6 +107,11 @@ type (
 		// interrupted.
 		subscribe bool
 
+		// subscribers is the number of callers which subscribed to the entry
+		// and didn't unsubscribe yet. They all share the same subscription
+		// which is only removed once the last one unsubscribed.
+		subscribers uint64
+
 		// subscribed is closed as soon as the corresponding entry is subscribed
 		// to and indicates that the worker is actively listening for updates.
 		// It's also closed when a subscription is deleted from the map due to
@@ -117,6 +126,19 @@ type (
 		latestRV *modules.SignedRegistryValue
 	}
 
+	// RegistrySubscriptionStats contains stats about the registry
+	// subscriptions of the renter's workers.
+	RegistrySubscriptionStats struct {
+		// ActiveSessions is the number of workers with an open subscription
+		// session with their host.
+		ActiveSessions uint64
+
+		// Subscriptions is the number of entries the workers are subscribed
+		// to and Subscribers is the number of callers sharing them.
+		Subscriptions uint64
+		Subscribers   uint64
+	}
+
 	// notificationHandler is a helper type that contains some information
 	// relevant to notification pricing and updating price tables.
 	notificationHandler struct {
@@ -310,6 +332,22 @@ func (subInfo *subscriptionInfos) managedClearSubscriptions() {
 	}
 }
 
+// managedNumSubscriptions returns the number of subscriptions the worker is
+// either supposed to be subscribed to or still subscribed to.
+func (subInfo *subscriptionInfos) managedNumSubscriptions() int {
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	return len(subInfo.subscriptions)
+}
+
+// managedSetSessionActive updates whether the worker has an open subscription
+// session.
+func (subInfo *subscriptionInfos) managedSetSessionActive(active bool) {
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	subInfo.sessionActive = active
+}
+
 // managedIncrementCooldown increments the subscription cooldown.
 func (subInfo *subscriptionInfos) managedIncrementCooldown() {
 	subInfo.mu.Lock()
@@ -556,8 +594,10 @@ func (w *worker) managedSubscriptionLoop(stream siamux.Stream, pt *modules.RPCPr
 	}
 
 	// Register some cleanup.
+	w.staticSubscriptionInfo.managedSetSessionActive(true)
 	defer func() {
 		err = errors.Compose(err, w.managedSubscriptionCleanup(stream, subscriber))
+		w.staticSubscriptionInfo.managedSetSessionActive(false)
 	}()
 
 	// Set the stream deadline to the subscription deadline.
@@ -566,6 +606,7 @@ func (w *worker) managedSubscriptionLoop(stream siamux.Stream, pt *modules.RPCPr
 		return errors.AddContext(err, "failed to set stream deadlien to subscription deadline")
 	}
 
+	hadSubscriptions := false
 	for {
 		// If the budget is half empty, fund it.
 		if budget.Remaining().Cmp(expectedBudget.Div64(2)) < 0 {
@@ -588,6 +629,13 @@ func (w *worker) managedSubscriptionLoop(stream siamux.Stream, pt *modules.RPCPr
 		subInfo := w.staticSubscriptionInfo
 		toSubscribe, toUnsubscribe, subChans := subInfo.managedSubscriptionDiff()
 
+		// Close the session once the last subscriber is gone.
+		numSubs := subInfo.managedNumSubscriptions()
+		if hadSubscriptions && numSubs == 0 {
+			return nil
+		}
+		hadSubscriptions = hadSubscriptions || numSubs > 0
+
 		// Unsubscribe from unnecessary subscriptions.
 		if len(toUnsubscribe) > 0 {
 			err = w.managedUnsubscribeFromRVs(stream, toUnsubscribe)
@@ -699,7 +747,7 @@ func (w *worker) threadedSubscriptionLoop() {
 	defer w.staticTG.Done()
 
 	// No need to run loop if the host doesn't support it.
-	if build.VersionCmp(w.staticCache().staticHostVersion, minSubscriptionVersion) < 0 {
+	if !w.staticRegistryCapabilities().Subscription {
 		return
 	}
 
@@ -798,8 +846,10 @@ func (w *worker) threadedSubscriptionLoop() {
 	}
 }
 
-// Unsubscribe marks the provided entries as not subscribed to and notifies the
-// worker of the change.
+// Unsubscribe removes the caller from the subscribers of the provided entries.
+// Entries without subscribers are marked as not subscribed to and the worker is
+// notified of the change. Once no entries are left, the worker closes the
+// subscription session with the host.
 func (w *worker) Unsubscribe(requests ...modules.RPCRegistrySubscriptionRequest) {
 	subInfo := w.staticSubscriptionInfo
 
@@ -808,11 +858,15 @@ func (w *worker) Unsubscribe(requests ...modules.RPCRegistrySubscriptionRequest)
 	for _, req := range requests {
 		sid := modules.DeriveRegistryEntryID(req.PubKey, req.Tweak)
 		sub, exists := subInfo.subscriptions[sid]
-		if !exists || !sub.subscribe {
+		if !exists || !sub.subscribe || sub.subscribers == 0 {
 			continue // nothing to do
 		}
-		// Mark the sub as no longer subscribed.
-		sub.subscribe = false
+		// Mark the sub as no longer subscribed if this was the last
+		// subscriber.
+		sub.subscribers--
+		if sub.subscribers == 0 {
+			sub.subscribe = false
+		}
 	}
 
 	// Notify the subscription loop of the changes.
@@ -824,8 +878,10 @@ func (w *worker) Unsubscribe(requests ...modules.RPCRegistrySubscriptionRequest)
 
 // Subscribe marks the provided entries as subscribed and waits for the
 // subscription to be done, returning potential initial values returend by the
-// host.
-func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistrySubscriptionRequest) ([]modules.RPCRegistrySubscriptionNotificationEntryUpdate, error) {
+// host. Every successful call needs to be followed by a call to Unsubscribe
+// with the same entries once the caller is no longer interested in them.
+// Callers subscribing to the same entry share a single subscription.
+func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistrySubscriptionRequest) (_ []modules.RPCRegistrySubscriptionNotificationEntryUpdate, err error) {
 	subInfo := w.staticSubscriptionInfo
 
 	// Add one subscription for every request that we are not yet subscribed to.
@@ -839,11 +895,20 @@ func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistryS
 			sub = newSubscription(&requests[i])
 			subInfo.subscriptions[sid] = sub
 		}
+		sub.subscribe = true
+		sub.subscribers++
 		subs = append(subs, sub)
 		subChans = append(subChans, sub.subscribed)
 	}
 	subInfo.mu.Unlock()
 
+	// Release the subscriptions again if they couldn't be established.
+	defer func() {
+		if err != nil {
+			w.Unsubscribe(requests...)
+		}
+	}()
+
 	// Notify the subscription loop of the changes.
 	select {
 	case subInfo.staticWakeChan <- struct{}{}:
@@ -878,3 +943,40 @@ func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistryS
 	}
 	return notifications, nil
 }
+
+// callSubscriptionStats returns the worker's contribution to the renter's
+// RegistrySubscriptionStats.
+func (w *worker) callSubscriptionStats() RegistrySubscriptionStats {
+	subInfo := w.staticSubscriptionInfo
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	var stats RegistrySubscriptionStats
+	if subInfo.sessionActive {
+		stats.ActiveSessions = 1
+	}
+	for _, sub := range subInfo.subscriptions {
+		if sub.subscribers == 0 {
+			continue
+		}
+		stats.Subscriptions++
+		stats.Subscribers += sub.subscribers
+	}
+	return stats
+}
+
+// RegistrySubscriptionStats returns stats about the registry subscriptions of
+// the renter's workers.
+func (r *Renter) RegistrySubscriptionStats() (RegistrySubscriptionStats, error) {
+	if err := r.tg.Add(); err != nil {
+		return RegistrySubscriptionStats{}, err
+	}
+	defer r.tg.Done()
+	var stats RegistrySubscriptionStats
+	for _, w := range r.staticWorkerPool.callWorkers() {
+		ws := w.callSubscriptionStats()
+		stats.ActiveSessions += ws.ActiveSessions
+		stats.Subscriptions += ws.Subscriptions
+		stats.Subscribers += ws.Subscribers
+	}
+	return stats, nil
+}
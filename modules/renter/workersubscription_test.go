@@ -1182,6 +1182,7 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	numSubscribes := 1
 	if len(resps) != 1 {
 		t.Fatal("invalid length", len(resps))
 	}
@@ -1206,6 +1207,7 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	numSubscribes++
 	if len(resps) != 1 {
 		t.Fatal("invalid length", len(resps))
 	}
@@ -1230,6 +1232,7 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 		if err != nil {
 			return err
 		}
+		numSubscribes++
 		if len(resps) != 1 {
 			return fmt.Errorf("invalid length %v", len(resps))
 		}
@@ -1245,8 +1248,16 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Unsubscribe from the entry.
-	wt.Unsubscribe(req)
+	// Get the account balance before the last subscriber unsubscribes which
+	// closes the session.
+	wt.staticAccount.mu.Lock()
+	balance := wt.staticAccount.availableBalance()
+	wt.staticAccount.mu.Unlock()
+
+	// Unsubscribe from the entry once for every subscription.
+	for i := 0; i < numSubscribes; i++ {
+		wt.Unsubscribe(req)
+	}
 
 	// There should be 0 subscriptions.
 	err = build.Retry(100, 100*time.Millisecond, func() error {
@@ -1261,11 +1272,6 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Get the account balance before interrupting the loop.
-	wt.staticAccount.mu.Lock()
-	balance := wt.staticAccount.availableBalance()
-	wt.staticAccount.mu.Unlock()
-
 	// Stop the loop by shutting down the worker.
 	err = wt.staticTG.Stop()
 	if err != nil {
@@ -1417,3 +1423,86 @@ func TestSubscribeUnsubscribeByRID(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestSubscriptionSharedSession tests that two subscribers of the same entry
+// share a single subscription and that the session with the host is only
+// closed once both of them unsubscribed.
+func TestSubscriptionSharedSession(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Set a random entry on the host.
+	rv, spk, _ := randomRegistryValue()
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := modules.RPCRegistrySubscriptionRequest{
+		PubKey: spk,
+		Tweak:  rv.Tweak,
+	}
+
+	// checkStats is a helper to check the worker's subscription stats.
+	checkStats := func(expected RegistrySubscriptionStats) error {
+		return build.Retry(100, 100*time.Millisecond, func() error {
+			stats := wt.callSubscriptionStats()
+			if stats != expected {
+				return fmt.Errorf("expected %v but got %v", expected, stats)
+			}
+			return nil
+		})
+	}
+
+	// Subscribe twice. The subscribers should share a single subscription.
+	for i := 0; i < 2; i++ {
+		if _, err := wt.Subscribe(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = checkStats(RegistrySubscriptionStats{
+		ActiveSessions: 1,
+		Subscriptions:  1,
+		Subscribers:    2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsubscribe once. The session should stay open.
+	wt.Unsubscribe(req)
+	err = checkStats(RegistrySubscriptionStats{
+		ActiveSessions: 1,
+		Subscriptions:  1,
+		Subscribers:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subInfo := wt.staticSubscriptionInfo
+	subInfo.mu.Lock()
+	sub, exists := subInfo.subscriptions[modules.DeriveRegistryEntryID(spk, rv.Tweak)]
+	subscribed := exists && sub.subscribe
+	subInfo.mu.Unlock()
+	if !subscribed {
+		t.Fatal("subscription should still be active")
+	}
+
+	// Unsubscribe again. The session should be closed.
+	wt.Unsubscribe(req)
+	err = checkStats(RegistrySubscriptionStats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -23,6 +23,15 @@ const (
 	// RenewDecodeMaxLen is the maximum length for decoding received objects
 	// read during a contract renewal.
 	RenewDecodeMaxLen = 1 << 18 // 256 kib
+
+	// MaxRegistryEnumerateEntries is the maximum page size of the
+	// RegistryEnumerate RPC. It is small enough for the response to fit
+	// within RPCMinLen.
+	MaxRegistryEnumerateEntries = 64
+
+	// RegistryEnumerateEntrySize is the size of a single entry returned by
+	// the RegistryEnumerate RPC.
+	RegistryEnumerateEntrySize = crypto.HashSize + 8
 )
 
 // Subcription request related enum.
@@ -174,6 +183,10 @@ type RPCPriceTable struct {
 	// Registry related fields.
 	RegistryEntriesLeft  uint64 `json:"registryentriesleft"`
 	RegistryEntriesTotal uint64 `json:"registryentriestotal"`
+
+	// MaxRegistryDataSize is the max size of the data of a registry entry
+	// the host accepts. Hosts which don't set it support RegistryDataSize.
+	MaxRegistryDataSize uint64 `json:"maxregistrydatasize,omitempty"`
 }
 
 var (
@@ -192,6 +205,9 @@ var (
 	// RPCLatestRevision specifier
 	RPCLatestRevision = types.NewSpecifier("LatestRevision")
 
+	// RPCRegistryEnumerate specifier
+	RPCRegistryEnumerate = types.NewSpecifier("RegistryEnum")
+
 	// RPCRegistrySubscription specifier
 	RPCRegistrySubscription = types.NewSpecifier("Subscription")
 
@@ -278,6 +294,32 @@ type (
 		Revision types.FileContractRevision
 	}
 
+	// RPCRegistryEnumerateRequest contains the public key for which to
+	// enumerate the registry entries. Only entries with a tweak larger than or
+	// equal to Start are returned. Limit is the page size which can't exceed
+	// MaxRegistryEnumerateEntries.
+	RPCRegistryEnumerateRequest struct {
+		PubKey types.SiaPublicKey
+		Start  crypto.Hash
+		Limit  uint64
+	}
+
+	// RPCRegistryEnumerateResponse contains a page of the entries a host
+	// stores for a public key sorted by tweak. More indicates that the host
+	// stores more entries starting at Next.
+	RPCRegistryEnumerateResponse struct {
+		Entries []RegistryEnumerateEntry
+		More    bool
+		Next    crypto.Hash
+	}
+
+	// RegistryEnumerateEntry describes a single entry returned by the
+	// RegistryEnumerate RPC.
+	RegistryEnumerateEntry struct {
+		Tweak    crypto.Hash
+		Revision uint64
+	}
+
 	// RPCRegistrySubscriptionRequest is a request to either add or remove a
 	// subscription.
 	RPCRegistrySubscriptionRequest struct {
@@ -425,6 +467,15 @@ func RPCRead(r io.Reader, obj interface{}) error {
 	return RPCReadMaxLen(r, obj, uint64(RPCMinLen))
 }
 
+// RegistryEnumerateCost is the cost of executing the RegistryEnumerate RPC
+// for the given number of entries. The lookup is charged like reading a single
+// registry entry and every returned entry like reading its data.
+func RegistryEnumerateCost(pt *RPCPriceTable, numEntries uint64) types.Currency {
+	lookupCost, _ := MDMReadRegistryCost(pt)
+	entryCost := MDMReadCost(pt, RegistryEnumerateEntrySize)
+	return lookupCost.Add(entryCost.Mul64(numEntries))
+}
+
 // RPCWrite writes the given object to the stream.
 func RPCWrite(w io.Writer, obj interface{}) error {
 	return encoding.WriteObject(w, &rpcResponse{nil, obj})
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 
 	mnemonics "gitlab.com/NebulousLabs/entropy-mnemonics"
@@ -27,6 +28,23 @@ const (
 	WalletDir = "wallet"
 )
 
+const (
+	// TxnCategoryMinerPayout is the category of transactions which contain a
+	// miner payout to the wallet.
+	TxnCategoryMinerPayout TransactionCategory = "minerpayout"
+
+	// TxnCategoryContractFormation is the category of transactions which
+	// form file contracts.
+	TxnCategoryContractFormation TransactionCategory = "contractformation"
+
+	// TxnCategoryContractRevision is the category of transactions which
+	// revise file contracts.
+	TxnCategoryContractRevision TransactionCategory = "contractrevision"
+
+	// TxnCategoryTransfer is the category of all other transactions.
+	TxnCategoryTransfer TransactionCategory = "transfer"
+)
+
 var (
 	// ErrBadEncryptionKey is returned if the incorrect encryption key to a
 	// file is provided.
@@ -108,13 +126,23 @@ type (
 		Outputs []ProcessedOutput `json:"outputs"`
 	}
 
+	// TransactionCategory is a human-friendly classification of a
+	// transaction.
+	TransactionCategory string
+
 	// ValuedTransaction is a transaction that has been given incoming and
 	// outgoing siacoin value fields.
 	ValuedTransaction struct {
 		ProcessedTransaction
 
-		ConfirmedIncomingValue types.Currency `json:"confirmedincomingvalue"`
-		ConfirmedOutgoingValue types.Currency `json:"confirmedoutgoingvalue"`
+		Category               TransactionCategory `json:"category"`
+		ConfirmedIncomingValue types.Currency      `json:"confirmedincomingvalue"`
+		ConfirmedOutgoingValue types.Currency      `json:"confirmedoutgoingvalue"`
+
+		// ConfirmationDepth is the number of blocks that were added on top
+		// of the block which confirmed the transaction. It is zero for
+		// unconfirmed transactions.
+		ConfirmationDepth types.BlockHeight `json:"confirmationdepth"`
 	}
 
 	// A UnspentOutput is a SiacoinOutput or SiafundOutput that the wallet
@@ -534,6 +562,16 @@ type (
 	// WalletSettings control the behavior of the Wallet.
 	WalletSettings struct {
 		NoDefrag bool `json:"nodefrag"`
+
+		// MaxUnconfirmedTransactions is the maximum number of unconfirmed
+		// transactions the wallet tracks. If it is exceeded, the oldest
+		// unconfirmed transactions which are older than MaxUnconfirmedAge
+		// are evicted. A value of 0 means there is no limit. An evicted
+		// transaction is only tracked again once it is confirmed or its
+		// transaction set changes within the transaction pool. Both limits
+		// are persisted.
+		MaxUnconfirmedTransactions uint64        `json:"maxunconfirmedtransactions"`
+		MaxUnconfirmedAge          time.Duration `json:"maxunconfirmedage"`
 	}
 )
 
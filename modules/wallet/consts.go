@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/types"
 )
 
 const (
@@ -35,6 +36,26 @@ var (
 		Testnet:  uint64(1000),
 		Testing:  uint64(10),
 	}).(uint64)
+
+	// confirmationBlockCapacity is the number of transaction bytes
+	// EstimateConfirmation expects to fit into a block. It is small during
+	// testing to make the estimates observable with a few transactions.
+	confirmationBlockCapacity = build.Select(build.Var{
+		Dev:      uint64(types.BlockSizeLimit),
+		Standard: uint64(types.BlockSizeLimit),
+		Testnet:  uint64(types.BlockSizeLimit),
+		Testing:  uint64(250),
+	}).(uint64)
+
+	// transactionGraphPageSize is the number of blocks whose transactions
+	// ExportTransactionGraph reads at once before releasing the wallet's
+	// lock and writing them out.
+	transactionGraphPageSize = build.Select(build.Var{
+		Dev:      types.BlockHeight(1000),
+		Standard: types.BlockHeight(1000),
+		Testnet:  types.BlockHeight(1000),
+		Testing:  types.BlockHeight(2),
+	}).(types.BlockHeight)
 )
 
 func init() {
@@ -43,6 +43,9 @@ var (
 	// is used to track UnlockConditions manually stored by the user,
 	// typically with an offline wallet.
 	bucketUnlockConditions = []byte("bucketUnlockConditions")
+	// bucketTransactionReferences maps an external reference to the ids of
+	// the transactions tagged with it.
+	bucketTransactionReferences = []byte("bucketTransactionReferences")
 	// bucketWallet contains various fields needed by the wallet, such as its
 	// UID, EncryptionVerification, and PrimarySeedFile.
 	bucketWallet = []byte("bucketWallet")
@@ -55,6 +58,7 @@ var (
 		bucketSiafundOutputs,
 		bucketSpentOutputs,
 		bucketUnlockConditions,
+		bucketTransactionReferences,
 		bucketWallet,
 	}
 
@@ -67,9 +71,12 @@ var (
 	keyEncryptionVerification = []byte("keyEncryptionVerification")
 	keyPrimarySeedFile        = []byte("keyPrimarySeedFile")
 	keyPrimarySeedProgress    = []byte("keyPrimarySeedProgress")
+	keyPruneCheckpoint        = []byte("keyPruneCheckpoint")
 	keySiafundPool            = []byte("keySiafundPool")
 	keySpendableKeyFiles      = []byte("keySpendableKeyFiles")
 	keySalt                   = []byte("keyUID")
+	keyTransactionRetention   = []byte("keyTransactionRetention")
+	keyUnconfirmedLimits      = []byte("keyUnconfirmedLimits")
 	keyWalletPassword         = []byte("keyWalletPassword")
 	keyWatchedAddrs           = []byte("keyWatchedAddrs")
 )
@@ -240,6 +247,21 @@ func dbGetAddrTransactions(tx *bolt.Tx, addr types.UnlockHash) (txns []uint64, e
 	return
 }
 
+func dbDeleteAddrTransactions(tx *bolt.Tx, addr types.UnlockHash) error {
+	return dbDelete(tx.Bucket(bucketAddrTransactions), addr)
+}
+
+func dbPutTransactionReferences(tx *bolt.Tx, ref string, txids []types.TransactionID) error {
+	return dbPut(tx.Bucket(bucketTransactionReferences), ref, txids)
+}
+func dbGetTransactionReferences(tx *bolt.Tx, ref string) (txids []types.TransactionID, err error) {
+	err = dbGet(tx.Bucket(bucketTransactionReferences), ref, &txids)
+	return
+}
+func dbDeleteTransactionReferences(tx *bolt.Tx, ref string) error {
+	return dbDelete(tx.Bucket(bucketTransactionReferences), ref)
+}
+
 func dbPutUnlockConditions(tx *bolt.Tx, uc types.UnlockConditions) error {
 	return dbPut(tx.Bucket(bucketUnlockConditions), uc.UnlockHash(), uc)
 }
@@ -268,6 +290,18 @@ func dbAddAddrTransaction(tx *bolt.Tx, addr types.UnlockHash, txn uint64) error
 // every address in pt with txn, which is assumed to be pt's index in
 // bucketProcessedTransactions.
 func dbAddProcessedTransactionAddrs(tx *bolt.Tx, pt modules.ProcessedTransaction, txn uint64) error {
+	for addr := range processedTransactionAddrs(pt) {
+		if err := dbAddAddrTransaction(tx, addr, txn); err != nil {
+			return errors.AddContext(err, fmt.Sprintf("failed to add txn %v to address %v",
+				pt.TransactionID, addr))
+		}
+	}
+	return nil
+}
+
+// processedTransactionAddrs returns the addresses which are associated with pt
+// in bucketAddrTransactions.
+func processedTransactionAddrs(pt modules.ProcessedTransaction) map[types.UnlockHash]struct{} {
 	addrs := make(map[types.UnlockHash]struct{})
 	for _, input := range pt.Inputs {
 		addrs[input.RelatedAddress] = struct{}{}
@@ -279,13 +313,7 @@ func dbAddProcessedTransactionAddrs(tx *bolt.Tx, pt modules.ProcessedTransaction
 		}
 		addrs[output.RelatedAddress] = struct{}{}
 	}
-	for addr := range addrs {
-		if err := dbAddAddrTransaction(tx, addr, txn); err != nil {
-			return errors.AddContext(err, fmt.Sprintf("failed to add txn %v to address %v",
-				pt.TransactionID, addr))
-		}
-	}
-	return nil
+	return addrs
 }
 
 // bucketProcessedTransactions works a little differently: the key is
@@ -356,13 +384,12 @@ func dbAppendProcessedTransaction(tx *bolt.Tx, pt modules.ProcessedTransaction)
 	return nil
 }
 
+// dbGetLastProcessedTransaction returns the processed transaction at the
+// sequence of bucketProcessedTransactions. errNoKey is returned if the bucket
+// is empty or the transaction was pruned.
 func dbGetLastProcessedTransaction(tx *bolt.Tx) (pt modules.ProcessedTransaction, err error) {
 	seq := tx.Bucket(bucketProcessedTransactions).Sequence()
-	keyBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(keyBytes, seq)
-	val := tx.Bucket(bucketProcessedTransactions).Get(keyBytes)
-	err = decodeProcessedTransaction(val, &pt)
-	return
+	return dbGetProcessedTransaction(tx, seq)
 }
 
 func dbDeleteLastProcessedTransaction(tx *bolt.Tx) error {
@@ -383,11 +410,52 @@ func dbDeleteLastProcessedTransaction(tx *bolt.Tx) error {
 	return errors.Compose(b.SetSequence(seq-1), b.Delete(keyBytes))
 }
 
+// dbExpectedProcessedTransactionSequence returns the sequence
+// bucketProcessedTransactions should have, which is the largest key within the
+// bucket. If all transactions were pruned, the bucket is empty and the current
+// sequence, which belongs to the last pruned transaction, is expected.
+func dbExpectedProcessedTransactionSequence(tx *bolt.Tx) (uint64, error) {
+	b := tx.Bucket(bucketProcessedTransactions)
+	if lastKey, _ := b.Cursor().Last(); lastKey != nil {
+		return binary.BigEndian.Uint64(lastKey), nil
+	}
+	cp, err := dbGetPruneCheckpoint(tx)
+	if err != nil {
+		return 0, err
+	}
+	if cp.NumTransactions > 0 {
+		return b.Sequence(), nil
+	}
+	return 0, nil
+}
+
+// dbRepairProcessedTransactionSequence sets the sequence of
+// bucketProcessedTransactions to the expected sequence and returns the
+// sequence before and after the repair.
+func dbRepairProcessedTransactionSequence(tx *bolt.Tx) (oldSeq, newSeq uint64, err error) {
+	b := tx.Bucket(bucketProcessedTransactions)
+	oldSeq = b.Sequence()
+	newSeq, err = dbExpectedProcessedTransactionSequence(tx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if oldSeq == newSeq {
+		return oldSeq, newSeq, nil
+	}
+	return oldSeq, newSeq, b.SetSequence(newSeq)
+}
+
+// dbGetProcessedTransaction returns the processed transaction at index.
+// errNoKey is returned if there is no transaction at index, e.g. because it
+// was pruned.
 func dbGetProcessedTransaction(tx *bolt.Tx, index uint64) (pt modules.ProcessedTransaction, err error) {
 	// big-endian is used so that the keys are properly sorted
 	indexBytes := make([]byte, 8)
 	binary.BigEndian.PutUint64(indexBytes, index)
 	val := tx.Bucket(bucketProcessedTransactions).Get(indexBytes)
+	if val == nil {
+		return modules.ProcessedTransaction{}, errNoKey
+	}
 	err = decodeProcessedTransaction(val, &pt)
 	return
 }
@@ -474,6 +542,64 @@ func dbPutConsensusHeight(tx *bolt.Tx, height types.BlockHeight) error {
 	return tx.Bucket(bucketWallet).Put(keyConsensusHeight, encoding.Marshal(height))
 }
 
+// dbGetTransactionRetention returns the number of blocks of processed
+// transactions the wallet keeps. 0 means that all transactions are kept.
+func dbGetTransactionRetention(tx *bolt.Tx) (retention types.BlockHeight, err error) {
+	b := tx.Bucket(bucketWallet).Get(keyTransactionRetention)
+	if b == nil {
+		return 0, nil // keep all by default
+	}
+	err = encoding.Unmarshal(b, &retention)
+	return
+}
+
+// dbPutTransactionRetention stores the number of blocks of processed
+// transactions the wallet keeps.
+func dbPutTransactionRetention(tx *bolt.Tx, retention types.BlockHeight) error {
+	return tx.Bucket(bucketWallet).Put(keyTransactionRetention, encoding.Marshal(retention))
+}
+
+// unconfirmedLimits are the limits for the unconfirmed transactions tracked by
+// the wallet which are set through SetSettings.
+type unconfirmedLimits struct {
+	MaxTransactions uint64
+	MaxAge          time.Duration
+}
+
+// dbGetUnconfirmedLimits returns the limits for the unconfirmed transactions
+// tracked by the wallet. By default there are no limits.
+func dbGetUnconfirmedLimits(tx *bolt.Tx) (limits unconfirmedLimits, err error) {
+	b := tx.Bucket(bucketWallet).Get(keyUnconfirmedLimits)
+	if b == nil {
+		return unconfirmedLimits{}, nil // no limits by default
+	}
+	err = encoding.Unmarshal(b, &limits)
+	return
+}
+
+// dbPutUnconfirmedLimits stores the limits for the unconfirmed transactions
+// tracked by the wallet.
+func dbPutUnconfirmedLimits(tx *bolt.Tx, limits unconfirmedLimits) error {
+	return tx.Bucket(bucketWallet).Put(keyUnconfirmedLimits, encoding.Marshal(limits))
+}
+
+// dbGetPruneCheckpoint returns the checkpoint of the pruned processed
+// transactions.
+func dbGetPruneCheckpoint(tx *bolt.Tx) (cp PruneCheckpoint, err error) {
+	b := tx.Bucket(bucketWallet).Get(keyPruneCheckpoint)
+	if b == nil {
+		return PruneCheckpoint{}, nil // nothing was pruned yet
+	}
+	err = encoding.Unmarshal(b, &cp)
+	return
+}
+
+// dbPutPruneCheckpoint stores the checkpoint of the pruned processed
+// transactions.
+func dbPutPruneCheckpoint(tx *bolt.Tx, cp PruneCheckpoint) error {
+	return tx.Bucket(bucketWallet).Put(keyPruneCheckpoint, encoding.Marshal(cp))
+}
+
 // dbGetSiafundPool returns the value of the siafund pool.
 func dbGetSiafundPool(tx *bolt.Tx) (pool types.Currency, err error) {
 	err = encoding.Unmarshal(tx.Bucket(bucketWallet).Get(keySiafundPool), &pool)
@@ -12,6 +12,39 @@ var (
 	errDefragNotNeeded = errors.New("defragging not needed, wallet is already sufficiently defragged")
 )
 
+// DustOutputs reports the number and total value of the wallet's unspent
+// siacoin outputs with a value below the provided threshold. Outputs which were
+// recently spent by an unconfirmed transaction are ignored. This can be used
+// to decide whether the wallet should be consolidated.
+func (w *Wallet) DustOutputs(threshold types.Currency) (count uint64, value types.Currency, err error) {
+	if err := w.tg.Add(); err != nil {
+		return 0, types.ZeroCurrency, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return
+	}
+
+	consensusHeight, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return 0, types.ZeroCurrency, err
+	}
+	err = dbForEachSiacoinOutput(w.dbTx, func(scoid types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if sco.Value.Cmp(threshold) >= 0 {
+			return
+		}
+		spendHeight, err := dbGetSpentOutput(w.dbTx, types.OutputID(scoid))
+		if err == nil && spendHeight+RespendTimeout > consensusHeight {
+			return
+		}
+		count++
+		value = value.Add(sco.Value)
+	})
+	return
+}
+
 // managedCreateDefragTransaction creates a transaction that spends multiple existing
 // wallet outputs into a single new address.
 func (w *Wallet) managedCreateDefragTransaction() (_ []types.Transaction, err error) {
@@ -329,3 +329,93 @@ func TestDefragInterrupted(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestDustOutputs tests reporting the dust outputs of a wallet.
+func TestDustOutputs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	dustOutputValue := types.NewCurrency64(10000)
+	threshold := dustOutputValue.Add64(1)
+
+	// The wallet should only contain miner payouts which aren't dust.
+	count, value, err := wt.wallet.DustOutputs(threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || !value.IsZero() {
+		t.Fatal("wallet shouldn't contain dust", count, value)
+	}
+
+	// Send a mix of dust and normal outputs to the wallet.
+	numDust := 5
+	normalOutputValue := types.SiacoinPrecision
+	tbuilder, err := wt.wallet.StartTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tbuilder.FundSiacoins(dustOutputValue.Mul64(uint64(numDust)).Add(normalOutputValue.Mul64(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < numDust; i++ {
+		tbuilder.AddSiacoinOutput(types.SiacoinOutput{
+			Value:      dustOutputValue,
+			UnlockHash: uc.UnlockHash(),
+		})
+	}
+	for i := 0; i < 2; i++ {
+		tbuilder.AddSiacoinOutput(types.SiacoinOutput{
+			Value:      normalOutputValue,
+			UnlockHash: uc.UnlockHash(),
+		})
+	}
+	txns, err := tbuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.tpool.AcceptTransactionSet(txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the dust outputs should be reported.
+	count, value, err = wt.wallet.DustOutputs(threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != uint64(numDust) {
+		t.Fatalf("expected %v dust outputs but got %v", numDust, count)
+	}
+	if !value.Equals(dustOutputValue.Mul64(uint64(numDust))) {
+		t.Fatal("wrong dust value", value)
+	}
+
+	// With a threshold of 0 there is no dust.
+	count, value, err = wt.wallet.DustOutputs(types.ZeroCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || !value.IsZero() {
+		t.Fatal("wallet shouldn't contain dust", count, value)
+	}
+}
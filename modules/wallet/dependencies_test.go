@@ -17,6 +17,19 @@ type (
 		modules.ProductionDependencies
 		f bool // indicates if the next call should fail
 	}
+
+	// dependencyTransactionSearchPanic is a dependency used to cause the
+	// binary search for processed transactions to panic.
+	dependencyTransactionSearchPanic struct {
+		modules.ProductionDependencies
+		f bool // indicates if the next call should panic
+	}
+
+	// dependencyDisableUnsortedTransactionsCritical is a dependency used to
+	// return ErrUnsortedTransactions in debug builds without panicking.
+	dependencyDisableUnsortedTransactionsCritical struct {
+		modules.ProductionDependencies
+	}
 )
 
 // Disrupt will return true if fail was called and the correct string value is
@@ -50,3 +63,23 @@ func (d *dependencyDefragInterrupted) Disrupt(s string) bool {
 func (d *dependencyDefragInterrupted) fail() {
 	d.f = true
 }
+
+// Disrupt will return true if fail was called and the correct string value is
+// provided. It also resets f back to false.
+func (d *dependencyTransactionSearchPanic) Disrupt(s string) bool {
+	if d.f && s == "TransactionSearchPanic" {
+		d.f = false
+		return true
+	}
+	return false
+}
+
+// fail causes the next TransactionSearchPanic disrupt to return true
+func (d *dependencyTransactionSearchPanic) fail() {
+	d.f = true
+}
+
+// Disrupt returns true if the correct string is provided.
+func (d *dependencyDisableUnsortedTransactionsCritical) Disrupt(s string) bool {
+	return s == "DisableUnsortedTransactionsCritical"
+}
@@ -454,6 +454,7 @@ func (w *Wallet) Reset() error {
 	w.lookahead = make(map[types.UnlockHash]uint64)
 	w.seeds = []modules.Seed{}
 	w.unconfirmedProcessedTransactions = []modules.ProcessedTransaction{}
+	w.unconfirmedAdded = make(map[types.TransactionID]time.Time)
 	w.unlocked = false
 	w.encrypted = false
 
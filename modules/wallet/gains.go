@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"math/big"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// errInvalidGainsRange is returned by RealizedGains if the start height is
+	// greater than the end height.
+	errInvalidGainsRange = errors.New("start height must not be greater than end height")
+
+	// errNoPriceFunc is returned by RealizedGains if no price function is
+	// provided.
+	errNoPriceFunc = errors.New("price function is required")
+)
+
+type (
+	// PriceFunc returns the price of one siacoin at the provided time. The
+	// currency of the price is up to the caller.
+	PriceFunc func(types.Timestamp) (*big.Rat, error)
+
+	// RealizedGain describes the gain of a single siacoin output that was
+	// received and later spent by the wallet.
+	RealizedGain struct {
+		OutputID types.SiacoinOutputID
+		Value    types.Currency
+
+		// AcquisitionTxnID is the transaction that created the output and
+		// DisposalTxnID the transaction that spent it. The heights and times
+		// are the ones of the blocks confirming these transactions.
+		AcquisitionTxnID  types.TransactionID
+		AcquisitionHeight types.BlockHeight
+		AcquisitionTime   types.Timestamp
+		DisposalTxnID     types.TransactionID
+		DisposalHeight    types.BlockHeight
+		DisposalTime      types.Timestamp
+
+		// CostBasis and Proceeds are the value of the output at the time of
+		// its acquisition and disposal according to the PriceFunc. Gain is
+		// the difference of the two and might be negative.
+		CostBasis *big.Rat
+		Proceeds  *big.Rat
+		Gain      *big.Rat
+	}
+
+	// RealizedGainsReport contains the realized gains of all outputs spent
+	// within a range of blocks.
+	RealizedGainsReport struct {
+		Gains     []RealizedGain
+		TotalGain *big.Rat
+	}
+
+	// acquiredOutput is a wallet output which wasn't spent yet while
+	// computing the realized gains.
+	acquiredOutput struct {
+		value     types.Currency
+		txnID     types.TransactionID
+		height    types.BlockHeight
+		timestamp types.Timestamp
+	}
+)
+
+// RealizedGains returns the realized gains of all siacoin outputs spent by
+// confirmed transactions between the start and end height (inclusive). Every
+// spent output is traced back to the transaction which created it. Its cost
+// basis is its value at the time that transaction was confirmed and its
+// proceeds are its value at the time it was spent. The wallet doesn't know
+// exchange rates, so the values are computed using the provided PriceFunc.
+// Outputs whose creation isn't known to the wallet are skipped.
+func (w *Wallet) RealizedGains(start, end types.BlockHeight, price PriceFunc) (RealizedGainsReport, error) {
+	if start > end {
+		return RealizedGainsReport{}, errInvalidGainsRange
+	}
+	if price == nil {
+		return RealizedGainsReport{}, errNoPriceFunc
+	}
+	if err := w.tg.Add(); err != nil {
+		return RealizedGainsReport{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return RealizedGainsReport{}, err
+	}
+
+	// Walk the processed transactions in chronological order, remembering
+	// the outputs the wallet received and matching them to the inputs which
+	// spend them.
+	report := RealizedGainsReport{TotalGain: new(big.Rat)}
+	acquired := make(map[types.SiacoinOutputID]acquiredOutput)
+	it := dbProcessedTransactionsIterator(w.dbTx)
+	for it.next() {
+		pt := it.value()
+		if pt.ConfirmationHeight > end {
+			break
+		}
+		for _, input := range pt.Inputs {
+			if input.FundType != types.SpecifierSiacoinInput || !input.WalletAddress {
+				continue
+			}
+			id := types.SiacoinOutputID(input.ParentID)
+			ao, exists := acquired[id]
+			if !exists {
+				continue
+			}
+			delete(acquired, id)
+			if pt.ConfirmationHeight < start {
+				continue
+			}
+			gain, err := newRealizedGain(id, ao, pt.TransactionID, pt.ConfirmationHeight, pt.ConfirmationTimestamp, price)
+			if err != nil {
+				return RealizedGainsReport{}, err
+			}
+			report.Gains = append(report.Gains, gain)
+			report.TotalGain.Add(report.TotalGain, gain.Gain)
+		}
+		for _, output := range pt.Outputs {
+			if !output.WalletAddress {
+				continue
+			}
+			if output.FundType != types.SpecifierSiacoinOutput && output.FundType != types.SpecifierMinerPayout && output.FundType != types.SpecifierClaimOutput {
+				continue
+			}
+			acquired[types.SiacoinOutputID(output.ID)] = acquiredOutput{
+				value:     output.Value,
+				txnID:     pt.TransactionID,
+				height:    pt.ConfirmationHeight,
+				timestamp: pt.ConfirmationTimestamp,
+			}
+		}
+	}
+	return report, nil
+}
+
+// newRealizedGain computes the gain of an acquired output which was spent by
+// the provided transaction.
+func newRealizedGain(id types.SiacoinOutputID, ao acquiredOutput, txnID types.TransactionID, height types.BlockHeight, timestamp types.Timestamp, price PriceFunc) (RealizedGain, error) {
+	acquisitionPrice, err := price(ao.timestamp)
+	if err != nil {
+		return RealizedGain{}, errors.AddContext(err, "failed to get acquisition price")
+	}
+	disposalPrice, err := price(timestamp)
+	if err != nil {
+		return RealizedGain{}, errors.AddContext(err, "failed to get disposal price")
+	}
+	siacoins := new(big.Rat).SetFrac(ao.value.Big(), types.SiacoinPrecision.Big())
+	costBasis := new(big.Rat).Mul(siacoins, acquisitionPrice)
+	proceeds := new(big.Rat).Mul(siacoins, disposalPrice)
+	return RealizedGain{
+		OutputID:          id,
+		Value:             ao.value,
+		AcquisitionTxnID:  ao.txnID,
+		AcquisitionHeight: ao.height,
+		AcquisitionTime:   ao.timestamp,
+		DisposalTxnID:     txnID,
+		DisposalHeight:    height,
+		DisposalTime:      timestamp,
+		CostBasis:         costBasis,
+		Proceeds:          proceeds,
+		Gain:              new(big.Rat).Sub(proceeds, costBasis),
+	}, nil
+}
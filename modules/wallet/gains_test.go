@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestRealizedGains tests computing the realized gains of spent outputs.
+func TestRealizedGains(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Use the timestamp as the price to get different prices for different
+	// blocks.
+	price := func(ts types.Timestamp) (*big.Rat, error) {
+		return new(big.Rat).SetInt64(int64(ts)), nil
+	}
+
+	// Spend some of the matured miner payouts and confirm the transactions.
+	startHeight, err := wt.wallet.Height()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	spendHeight, err := wt.wallet.Height()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Before the spend, no outputs were spent.
+	report, err := wt.wallet.RealizedGains(0, startHeight, price)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Gains) != 0 || report.TotalGain.Sign() != 0 {
+		t.Fatal("expected no gains", report.Gains)
+	}
+
+	// Get the gains of the block that confirmed the spend.
+	report, err = wt.wallet.RealizedGains(spendHeight, spendHeight, price)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Gains) == 0 {
+		t.Fatal("expected gains")
+	}
+	txnIDs := make(map[types.TransactionID]struct{})
+	for _, txn := range txns {
+		txnIDs[txn.ID()] = struct{}{}
+	}
+	total := new(big.Rat)
+	for _, gain := range report.Gains {
+		if _, exists := txnIDs[gain.DisposalTxnID]; !exists {
+			t.Fatal("output wasn't spent by the sent transactions")
+		}
+		if gain.DisposalHeight != spendHeight {
+			t.Fatal("wrong disposal height", gain.DisposalHeight, spendHeight)
+		}
+		if gain.AcquisitionHeight > gain.DisposalHeight {
+			t.Fatal("output acquired after it was spent")
+		}
+		// The output needs to have been acquired by a previous transaction.
+		acquisitionTxn, found, err := wt.wallet.Transaction(gain.AcquisitionTxnID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || acquisitionTxn.ConfirmationHeight != gain.AcquisitionHeight {
+			t.Fatal("acquisition transaction doesn't match", found, acquisitionTxn.ConfirmationHeight, gain.AcquisitionHeight)
+		}
+		// Check the gain.
+		siacoins := new(big.Rat).SetFrac(gain.Value.Big(), types.SiacoinPrecision.Big())
+		priceDiff := new(big.Rat).SetInt64(int64(gain.DisposalTime) - int64(gain.AcquisitionTime))
+		expected := new(big.Rat).Mul(siacoins, priceDiff)
+		if gain.Gain.Cmp(expected) != 0 {
+			t.Fatalf("expected gain %v but got %v", expected, gain.Gain)
+		}
+		total.Add(total, gain.Gain)
+	}
+	if report.TotalGain.Cmp(total) != 0 {
+		t.Fatalf("expected total gain %v but got %v", total, report.TotalGain)
+	}
+
+	// Invalid ranges and missing prices should fail.
+	if _, err := wt.wallet.RealizedGains(2, 1, price); err != errInvalidGainsRange {
+		t.Fatal("expected errInvalidGainsRange", err)
+	}
+	if _, err := wt.wallet.RealizedGains(0, 1, nil); err != errNoPriceFunc {
+		t.Fatal("expected errNoPriceFunc", err)
+	}
+}
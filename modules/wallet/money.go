@@ -52,11 +52,10 @@ func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalan
 		return
 	}
 
-	dbForEachSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
-		if sco.Value.Cmp(dustThreshold) > 0 {
-			siacoinBalance = siacoinBalance.Add(sco.Value)
-		}
-	})
+	siacoinBalance, err = w.confirmedSiacoinBalance(dustThreshold)
+	if err != nil {
+		return
+	}
 
 	siafundPool, err := dbGetSiafundPool(w.dbTx)
 	if err != nil {
@@ -75,6 +74,17 @@ func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalan
 	return
 }
 
+// confirmedSiacoinBalance returns the sum of the confirmed siacoin outputs
+// above the dust threshold. The caller needs to hold the wallet's lock.
+func (w *Wallet) confirmedSiacoinBalance(dustThreshold types.Currency) (siacoinBalance types.Currency, err error) {
+	err = dbForEachSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if sco.Value.Cmp(dustThreshold) > 0 {
+			siacoinBalance = siacoinBalance.Add(sco.Value)
+		}
+	})
+	return
+}
+
 // UnconfirmedBalance returns the number of outgoing and incoming siacoins in
 // the unconfirmed transaction set. Refund outputs are included in this
 // reporting.
@@ -108,6 +118,94 @@ func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incoming
 	return
 }
 
+// UnconfirmedBalanceDelta returns the net effect of the unconfirmed
+// transactions on the wallet's siacoin balance. Unlike UnconfirmedBalance, the
+// value of every transaction is netted first, so change outputs don't count as
+// incoming siacoins. A transaction which receives more than it spends counts
+// towards incoming and one which spends more than it receives, e.g. because
+// of its fees, counts towards outgoing. Transactions without a net effect are
+// ignored. The values are computed the same way as for a ValuedTransaction.
+func (w *Wallet) UnconfirmedBalanceDelta() (incoming, outgoing types.Currency, err error) {
+	if err := w.tg.Add(); err != nil {
+		return types.ZeroCurrency, types.ZeroCurrency, modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, upt := range w.unconfirmedProcessedTransactions {
+		var in, out types.Currency
+		for _, input := range upt.Inputs {
+			if input.FundType == types.SpecifierSiacoinInput && input.WalletAddress {
+				out = out.Add(input.Value)
+			}
+		}
+		for _, output := range upt.Outputs {
+			if (output.FundType == types.SpecifierSiacoinOutput || output.FundType == types.SpecifierMinerPayout) && output.WalletAddress {
+				in = in.Add(output.Value)
+			}
+		}
+		switch in.Cmp(out) {
+		case 1:
+			incoming = incoming.Add(in.Sub(out))
+		case -1:
+			outgoing = outgoing.Add(out.Sub(in))
+		}
+	}
+	return
+}
+
+// SpendableBalance returns the confirmed siacoin balance of the wallet minus
+// the value of the confirmed outputs which are spent by unconfirmed
+// transactions. Unlike UnconfirmedBalance, incoming siacoins of unconfirmed
+// transactions, including refunds, are not considered spendable.
+func (w *Wallet) SpendableBalance() (types.Currency, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.ZeroCurrency, modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	// dustThreshold has to be obtained separate from the lock
+	dustThreshold, err := w.DustThreshold()
+	if err != nil {
+		return types.ZeroCurrency, modules.ErrWalletShutdown
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// ensure durability of reported balance
+	if err = w.syncDB(); err != nil {
+		return types.ZeroCurrency, err
+	}
+
+	// Get the confirmed outputs which count towards the balance.
+	confirmed := make(map[types.OutputID]types.Currency)
+	err = dbForEachSiacoinOutput(w.dbTx, func(id types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if sco.Value.Cmp(dustThreshold) > 0 {
+			confirmed[types.OutputID(id)] = sco.Value
+		}
+	})
+	if err != nil {
+		return types.ZeroCurrency, err
+	}
+
+	// Remove the outputs which are spent by unconfirmed transactions.
+	for _, upt := range w.unconfirmedProcessedTransactions {
+		for _, input := range upt.Inputs {
+			if input.FundType == types.SpecifierSiacoinInput && input.WalletAddress {
+				delete(confirmed, input.ParentID)
+			}
+		}
+	}
+	var balance types.Currency
+	for _, value := range confirmed {
+		balance = balance.Add(value)
+	}
+	return balance, nil
+}
+
 // SendSiacoins creates a transaction sending 'amount' to 'dest'. The
 // transaction is submitted to the transaction pool and is also returned. Fees
 // are added to the amount sent.
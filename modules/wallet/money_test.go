@@ -410,3 +410,183 @@ func TestSendSiacoinsAcceptTxnSetFailed(t *testing.T) {
 		t.Fatalf("SendSiacoins failed: %v", err)
 	}
 }
+
+// TestSpendableBalance tests that SpendableBalance subtracts pending outgoing
+// siacoins from the confirmed balance without adding pending incoming ones.
+func TestSpendableBalance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Without unconfirmed transactions the spendable balance should match the
+	// confirmed balance.
+	confirmedBal, _, _, err := wt.wallet.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spendable, err := wt.wallet.SpendableBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spendable.Equals(confirmedBal) {
+		t.Fatalf("expected spendable balance %v but got %v", confirmedBal, spendable)
+	}
+
+	// Send some money to a foreign address and some money to the wallet
+	// itself. This results in a pending outgoing and a pending incoming
+	// transfer.
+	_, err = wt.wallet.SendSiacoins(types.SiacoinPrecision.Mul64(3), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.SendSiacoins(types.SiacoinPrecision.Mul64(2), uc.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, unconfirmedIn, err := wt.wallet.UnconfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unconfirmedIn.IsZero() {
+		t.Fatal("expected pending incoming siacoins")
+	}
+
+	// Compute the value of the confirmed outputs spent by the unconfirmed
+	// transactions.
+	upts, err := wt.wallet.UnconfirmedTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unconfirmedOutputs := make(map[types.OutputID]struct{})
+	for _, upt := range upts {
+		for _, output := range upt.Outputs {
+			unconfirmedOutputs[output.ID] = struct{}{}
+		}
+	}
+	var spent types.Currency
+	for _, upt := range upts {
+		for _, input := range upt.Inputs {
+			if _, exists := unconfirmedOutputs[input.ParentID]; !exists && input.WalletAddress {
+				spent = spent.Add(input.Value)
+			}
+		}
+	}
+
+	// The confirmed balance shouldn't change but the spendable balance
+	// should only contain the outputs which aren't spent.
+	confirmedBal2, _, _, err := wt.wallet.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmedBal2.Equals(confirmedBal) {
+		t.Fatal("confirmed balance changed without introduction of blocks")
+	}
+	spendable, err = wt.wallet.SpendableBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent.IsZero() || !spendable.Equals(confirmedBal.Sub(spent)) {
+		t.Fatalf("expected spendable balance %v but got %v", confirmedBal.Sub(spent), spendable)
+	}
+}
+
+// TestUnconfirmedBalanceDelta tests computing the net effect of a pending
+// receive and a pending send.
+func TestUnconfirmedBalanceDelta(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Without unconfirmed transactions there is no delta.
+	in, out, err := wt.wallet.UnconfirmedBalanceDelta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !in.IsZero() || !out.IsZero() {
+		t.Fatal("expected no delta", in, out)
+	}
+
+	// Send coins to an external address. The change shouldn't count as
+	// incoming.
+	sent := types.SiacoinPrecision.Mul64(3)
+	txns, err := wt.wallet.SendSiacoins(sent, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOut := sent
+	for _, txn := range txns {
+		for _, fee := range txn.MinerFees {
+			expectedOut = expectedOut.Add(fee)
+		}
+	}
+
+	// Add a pending receive and a transaction which only passes coins
+	// through the wallet.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := types.SiacoinPrecision.Mul64(2)
+	passedThrough := types.SiacoinPrecision
+	wt.wallet.mu.Lock()
+	wt.wallet.unconfirmedProcessedTransactions = append(wt.wallet.unconfirmedProcessedTransactions, modules.ProcessedTransaction{
+		TransactionID: types.TransactionID{1},
+		Inputs: []modules.ProcessedInput{{
+			FundType: types.SpecifierSiacoinInput,
+			Value:    received,
+		}},
+		Outputs: []modules.ProcessedOutput{{
+			FundType:       types.SpecifierSiacoinOutput,
+			RelatedAddress: uc.UnlockHash(),
+			WalletAddress:  true,
+			Value:          received,
+		}},
+	}, modules.ProcessedTransaction{
+		TransactionID: types.TransactionID{2},
+		Inputs: []modules.ProcessedInput{{
+			FundType:      types.SpecifierSiacoinInput,
+			WalletAddress: true,
+			Value:         passedThrough,
+		}},
+		Outputs: []modules.ProcessedOutput{{
+			FundType:       types.SpecifierSiacoinOutput,
+			RelatedAddress: uc.UnlockHash(),
+			WalletAddress:  true,
+			Value:          passedThrough,
+		}},
+	})
+	wt.wallet.mu.Unlock()
+
+	in, out, err = wt.wallet.UnconfirmedBalanceDelta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !in.Equals(received) {
+		t.Fatalf("expected incoming %v but got %v", received, in)
+	}
+	if !out.Equals(expectedOut) {
+		t.Fatalf("expected outgoing %v but got %v", expectedOut, out)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"math"
+	"time"
 
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
@@ -61,24 +62,8 @@ func (w *Wallet) UnspentOutputs() ([]modules.UnspentOutput, error) {
 	outputs = filtered
 
 	// set the confirmation height for each output
-outer:
-	for i, o := range outputs {
-		txnIndices, err := dbGetAddrTransactions(w.dbTx, o.UnlockHash)
-		if err != nil {
-			return nil, err
-		}
-		for _, j := range txnIndices {
-			pt, err := dbGetProcessedTransaction(w.dbTx, j)
-			if err != nil {
-				return nil, err
-			}
-			for _, sco := range pt.Outputs {
-				if sco.ID == o.ID {
-					outputs[i].ConfirmationHeight = pt.ConfirmationHeight
-					continue outer
-				}
-			}
-		}
+	if err := w.setConfirmationHeights(outputs); err != nil {
+		return nil, err
 	}
 
 	// add unconfirmed outputs, except those that are spent in pending
@@ -106,6 +91,109 @@ outer:
 	return outputs, nil
 }
 
+// SpendableOutputs returns the confirmed siacoin outputs of the wallet with a
+// value above minValue which could be used to fund a transaction right now.
+// Outputs which are spent by pending transactions, were spent recently, are
+// still timelocked, are dust or belong to watch-only addresses are excluded.
+// Immature miner payouts are never included since the wallet only tracks them
+// once they mature.
+func (w *Wallet) SpendableOutputs(minValue types.Currency) ([]modules.UnspentOutput, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	// dustThreshold has to be obtained separate from the lock
+	dustThreshold, err := w.DustThreshold()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// ensure durability of reported outputs
+	if err := w.syncDB(); err != nil {
+		return nil, err
+	}
+	height, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	// don't include outputs marked as spent in pending transactions
+	pending := make(map[types.OutputID]struct{})
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		for _, input := range pt.Inputs {
+			if input.WalletAddress {
+				pending[input.ParentID] = struct{}{}
+			}
+		}
+	}
+
+	var outputs []modules.UnspentOutput
+	err = dbForEachSiacoinOutput(w.dbTx, func(scoid types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if _, ok := w.keys[sco.UnlockHash]; !ok {
+			return // watch-only
+		}
+		if _, ok := pending[types.OutputID(scoid)]; ok {
+			return
+		}
+		if sco.Value.Cmp(minValue) <= 0 {
+			return
+		}
+		if w.checkOutput(w.dbTx, height, scoid, sco, dustThreshold) != nil {
+			return
+		}
+		outputs = append(outputs, modules.UnspentOutput{
+			FundType:   types.SpecifierSiacoinOutput,
+			ID:         types.OutputID(scoid),
+			UnlockHash: sco.UnlockHash,
+			Value:      sco.Value,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// set the confirmation height for each output
+	if err := w.setConfirmationHeights(outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// setConfirmationHeights sets the confirmation height of the confirmed outputs
+// using the transactions of their addresses. The confirmation height of
+// outputs whose transactions were pruned remains unset. The caller needs to
+// hold the wallet's lock.
+func (w *Wallet) setConfirmationHeights(outputs []modules.UnspentOutput) error {
+outer:
+	for i, o := range outputs {
+		txnIndices, err := dbGetAddrTransactions(w.dbTx, o.UnlockHash)
+		if errors.Is(err, errNoKey) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, j := range txnIndices {
+			pt, err := dbGetProcessedTransaction(w.dbTx, j)
+			if errors.Is(err, errNoKey) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			for _, sco := range pt.Outputs {
+				if sco.ID == o.ID {
+					outputs[i].ConfirmationHeight = pt.ConfirmationHeight
+					continue outer
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // UnlockConditions returns the UnlockConditions for the specified address, if
 // they are known to the wallet.
 func (w *Wallet) UnlockConditions(addr types.UnlockHash) (uc types.UnlockConditions, err error) {
@@ -325,6 +413,7 @@ func (w *Wallet) AddWatchAddresses(addrs []types.UnlockHash, unused bool) error
 				return err
 			}
 			w.unconfirmedProcessedTransactions = nil
+			w.unconfirmedAdded = make(map[types.TransactionID]time.Time)
 			if err := dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning); err != nil {
 				return err
 			}
@@ -409,6 +498,7 @@ func (w *Wallet) RemoveWatchAddresses(addrs []types.UnlockHash, unused bool) err
 				return err
 			}
 			w.unconfirmedProcessedTransactions = nil
+			w.unconfirmedAdded = make(map[types.TransactionID]time.Time)
 			if err := dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning); err != nil {
 				return err
 			}
@@ -283,6 +283,74 @@ func TestUnspentOutputs(t *testing.T) {
 	}
 }
 
+// TestSpendableOutputs tests that SpendableOutputs excludes outputs which are
+// spent by pending transactions and immature miner payouts.
+func TestSpendableOutputs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// mine a block to create an immature payout
+	b, err := wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	immature := types.OutputID(b.MinerPayoutID(0))
+
+	// spend an output in a pending transaction
+	txns, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent := make(map[types.OutputID]struct{})
+	for _, txn := range txns {
+		for _, sci := range txn.SiacoinInputs {
+			spent[types.OutputID(sci.ParentID)] = struct{}{}
+		}
+	}
+	if len(spent) == 0 {
+		t.Fatal("transaction doesn't spend any outputs")
+	}
+
+	outputs, err := wt.wallet.SpendableOutputs(types.ZeroCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) == 0 {
+		t.Fatal("expected spendable outputs")
+	}
+	var largest types.Currency
+	for _, o := range outputs {
+		if o.ID == immature {
+			t.Fatal("immature payout is spendable")
+		}
+		if _, ok := spent[o.ID]; ok {
+			t.Fatal("output spent by pending transaction is spendable")
+		}
+		if o.Value.Cmp(largest) > 0 {
+			largest = o.Value
+		}
+	}
+
+	// no output is above the largest value
+	outputs, err = wt.wallet.SpendableOutputs(largest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 0 {
+		t.Fatal("expected no outputs above", largest, len(outputs))
+	}
+}
+
 // TestWatchOnly tests the ability of the wallet to track addresses that it
 // does not own.
 func TestWatchOnly(t *testing.T) {
@@ -140,6 +140,14 @@ func (w *Wallet) initPersist() error {
 		w.log.Critical("ERROR: failed to start database update:", err)
 	}
 
+	// load the limits for unconfirmed transactions
+	limits, err := dbGetUnconfirmedLimits(w.dbTx)
+	if err != nil {
+		return err
+	}
+	w.maxUnconfirmed = limits.MaxTransactions
+	w.maxUnconfirmedAge = limits.MaxAge
+
 	// COMPATv131 we need to create the bucketProcessedTxnIndex if it doesn't exist
 	if w.dbTx.Bucket(bucketProcessedTransactions).Stats().KeyN > 0 &&
 		w.dbTx.Bucket(bucketProcessedTxnIndex).Stats().KeyN == 0 {
@@ -0,0 +1,195 @@
+package wallet
+
+import (
+	"encoding/binary"
+
+	"gitlab.com/NebulousLabs/bolt"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// PruneCheckpoint summarizes the processed transactions which were pruned
+// from the wallet's history. The detailed history below Height is lost, but
+// the net effect of the pruned transactions on the wallet's balance is
+// preserved. Adding the net value of the remaining history to the net value of
+// the checkpoint results in the same value as before pruning.
+type PruneCheckpoint struct {
+	// Height is the cutoff height. All processed transactions confirmed
+	// below it were pruned.
+	Height types.BlockHeight `json:"height"`
+
+	// NumTransactions is the number of pruned transactions.
+	NumTransactions uint64 `json:"numtransactions"`
+
+	// The siacoins and siafunds which were received and sent by the pruned
+	// transactions. The values are computed the same way as the values of a
+	// ValuedTransaction.
+	IncomingSiacoins types.Currency `json:"incomingsiacoins"`
+	OutgoingSiacoins types.Currency `json:"outgoingsiacoins"`
+	IncomingSiafunds types.Currency `json:"incomingsiafunds"`
+	OutgoingSiafunds types.Currency `json:"outgoingsiafunds"`
+}
+
+// add folds the net effect of a processed transaction into the checkpoint.
+func (cp *PruneCheckpoint) add(pt modules.ProcessedTransaction) {
+	cp.NumTransactions++
+	for _, input := range pt.Inputs {
+		if !input.WalletAddress {
+			continue
+		}
+		switch input.FundType {
+		case types.SpecifierSiacoinInput:
+			cp.OutgoingSiacoins = cp.OutgoingSiacoins.Add(input.Value)
+		case types.SpecifierSiafundInput:
+			cp.OutgoingSiafunds = cp.OutgoingSiafunds.Add(input.Value)
+		}
+	}
+	for _, output := range pt.Outputs {
+		if !output.WalletAddress {
+			continue
+		}
+		switch output.FundType {
+		case types.SpecifierSiacoinOutput, types.SpecifierMinerPayout:
+			cp.IncomingSiacoins = cp.IncomingSiacoins.Add(output.Value)
+		case types.SpecifierSiafundOutput:
+			cp.IncomingSiafunds = cp.IncomingSiafunds.Add(output.Value)
+		}
+	}
+}
+
+// PruneCheckpoint returns the checkpoint of the transactions which were pruned
+// from the wallet's history. It is empty if no transactions were pruned.
+func (w *Wallet) PruneCheckpoint() (PruneCheckpoint, error) {
+	if err := w.tg.Add(); err != nil {
+		return PruneCheckpoint{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return dbGetPruneCheckpoint(w.dbTx)
+}
+
+// TransactionRetention returns the number of blocks of processed transactions
+// the wallet keeps. 0 means that the full history is kept.
+func (w *Wallet) TransactionRetention() (types.BlockHeight, error) {
+	if err := w.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return dbGetTransactionRetention(w.dbTx)
+}
+
+// SetTransactionRetention sets the number of blocks of processed transactions
+// the wallet keeps. Transactions which were confirmed more than retention
+// blocks ago are pruned right away and whenever the wallet processes a new
+// block. Their net effect is folded into the PruneCheckpoint but their
+// details, e.g. for Transactions or AddressTransactions, are lost. Setting the
+// retention to 0 keeps all transactions from now on but doesn't restore pruned
+// ones. Pruned transactions can't be reverted by a reorg, so the retention
+// should be large enough to cover any reasonable reorg.
+func (w *Wallet) SetTransactionRetention(retention types.BlockHeight) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := dbPutTransactionRetention(w.dbTx, retention); err != nil {
+		return err
+	}
+	if err := w.pruneTransactions(w.dbTx); err != nil {
+		return err
+	}
+	return w.syncDB()
+}
+
+// pruneTransactions prunes the processed transactions according to the
+// wallet's retention.
+func (w *Wallet) pruneTransactions(tx *bolt.Tx) error {
+	retention, err := dbGetTransactionRetention(tx)
+	if err != nil {
+		return err
+	}
+	height, err := dbGetConsensusHeight(tx)
+	if err != nil {
+		return err
+	}
+	if retention == 0 || height < retention {
+		return nil
+	}
+	cutoff := height - retention
+	cp, err := dbGetPruneCheckpoint(tx)
+	if err != nil {
+		return err
+	}
+	if cutoff <= cp.Height {
+		return nil
+	}
+
+	// Collect the transactions below the cutoff. Since the transactions are
+	// ordered by confirmation height, they are at the start of the bucket.
+	var keys []uint64
+	var pts []modules.ProcessedTransaction
+	it := dbProcessedTransactionsIterator(tx)
+	for it.next() && it.value().ConfirmationHeight < cutoff {
+		keys = append(keys, it.key())
+		pts = append(pts, it.value())
+	}
+
+	// Delete them from the bucket and the indices.
+	pruned := make(map[uint64]struct{}, len(keys))
+	addrs := make(map[types.UnlockHash]struct{})
+	b := tx.Bucket(bucketProcessedTransactions)
+	keyBytes := make([]byte, 8)
+	for i, key := range keys {
+		pt := pts[i]
+		binary.BigEndian.PutUint64(keyBytes, key)
+		if err := b.Delete(keyBytes); err != nil {
+			return err
+		}
+		if err := dbDeleteTransactionIndex(tx, pt.TransactionID); err != nil {
+			return err
+		}
+		for _, input := range pt.Inputs {
+			addrs[input.RelatedAddress] = struct{}{}
+		}
+		for _, output := range pt.Outputs {
+			addrs[output.RelatedAddress] = struct{}{}
+		}
+		pruned[key] = struct{}{}
+		cp.add(pt)
+	}
+	for addr := range addrs {
+		if err := dbPruneAddrTransactions(tx, addr, pruned); err != nil {
+			return err
+		}
+	}
+
+	cp.Height = cutoff
+	if len(keys) > 0 {
+		w.log.Printf("Pruned %v processed transactions below height %v", len(keys), cutoff)
+	}
+	return dbPutPruneCheckpoint(tx, cp)
+}
+
+// dbPruneAddrTransactions removes the pruned transactions from the
+// transactions of an address. The entry of the address is kept even if all of
+// its transactions were pruned since it marks the address as used.
+func dbPruneAddrTransactions(tx *bolt.Tx, addr types.UnlockHash, pruned map[uint64]struct{}) error {
+	txns, err := dbGetAddrTransactions(tx, addr)
+	if err == errNoKey {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	remaining := txns[:0]
+	for _, txn := range txns {
+		if _, exists := pruned[txn]; !exists {
+			remaining = append(remaining, txn)
+		}
+	}
+	return dbPutAddrTransactions(tx, addr, remaining)
+}
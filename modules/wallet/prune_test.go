@@ -0,0 +1,205 @@
+package wallet
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestPruneTransactions tests that pruning the processed transactions
+// preserves the wallet's balance and the net value of its history.
+func TestPruneTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create some history.
+	for i := 0; i < 3; i++ {
+		if _, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := wt.addBlockNoPayout(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// netHistory returns the net siacoin value of the remaining history and
+	// the checkpoint.
+	netHistory := func() (incoming, outgoing types.Currency, numTxns uint64) {
+		t.Helper()
+		cp, err := wt.wallet.PruneCheckpoint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pts, err := wt.wallet.Transactions(0, wt.cs.Height())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pt := range pts {
+			cp.add(pt)
+		}
+		return cp.IncomingSiacoins, cp.OutgoingSiacoins, cp.NumTransactions
+	}
+	balanceBefore, _, _, err := wt.wallet.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inBefore, outBefore, numBefore := netHistory()
+
+	// Keep all transactions by default.
+	if retention, err := wt.wallet.TransactionRetention(); err != nil || retention != 0 {
+		t.Fatal("unexpected retention", retention, err)
+	}
+
+	// Only keep the last 2 blocks.
+	retention := types.BlockHeight(2)
+	if err := wt.wallet.SetTransactionRetention(retention); err != nil {
+		t.Fatal(err)
+	}
+	cutoff := wt.cs.Height() - retention
+	cp, err := wt.wallet.PruneCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Height != cutoff || cp.NumTransactions == 0 {
+		t.Fatal("unexpected checkpoint", cp.Height, cp.NumTransactions)
+	}
+
+	// The transactions below the cutoff are gone.
+	pts, err := wt.wallet.Transactions(0, cutoff-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 0 {
+		t.Fatal("expected pruned transactions to be gone", len(pts))
+	}
+
+	// The balance and the net value of the history are unchanged.
+	balanceAfter, _, _, err := wt.wallet.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !balanceAfter.Equals(balanceBefore) {
+		t.Fatalf("balance changed from %v to %v", balanceBefore, balanceAfter)
+	}
+	inAfter, outAfter, numAfter := netHistory()
+	if !inAfter.Equals(inBefore) || !outAfter.Equals(outBefore) || numAfter != numBefore {
+		t.Fatal("net value of history changed", inBefore, inAfter, outBefore, outAfter, numBefore, numAfter)
+	}
+
+	// The outputs can still be listed.
+	if _, err := wt.wallet.UnspentOutputs(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.wallet.SpendableOutputs(types.ZeroCurrency); err != nil {
+		t.Fatal(err)
+	}
+
+	// New blocks advance the cutoff.
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	cp, err = wt.wallet.PruneCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Height != cutoff+1 {
+		t.Fatal("cutoff didn't advance", cp.Height, cutoff+1)
+	}
+}
+
+// TestPruneAllTransactions tests that the wallet keeps working after all of its
+// processed transactions were pruned.
+func TestPruneAllTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createBlankWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.wallet
+
+	// Add a confirmed transaction which created an output of the wallet.
+	addr := types.UnlockHash{1}
+	scoid := types.SiacoinOutputID{2}
+	value := types.NewCurrency64(10)
+	pt := modules.ProcessedTransaction{
+		TransactionID:      types.TransactionID{3},
+		ConfirmationHeight: 1,
+		Outputs: []modules.ProcessedOutput{{
+			ID:             types.OutputID(scoid),
+			FundType:       types.SpecifierSiacoinOutput,
+			RelatedAddress: addr,
+			Value:          value,
+			WalletAddress:  true,
+		}},
+	}
+	w.mu.Lock()
+	err = errors.Compose(
+		dbAppendProcessedTransaction(w.dbTx, pt),
+		dbPutSiacoinOutput(w.dbTx, scoid, types.SiacoinOutput{Value: value, UnlockHash: addr}),
+		dbPutConsensusHeight(w.dbTx, 10),
+	)
+	w.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := w.TransactionSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Prune all transactions.
+	if err := w.SetTransactionRetention(1); err != nil {
+		t.Fatal(err)
+	}
+	pts, err := w.Transactions(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 0 {
+		t.Fatal("expected all transactions to be pruned", len(pts))
+	}
+
+	// The output is still listed but its confirmation height is unknown.
+	outputs, err := w.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || outputs[0].ID != types.OutputID(scoid) || outputs[0].ConfirmationHeight != 0 {
+		t.Fatal("unexpected outputs", outputs)
+	}
+
+	// Snapshots and diffs still work. The pruned transaction of the old
+	// snapshot isn't mistaken for a reorg.
+	if _, err := w.TransactionSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := w.TransactionDiff(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Confirmed) != 0 {
+		t.Fatal("expected no new transactions", len(diff.Confirmed))
+	}
+	if _, err := w.TransactionDiff(diff.Token); err != nil {
+		t.Fatal(err)
+	}
+}
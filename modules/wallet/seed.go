@@ -3,6 +3,7 @@ package wallet
 import (
 	"runtime"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/bolt"
 	"gitlab.com/NebulousLabs/errors"
@@ -375,6 +376,7 @@ func (w *Wallet) LoadSeed(masterKey crypto.CipherKey, seed modules.Seed) error {
 			return err
 		}
 		w.unconfirmedProcessedTransactions = nil
+		w.unconfirmedAdded = make(map[types.TransactionID]time.Time)
 
 		// reset the consensus change ID and height in preparation for rescan
 		err = dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning)
@@ -0,0 +1,196 @@
+package wallet
+
+import (
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// ErrTransactionSnapshotReorg is returned by TransactionDiff if the
+	// confirmed transactions the snapshot was taken from were reverted. The
+	// caller needs to perform a full resync.
+	ErrTransactionSnapshotReorg = errors.New("transactions of snapshot were reverted - full resync required")
+
+	// errInvalidSnapshotToken is returned if a snapshot token can't be
+	// decoded.
+	errInvalidSnapshotToken = errors.New("invalid transaction snapshot token")
+)
+
+type (
+	// TransactionSnapshotToken is an opaque token which describes the state of
+	// the wallet's transactions at the time it was created.
+	TransactionSnapshotToken []byte
+
+	// TransactionDiff contains the changes to the wallet's transactions since
+	// a snapshot was taken.
+	TransactionDiff struct {
+		// Confirmed contains all transactions that were confirmed since the
+		// snapshot was taken in chronological order.
+		Confirmed []modules.ProcessedTransaction
+
+		// Unconfirmed contains the current unconfirmed transactions if they
+		// changed since the snapshot was taken. UnconfirmedChanged indicates
+		// whether that's the case.
+		Unconfirmed        []modules.ProcessedTransaction
+		UnconfirmedChanged bool
+
+		// ConfirmedSiacoinBalance is the wallet's confirmed siacoin balance
+		// at the time the diff was computed. SiacoinBalanceIncrease and
+		// SiacoinBalanceDecrease contain the change of the balance since the
+		// snapshot was taken. At most one of them is non-zero. Since miner
+		// payouts only become part of the balance once they matured, the
+		// balance can change without new transactions being confirmed.
+		ConfirmedSiacoinBalance types.Currency
+		SiacoinBalanceIncrease  types.Currency
+		SiacoinBalanceDecrease  types.Currency
+
+		// Token is a snapshot of the state the diff was computed from. It
+		// can be passed to the next call to TransactionDiff.
+		Token TransactionSnapshotToken
+	}
+
+	// transactionSnapshot is the decoded form of a TransactionSnapshotToken.
+	transactionSnapshot struct {
+		// Index is the index of the last processed transaction within
+		// bucketProcessedTransactions and LastTxnID is its id. The id is used
+		// to detect reorgs.
+		Index     uint64
+		LastTxnID types.TransactionID
+
+		// UnconfirmedHash is the hash of the ids of all unconfirmed
+		// transactions.
+		UnconfirmedHash crypto.Hash
+
+		// SiacoinBalance is the confirmed siacoin balance of the wallet.
+		SiacoinBalance types.Currency
+	}
+)
+
+// TransactionSnapshot returns a token describing the current state of the
+// wallet's transactions. It can be passed to TransactionDiff to get the
+// changes since the snapshot was taken.
+func (w *Wallet) TransactionSnapshot() (TransactionSnapshotToken, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	// dustThreshold has to be obtained separate from the lock
+	dustThreshold, err := w.DustThreshold()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return nil, err
+	}
+	ts, err := w.transactionSnapshot(dustThreshold)
+	if err != nil {
+		return nil, err
+	}
+	return encoding.Marshal(ts), nil
+}
+
+// TransactionDiff returns all transactions confirmed since the snapshot
+// described by the token was taken as well as the current set of unconfirmed
+// transactions if it changed. If the confirmed transactions the snapshot was
+// taken from were reverted, ErrTransactionSnapshotReorg is returned.
+func (w *Wallet) TransactionDiff(token TransactionSnapshotToken) (TransactionDiff, error) {
+	if err := w.tg.Add(); err != nil {
+		return TransactionDiff{}, err
+	}
+	defer w.tg.Done()
+
+	var old transactionSnapshot
+	if err := encoding.Unmarshal(token, &old); err != nil {
+		return TransactionDiff{}, errors.Compose(err, errInvalidSnapshotToken)
+	}
+
+	// dustThreshold has to be obtained separate from the lock
+	dustThreshold, err := w.DustThreshold()
+	if err != nil {
+		return TransactionDiff{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return TransactionDiff{}, err
+	}
+	current, err := w.transactionSnapshot(dustThreshold)
+	if err != nil {
+		return TransactionDiff{}, err
+	}
+
+	// Check for reorgs. The last transaction of the snapshot needs to still
+	// exist at the same index unless it was pruned. Pruned transactions can't
+	// be reverted.
+	if current.Index < old.Index {
+		return TransactionDiff{}, ErrTransactionSnapshotReorg
+	}
+	if old.Index > 0 {
+		pt, err := dbGetProcessedTransaction(w.dbTx, old.Index)
+		pruned := errors.Contains(err, errNoKey)
+		if !pruned && (err != nil || pt.TransactionID != old.LastTxnID) {
+			return TransactionDiff{}, ErrTransactionSnapshotReorg
+		}
+	}
+
+	// Collect the newly confirmed transactions.
+	var diff TransactionDiff
+	for i := old.Index + 1; i <= current.Index; i++ {
+		pt, err := dbGetProcessedTransaction(w.dbTx, i)
+		if err != nil {
+			return TransactionDiff{}, errors.AddContext(err, "failed to fetch processed transaction")
+		}
+		diff.Confirmed = append(diff.Confirmed, pt)
+	}
+
+	// Add the unconfirmed transactions if they changed.
+	if current.UnconfirmedHash != old.UnconfirmedHash {
+		diff.UnconfirmedChanged = true
+		diff.Unconfirmed = append(diff.Unconfirmed, w.unconfirmedProcessedTransactions...)
+	}
+
+	// Compute the change of the balance.
+	diff.ConfirmedSiacoinBalance = current.SiacoinBalance
+	if current.SiacoinBalance.Cmp(old.SiacoinBalance) > 0 {
+		diff.SiacoinBalanceIncrease = current.SiacoinBalance.Sub(old.SiacoinBalance)
+	} else {
+		diff.SiacoinBalanceDecrease = old.SiacoinBalance.Sub(current.SiacoinBalance)
+	}
+	diff.Token = encoding.Marshal(current)
+	return diff, nil
+}
+
+// transactionSnapshot creates a snapshot of the current state of the
+// wallet's transactions. The caller needs to hold the wallet's lock.
+func (w *Wallet) transactionSnapshot(dustThreshold types.Currency) (transactionSnapshot, error) {
+	var ts transactionSnapshot
+	balance, err := w.confirmedSiacoinBalance(dustThreshold)
+	if err != nil {
+		return transactionSnapshot{}, errors.AddContext(err, "failed to compute balance")
+	}
+	ts.SiacoinBalance = balance
+	ts.Index = w.dbTx.Bucket(bucketProcessedTransactions).Sequence()
+	if ts.Index > 0 {
+		// If all transactions were pruned, the bucket is empty and the
+		// snapshot only contains the index.
+		pt, err := dbGetLastProcessedTransaction(w.dbTx)
+		if err != nil && !errors.Contains(err, errNoKey) {
+			return transactionSnapshot{}, errors.AddContext(err, "failed to fetch last processed transaction")
+		}
+		ts.LastTxnID = pt.TransactionID
+	}
+	txids := make([]types.TransactionID, 0, len(w.unconfirmedProcessedTransactions))
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		txids = append(txids, pt.TransactionID)
+	}
+	ts.UnconfirmedHash = crypto.HashObject(txids)
+	return ts, nil
+}
@@ -0,0 +1,174 @@
+package wallet
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestTransactionDiff tests taking a snapshot of the wallet's transactions and
+// computing the diff after sending and confirming transactions.
+func TestTransactionDiff(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Take a snapshot.
+	token, err := wt.wallet.TransactionSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without changes the diff should be empty.
+	diff, err := wt.wallet.TransactionDiff(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Confirmed) != 0 || len(diff.Unconfirmed) != 0 || diff.UnconfirmedChanged {
+		t.Fatal("diff should be empty", diff)
+	}
+
+	// Send some coins. This creates 2 unconfirmed transactions.
+	_, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err = wt.wallet.TransactionDiff(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Confirmed) != 0 {
+		t.Fatal("no transactions should be confirmed", len(diff.Confirmed))
+	}
+	if !diff.UnconfirmedChanged || len(diff.Unconfirmed) != 2 {
+		t.Fatal("expected 2 unconfirmed transactions", diff.UnconfirmedChanged, len(diff.Unconfirmed))
+	}
+
+	// Using the new token, nothing should have changed.
+	token = diff.Token
+	diff, err = wt.wallet.TransactionDiff(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Confirmed) != 0 || diff.UnconfirmedChanged {
+		t.Fatal("diff should be empty", diff)
+	}
+
+	// Mine a block. This confirms the 2 transactions and adds the miner
+	// payout.
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	diff, err = wt.wallet.TransactionDiff(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Confirmed) != 3 {
+		t.Fatal("expected 3 confirmed transactions", len(diff.Confirmed))
+	}
+	if !diff.UnconfirmedChanged || len(diff.Unconfirmed) != 0 {
+		t.Fatal("unconfirmed set should be empty", diff.UnconfirmedChanged, len(diff.Unconfirmed))
+	}
+
+	// Simulate a reorg by removing the last processed transaction. Using the
+	// latest token should return the sentinel error.
+	token = diff.Token
+	wt.wallet.mu.Lock()
+	err = dbDeleteLastProcessedTransaction(wt.wallet.dbTx)
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.TransactionDiff(token)
+	if !errors.Contains(err, ErrTransactionSnapshotReorg) {
+		t.Fatal("expected reorg error", err)
+	}
+
+	// An invalid token should return an error.
+	_, err = wt.wallet.TransactionDiff(TransactionSnapshotToken{1, 2, 3})
+	if !errors.Contains(err, errInvalidSnapshotToken) {
+		t.Fatal("expected invalid token error", err)
+	}
+}
+
+// TestTransactionDiffBalance tests that the balance changes reported by
+// TransactionDiff add up to the wallet's confirmed balance.
+func TestTransactionDiffBalance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Take a snapshot and remember the starting balance.
+	token, err := wt.wallet.TransactionSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, _, _, err := wt.wallet.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkDiff applies the change of the next diff to the balance and
+	// compares it to the confirmed balance.
+	checkDiff := func() {
+		t.Helper()
+		diff, err := wt.wallet.TransactionDiff(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token = diff.Token
+		if !diff.SiacoinBalanceIncrease.IsZero() && !diff.SiacoinBalanceDecrease.IsZero() {
+			t.Fatal("balance can't increase and decrease at the same time")
+		}
+		balance = balance.Add(diff.SiacoinBalanceIncrease).Sub(diff.SiacoinBalanceDecrease)
+		expected, _, _, err := wt.wallet.ConfirmedBalance()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !balance.Equals(expected) {
+			t.Fatalf("expected balance %v but got %v", expected, balance)
+		}
+		if !diff.ConfirmedSiacoinBalance.Equals(expected) {
+			t.Fatalf("expected confirmed balance %v but got %v", expected, diff.ConfirmedSiacoinBalance)
+		}
+	}
+
+	// Mine blocks to have miner payouts mature.
+	for i := types.BlockHeight(0); i <= types.MaturityDelay; i++ {
+		if _, err := wt.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+		checkDiff()
+	}
+
+	// Send coins away and confirm the transaction.
+	_, err = wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDiff()
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	checkDiff()
+}
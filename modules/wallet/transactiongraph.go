@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// transactionGraphNode is a transaction of the graph exported by
+// ExportTransactionGraph together with the transactions whose outputs it
+// spends.
+type transactionGraphNode struct {
+	id      types.TransactionID
+	height  types.BlockHeight
+	parents []types.TransactionID
+}
+
+// ExportTransactionGraph writes the graph of the wallet's transactions
+// confirmed within [start, end] to out in the Graphviz DOT format. Every
+// transaction is a node and every transaction has an edge to the transactions
+// whose outputs it spends. Only spent outputs created by transactions within
+// the range are considered.
+//
+// The range is read in pages of transactionGraphPageSize blocks. Each page is
+// collected while holding the wallet's lock and written to out afterwards, so
+// a slow writer doesn't block the wallet. The nodes of a page are released
+// once they were written, but the ids of all outputs created within the range
+// are kept in memory to find the edges, which is about 64 bytes per output.
+func (w *Wallet) ExportTransactionGraph(out io.Writer, start, end types.BlockHeight) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	if start > end {
+		return errors.AddContext(errOutOfBounds, "failed to export transaction graph")
+	}
+
+	bw := bufio.NewWriter(out)
+	if _, err := fmt.Fprintln(bw, "digraph transactions {"); err != nil {
+		return err
+	}
+	creators := make(map[types.OutputID]types.TransactionID)
+	for pageStart := start; ; pageStart += transactionGraphPageSize {
+		pageEnd := end
+		if end-pageStart >= transactionGraphPageSize {
+			pageEnd = pageStart + transactionGraphPageSize - 1
+		}
+		nodes, done, err := w.managedTransactionGraphPage(pageStart, pageEnd, pageStart == start, creators)
+		if err != nil {
+			return errors.AddContext(err, "failed to export transaction graph")
+		}
+		if err := writeTransactionGraphNodes(bw, nodes); err != nil {
+			return err
+		}
+		if done || pageEnd == end {
+			break
+		}
+	}
+	if _, err := fmt.Fprintln(bw, "}"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeTransactionGraphNodes writes the nodes and their edges to bw.
+func writeTransactionGraphNodes(bw *bufio.Writer, nodes []transactionGraphNode) error {
+	for _, node := range nodes {
+		_, err := fmt.Fprintf(bw, "\t\"%v\" [label=\"%v\\nheight %v\"];\n", node.id, node.id, node.height)
+		if err != nil {
+			return err
+		}
+		for _, parent := range node.parents {
+			if _, err := fmt.Fprintf(bw, "\t\"%v\" -> \"%v\";\n", node.id, parent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// managedTransactionGraphPage returns the nodes of the graph of the wallet's
+// transactions confirmed within [start, end] in the order of their
+// confirmation. creators maps the outputs of the previous pages to the
+// transactions which created them and is updated with the outputs of the
+// page. done is true if the page reaches the current consensus height. Only
+// the first page returns an error if start is above the consensus height.
+func (w *Wallet) managedTransactionGraphPage(start, end types.BlockHeight, first bool, creators map[types.OutputID]types.TransactionID) (nodes []transactionGraphNode, done bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return nil, false, err
+	}
+	height, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return nil, false, err
+	}
+	if !first && start > height {
+		return nil, true, nil
+	}
+
+	err = w.forEachProcessedTransaction(start, end, func(pt modules.ProcessedTransaction) error {
+		node := transactionGraphNode{
+			id:     pt.TransactionID,
+			height: pt.ConfirmationHeight,
+		}
+		// Add a single edge per parent, even if multiple of its outputs are
+		// spent.
+		parents := make(map[types.TransactionID]struct{})
+		for _, input := range pt.Inputs {
+			parent, exists := creators[input.ParentID]
+			if !exists {
+				continue
+			}
+			if _, exists := parents[parent]; exists {
+				continue
+			}
+			parents[parent] = struct{}{}
+			node.parents = append(node.parents, parent)
+		}
+		for _, output := range pt.Outputs {
+			creators[output.ID] = pt.TransactionID
+		}
+		nodes = append(nodes, node)
+		return nil
+	})
+	return nodes, end >= height, err
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestExportTransactionGraph tests that a transaction spending the output of
+// another transaction results in a single edge, even if the transactions are
+// exported in different pages.
+func TestExportTransactionGraph(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	wt, err := createBlankWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a chain of two transactions. The child spends both outputs of
+	// the parent.
+	var parentID, childID types.TransactionID
+	fastrand.Read(parentID[:])
+	fastrand.Read(childID[:])
+	parent := modules.ProcessedTransaction{TransactionID: parentID}
+	child := modules.ProcessedTransaction{TransactionID: childID}
+	for i := 0; i < 2; i++ {
+		var id types.OutputID
+		fastrand.Read(id[:])
+		parent.Outputs = append(parent.Outputs, modules.ProcessedOutput{ID: id, FundType: types.SpecifierSiacoinOutput})
+		child.Inputs = append(child.Inputs, modules.ProcessedInput{ParentID: id, FundType: types.SpecifierSiacoinInput})
+	}
+	// The grandchild spends an output of the child and is confirmed after
+	// the first page.
+	var grandchildID types.TransactionID
+	var childOutputID types.OutputID
+	fastrand.Read(grandchildID[:])
+	fastrand.Read(childOutputID[:])
+	child.Outputs = append(child.Outputs, modules.ProcessedOutput{ID: childOutputID, FundType: types.SpecifierSiacoinOutput})
+	grandchild := modules.ProcessedTransaction{
+		TransactionID:      grandchildID,
+		ConfirmationHeight: transactionGraphPageSize,
+		Inputs:             []modules.ProcessedInput{{ParentID: childOutputID, FundType: types.SpecifierSiacoinInput}},
+	}
+	w := wt.wallet
+	w.mu.Lock()
+	err = dbPutConsensusHeight(w.dbTx, transactionGraphPageSize)
+	for _, pt := range []modules.ProcessedTransaction{parent, child, grandchild} {
+		if err == nil {
+			err = dbAppendProcessedTransaction(w.dbTx, pt)
+		}
+	}
+	w.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Export the graph of the first page.
+	var buf bytes.Buffer
+	if err := w.ExportTransactionGraph(&buf, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	graph := buf.String()
+	if !strings.HasPrefix(graph, "digraph transactions {\n") || !strings.HasSuffix(graph, "}\n") {
+		t.Fatal("invalid graph", graph)
+	}
+	if strings.Count(graph, "[label=") != 2 {
+		t.Fatal("expected 2 nodes", graph)
+	}
+	if strings.Count(graph, "->") != 1 {
+		t.Fatal("expected 1 edge", graph)
+	}
+	edge := "\"" + childID.String() + "\" -> \"" + parentID.String() + "\";"
+	if !strings.Contains(graph, edge) {
+		t.Fatal("missing edge from child to parent", graph)
+	}
+
+	// Export the graph of a range beyond the consensus height. The edge
+	// between the pages should be found.
+	buf.Reset()
+	if err := w.ExportTransactionGraph(&buf, 0, 10*transactionGraphPageSize); err != nil {
+		t.Fatal(err)
+	}
+	graph = buf.String()
+	if strings.Count(graph, "[label=") != 3 || strings.Count(graph, "->") != 2 {
+		t.Fatal("expected 3 nodes and 2 edges", graph)
+	}
+	edge = "\"" + grandchildID.String() + "\" -> \"" + childID.String() + "\";"
+	if !strings.Contains(graph, edge) {
+		t.Fatal("missing edge from grandchild to child", graph)
+	}
+
+	// An invalid range is rejected.
+	if err := w.ExportTransactionGraph(&buf, 1, 0); !errors.Contains(err, errOutOfBounds) {
+		t.Fatal("expected errOutOfBounds", err)
+	}
+}
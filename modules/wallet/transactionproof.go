@@ -0,0 +1,169 @@
+package wallet
+
+import (
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// transactionProofVersion is the current version of the TransactionProof
+	// encoding.
+	transactionProofVersion uint8 = 1
+)
+
+var (
+	// ErrTransactionNotFound is returned by ExportTransactionProof if the
+	// transaction is not a confirmed transaction of the wallet.
+	ErrTransactionNotFound = errors.New("transaction not found")
+
+	// errNoInclusionProof is returned by ExportTransactionProof for
+	// transactions which can't be proven to be part of a block.
+	errNoInclusionProof = errors.New("miner payouts can't be proven to be part of a block")
+
+	// errUnknownTransactionProofVersion is returned when decoding a
+	// TransactionProof with an unknown version.
+	errUnknownTransactionProofVersion = errors.New("unknown transaction proof version")
+)
+
+// TransactionProof proves that a transaction was confirmed in a specific
+// block. The proof is a Merkle proof of the transaction's leaf within the
+// block's Merkle tree which consists of one leaf per miner payout followed by
+// one leaf per transaction.
+type TransactionProof struct {
+	Version            uint8
+	Transaction        modules.ProcessedTransaction
+	ConfirmationHeight types.BlockHeight
+	BlockID            types.BlockID
+	MerkleRoot         crypto.Hash
+	LeafIndex          uint64
+	NumLeaves          uint64
+	ProofSet           []crypto.Hash
+}
+
+// ExportTransactionProof returns the encoded TransactionProof of a confirmed
+// transaction of the wallet. The proof can be decoded with
+// DecodeTransactionProof.
+func (w *Wallet) ExportTransactionProof(txid types.TransactionID) ([]byte, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	// Look up the transaction.
+	pt, found, err := w.managedConfirmedTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrTransactionNotFound
+	}
+	if pt.TransactionID != pt.Transaction.ID() {
+		// The processed transactions of miner payouts use the block's id.
+		return nil, errNoInclusionProof
+	}
+
+	// Fetch the block and find the transaction's leaf. This happens without
+	// holding the wallet's lock since the consensus set might be waiting for
+	// the wallet to process a change.
+	block, exists := w.cs.BlockAtHeight(pt.ConfirmationHeight)
+	if !exists {
+		return nil, errors.AddContext(ErrTransactionNotFound, "block at confirmation height doesn't exist")
+	}
+	txnIndex := -1
+	for i, txn := range block.Transactions {
+		if txn.ID() == txid {
+			txnIndex = i
+			break
+		}
+	}
+	if txnIndex == -1 {
+		return nil, errors.AddContext(ErrTransactionNotFound, "block at confirmation height doesn't contain transaction")
+	}
+	tp := TransactionProof{
+		Version:            transactionProofVersion,
+		Transaction:        pt,
+		ConfirmationHeight: pt.ConfirmationHeight,
+		BlockID:            block.ID(),
+	}
+	tp.MerkleRoot, tp.LeafIndex, tp.NumLeaves, tp.ProofSet = blockTransactionProof(block, txnIndex)
+	return encoding.Marshal(tp), nil
+}
+
+// blockTransactionProof creates a Merkle proof for the transaction at txnIndex
+// within the block. The hash of the transaction's leaf is not part of the
+// proof set since the verifier computes it from the transaction.
+func blockTransactionProof(b types.Block, txnIndex int) (root crypto.Hash, leafIndex, numLeaves uint64, proofSet []crypto.Hash) {
+	leafIndex = uint64(len(b.MinerPayouts) + txnIndex)
+	tree := crypto.NewTree()
+	if err := tree.SetIndex(leafIndex); err != nil {
+		build.Critical("failed to set proof index", err)
+	}
+	for _, payout := range b.MinerPayouts {
+		tree.Push(encoding.Marshal(payout))
+	}
+	for _, txn := range b.Transactions {
+		tree.Push(encoding.Marshal(txn))
+	}
+	r, _, proof, _, numLeaves := tree.Prove()
+	for _, h := range proof[1:] {
+		proofSet = append(proofSet, crypto.Hash(h))
+	}
+	return crypto.Hash(r), leafIndex, numLeaves, proofSet
+}
+
+// DecodeTransactionProof decodes a TransactionProof created by
+// ExportTransactionProof.
+func DecodeTransactionProof(b []byte) (tp TransactionProof, err error) {
+	if err := encoding.Unmarshal(b, &tp); err != nil {
+		return TransactionProof{}, errors.AddContext(err, "failed to decode transaction proof")
+	}
+	if tp.Version != transactionProofVersion {
+		return TransactionProof{}, errUnknownTransactionProofVersion
+	}
+	return tp, nil
+}
+
+// Verify checks that the proof proves that its transaction is part of the
+// provided block. The block is usually the block at the proof's confirmation
+// height according to the verifier's consensus set.
+func (tp TransactionProof) Verify(b types.Block) error {
+	if b.ID() != tp.BlockID {
+		return errors.New("block id doesn't match")
+	}
+	if b.MerkleRoot() != tp.MerkleRoot {
+		return errors.New("merkle root doesn't match")
+	}
+	if tp.Transaction.Transaction.ID() != tp.Transaction.TransactionID {
+		return errors.New("transaction doesn't match its id")
+	}
+	leaf := encoding.Marshal(tp.Transaction.Transaction)
+	if !crypto.VerifySegment(leaf, tp.ProofSet, tp.NumLeaves, tp.LeafIndex, tp.MerkleRoot) {
+		return errors.New("invalid merkle proof")
+	}
+	return nil
+}
+
+// managedConfirmedTransaction returns the confirmed transaction with the given
+// id.
+func (w *Wallet) managedConfirmedTransaction(txid types.TransactionID) (pt modules.ProcessedTransaction, found bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return modules.ProcessedTransaction{}, false, err
+	}
+	keyBytes, err := dbGetTransactionIndex(w.dbTx, txid)
+	if err == errNoKey {
+		return modules.ProcessedTransaction{}, false, nil
+	} else if err != nil {
+		return modules.ProcessedTransaction{}, false, err
+	}
+	err = encoding.Unmarshal(w.dbTx.Bucket(bucketProcessedTransactions).Get(keyBytes), &pt)
+	if err != nil {
+		return modules.ProcessedTransaction{}, false, err
+	}
+	return pt, true, nil
+}
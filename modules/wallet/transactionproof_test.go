@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestTransactionProofVerify is a unit test for creating and verifying a
+// TransactionProof for a block.
+func TestTransactionProofVerify(t *testing.T) {
+	t.Parallel()
+
+	// Create a block with a few payouts and transactions.
+	var b types.Block
+	for i := 0; i < 3; i++ {
+		b.MinerPayouts = append(b.MinerPayouts, types.SiacoinOutput{Value: types.NewCurrency64(fastrand.Uint64n(100))})
+	}
+	for i := 0; i < 5; i++ {
+		b.Transactions = append(b.Transactions, types.Transaction{ArbitraryData: [][]byte{fastrand.Bytes(10)}})
+	}
+
+	for txnIndex, txn := range b.Transactions {
+		tp := TransactionProof{
+			Version:     transactionProofVersion,
+			Transaction: modules.ProcessedTransaction{Transaction: txn, TransactionID: txn.ID()},
+			BlockID:     b.ID(),
+		}
+		tp.MerkleRoot, tp.LeafIndex, tp.NumLeaves, tp.ProofSet = blockTransactionProof(b, txnIndex)
+
+		// The proof should survive a roundtrip and verify.
+		decoded, err := DecodeTransactionProof(encoding.Marshal(tp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := decoded.Verify(b); err != nil {
+			t.Fatal(txnIndex, err)
+		}
+
+		// A proof for another transaction should fail.
+		other := decoded
+		other.Transaction.Transaction = b.Transactions[(txnIndex+1)%len(b.Transactions)]
+		other.Transaction.TransactionID = other.Transaction.Transaction.ID()
+		if err := other.Verify(b); err == nil {
+			t.Fatal("proof for wrong transaction verified")
+		}
+	}
+
+	// A proof for another block should fail.
+	tp := TransactionProof{
+		Version:     transactionProofVersion,
+		Transaction: modules.ProcessedTransaction{Transaction: b.Transactions[0], TransactionID: b.Transactions[0].ID()},
+		BlockID:     b.ID(),
+	}
+	tp.MerkleRoot, tp.LeafIndex, tp.NumLeaves, tp.ProofSet = blockTransactionProof(b, 0)
+	otherBlock := b
+	otherBlock.Nonce[0]++
+	if err := tp.Verify(otherBlock); err == nil {
+		t.Fatal("proof verified for wrong block")
+	}
+
+	// Unknown versions can't be decoded.
+	tp.Version++
+	if _, err := DecodeTransactionProof(encoding.Marshal(tp)); !errors.Contains(err, errUnknownTransactionProofVersion) {
+		t.Fatal("wrong error", err)
+	}
+}
+
+// TestExportTransactionProof tests exporting the proof of a confirmed
+// transaction and verifying it against the consensus set.
+func TestExportTransactionProof(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Unknown transactions can't be exported.
+	_, err = wt.wallet.ExportTransactionProof(types.TransactionID{})
+	if !errors.Contains(err, ErrTransactionNotFound) {
+		t.Fatal("wrong error", err)
+	}
+
+	// Confirm a transaction.
+	txns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid := txns[len(txns)-1].ID()
+
+	// Unconfirmed transactions can't be exported.
+	_, err = wt.wallet.ExportTransactionProof(txid)
+	if !errors.Contains(err, ErrTransactionNotFound) {
+		t.Fatal("wrong error", err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Export and decode the proof.
+	b, err := wt.wallet.ExportTransactionProof(txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := DecodeTransactionProof(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tp.Transaction.TransactionID != txid || tp.ConfirmationHeight != wt.cs.Height() {
+		t.Fatal("wrong proof", tp.Transaction.TransactionID, tp.ConfirmationHeight)
+	}
+
+	// Verify it against the consensus set.
+	block, exists := wt.cs.BlockAtHeight(tp.ConfirmationHeight)
+	if !exists {
+		t.Fatal("block doesn't exist")
+	}
+	if err := tp.Verify(block); err != nil {
+		t.Fatal(err)
+	}
+}
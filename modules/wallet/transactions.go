@@ -4,16 +4,139 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
+	"runtime/debug"
 	"sort"
+	"time"
 
+	"gitlab.com/NebulousLabs/bolt"
 	"gitlab.com/NebulousLabs/encoding"
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
 
+const (
+	// TransactionStatusUnknown indicates that the wallet doesn't know about a
+	// transaction.
+	TransactionStatusUnknown TransactionStatusType = iota
+
+	// TransactionStatusUnconfirmed indicates that a transaction is in the
+	// wallet's unconfirmed set.
+	TransactionStatusUnconfirmed
+
+	// TransactionStatusConfirmed indicates that a transaction was confirmed.
+	TransactionStatusConfirmed
+)
+
 var (
 	errOutOfBounds = errors.New("requesting transactions at unknown confirmation heights")
+
+	// errEmptyTransactionReference is returned when tagging a transaction
+	// with an empty reference.
+	errEmptyTransactionReference = errors.New("transaction reference can't be empty")
+
+	// errTransactionNotUnconfirmed is returned by EstimateConfirmation if the
+	// transaction isn't in the wallet's unconfirmed set.
+	errTransactionNotUnconfirmed = errors.New("transaction isn't an unconfirmed wallet transaction")
+)
+
+type (
+	// TransactionSearchPanicError is returned if searching the processed
+	// transactions panicked, e.g. due to a corrupted database. It contains
+	// the value the search panicked with and, in debug builds, the stack of
+	// the panic.
+	TransactionSearchPanicError struct {
+		Value interface{}
+		Stack []byte
+	}
+
+	// ErrUnsortedTransactions is returned if a transaction confirmed outside
+	// of the requested range shows up in the middle of walking the processed
+	// transactions. That means they are not sorted by confirmation height
+	// anymore and the result of the walk would be wrong.
+	ErrUnsortedTransactions struct {
+		TransactionID      types.TransactionID
+		ConfirmationHeight types.BlockHeight
+	}
+
+	// TransactionStatusType describes whether a transaction is known to the
+	// wallet and whether it was confirmed.
+	TransactionStatusType int
+
+	// TransactionStatus is the confirmation status of a single transaction.
+	// ConfirmationHeight is only set for confirmed transactions.
+	TransactionStatus struct {
+		Status             TransactionStatusType
+		ConfirmationHeight types.BlockHeight
+	}
+
+	// MinerPayout is a miner payout to one of the wallet's addresses. The
+	// payout can be spent once the wallet's height reaches MaturityHeight.
+	MinerPayout struct {
+		ID                 types.SiacoinOutputID
+		TransactionID      types.TransactionID
+		UnlockHash         types.UnlockHash
+		Value              types.Currency
+		ConfirmationHeight types.BlockHeight
+		MaturityHeight     types.BlockHeight
+	}
+
+	// ChangeOutput is a siacoin output to one of the wallet's addresses which
+	// was created by a transaction that also spent the wallet's outputs.
+	ChangeOutput struct {
+		ID                 types.SiacoinOutputID
+		TransactionID      types.TransactionID
+		UnlockHash         types.UnlockHash
+		Value              types.Currency
+		ConfirmationHeight types.BlockHeight
+	}
+
+	// OutputHistory is the lifecycle of a siacoin output. The spend fields are
+	// only set if Spent is true.
+	OutputHistory struct {
+		ID             types.SiacoinOutputID
+		UnlockHash     types.UnlockHash
+		Value          types.Currency
+		CreatedBy      types.TransactionID
+		CreationHeight types.BlockHeight
+
+		Spent       bool
+		SpentBy     types.TransactionID
+		SpendHeight types.BlockHeight
+	}
+
+	// InputSource is the source of an input of a transaction. Known is false
+	// if the output spent by the input wasn't created by a transaction
+	// relevant to the wallet. Otherwise CreatedBy is the id of that
+	// transaction.
+	InputSource struct {
+		ParentID  types.OutputID
+		FundType  types.Specifier
+		Known     bool
+		CreatedBy types.TransactionID
+	}
+
+	// SiafundClaimTransaction is a transaction which sent siafunds to the
+	// wallet or paid out a siafund claim to the wallet. ClaimValue is the
+	// value of the siacoin claims the wallet earned by spending its siafunds
+	// within the transaction.
+	SiafundClaimTransaction struct {
+		Transaction      modules.ProcessedTransaction
+		SiafundsReceived types.Currency
+		ClaimValue       types.Currency
+	}
+
+	// MinerPayoutSpend links a miner payout of the wallet to the transaction
+	// which spent it.
+	MinerPayoutSpend struct {
+		PayoutID     types.SiacoinOutputID `json:"payoutid"`
+		PayoutHeight types.BlockHeight     `json:"payoutheight"`
+		Value        types.Currency        `json:"value"`
+
+		SpendTransactionID types.TransactionID `json:"spendtransactionid"`
+		SpendHeight        types.BlockHeight   `json:"spendheight"`
+	}
 )
 
 // AddressTransactions returns all of the wallet transactions associated with a
@@ -23,113 +146,1142 @@ func (w *Wallet) AddressTransactions(uh types.UnlockHash) (pts []modules.Process
 		return []modules.ProcessedTransaction{}, err
 	}
 	defer w.tg.Done()
-	// ensure durability of reported transactions
+	// ensure durability of reported transactions
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return
+	}
+
+	txnIndices, _ := dbGetAddrTransactions(w.dbTx, uh)
+	for _, i := range txnIndices {
+		pt, err := dbGetProcessedTransaction(w.dbTx, i)
+		if err != nil {
+			continue
+		}
+		pts = append(pts, pt)
+	}
+	return pts, nil
+}
+
+// FirstTransaction returns the earliest confirmed transaction associated with
+// an unlock hash. Since transactions are appended to the index of an address
+// in the order they are processed, the first transaction of the index is the
+// earliest one. If the address has no transactions, found is false.
+func (w *Wallet) FirstTransaction(uh types.UnlockHash) (pt modules.ProcessedTransaction, found bool, err error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.ProcessedTransaction{}, false, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return
+	}
+
+	txnIndices, err := dbGetAddrTransactions(w.dbTx, uh)
+	if err == errNoKey {
+		return modules.ProcessedTransaction{}, false, nil
+	} else if err != nil {
+		return modules.ProcessedTransaction{}, false, err
+	}
+	for _, i := range txnIndices {
+		pt, err := dbGetProcessedTransaction(w.dbTx, i)
+		if err != nil {
+			continue // transaction was reverted
+		}
+		return pt, true, nil
+	}
+	return modules.ProcessedTransaction{}, false, nil
+}
+
+// AddressUnconfirmedTransactions returns all of the unconfirmed wallet transactions
+// related to a specific address.
+func (w *Wallet) AddressUnconfirmedTransactions(uh types.UnlockHash) (pts []modules.ProcessedTransaction, err error) {
+	if err := w.tg.Add(); err != nil {
+		return []modules.ProcessedTransaction{}, err
+	}
+	defer w.tg.Done()
+	// ensure durability of reported transactions
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return
+	}
+
+	// Scan the full list of unconfirmed transactions to see if there are any
+	// related transactions.
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		relevant := false
+		for _, input := range pt.Inputs {
+			if input.RelatedAddress == uh {
+				relevant = true
+				break
+			}
+		}
+		for _, output := range pt.Outputs {
+			if output.RelatedAddress == uh {
+				relevant = true
+				break
+			}
+		}
+		if relevant {
+			pts = append(pts, pt)
+		}
+	}
+	return pts, err
+}
+
+// Transaction returns the transaction with the given id. 'False' is returned
+// if the transaction does not exist.
+func (w *Wallet) Transaction(txid types.TransactionID) (pt modules.ProcessedTransaction, found bool, err error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.ProcessedTransaction{}, false, err
+	}
+	defer w.tg.Done()
+	// ensure durability of reported transaction
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return
+	}
+
+	// Get the keyBytes for the given txid
+	keyBytes, err := dbGetTransactionIndex(w.dbTx, txid)
+	if err != nil {
+		for _, txn := range w.unconfirmedProcessedTransactions {
+			if txn.TransactionID == txid {
+				return txn, true, nil
+			}
+		}
+		return modules.ProcessedTransaction{}, false, nil
+	}
+
+	// Retrieve the transaction
+	found = encoding.Unmarshal(w.dbTx.Bucket(bucketProcessedTransactions).Get(keyBytes), &pt) == nil
+	return
+}
+
+// RawTransaction returns the encoded transaction with the given id. Both
+// confirmed and unconfirmed transactions are searched. The bool indicates
+// whether the transaction is known to the wallet.
+func (w *Wallet) RawTransaction(txid types.TransactionID) ([]byte, bool, error) {
+	pt, found, err := w.Transaction(txid)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	return encoding.Marshal(pt.Transaction), true, nil
+}
+
+// TransactionsForOutput returns the processed transactions which created and
+// spent the siacoin output with the given id. Both confirmed and unconfirmed
+// transactions are searched. If no relevant transaction was found, the
+// corresponding return value is nil. If the output is not relevant to the
+// wallet, both return values are nil.
+func (w *Wallet) TransactionsForOutput(id types.SiacoinOutputID) (created, spent *modules.ProcessedTransaction, err error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, nil, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return
+	}
+
+	oid := types.OutputID(id)
+	check := func(pt modules.ProcessedTransaction) {
+		for _, output := range pt.Outputs {
+			if created == nil && output.ID == oid {
+				created = &pt
+				break
+			}
+		}
+		for _, input := range pt.Inputs {
+			if spent == nil && input.ParentID == oid {
+				spent = &pt
+				break
+			}
+		}
+	}
+	it := dbProcessedTransactionsIterator(w.dbTx)
+	for it.next() && (created == nil || spent == nil) {
+		check(it.value())
+	}
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		if created != nil && spent != nil {
+			break
+		}
+		check(pt)
+	}
+	return created, spent, nil
+}
+
+// OutputHistory returns the lifecycle of the siacoin output with the provided
+// id. Only confirmed transactions are considered. If the wallet never received
+// the output, false is returned.
+func (w *Wallet) OutputHistory(id types.SiacoinOutputID) (oh OutputHistory, found bool, err error) {
+	if err := w.tg.Add(); err != nil {
+		return OutputHistory{}, false, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return OutputHistory{}, false, err
+	}
+
+	oid := types.OutputID(id)
+	oh.ID = id
+	it := dbProcessedTransactionsIterator(w.dbTx)
+	for it.next() && !(found && oh.Spent) {
+		pt := it.value()
+		for _, po := range pt.Outputs {
+			if !found && po.ID == oid && po.WalletAddress && po.FundType != types.SpecifierSiafundOutput {
+				oh.UnlockHash = po.RelatedAddress
+				oh.Value = po.Value
+				oh.CreatedBy = pt.TransactionID
+				oh.CreationHeight = pt.ConfirmationHeight
+				found = true
+				break
+			}
+		}
+		for _, pi := range pt.Inputs {
+			if !oh.Spent && pi.ParentID == oid && pi.FundType == types.SpecifierSiacoinInput {
+				oh.Spent = true
+				oh.SpentBy = pt.TransactionID
+				oh.SpendHeight = pt.ConfirmationHeight
+				break
+			}
+		}
+	}
+	if !found {
+		return OutputHistory{}, false, nil
+	}
+	return oh, true, nil
+}
+
+// SetTransactionReference tags the transaction with the given id with an
+// external reference, e.g. an invoice id. A reference can be used for multiple
+// transactions and a transaction can have multiple references. The
+// transaction doesn't need to be known to the wallet yet.
+func (w *Wallet) SetTransactionReference(txid types.TransactionID, ref string) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	if ref == "" {
+		return errEmptyTransactionReference
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	txids, err := dbGetTransactionReferences(w.dbTx, ref)
+	if err != nil && err != errNoKey {
+		return err
+	}
+	for _, id := range txids {
+		if id == txid {
+			return nil // already tagged
+		}
+	}
+	if err := dbPutTransactionReferences(w.dbTx, ref, append(txids, txid)); err != nil {
+		return err
+	}
+	return w.syncDB()
+}
+
+// RemoveTransactionReference removes the reference from the transaction with
+// the given id. Removing a reference which wasn't set is a no-op.
+func (w *Wallet) RemoveTransactionReference(txid types.TransactionID, ref string) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	txids, err := dbGetTransactionReferences(w.dbTx, ref)
+	if err == errNoKey {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	remaining := txids[:0]
+	for _, id := range txids {
+		if id != txid {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == 0 {
+		err = dbDeleteTransactionReferences(w.dbTx, ref)
+	} else {
+		err = dbPutTransactionReferences(w.dbTx, ref, remaining)
+	}
+	if err != nil {
+		return err
+	}
+	return w.syncDB()
+}
+
+// TransactionsByReference returns the transactions tagged with the given
+// reference in the order they were tagged. Both confirmed and unconfirmed
+// transactions are returned. Tagged transactions which are not known to the
+// wallet are skipped.
+func (w *Wallet) TransactionsByReference(ref string) ([]modules.ProcessedTransaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return nil, err
+	}
+
+	txids, err := dbGetTransactionReferences(w.dbTx, ref)
+	if err == errNoKey {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	unconfirmed := make(map[types.TransactionID]modules.ProcessedTransaction, len(w.unconfirmedProcessedTransactions))
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		unconfirmed[pt.TransactionID] = pt
+	}
+	var pts []modules.ProcessedTransaction
+	for _, txid := range txids {
+		keyBytes, err := dbGetTransactionIndex(w.dbTx, txid)
+		if err == errNoKey {
+			if pt, exists := unconfirmed[txid]; exists {
+				pts = append(pts, pt)
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var pt modules.ProcessedTransaction
+		err = encoding.Unmarshal(w.dbTx.Bucket(bucketProcessedTransactions).Get(keyBytes), &pt)
+		if err != nil {
+			return nil, err
+		}
+		pts = append(pts, pt)
+	}
+	return pts, nil
+}
+
+// TransactionInputSources returns the source of every input of the transaction
+// with the given id in the order of the inputs. Both confirmed and unconfirmed
+// transactions are searched. If the transaction is not known to the wallet,
+// false is returned.
+func (w *Wallet) TransactionInputSources(txid types.TransactionID) (sources []InputSource, found bool, err error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, false, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return nil, false, err
+	}
+
+	// Find the transaction.
+	var pt modules.ProcessedTransaction
+	keyBytes, err := dbGetTransactionIndex(w.dbTx, txid)
+	if err == nil {
+		err = encoding.Unmarshal(w.dbTx.Bucket(bucketProcessedTransactions).Get(keyBytes), &pt)
+		if err != nil {
+			return nil, false, err
+		}
+		found = true
+	} else if err != errNoKey {
+		return nil, false, err
+	}
+	for i := 0; !found && i < len(w.unconfirmedProcessedTransactions); i++ {
+		if w.unconfirmedProcessedTransactions[i].TransactionID == txid {
+			pt = w.unconfirmedProcessedTransactions[i]
+			found = true
+		}
+	}
+	if !found {
+		return nil, false, nil
+	}
+
+	// Search the transactions which created the spent outputs.
+	sources = make([]InputSource, len(pt.Inputs))
+	pending := make(map[types.OutputID][]int)
+	for i, pi := range pt.Inputs {
+		sources[i] = InputSource{
+			ParentID: pi.ParentID,
+			FundType: pi.FundType,
+		}
+		pending[pi.ParentID] = append(pending[pi.ParentID], i)
+	}
+	check := func(source modules.ProcessedTransaction) {
+		for _, po := range source.Outputs {
+			if po.FundType == types.SpecifierMinerFee {
+				continue // miner fees can't be spent
+			}
+			for _, i := range pending[po.ID] {
+				sources[i].Known = true
+				sources[i].CreatedBy = source.TransactionID
+			}
+			delete(pending, po.ID)
+		}
+	}
+	it := dbProcessedTransactionsIterator(w.dbTx)
+	for len(pending) > 0 && it.next() {
+		check(it.value())
+	}
+	for i := 0; len(pending) > 0 && i < len(w.unconfirmedProcessedTransactions); i++ {
+		check(w.unconfirmedProcessedTransactions[i])
+	}
+	return sources, true, nil
+}
+
+// TransactionStatuses returns the confirmation status of each of the provided
+// transactions. The returned statuses are in the same order as the txids.
+// Unlike calling Transaction for every txid, the statuses are computed while
+// holding the wallet's lock once.
+func (w *Wallet) TransactionStatuses(txids []types.TransactionID) ([]TransactionStatus, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return nil, err
+	}
+
+	unconfirmed := make(map[types.TransactionID]struct{}, len(w.unconfirmedProcessedTransactions))
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		unconfirmed[pt.TransactionID] = struct{}{}
+	}
+	statuses := make([]TransactionStatus, len(txids))
+	for i, txid := range txids {
+		keyBytes, err := dbGetTransactionIndex(w.dbTx, txid)
+		if err == nil {
+			var pt modules.ProcessedTransaction
+			err = encoding.Unmarshal(w.dbTx.Bucket(bucketProcessedTransactions).Get(keyBytes), &pt)
+			if err != nil {
+				return nil, err
+			}
+			statuses[i] = TransactionStatus{
+				Status:             TransactionStatusConfirmed,
+				ConfirmationHeight: pt.ConfirmationHeight,
+			}
+			continue
+		} else if err != errNoKey {
+			return nil, err
+		}
+		if _, ok := unconfirmed[txid]; ok {
+			statuses[i].Status = TransactionStatusUnconfirmed
+		}
+	}
+	return statuses, nil
+}
+
+// TransactionsForContracts returns the confirmed processed transactions which
+// create, revise or submit a storage proof for any of the provided file
+// contracts. The transactions are grouped by contract id. Contracts without
+// transactions are omitted from the result.
+func (w *Wallet) TransactionsForContracts(fcids []types.FileContractID) (map[types.FileContractID][]modules.ProcessedTransaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return nil, err
+	}
+
+	requested := make(map[types.FileContractID]struct{}, len(fcids))
+	for _, fcid := range fcids {
+		requested[fcid] = struct{}{}
+	}
+	txns := make(map[types.FileContractID][]modules.ProcessedTransaction)
+	it := dbProcessedTransactionsIterator(w.dbTx)
+	for it.next() {
+		pt := it.value()
+
+		// Collect the contracts touched by the transaction. A transaction is
+		// only added once per contract.
+		touched := make(map[types.FileContractID]struct{})
+		for i := range pt.Transaction.FileContracts {
+			touched[pt.Transaction.FileContractID(uint64(i))] = struct{}{}
+		}
+		for _, fcr := range pt.Transaction.FileContractRevisions {
+			touched[fcr.ParentID] = struct{}{}
+		}
+		for _, sp := range pt.Transaction.StorageProofs {
+			touched[sp.ParentID] = struct{}{}
+		}
+		for fcid := range touched {
+			if _, ok := requested[fcid]; ok {
+				txns[fcid] = append(txns[fcid], pt)
+			}
+		}
+	}
+	return txns, nil
+}
+
+// RebuildTransactionSequence repairs the sequence of the processed
+// transactions bucket if it doesn't match the largest key within the bucket
+// anymore, e.g. after an interrupted write. Since the sequence is used to
+// search the processed transactions, a corrupted sequence breaks methods like
+// Transactions. If all transactions were pruned, the sequence of the empty
+// bucket is kept. The sequence before and after the repair is returned. If the
+// sequence was correct, both values are equal and nothing is changed.
+func (w *Wallet) RebuildTransactionSequence() (oldSeq, newSeq uint64, err error) {
+	if err := w.tg.Add(); err != nil {
+		return 0, 0, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return 0, 0, err
+	}
+	oldSeq, newSeq, err = dbRepairProcessedTransactionSequence(w.dbTx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if oldSeq == newSeq {
+		return oldSeq, newSeq, nil
+	}
+	w.log.Printf("INFO: repaired processed transactions sequence from %v to %v", oldSeq, newSeq)
+	return oldSeq, newSeq, w.syncDB()
+}
+
+// TransactionIndex returns the index of a confirmed transaction within the
+// wallet's history of processed transactions. The index is stable as long as
+// the transaction isn't reverted and can be used as a cursor for pagination.
+// If the transaction is unconfirmed or unknown, found is false.
+func (w *Wallet) TransactionIndex(txid types.TransactionID) (index uint64, found bool, err error) {
+	if err := w.tg.Add(); err != nil {
+		return 0, false, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return
+	}
+
+	keyBytes, err := dbGetTransactionIndex(w.dbTx, txid)
+	if err == errNoKey {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return binary.BigEndian.Uint64(keyBytes), true, nil
+}
+
+// Transactions returns all transactions relevant to the wallet that were
+// confirmed in the range [startHeight, endHeight].
+func (w *Wallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []modules.ProcessedTransaction, err error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	// There may be transactions which haven't been saved / committed yet. Sync
+	// the database to ensure that any information which gets reported to the
+	// user will be persisted through a restart.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return nil, err
+	}
+	err = w.forEachProcessedTransaction(startHeight, endHeight, func(pt modules.ProcessedTransaction) error {
+		pts = append(pts, pt)
+		return nil
+	})
+	return pts, err
+}
+
+// BlockTransactions returns the valued transactions relevant to the wallet
+// that were confirmed in the block at the provided height. An empty slice is
+// returned if the block doesn't contain any transactions relevant to the
+// wallet.
+func (w *Wallet) BlockTransactions(height types.BlockHeight) ([]modules.ValuedTransaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	if err := w.syncDB(); err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	pts := []modules.ProcessedTransaction{}
+	err := w.forEachProcessedTransaction(height, height, func(pt modules.ProcessedTransaction) error {
+		pts = append(pts, pt)
+		return nil
+	})
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	consensusHeight, err := dbGetConsensusHeight(w.dbTx)
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return ComputeValuedTransactions(pts, consensusHeight)
+}
+
+// VisitTransactions calls fn for every transaction relevant to the wallet that
+// was confirmed in the range [startHeight, endHeight] in ascending order of
+// confirmation height without loading all of them into memory at once. The
+// walk stops at the first error returned by fn which is then returned. fn is
+// called while the wallet is locked and must not call any wallet methods.
+func (w *Wallet) VisitTransactions(startHeight, endHeight types.BlockHeight, fn func(modules.ProcessedTransaction) error) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return err
+	}
+	return w.forEachProcessedTransaction(startHeight, endHeight, fn)
+}
+
+// TransactionsDesc is like Transactions but returns the transactions in
+// descending order of confirmation height, newest first.
+func (w *Wallet) TransactionsDesc(startHeight, endHeight types.BlockHeight) (pts []modules.ProcessedTransaction, err error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return nil, err
+	}
+	err = w.forEachProcessedTransactionDesc(startHeight, endHeight, func(pt modules.ProcessedTransaction) error {
+		pts = append(pts, pt)
+		return nil
+	})
+	return pts, err
+}
+
+// VisitTransactionsDesc is like VisitTransactions but visits the transactions
+// in descending order of confirmation height, newest first. That allows for
+// stopping the walk once enough recent transactions were visited.
+func (w *Wallet) VisitTransactionsDesc(startHeight, endHeight types.BlockHeight, fn func(modules.ProcessedTransaction) error) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return err
+	}
+	return w.forEachProcessedTransactionDesc(startHeight, endHeight, fn)
+}
+
+// RecentTransactions returns the n most recently confirmed transactions
+// relevant to the wallet, newest first. Only the returned transactions are
+// decoded which makes it cheaper than fetching the whole history.
+func (w *Wallet) RecentTransactions(n int) ([]modules.ValuedTransaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	if n <= 0 {
+		return nil, nil
+	}
+
+	w.mu.Lock()
+	if err := w.syncDB(); err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	var pts []modules.ProcessedTransaction
+	cursor := w.dbTx.Bucket(bucketProcessedTransactions).Cursor()
+	for key, ptBytes := cursor.Last(); key != nil && len(pts) < n; key, ptBytes = cursor.Prev() {
+		var pt modules.ProcessedTransaction
+		if err := decodeProcessedTransaction(ptBytes, &pt); err != nil {
+			w.mu.Unlock()
+			return nil, err
+		}
+		pts = append(pts, pt)
+	}
+	height, err := dbGetConsensusHeight(w.dbTx)
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return ComputeValuedTransactions(pts, height)
+}
+
+// TransactionsDiffRanges compares the transactions confirmed in the range
+// [aStart, aEnd] with the ones confirmed in the range [bStart, bEnd]. added
+// contains the ids of the transactions only found in range B and removed the
+// ids of the transactions only found in range A. Both are ordered by
+// confirmation height. Comparing overlapping ranges before and after a reorg
+// reveals the transactions affected by it.
+func (w *Wallet) TransactionsDiffRanges(aStart, aEnd, bStart, bEnd types.BlockHeight) (added, removed []types.TransactionID, err error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, nil, err
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return nil, nil, err
+	}
+	txidsInRange := func(start, end types.BlockHeight) (txids []types.TransactionID, err error) {
+		err = w.forEachProcessedTransaction(start, end, func(pt modules.ProcessedTransaction) error {
+			txids = append(txids, pt.TransactionID)
+			return nil
+		})
+		return txids, err
+	}
+	a, err := txidsInRange(aStart, aEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := txidsInRange(bStart, bEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inA := make(map[types.TransactionID]struct{}, len(a))
+	for _, txid := range a {
+		inA[txid] = struct{}{}
+	}
+	inB := make(map[types.TransactionID]struct{}, len(b))
+	for _, txid := range b {
+		inB[txid] = struct{}{}
+		if _, ok := inA[txid]; !ok {
+			added = append(added, txid)
+		}
+	}
+	for _, txid := range a {
+		if _, ok := inB[txid]; !ok {
+			removed = append(removed, txid)
+		}
+	}
+	return added, removed, nil
+}
+
+// ZeroValueTransactions returns the transactions relevant to the wallet that
+// were confirmed in the range [startHeight, endHeight] and neither add value to
+// nor remove value from the wallet. These are transactions which were recorded
+// because they reference a wallet address without moving any value, e.g.
+// outdated contract revisions.
+func (w *Wallet) ZeroValueTransactions(startHeight, endHeight types.BlockHeight) ([]modules.ValuedTransaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	vts, err := w.managedValuedTransactions(startHeight, endHeight)
+	if err != nil {
+		return nil, err
+	}
+	var zvts []modules.ValuedTransaction
+	for _, vt := range vts {
+		if vt.ConfirmedIncomingValue.IsZero() && vt.ConfirmedOutgoingValue.IsZero() {
+			zvts = append(zvts, vt)
+		}
+	}
+	return zvts, nil
+}
+
+// TransactionsByCounterparty returns the transactions confirmed in the range
+// [startHeight, endHeight] grouped by the addresses outside of the wallet they
+// sent coins to or received coins from. A transaction involving multiple
+// counterparties is returned for each of them. Miner fees don't have a
+// counterparty.
+func (w *Wallet) TransactionsByCounterparty(startHeight, endHeight types.BlockHeight) (map[types.UnlockHash][]modules.ValuedTransaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	vts, err := w.managedValuedTransactions(startHeight, endHeight)
+	if err != nil {
+		return nil, err
+	}
+	byCounterparty := make(map[types.UnlockHash][]modules.ValuedTransaction)
+	for _, vt := range vts {
+		counterparties := make(map[types.UnlockHash]struct{})
+		for _, pi := range vt.Inputs {
+			if !pi.WalletAddress {
+				counterparties[pi.RelatedAddress] = struct{}{}
+			}
+		}
+		for _, po := range vt.Outputs {
+			if !po.WalletAddress && po.FundType != types.SpecifierMinerFee {
+				counterparties[po.RelatedAddress] = struct{}{}
+			}
+		}
+		for uh := range counterparties {
+			byCounterparty[uh] = append(byCounterparty[uh], vt)
+		}
+	}
+	return byCounterparty, nil
+}
+
+// managedValuedTransactions returns the valued transactions confirmed in the
+// range [startHeight, endHeight]. The values are computed after releasing the
+// lock.
+func (w *Wallet) managedValuedTransactions(startHeight, endHeight types.BlockHeight) ([]modules.ValuedTransaction, error) {
+	w.mu.Lock()
+	if err := w.syncDB(); err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	var pts []modules.ProcessedTransaction
+	err := w.forEachProcessedTransaction(startHeight, endHeight, func(pt modules.ProcessedTransaction) error {
+		pts = append(pts, pt)
+		return nil
+	})
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	height, err := dbGetConsensusHeight(w.dbTx)
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return ComputeValuedTransactions(pts, height)
+}
+
+// RecentlyConfirmed returns the transactions relevant to the wallet that were
+// confirmed at or after the time 'since'. The timestamp is mapped to the
+// height of the first block with a timestamp at or after 'since' using a
+// binary search over the block timestamps which avoids scanning the whole
+// history. Since block timestamps are only roughly increasing, transactions
+// close to the boundary might be included or excluded even though their
+// block's timestamp suggests otherwise.
+func (w *Wallet) RecentlyConfirmed(since time.Time) (pts []modules.ProcessedTransaction, err error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	// Nothing can have been confirmed in the future.
+	if since.After(time.Now()) {
+		return nil, nil
+	}
+
+	// Map the timestamp to a height. This happens before acquiring the
+	// wallet's lock since the consensus set might be waiting for the wallet
+	// to process a change.
+	ts := types.Timestamp(since.Unix())
+	if since.Nanosecond() > 0 {
+		ts++ // round up to avoid including blocks from before 'since'
+	}
+	csHeight := w.cs.Height()
+	startHeight := types.BlockHeight(sort.Search(int(csHeight)+1, func(i int) bool {
+		b, exists := w.cs.BlockAtHeight(types.BlockHeight(i))
+		return !exists || b.Timestamp >= ts
+	}))
+	if startHeight > csHeight {
+		return nil, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return nil, err
+	}
+	err = w.forEachProcessedTransaction(startHeight, math.MaxUint64, func(pt modules.ProcessedTransaction) error {
+		pts = append(pts, pt)
+		return nil
+	})
+	if err == errOutOfBounds {
+		// The wallet hasn't processed the blocks after startHeight yet.
+		return nil, nil
+	}
+	return pts, err
+}
+
+// MinerPayouts returns the miner payouts to the wallet's addresses which have
+// matured if matured is true or which haven't matured yet otherwise. A payout
+// has matured once its confirmation height plus the maturity delay is less
+// than or equal to the wallet's current height.
+func (w *Wallet) MinerPayouts(matured bool) (payouts []MinerPayout, err error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if err = w.syncDB(); err != nil {
-		return
+		return nil, err
 	}
-
-	txnIndices, _ := dbGetAddrTransactions(w.dbTx, uh)
-	for _, i := range txnIndices {
-		pt, err := dbGetProcessedTransaction(w.dbTx, i)
-		if err != nil {
-			continue
-		}
-		pts = append(pts, pt)
+	height, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return nil, err
 	}
-	return pts, nil
+	err = w.forEachProcessedTransaction(0, height, func(pt modules.ProcessedTransaction) error {
+		for _, po := range pt.Outputs {
+			if po.FundType != types.SpecifierMinerPayout || !po.WalletAddress {
+				continue
+			}
+			if (po.MaturityHeight <= height) != matured {
+				continue
+			}
+			payouts = append(payouts, MinerPayout{
+				ID:                 types.SiacoinOutputID(po.ID),
+				TransactionID:      pt.TransactionID,
+				UnlockHash:         po.RelatedAddress,
+				Value:              po.Value,
+				ConfirmationHeight: pt.ConfirmationHeight,
+				MaturityHeight:     po.MaturityHeight,
+			})
+		}
+		return nil
+	})
+	return payouts, err
 }
 
-// AddressUnconfirmedTransactions returns all of the unconfirmed wallet transactions
-// related to a specific address.
-func (w *Wallet) AddressUnconfirmedTransactions(uh types.UnlockHash) (pts []modules.ProcessedTransaction, err error) {
+// ChangeOutputs returns the siacoin outputs the wallet created as change in
+// transactions confirmed in the range [startHeight, endHeight]. An output is
+// considered change if it belongs to the wallet and the transaction creating
+// it also spent siacoin inputs of the wallet. Outputs of transactions without
+// wallet inputs are deposits and are not returned.
+//
+// This is a heuristic. The wallet doesn't record why an output was created,
+// so payments to the wallet's own addresses, e.g. when a transaction is split
+// to create an output with an exact value, are reported as change too.
+// Transactions which combine inputs of multiple parties might also send
+// outputs to the wallet which are reported as change even though they are
+// deposits.
+func (w *Wallet) ChangeOutputs(startHeight, endHeight types.BlockHeight) (outputs []ChangeOutput, err error) {
 	if err := w.tg.Add(); err != nil {
-		return []modules.ProcessedTransaction{}, err
+		return nil, err
 	}
 	defer w.tg.Done()
-	// ensure durability of reported transactions
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if err = w.syncDB(); err != nil {
-		return
+		return nil, err
 	}
-
-	// Scan the full list of unconfirmed transactions to see if there are any
-	// related transactions.
-	for _, pt := range w.unconfirmedProcessedTransactions {
-		relevant := false
-		for _, input := range pt.Inputs {
-			if input.RelatedAddress == uh {
-				relevant = true
+	err = w.forEachProcessedTransaction(startHeight, endHeight, func(pt modules.ProcessedTransaction) error {
+		spendsWalletInputs := false
+		for _, pi := range pt.Inputs {
+			if pi.FundType == types.SpecifierSiacoinInput && pi.WalletAddress {
+				spendsWalletInputs = true
 				break
 			}
 		}
-		for _, output := range pt.Outputs {
-			if output.RelatedAddress == uh {
-				relevant = true
+		if !spendsWalletInputs {
+			return nil
+		}
+		for _, po := range pt.Outputs {
+			if po.FundType != types.SpecifierSiacoinOutput || !po.WalletAddress {
+				continue
+			}
+			outputs = append(outputs, ChangeOutput{
+				ID:                 types.SiacoinOutputID(po.ID),
+				TransactionID:      pt.TransactionID,
+				UnlockHash:         po.RelatedAddress,
+				Value:              po.Value,
+				ConfirmationHeight: pt.ConfirmationHeight,
+			})
+		}
+		return nil
+	})
+	return outputs, err
+}
+
+// FeesPaid returns the sum of the miner fees of the transactions confirmed in
+// the range [startHeight, endHeight] which spent siacoin inputs of the wallet.
+// Fees of transactions which were only received by the wallet are paid by the
+// sender and are not included.
+func (w *Wallet) FeesPaid(startHeight, endHeight types.BlockHeight) (fees types.Currency, err error) {
+	if err := w.tg.Add(); err != nil {
+		return types.ZeroCurrency, err
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err = w.syncDB(); err != nil {
+		return types.ZeroCurrency, err
+	}
+	err = w.forEachProcessedTransaction(startHeight, endHeight, func(pt modules.ProcessedTransaction) error {
+		spendsWalletInputs := false
+		for _, pi := range pt.Inputs {
+			if pi.FundType == types.SpecifierSiacoinInput && pi.WalletAddress {
+				spendsWalletInputs = true
 				break
 			}
 		}
-		if relevant {
-			pts = append(pts, pt)
+		if !spendsWalletInputs {
+			return nil
 		}
-	}
-	return pts, err
+		for _, po := range pt.Outputs {
+			if po.FundType == types.SpecifierMinerFee {
+				fees = fees.Add(po.Value)
+			}
+		}
+		return nil
+	})
+	return fees, err
 }
 
-// Transaction returns the transaction with the given id. 'False' is returned
-// if the transaction does not exist.
-func (w *Wallet) Transaction(txid types.TransactionID) (pt modules.ProcessedTransaction, found bool, err error) {
+// MinerPayoutSpends returns the miner payouts of the wallet which were spent by
+// transactions confirmed in the range [startHeight, endHeight]. The payouts
+// themselves might have been created before startHeight. Payouts whose
+// processed transaction was pruned from the history can't be linked to their
+// spends anymore.
+func (w *Wallet) MinerPayoutSpends(startHeight, endHeight types.BlockHeight) (spends []MinerPayoutSpend, err error) {
 	if err := w.tg.Add(); err != nil {
-		return modules.ProcessedTransaction{}, false, err
+		return nil, err
 	}
 	defer w.tg.Done()
-	// ensure durability of reported transaction
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if err = w.syncDB(); err != nil {
-		return
+		return nil, err
+	}
+	if startHeight > endHeight {
+		return nil, errOutOfBounds
 	}
 
-	// Get the keyBytes for the given txid
-	keyBytes, err := dbGetTransactionIndex(w.dbTx, txid)
-	if err != nil {
-		for _, txn := range w.unconfirmedProcessedTransactions {
-			if txn.TransactionID == txid {
-				return txn, true, nil
+	// Track the wallet's payouts up to endHeight and match them against the
+	// inputs of the transactions within the range. Payouts always mature
+	// before they can be spent, so they are seen before their spends.
+	payouts := make(map[types.OutputID]MinerPayoutSpend)
+	err = w.forEachProcessedTransaction(0, endHeight, func(pt modules.ProcessedTransaction) error {
+		for _, po := range pt.Outputs {
+			if po.FundType == types.SpecifierMinerPayout && po.WalletAddress {
+				payouts[po.ID] = MinerPayoutSpend{
+					PayoutID:     types.SiacoinOutputID(po.ID),
+					PayoutHeight: pt.ConfirmationHeight,
+					Value:        po.Value,
+				}
 			}
 		}
-		return modules.ProcessedTransaction{}, false, nil
-	}
-
-	// Retrieve the transaction
-	found = encoding.Unmarshal(w.dbTx.Bucket(bucketProcessedTransactions).Get(keyBytes), &pt) == nil
-	return
+		if pt.ConfirmationHeight < startHeight {
+			return nil
+		}
+		for _, pi := range pt.Inputs {
+			spend, ok := payouts[pi.ParentID]
+			if !ok || pi.FundType != types.SpecifierSiacoinInput {
+				continue
+			}
+			spend.SpendTransactionID = pt.TransactionID
+			spend.SpendHeight = pt.ConfirmationHeight
+			spends = append(spends, spend)
+		}
+		return nil
+	})
+	return spends, err
 }
 
-// Transactions returns all transactions relevant to the wallet that were
-// confirmed in the range [startHeight, endHeight].
-func (w *Wallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []modules.ProcessedTransaction, err error) {
+// SiafundClaimTransactions returns the transactions confirmed in the range
+// [startHeight, endHeight] which sent siafunds to the wallet or paid out
+// siafund claims to the wallet. The claim income is reported separately from
+// the regular siacoin flow of the transactions.
+func (w *Wallet) SiafundClaimTransactions(startHeight, endHeight types.BlockHeight) (txns []SiafundClaimTransaction, err error) {
 	if err := w.tg.Add(); err != nil {
 		return nil, err
 	}
 	defer w.tg.Done()
 
-	// There may be transactions which haven't been saved / committed yet. Sync
-	// the database to ensure that any information which gets reported to the
-	// user will be persisted through a restart.
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if err = w.syncDB(); err != nil {
 		return nil, err
 	}
+	err = w.forEachProcessedTransaction(startHeight, endHeight, func(pt modules.ProcessedTransaction) error {
+		sct := SiafundClaimTransaction{Transaction: pt}
+		relevant := false
+		for _, po := range pt.Outputs {
+			if !po.WalletAddress {
+				continue
+			}
+			switch po.FundType {
+			case types.SpecifierSiafundOutput:
+				sct.SiafundsReceived = sct.SiafundsReceived.Add(po.Value)
+				relevant = true
+			case types.SpecifierClaimOutput:
+				sct.ClaimValue = sct.ClaimValue.Add(po.Value)
+				relevant = true
+			}
+		}
+		if relevant {
+			txns = append(txns, sct)
+		}
+		return nil
+	})
+	return txns, err
+}
+
+// newTransactionSearchPanicError creates a TransactionSearchPanicError from a
+// recovered value. The stack is only captured in debug builds.
+func newTransactionSearchPanicError(r interface{}) *TransactionSearchPanicError {
+	err := &TransactionSearchPanicError{Value: r}
+	if build.DEBUG {
+		err.Stack = debug.Stack()
+	}
+	return err
+}
+
+// Error implements the error interface.
+func (err *TransactionSearchPanicError) Error() string {
+	msg := fmt.Sprintf("panic while searching transactions: %T: %v", err.Value, err.Value)
+	if len(err.Stack) > 0 {
+		msg += "\n" + string(err.Stack)
+	}
+	return msg
+}
+
+// Unwrap returns the value the search panicked with if it is an error.
+func (err *TransactionSearchPanicError) Unwrap() error {
+	e, _ := err.Value.(error)
+	return e
+}
+
+// newErrUnsortedTransactions creates an ErrUnsortedTransactions for a
+// transaction which is out of order. Since this indicates a corrupted
+// database, it is also a critical.
+func (w *Wallet) newErrUnsortedTransactions(pt modules.ProcessedTransaction) *ErrUnsortedTransactions {
+	err := &ErrUnsortedTransactions{
+		TransactionID:      pt.TransactionID,
+		ConfirmationHeight: pt.ConfirmationHeight,
+	}
+	if !w.deps.Disrupt("DisableUnsortedTransactionsCritical") {
+		build.Critical(err)
+	}
+	return err
+}
+
+// Error implements the error interface.
+func (err *ErrUnsortedTransactions) Error() string {
+	return fmt.Sprintf("wallet processed transactions are not sorted: transaction %v confirmed at height %v is out of order", err.TransactionID, err.ConfirmationHeight)
+}
 
+// forEachProcessedTransaction calls fn for every processed transaction that
+// was confirmed in the range [startHeight, endHeight]. It stops at the first
+// error returned by fn.
+func (w *Wallet) forEachProcessedTransaction(startHeight, endHeight types.BlockHeight, fn func(modules.ProcessedTransaction) error) (err error) {
 	height, err := dbGetConsensusHeight(w.dbTx)
 	if err != nil {
 		return
 	} else if startHeight > height || startHeight > endHeight {
-		return nil, errOutOfBounds
+		return errOutOfBounds
 	}
 
 	// Get the bucket, the largest key in it and the cursor
@@ -137,42 +1289,16 @@ func (w *Wallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []m
 	cursor := bucket.Cursor()
 	nextKey := bucket.Sequence() + 1
 
-	// Database is empty
-	if nextKey == 1 {
+	// Database is empty. The sequence alone isn't enough to tell since
+	// pruned transactions don't reset it.
+	if firstKey, _ := cursor.First(); firstKey == nil {
 		return
 	}
 
-	var pt modules.ProcessedTransaction
-	keyBytes := make([]byte, 8)
-	var result int
-	func() {
-		// Recover from possible panic during binary search
-		defer func() {
-			r := recover()
-			if r != nil {
-				err = fmt.Errorf("%v", r)
-			}
-		}()
-
-		// Start binary searching
-		result = sort.Search(int(nextKey), func(i int) bool {
-			// Create the key for the index
-			binary.BigEndian.PutUint64(keyBytes, uint64(i))
-
-			// Retrieve the processed transaction
-			key, ptBytes := cursor.Seek(keyBytes)
-			if build.DEBUG && key == nil {
-				panic("Failed to retrieve processed Transaction by key")
-			}
-
-			// Decode the transaction
-			if err = decodeProcessedTransaction(ptBytes, &pt); build.DEBUG && err != nil {
-				panic(err)
-			}
-
-			return pt.ConfirmationHeight >= startHeight
-		})
-	}()
+	// Search for the first transaction confirmed at or after startHeight.
+	result, err := w.searchProcessedTransactions(cursor, nextKey, func(pt modules.ProcessedTransaction) bool {
+		return pt.ConfirmationHeight >= startHeight
+	})
 	if err != nil {
 		return
 	}
@@ -183,6 +1309,8 @@ func (w *Wallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []m
 	}
 
 	// Create the key that corresponds to the result of the search
+	var pt modules.ProcessedTransaction
+	keyBytes := make([]byte, 8)
 	binary.BigEndian.PutUint64(keyBytes, uint64(result))
 
 	// Get the processed transaction and decode it
@@ -196,10 +1324,12 @@ func (w *Wallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []m
 
 	// Gather all transactions until endHeight is reached
 	for pt.ConfirmationHeight <= endHeight {
-		if build.DEBUG && pt.ConfirmationHeight < startHeight {
-			build.Critical("wallet processed transactions are not sorted")
+		if pt.ConfirmationHeight < startHeight {
+			return w.newErrUnsortedTransactions(pt)
+		}
+		if err := fn(pt); err != nil {
+			return err
 		}
-		pts = append(pts, pt)
 
 		// Get next processed transaction
 		key, ptBytes := cursor.Next()
@@ -215,8 +1345,120 @@ func (w *Wallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []m
 	return
 }
 
+// forEachProcessedTransactionDesc is like forEachProcessedTransaction but
+// calls fn in descending order of confirmation height. The cursor walks
+// backwards from the last transaction confirmed at or before endHeight.
+func (w *Wallet) forEachProcessedTransactionDesc(startHeight, endHeight types.BlockHeight, fn func(modules.ProcessedTransaction) error) (err error) {
+	height, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return
+	} else if startHeight > height || startHeight > endHeight {
+		return errOutOfBounds
+	}
+
+	// Get the bucket, the largest key in it and the cursor
+	bucket := w.dbTx.Bucket(bucketProcessedTransactions)
+	cursor := bucket.Cursor()
+	nextKey := bucket.Sequence() + 1
+
+	// Database is empty. The sequence alone isn't enough to tell since
+	// pruned transactions don't reset it.
+	if firstKey, _ := cursor.First(); firstKey == nil {
+		return
+	}
+
+	// Search for the first transaction confirmed after endHeight. The one
+	// before it is the last one within the range.
+	result, err := w.searchProcessedTransactions(cursor, nextKey, func(pt modules.ProcessedTransaction) bool {
+		return pt.ConfirmationHeight > endHeight
+	})
+	if err != nil {
+		return
+	}
+
+	if result == 0 {
+		// No transaction was found
+		return
+	}
+
+	// Create the key that corresponds to the result of the search
+	var pt modules.ProcessedTransaction
+	keyBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(keyBytes, uint64(result-1))
+
+	// Get the processed transaction and decode it
+	key, ptBytes := cursor.Seek(keyBytes)
+	if build.DEBUG && key == nil {
+		build.Critical("Couldn't find the processed transaction from the search.")
+	}
+	if err = decodeProcessedTransaction(ptBytes, &pt); build.DEBUG && err != nil {
+		build.Critical(err)
+	}
+
+	// Gather all transactions until startHeight is reached
+	for pt.ConfirmationHeight >= startHeight {
+		if pt.ConfirmationHeight > endHeight {
+			return w.newErrUnsortedTransactions(pt)
+		}
+		if err := fn(pt); err != nil {
+			return err
+		}
+
+		// Get previous processed transaction
+		key, ptBytes := cursor.Prev()
+		if key == nil {
+			break
+		}
+
+		// Decode the transaction
+		if err := decodeProcessedTransaction(ptBytes, &pt); build.DEBUG && err != nil {
+			panic("Failed to decode the processed transaction")
+		}
+	}
+	return
+}
+
+// searchProcessedTransactions binary searches the processed transactions for
+// the smallest key in [0, nextKey) for which f returns true. A panic during
+// the search is returned as a TransactionSearchPanicError.
+func (w *Wallet) searchProcessedTransactions(cursor *bolt.Cursor, nextKey uint64, f func(modules.ProcessedTransaction) bool) (result int, err error) {
+	// Recover from possible panic during binary search
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = newTransactionSearchPanicError(r)
+		}
+	}()
+
+	var pt modules.ProcessedTransaction
+	keyBytes := make([]byte, 8)
+	result = sort.Search(int(nextKey), func(i int) bool {
+		if w.deps.Disrupt("TransactionSearchPanic") {
+			panic("transaction search disrupted")
+		}
+
+		// Create the key for the index
+		binary.BigEndian.PutUint64(keyBytes, uint64(i))
+
+		// Retrieve the processed transaction
+		key, ptBytes := cursor.Seek(keyBytes)
+		if build.DEBUG && key == nil {
+			panic("Failed to retrieve processed Transaction by key")
+		}
+
+		// Decode the transaction
+		if err = decodeProcessedTransaction(ptBytes, &pt); build.DEBUG && err != nil {
+			panic(err)
+		}
+
+		return f(pt)
+	})
+	return result, err
+}
+
 // ComputeValuedTransactions creates ValuedTransaction from a set of
-// ProcessedTransactions.
+// ProcessedTransactions. The confirmation depth of the transactions is computed
+// relative to blockHeight.
 func ComputeValuedTransactions(pts []modules.ProcessedTransaction, blockHeight types.BlockHeight) ([]modules.ValuedTransaction, error) {
 	// Loop over all transactions and map the id of each contract to the most
 	// recent revision within the set.
@@ -249,8 +1491,10 @@ func ComputeValuedTransactions(pts []modules.ProcessedTransaction, blockHeight t
 		// revisions.
 		st := modules.ValuedTransaction{
 			ProcessedTransaction:   pt,
+			Category:               transactionCategory(pt),
 			ConfirmedIncomingValue: incomingSiacoins,
 			ConfirmedOutgoingValue: outgoingSiacoins,
+			ConfirmationDepth:      confirmationDepth(pt, blockHeight),
 		}
 		// If the transaction doesn't contain contracts or revisions we are done.
 		if len(pt.Transaction.FileContracts) == 0 && len(pt.Transaction.FileContractRevisions) == 0 {
@@ -302,6 +1546,35 @@ func ComputeValuedTransactions(pts []modules.ProcessedTransaction, blockHeight t
 	return sts, nil
 }
 
+// confirmationDepth returns the number of blocks on top of the block which
+// confirmed pt at the given height. Unconfirmed transactions and transactions
+// confirmed after blockHeight have a depth of zero.
+func confirmationDepth(pt modules.ProcessedTransaction, blockHeight types.BlockHeight) types.BlockHeight {
+	if pt.ConfirmationHeight == types.BlockHeight(math.MaxUint64) || pt.ConfirmationHeight > blockHeight {
+		return 0
+	}
+	return blockHeight - pt.ConfirmationHeight
+}
+
+// transactionCategory classifies a transaction. A transaction which matches
+// multiple categories is assigned the first matching one in the following
+// order: miner payout to the wallet, contract formation, contract revision.
+// Transactions which don't match any of them are transfers.
+func transactionCategory(pt modules.ProcessedTransaction) modules.TransactionCategory {
+	for _, output := range pt.Outputs {
+		if output.FundType == types.SpecifierMinerPayout && output.WalletAddress {
+			return modules.TxnCategoryMinerPayout
+		}
+	}
+	if len(pt.Transaction.FileContracts) > 0 {
+		return modules.TxnCategoryContractFormation
+	}
+	if len(pt.Transaction.FileContractRevisions) > 0 {
+		return modules.TxnCategoryContractRevision
+	}
+	return modules.TxnCategoryTransfer
+}
+
 // UnconfirmedTransactions returns the set of unconfirmed transactions that are
 // relevant to the wallet.
 func (w *Wallet) UnconfirmedTransactions() ([]modules.ProcessedTransaction, error) {
@@ -313,3 +1586,156 @@ func (w *Wallet) UnconfirmedTransactions() ([]modules.ProcessedTransaction, erro
 	defer w.mu.RUnlock()
 	return w.unconfirmedProcessedTransactions, nil
 }
+
+// EstimateConfirmation estimates the number of blocks until the unconfirmed
+// wallet transaction with the given id is confirmed. Miners are assumed to
+// include the transactions of the transaction pool in order of their fee per
+// byte, so the estimate is derived from the size of the transactions in the
+// pool which pay a higher fee per byte than the wallet transaction. A
+// transaction which is expected to make it into the next block has an estimate
+// of 1.
+func (w *Wallet) EstimateConfirmation(txid types.TransactionID) (int, error) {
+	if err := w.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer w.tg.Done()
+
+	// Fetch the pool before acquiring the wallet's lock since the pool calls
+	// into the wallet while holding its own lock.
+	pool := w.tpool.TransactionList()
+
+	w.mu.RLock()
+	var txn types.Transaction
+	var found bool
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		if pt.TransactionID == txid {
+			txn, found = pt.Transaction, true
+			break
+		}
+	}
+	w.mu.RUnlock()
+	if !found {
+		return 0, errTransactionNotUnconfirmed
+	}
+
+	// Sum up the size of the transactions with a higher fee per byte.
+	// Comparing fee*otherSize to otherFee*size avoids rounding errors.
+	fee := types.ZeroCurrency
+	for _, f := range txn.MinerFees {
+		fee = fee.Add(f)
+	}
+	size := uint64(len(encoding.Marshal(txn)))
+	var bytesAhead uint64
+	for _, other := range pool {
+		if other.ID() == txid {
+			continue
+		}
+		otherFee := types.ZeroCurrency
+		for _, f := range other.MinerFees {
+			otherFee = otherFee.Add(f)
+		}
+		otherSize := uint64(len(encoding.Marshal(other)))
+		if otherFee.Mul64(size).Cmp(fee.Mul64(otherSize)) > 0 {
+			bytesAhead += otherSize
+		}
+	}
+	return int(bytesAhead/confirmationBlockCapacity) + 1, nil
+}
+
+// RebroadcastSet returns the transactions of the wallet's unconfirmed set
+// sorted such that every transaction follows the unconfirmed transactions it
+// spends outputs of. The result can be passed to the transaction pool as is.
+// Transactions which were confirmed already are left out. Their outputs exist
+// on chain, so their descendants remain valid. Transactions spending wallet
+// outputs which were spent by a confirmed transaction in the meantime are
+// dropped since they can never be confirmed. The descendants of dropped
+// transactions are dropped as well.
+func (w *Wallet) RebroadcastSet() ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncDB(); err != nil {
+		return nil, err
+	}
+
+	// Map the outputs created by the unconfirmed transactions to their
+	// creators.
+	txns := w.unconfirmedProcessedTransactions
+	scoCreators := make(map[types.SiacoinOutputID]int)
+	sfoCreators := make(map[types.SiafundOutputID]int)
+	for i, pt := range txns {
+		for j := range pt.Transaction.SiacoinOutputs {
+			scoCreators[pt.Transaction.SiacoinOutputID(uint64(j))] = i
+		}
+		for j := range pt.Transaction.SiafundOutputs {
+			sfoCreators[pt.Transaction.SiafundOutputID(uint64(j))] = i
+		}
+	}
+
+	// Find the transactions which were confirmed already.
+	confirmed := make([]bool, len(txns))
+	for i, pt := range txns {
+		_, err := dbGetTransactionIndex(w.dbTx, pt.TransactionID)
+		confirmed[i] = err == nil
+	}
+
+	// Determine the unconfirmed parents of every transaction and drop the
+	// ones which can't be confirmed anymore. Confirmed parents are satisfied
+	// dependencies.
+	parents := make([][]int, len(txns))
+	dropped := make([]bool, len(txns))
+	outputs := w.dbTx.Bucket(bucketSiacoinOutputs)
+	for i, pt := range txns {
+		if confirmed[i] {
+			continue
+		}
+		for _, sci := range pt.Transaction.SiacoinInputs {
+			if p, exists := scoCreators[sci.ParentID]; exists && !confirmed[p] {
+				parents[i] = append(parents[i], p)
+				continue
+			}
+			// A confirmed wallet output which is no longer in the output set
+			// was spent by a confirmed transaction.
+			if _, exists := w.keys[sci.UnlockConditions.UnlockHash()]; exists && outputs.Get(encoding.Marshal(sci.ParentID)) == nil {
+				dropped[i] = true
+			}
+		}
+		for _, sfi := range pt.Transaction.SiafundInputs {
+			if p, exists := sfoCreators[sfi.ParentID]; exists && !confirmed[p] {
+				parents[i] = append(parents[i], p)
+			}
+		}
+	}
+
+	// Add the transactions once all of their parents were added. Keep the
+	// original order otherwise.
+	sorted := make([]types.Transaction, 0, len(txns))
+	added := make([]bool, len(txns))
+	for progress := true; progress; {
+		progress = false
+		for i, pt := range txns {
+			if added[i] || dropped[i] || confirmed[i] {
+				continue
+			}
+			ready := true
+			for _, p := range parents[i] {
+				if dropped[p] {
+					dropped[i] = true
+					progress = true
+				}
+				if !added[p] {
+					ready = false
+				}
+			}
+			if ready && !dropped[i] {
+				sorted = append(sorted, pt.Transaction)
+				added[i] = true
+				progress = true
+			}
+		}
+	}
+	return sorted, nil
+}
@@ -1,9 +1,18 @@
 package wallet
 
 import (
+	"fmt"
+	"math"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
@@ -229,6 +238,150 @@ func TestIntegrationTransaction(t *testing.T) {
 	}
 }
 
+// TestTransactionIndex probes the TransactionIndex method of the wallet.
+func TestTransactionIndex(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// An unknown transaction shouldn't be found.
+	_, found, err := wt.wallet.TransactionIndex(types.TransactionID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("unknown transaction shouldn't have an index")
+	}
+
+	// An unconfirmed transaction shouldn't be found either.
+	sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid := sendTxns[1].ID()
+	_, found, err = wt.wallet.TransactionIndex(txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("unconfirmed transaction shouldn't have an index")
+	}
+
+	// Confirm the transaction.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, found, err := wt.wallet.TransactionIndex(txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("confirmed transaction should have an index")
+	}
+
+	// The transaction at that index should be the confirmed one.
+	wt.wallet.mu.Lock()
+	pt, err := dbGetProcessedTransaction(wt.wallet.dbTx, index)
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.TransactionID != txid {
+		t.Fatal("wrong transaction at index", index)
+	}
+}
+
+// TestTransactionsForOutput probes the TransactionsForOutput method of the
+// wallet.
+func TestTransactionsForOutput(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// An unknown output shouldn't return any transactions.
+	created, spent, err := wt.wallet.TransactionsForOutput(types.SiacoinOutputID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != nil || spent != nil {
+		t.Fatal("unknown output shouldn't have transactions")
+	}
+
+	// Send some coins. The parent transaction creates the output which is
+	// spent by the second transaction.
+	sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentID := sendTxns[0].ID()
+	childID := sendTxns[1].ID()
+	oid := sendTxns[1].SiacoinInputs[0].ParentID
+
+	// checkOutput checks the transactions for oid.
+	checkOutput := func(confirmed bool) error {
+		created, spent, err := wt.wallet.TransactionsForOutput(oid)
+		if err != nil {
+			return err
+		}
+		if created == nil || created.TransactionID != parentID {
+			return fmt.Errorf("wrong creating transaction: %v", created)
+		}
+		if spent == nil || spent.TransactionID != childID {
+			return fmt.Errorf("wrong spending transaction: %v", spent)
+		}
+		if unconfirmed := created.ConfirmationHeight == math.MaxUint64; unconfirmed == confirmed {
+			return fmt.Errorf("creating transaction has wrong confirmation height %v", created.ConfirmationHeight)
+		}
+		if unconfirmed := spent.ConfirmationHeight == math.MaxUint64; unconfirmed == confirmed {
+			return fmt.Errorf("spending transaction has wrong confirmation height %v", spent.ConfirmationHeight)
+		}
+		return nil
+	}
+	if err := checkOutput(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm the transactions and check again.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkOutput(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The change output of the child is created but not spent.
+	created, spent, err = wt.wallet.TransactionsForOutput(sendTxns[1].SiacoinOutputID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created == nil || created.TransactionID != childID {
+		t.Fatal("wrong creating transaction", created)
+	}
+	if spent != nil {
+		t.Fatal("output shouldn't be spent")
+	}
+}
+
 // TestProcessedTxnIndexCompatCode checks if the compatibility code for the
 // bucketProcessedTxnIndex works as expected
 func TestProcessedTxnIndexCompatCode(t *testing.T) {
@@ -594,3 +747,1914 @@ func BenchmarkAddressTransactions(b *testing.B) {
 		}
 	})
 }
+
+// TestTransactionStatuses tests fetching the statuses of confirmed,
+// unconfirmed and unknown transactions at once.
+func TestTransactionStatuses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Send a transaction and confirm it.
+	sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed := sendTxns[1].ID()
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	height, err := wt.wallet.Height()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send another transaction without confirming it.
+	sendTxns, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unconfirmed := sendTxns[1].ID()
+
+	statuses, err := wt.wallet.TransactionStatuses([]types.TransactionID{unconfirmed, {}, confirmed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 {
+		t.Fatal("wrong number of statuses", len(statuses))
+	}
+	if statuses[0].Status != TransactionStatusUnconfirmed {
+		t.Fatal("expected transaction to be unconfirmed", statuses[0].Status)
+	}
+	if statuses[1].Status != TransactionStatusUnknown {
+		t.Fatal("expected transaction to be unknown", statuses[1].Status)
+	}
+	if statuses[2].Status != TransactionStatusConfirmed {
+		t.Fatal("expected transaction to be confirmed", statuses[2].Status)
+	}
+	if statuses[2].ConfirmationHeight != height {
+		t.Fatalf("expected confirmation height %v but got %v", height, statuses[2].ConfirmationHeight)
+	}
+}
+
+// TestTransactionsForContracts tests fetching the transactions which touch a
+// set of file contracts.
+func TestTransactionsForContracts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// createContract is a helper to form a file contract and return the id of
+	// the forming transaction and the contract.
+	createContract := func() (types.TransactionID, types.FileContractID) {
+		builder, err := wt.wallet.StartTransaction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = builder.FundSiacoins(types.NewCurrency64(5e9))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fcOutputs := []types.SiacoinOutput{{Value: types.NewCurrency64(4805e6)}}
+		fc := types.FileContract{
+			FileSize:           5e3,
+			WindowStart:        wt.cs.Height() + 2,
+			WindowEnd:          wt.cs.Height() + 3,
+			Payout:             types.NewCurrency64(5e9),
+			ValidProofOutputs:  fcOutputs,
+			MissedProofOutputs: fcOutputs,
+		}
+		_ = builder.AddFileContract(fc)
+		txns, err := builder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = wt.tpool.AcceptTransactionSet(txns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txn := txns[len(txns)-1]
+		return txn.ID(), txn.FileContractID(0)
+	}
+
+	// Create two contracts and confirm them.
+	txid1, fcid1 := createContract()
+	txid2, fcid2 := createContract()
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Request the two contracts and a third one which doesn't exist.
+	var fcid3 types.FileContractID
+	fastrand.Read(fcid3[:])
+	txns, err := wt.wallet.TransactionsForContracts([]types.FileContractID{fcid1, fcid2, fcid3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 2 {
+		t.Fatal("expected transactions for 2 contracts but got", len(txns))
+	}
+	if pts := txns[fcid1]; len(pts) != 1 || pts[0].TransactionID != txid1 {
+		t.Fatal("wrong transactions for first contract", pts)
+	}
+	if pts := txns[fcid2]; len(pts) != 1 || pts[0].TransactionID != txid2 {
+		t.Fatal("wrong transactions for second contract", pts)
+	}
+	if _, exists := txns[fcid3]; exists {
+		t.Fatal("unknown contract shouldn't have transactions")
+	}
+}
+
+// TestFirstTransaction tests fetching the earliest transaction of an address.
+func TestFirstTransaction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// A new address shouldn't have a transaction.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uh := uc.UnlockHash()
+	_, found, err := wt.wallet.FirstTransaction(uh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("new address shouldn't have a transaction")
+	}
+
+	// Send money to the address in multiple blocks.
+	var firstTxnID types.TransactionID
+	var firstHeight types.BlockHeight
+	for i := 0; i < 3; i++ {
+		sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), uh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			firstTxnID = sendTxns[1].ID()
+		}
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			firstHeight, err = wt.wallet.Height()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// The first transaction should be returned.
+	pt, found, err := wt.wallet.FirstTransaction(uh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("transaction wasn't found")
+	}
+	if pt.TransactionID != firstTxnID {
+		t.Fatal("wrong transaction returned")
+	}
+	if pt.ConfirmationHeight != firstHeight {
+		t.Fatalf("expected confirmation height %v but got %v", firstHeight, pt.ConfirmationHeight)
+	}
+}
+
+// TestVisitTransactions checks that VisitTransactions visits the transactions
+// in ascending height order and aborts on the first error.
+func TestVisitTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a few more transactions.
+	for i := 0; i < 3; i++ {
+		_, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The visited transactions should match the result of Transactions.
+	txns, err := wt.wallet.Transactions(0, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []modules.ProcessedTransaction
+	err = wt.wallet.VisitTransactions(0, math.MaxUint64, func(pt modules.ProcessedTransaction) error {
+		if len(visited) > 0 && pt.ConfirmationHeight < visited[len(visited)-1].ConfirmationHeight {
+			return fmt.Errorf("transactions not in ascending order: %v < %v", pt.ConfirmationHeight, visited[len(visited)-1].ConfirmationHeight)
+		}
+		visited = append(visited, pt)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != len(txns) {
+		t.Fatalf("expected %v transactions but visited %v", len(txns), len(visited))
+	}
+	for i := range txns {
+		if visited[i].TransactionID != txns[i].TransactionID {
+			t.Fatal("visited transactions don't match", i)
+		}
+	}
+
+	// An error returned by fn should abort the walk.
+	errAbort := errors.New("abort")
+	calls := 0
+	err = wt.wallet.VisitTransactions(0, math.MaxUint64, func(pt modules.ProcessedTransaction) error {
+		calls++
+		if calls == 2 {
+			return errAbort
+		}
+		return nil
+	})
+	if !errors.Contains(err, errAbort) {
+		t.Fatal("expected errAbort", err)
+	}
+	if calls != 2 {
+		t.Fatal("walk wasn't aborted", calls)
+	}
+}
+
+// TestRecentlyConfirmed checks that RecentlyConfirmed only returns
+// transactions which were confirmed after the specified timestamp.
+func TestRecentlyConfirmed(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Confirm a transaction before the boundary.
+	_, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the timestamps to change since they only have a granularity of
+	// seconds.
+	time.Sleep(1100 * time.Millisecond)
+	since := time.Now()
+	time.Sleep(1100 * time.Millisecond)
+
+	// Confirm a transaction after the boundary.
+	sent, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	height := wt.cs.Height()
+
+	// Only the transactions of the last block should be returned. That's the
+	// 2 transactions of the send and the miner payout.
+	pts, err := wt.wallet.RecentlyConfirmed(since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 3 {
+		t.Fatalf("expected %v transactions but got %v", 3, len(pts))
+	}
+	found := false
+	for _, pt := range pts {
+		if pt.ConfirmationHeight != height {
+			t.Fatal("transaction from before the boundary was returned", pt.ConfirmationHeight, height)
+		}
+		if pt.TransactionID == sent[len(sent)-1].ID() {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("sent transaction wasn't returned")
+	}
+
+	// A timestamp from before the first block should return everything.
+	all, err := wt.wallet.Transactions(0, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pts, err = wt.wallet.RecentlyConfirmed(time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != len(all) {
+		t.Fatalf("expected %v transactions but got %v", len(all), len(pts))
+	}
+
+	// A timestamp in the future should return nothing.
+	pts, err = wt.wallet.RecentlyConfirmed(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 0 {
+		t.Fatal("expected no transactions", len(pts))
+	}
+}
+
+// TestMinerPayouts tests that MinerPayouts distinguishes between matured and
+// immature payouts.
+func TestMinerPayouts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Creating the wallet tester mines types.MaturityDelay+1 blocks which each
+	// pay the wallet. At the current height only the payout of the first block
+	// has matured.
+	height := wt.cs.Height()
+	if height != types.MaturityDelay+1 {
+		t.Fatalf("unexpected height %v", height)
+	}
+	matured, err := wt.wallet.MinerPayouts(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matured) != 1 {
+		t.Fatalf("expected %v matured payout but got %v", 1, len(matured))
+	}
+	if matured[0].ConfirmationHeight != 1 || matured[0].MaturityHeight != 1+types.MaturityDelay {
+		t.Fatal("wrong heights", matured[0].ConfirmationHeight, matured[0].MaturityHeight)
+	}
+	immature, err := wt.wallet.MinerPayouts(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(immature) != int(types.MaturityDelay) {
+		t.Fatalf("expected %v immature payouts but got %v", types.MaturityDelay, len(immature))
+	}
+	for _, mp := range immature {
+		if mp.MaturityHeight <= height {
+			t.Fatal("matured payout returned as immature", mp.MaturityHeight, height)
+		}
+		if mp.MaturityHeight != mp.ConfirmationHeight+types.MaturityDelay {
+			t.Fatal("wrong maturity height", mp.ConfirmationHeight, mp.MaturityHeight)
+		}
+		if mp.Value.IsZero() {
+			t.Fatal("payout has no value")
+		}
+	}
+
+	// Mine another block. The payout of the second block matures while a new
+	// immature one is added.
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	matured, err = wt.wallet.MinerPayouts(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	immature, err = wt.wallet.MinerPayouts(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matured) != 2 || len(immature) != int(types.MaturityDelay) {
+		t.Fatal("wrong number of payouts", len(matured), len(immature))
+	}
+}
+
+// TestRebuildTransactionSequence tests that RebuildTransactionSequence repairs
+// a corrupted sequence of the processed transactions bucket.
+func TestRebuildTransactionSequence(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	expected, err := wt.wallet.Transactions(0, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Repairing a correct sequence is a no-op.
+	oldSeq, newSeq, err := wt.wallet.RebuildTransactionSequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldSeq != newSeq || newSeq != uint64(len(expected)) {
+		t.Fatal("wrong sequences", oldSeq, newSeq, len(expected))
+	}
+
+	// Corrupt the sequence like an interrupted append would.
+	wt.wallet.mu.Lock()
+	err = wt.wallet.dbTx.Bucket(bucketProcessedTransactions).SetSequence(newSeq + 5)
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pts, err := wt.wallet.Transactions(0, math.MaxUint64); err == nil && reflect.DeepEqual(pts, expected) {
+		t.Fatal("corrupted sequence should break Transactions")
+	}
+
+	// Repair it.
+	oldSeq, newSeq, err = wt.wallet.RebuildTransactionSequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldSeq != uint64(len(expected))+5 || newSeq != uint64(len(expected)) {
+		t.Fatal("wrong sequences", oldSeq, newSeq)
+	}
+	pts, err := wt.wallet.Transactions(0, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pts, expected) {
+		t.Fatal("transactions don't match after repair")
+	}
+}
+
+// TestTransactionsDiffRanges tests comparing the transactions of two ranges.
+func TestTransactionsDiffRanges(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Every block of the wallet tester contains a miner payout to the wallet.
+	height := wt.cs.Height()
+	pts, err := wt.wallet.Transactions(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 1 {
+		t.Fatalf("expected %v transaction but got %v", 1, len(pts))
+	}
+	first := pts[0].TransactionID
+
+	// Identical ranges don't differ.
+	added, removed, err := wt.wallet.TransactionsDiffRanges(0, height, 0, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 0 || len(removed) != 0 {
+		t.Fatal("identical ranges differ", added, removed)
+	}
+
+	// Simulate a block at the next height which replaces the block at the
+	// current height like a reorg would. We can't just mine a block since
+	// that would create new transactions.
+	pts, err = wt.wallet.Transactions(height, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reorged := pts[0].TransactionID
+	pt := modules.ProcessedTransaction{
+		TransactionID:      types.TransactionID{1, 2, 3},
+		ConfirmationHeight: height + 1,
+	}
+	wt.wallet.mu.Lock()
+	if err := dbAppendProcessedTransaction(wt.wallet.dbTx, pt); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbPutConsensusHeight(wt.wallet.dbTx, height+1); err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.Unlock()
+
+	// Compare the overlapping ranges [1, height] and [2, height+1].
+	added, removed, err = wt.wallet.TransactionsDiffRanges(1, height, 2, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || added[0] != pt.TransactionID {
+		t.Fatal("wrong added transactions", added)
+	}
+	if len(removed) != 1 || removed[0] != first {
+		t.Fatal("wrong removed transactions", removed)
+	}
+
+	// Compare the ranges which only contain the replaced block.
+	added, removed, err = wt.wallet.TransactionsDiffRanges(height, height, height+1, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || added[0] != pt.TransactionID || len(removed) != 1 || removed[0] != reorged {
+		t.Fatal("wrong diff", added, removed)
+	}
+
+	// Ranges beyond the wallet's height can't be compared.
+	_, _, err = wt.wallet.TransactionsDiffRanges(0, height, height+2, height+3)
+	if !errors.Contains(err, errOutOfBounds) {
+		t.Fatal("wrong error", err)
+	}
+}
+
+// TestZeroValueTransactions tests that ZeroValueTransactions only returns
+// transactions without any value for the wallet.
+func TestZeroValueTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Send some coins and mine a block without a payout to confirm the
+	// transactions.
+	sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	height := wt.cs.Height()
+
+	// Add a transaction which only revises a contract and neither has
+	// inputs nor outputs of the wallet.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	revTxn := types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:          types.FileContractID{1},
+			UnlockConditions:  uc,
+			NewRevisionNumber: 1,
+			NewUnlockHash:     uc.UnlockHash(),
+		}},
+	}
+	pt := modules.ProcessedTransaction{
+		Transaction:        revTxn,
+		TransactionID:      revTxn.ID(),
+		ConfirmationHeight: height + 1,
+	}
+	wt.wallet.mu.Lock()
+	if err := dbAppendProcessedTransaction(wt.wallet.dbTx, pt); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbPutConsensusHeight(wt.wallet.dbTx, height+1); err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.Unlock()
+
+	// Only the revision should be returned.
+	vts, err := wt.wallet.ZeroValueTransactions(0, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vts) != 1 {
+		t.Fatalf("expected %v transaction but got %v", 1, len(vts))
+	}
+	if vts[0].TransactionID != pt.TransactionID {
+		t.Fatal("wrong transaction returned", vts[0].TransactionID)
+	}
+	sendTxn := sendTxns[len(sendTxns)-1].ID()
+	for _, vt := range vts {
+		if vt.TransactionID == sendTxn {
+			t.Fatal("send transaction shouldn't be returned")
+		}
+	}
+}
+
+// TestTransactionCategory tests that ComputeValuedTransactions assigns the
+// right category to transactions.
+func TestTransactionCategory(t *testing.T) {
+	t.Parallel()
+
+	minerPayout := modules.ProcessedOutput{
+		FundType:      types.SpecifierMinerPayout,
+		WalletAddress: true,
+		Value:         types.SiacoinPrecision,
+	}
+	transfer := modules.ProcessedOutput{
+		FundType:      types.SpecifierSiacoinOutput,
+		WalletAddress: true,
+		Value:         types.SiacoinPrecision,
+	}
+	formation := types.Transaction{
+		FileContracts: []types.FileContract{{}},
+	}
+	revision := types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID: types.FileContractID{1},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		pt       modules.ProcessedTransaction
+		category modules.TransactionCategory
+	}{
+		{
+			name:     "minerpayout",
+			pt:       modules.ProcessedTransaction{Outputs: []modules.ProcessedOutput{minerPayout}},
+			category: modules.TxnCategoryMinerPayout,
+		},
+		{
+			name:     "formation",
+			pt:       modules.ProcessedTransaction{Transaction: formation},
+			category: modules.TxnCategoryContractFormation,
+		},
+		{
+			name:     "revision",
+			pt:       modules.ProcessedTransaction{Transaction: revision},
+			category: modules.TxnCategoryContractRevision,
+		},
+		{
+			name:     "transfer",
+			pt:       modules.ProcessedTransaction{Outputs: []modules.ProcessedOutput{transfer}},
+			category: modules.TxnCategoryTransfer,
+		},
+		{
+			// A miner payout takes precedence over a contract.
+			name: "minerpayout+formation",
+			pt: modules.ProcessedTransaction{
+				Transaction: formation,
+				Outputs:     []modules.ProcessedOutput{minerPayout},
+			},
+			category: modules.TxnCategoryMinerPayout,
+		},
+		{
+			// A contract formation takes precedence over a revision.
+			name: "formation+revision",
+			pt: modules.ProcessedTransaction{
+				Transaction: types.Transaction{
+					FileContracts:         formation.FileContracts,
+					FileContractRevisions: revision.FileContractRevisions,
+				},
+				Outputs: []modules.ProcessedOutput{transfer},
+			},
+			category: modules.TxnCategoryContractFormation,
+		},
+	}
+	for _, test := range tests {
+		vts, err := ComputeValuedTransactions([]modules.ProcessedTransaction{test.pt}, 0)
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		if vts[0].Category != test.category {
+			t.Fatalf("%v: expected category %v but got %v", test.name, test.category, vts[0].Category)
+		}
+	}
+}
+
+// TestChangeOutputs tests that ChangeOutputs returns the change outputs of a
+// send and no deposits.
+func TestChangeOutputs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The miner payouts are deposits.
+	height := wt.cs.Height()
+	outputs, err := wt.wallet.ChangeOutputs(0, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 0 {
+		t.Fatal("expected no change outputs but got", len(outputs))
+	}
+
+	// Send some coins to an external address and confirm the transactions.
+	sentValue := types.NewCurrency64(5000)
+	sendTxns, err := wt.wallet.SendSiacoins(sentValue, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The change outputs of the send should be returned but not the output to
+	// the external address.
+	outputs, err = wt.wallet.ChangeOutputs(height+1, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) == 0 {
+		t.Fatal("expected change outputs")
+	}
+	changeOutputs := make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	for _, txn := range sendTxns {
+		for i, sco := range txn.SiacoinOutputs {
+			if sco.UnlockHash != (types.UnlockHash{}) {
+				changeOutputs[txn.SiacoinOutputID(uint64(i))] = sco
+			}
+		}
+	}
+	for _, co := range outputs {
+		sco, ok := changeOutputs[co.ID]
+		if !ok {
+			t.Fatal("unexpected change output", co.ID)
+		}
+		if !sco.Value.Equals(co.Value) || sco.UnlockHash != co.UnlockHash {
+			t.Fatal("wrong change output", co, sco)
+		}
+		if co.ConfirmationHeight != height+1 {
+			t.Fatal("wrong confirmation height", co.ConfirmationHeight)
+		}
+	}
+}
+
+// TestSiafundClaimTransactions tests that SiafundClaimTransactions returns
+// the transactions which sent siafunds or claims to the wallet.
+func TestSiafundClaimTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	height := wt.cs.Height()
+
+	// Add a transaction which spends the wallet's siafunds, pays out the
+	// claim and sends some of the siafunds back to the wallet.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uh := uc.UnlockHash()
+	sfTxn := types.Transaction{
+		SiafundInputs: []types.SiafundInput{{
+			ParentID:         types.SiafundOutputID{1},
+			UnlockConditions: uc,
+			ClaimUnlockHash:  uh,
+		}},
+		SiafundOutputs: []types.SiafundOutput{
+			{Value: types.NewCurrency64(30), UnlockHash: uh},
+			{Value: types.NewCurrency64(70), UnlockHash: types.UnlockHash{}},
+		},
+	}
+	claimValue := types.NewCurrency64(12345)
+	sfPT := modules.ProcessedTransaction{
+		Transaction:        sfTxn,
+		TransactionID:      sfTxn.ID(),
+		ConfirmationHeight: height + 1,
+		Inputs: []modules.ProcessedInput{{
+			ParentID:       types.OutputID{1},
+			FundType:       types.SpecifierSiafundInput,
+			WalletAddress:  true,
+			RelatedAddress: uh,
+			Value:          types.NewCurrency64(100),
+		}},
+		Outputs: []modules.ProcessedOutput{
+			{
+				ID:             types.OutputID{1},
+				FundType:       types.SpecifierClaimOutput,
+				WalletAddress:  true,
+				RelatedAddress: uh,
+				Value:          claimValue,
+			},
+			{
+				ID:             types.OutputID(sfTxn.SiafundOutputID(0)),
+				FundType:       types.SpecifierSiafundOutput,
+				WalletAddress:  true,
+				RelatedAddress: uh,
+				Value:          types.NewCurrency64(30),
+			},
+			{
+				ID:             types.OutputID(sfTxn.SiafundOutputID(1)),
+				FundType:       types.SpecifierSiafundOutput,
+				RelatedAddress: types.UnlockHash{},
+				Value:          types.NewCurrency64(70),
+			},
+		},
+	}
+	wt.wallet.mu.Lock()
+	if err := dbAppendProcessedTransaction(wt.wallet.dbTx, sfPT); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbPutConsensusHeight(wt.wallet.dbTx, height+1); err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.Unlock()
+
+	// The miner payouts shouldn't be returned.
+	txns, err := wt.wallet.SiafundClaimTransactions(0, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 0 {
+		t.Fatalf("expected no transactions but got %v", len(txns))
+	}
+
+	// Only the siafund transaction should be returned.
+	txns, err = wt.wallet.SiafundClaimTransactions(height+1, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 1 {
+		t.Fatalf("expected %v transaction but got %v", 1, len(txns))
+	}
+	if txns[0].Transaction.TransactionID != sfPT.TransactionID {
+		t.Fatal("wrong transaction returned", txns[0].Transaction.TransactionID)
+	}
+	if !txns[0].ClaimValue.Equals(claimValue) {
+		t.Fatal("wrong claim value", txns[0].ClaimValue)
+	}
+	if !txns[0].SiafundsReceived.Equals64(30) {
+		t.Fatal("wrong siafunds received", txns[0].SiafundsReceived)
+	}
+}
+
+// TestOutputHistory tests that OutputHistory returns the lifecycle of an
+// output which was created and later spent by the wallet.
+func TestOutputHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// An unknown output shouldn't be found.
+	_, found, err := wt.wallet.OutputHistory(types.SiacoinOutputID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("unknown output shouldn't be found")
+	}
+
+	// Send some coins. The parent transaction creates the output which is
+	// spent by the second transaction. Confirm the transactions.
+	sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentID := sendTxns[0].ID()
+	childID := sendTxns[1].ID()
+	oid := sendTxns[1].SiacoinInputs[0].ParentID
+	var sco types.SiacoinOutput
+	for i, output := range sendTxns[0].SiacoinOutputs {
+		if sendTxns[0].SiacoinOutputID(uint64(i)) == oid {
+			sco = output
+		}
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	height := wt.cs.Height()
+
+	// The output should be created and spent at the new height.
+	oh, found, err := wt.wallet.OutputHistory(oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("output wasn't found")
+	}
+	expected := OutputHistory{
+		ID:             oid,
+		UnlockHash:     sco.UnlockHash,
+		Value:          sco.Value,
+		CreatedBy:      parentID,
+		CreationHeight: height,
+		Spent:          true,
+		SpentBy:        childID,
+		SpendHeight:    height,
+	}
+	if !reflect.DeepEqual(oh, expected) {
+		t.Fatalf("expected %v but got %v", expected, oh)
+	}
+
+	// The output to the external address isn't wallet-relevant.
+	for i, output := range sendTxns[1].SiacoinOutputs {
+		if output.UnlockHash != (types.UnlockHash{}) {
+			continue
+		}
+		_, found, err := wt.wallet.OutputHistory(sendTxns[1].SiacoinOutputID(uint64(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found {
+			t.Fatal("external output shouldn't be found")
+		}
+	}
+}
+
+// TestTransactionSearchPanic tests that a panic during the search for
+// processed transactions is returned as a TransactionSearchPanicError which
+// carries the original detail.
+func TestTransactionSearchPanic(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	deps := &dependencyTransactionSearchPanic{}
+	wt, err := createWalletTester(t.Name(), deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Force a panic during the next search.
+	wt.wallet.mu.Lock()
+	deps.fail()
+	wt.wallet.mu.Unlock()
+	_, err = wt.wallet.Transactions(0, wt.cs.Height())
+	searchErr, ok := err.(*TransactionSearchPanicError)
+	if !ok {
+		t.Fatal("expected TransactionSearchPanicError but got", err)
+	}
+	if searchErr.Value != "transaction search disrupted" {
+		t.Fatal("wrong panic value", searchErr.Value)
+	}
+	if !strings.Contains(err.Error(), "transaction search disrupted") {
+		t.Fatal("error doesn't contain the panic detail", err)
+	}
+	if build.DEBUG && len(searchErr.Stack) == 0 {
+		t.Fatal("stack wasn't captured")
+	}
+
+	// The next search should succeed again.
+	if _, err := wt.wallet.Transactions(0, wt.cs.Height()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTransactionsByCounterparty tests that transactions are grouped by the
+// external addresses they sent coins to or received coins from.
+func TestTransactionsByCounterparty(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Send some coins to an external address and confirm the transactions.
+	recipient := types.UnlockHash{1}
+	sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	height := wt.cs.Height()
+
+	// Add a transaction which receives coins from another external address.
+	sender := types.UnlockHash{2}
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiveTxn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{
+			ParentID: types.SiacoinOutputID{2},
+		}},
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      types.NewCurrency64(1000),
+			UnlockHash: uc.UnlockHash(),
+		}},
+	}
+	receivePT := modules.ProcessedTransaction{
+		Transaction:        receiveTxn,
+		TransactionID:      receiveTxn.ID(),
+		ConfirmationHeight: height + 1,
+		Inputs: []modules.ProcessedInput{{
+			ParentID:       types.OutputID{2},
+			FundType:       types.SpecifierSiacoinInput,
+			RelatedAddress: sender,
+			Value:          types.NewCurrency64(1000),
+		}},
+		Outputs: []modules.ProcessedOutput{{
+			ID:             types.OutputID(receiveTxn.SiacoinOutputID(0)),
+			FundType:       types.SpecifierSiacoinOutput,
+			WalletAddress:  true,
+			RelatedAddress: uc.UnlockHash(),
+			Value:          types.NewCurrency64(1000),
+		}},
+	}
+	wt.wallet.mu.Lock()
+	if err := dbAppendProcessedTransaction(wt.wallet.dbTx, receivePT); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbPutConsensusHeight(wt.wallet.dbTx, height+1); err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.Unlock()
+
+	// Each counterparty should only have its own transaction.
+	byCounterparty, err := wt.wallet.TransactionsByCounterparty(height, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byCounterparty) != 2 {
+		t.Fatalf("expected %v counterparties but got %v", 2, len(byCounterparty))
+	}
+	sent := byCounterparty[recipient]
+	if len(sent) != 1 || sent[0].TransactionID != sendTxns[len(sendTxns)-1].ID() {
+		t.Fatal("wrong transactions for recipient", sent)
+	}
+	received := byCounterparty[sender]
+	if len(received) != 1 || received[0].TransactionID != receivePT.TransactionID {
+		t.Fatal("wrong transactions for sender", received)
+	}
+}
+
+// TestTransactionsDesc tests that TransactionsDesc and VisitTransactionsDesc
+// return the exact reverse of Transactions for the same range.
+func TestTransactionsDesc(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a few more transactions.
+	for i := 0; i < 3; i++ {
+		_, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	height := wt.cs.Height()
+
+	// Check a few ranges including ones that start and end in the middle.
+	ranges := [][2]types.BlockHeight{
+		{0, math.MaxUint64},
+		{0, height},
+		{height - 2, height - 1},
+		{height, height},
+		{1, 1},
+	}
+	for _, r := range ranges {
+		asc, err := wt.wallet.Transactions(r[0], r[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc, err := wt.wallet.TransactionsDesc(r[0], r[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var visited []modules.ProcessedTransaction
+		err = wt.wallet.VisitTransactionsDesc(r[0], r[1], func(pt modules.ProcessedTransaction) error {
+			visited = append(visited, pt)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(asc) != len(desc) || len(asc) != len(visited) {
+			t.Fatalf("%v: lengths don't match %v %v %v", r, len(asc), len(desc), len(visited))
+		}
+		for i := range asc {
+			j := len(asc) - 1 - i
+			if asc[i].TransactionID != desc[j].TransactionID || asc[i].TransactionID != visited[j].TransactionID {
+				t.Fatalf("%v: transactions don't match at %v", r, i)
+			}
+		}
+	}
+}
+
+// TestRecentTransactions tests that RecentTransactions returns the most recent
+// transactions newest first.
+func TestRecentTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a few more transactions.
+	for i := 0; i < 3; i++ {
+		_, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := wt.wallet.Transactions(0, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 4
+	if len(all) <= n {
+		t.Fatal("not enough transactions", len(all))
+	}
+
+	recent, err := wt.wallet.RecentTransactions(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != n {
+		t.Fatalf("expected %v transactions but got %v", n, len(recent))
+	}
+	for i, vt := range recent {
+		if expected := all[len(all)-1-i].TransactionID; vt.TransactionID != expected {
+			t.Fatalf("wrong transaction at %v: %v != %v", i, vt.TransactionID, expected)
+		}
+	}
+
+	// Asking for more than there are returns all of them.
+	recent, err = wt.wallet.RecentTransactions(len(all) + 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != len(all) {
+		t.Fatalf("expected %v transactions but got %v", len(all), len(recent))
+	}
+}
+
+// TestUnsortedTransactions tests that walking processed transactions which are
+// not sorted by confirmation height returns ErrUnsortedTransactions.
+func TestUnsortedTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), &dependencyDisableUnsortedTransactionsCritical{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	height := wt.cs.Height()
+	if height < 2 {
+		t.Fatal("wallet tester should have more blocks", height)
+	}
+
+	// The transactions are sorted initially.
+	if _, err := wt.wallet.Transactions(1, height); err != nil {
+		t.Fatal(err)
+	}
+
+	// Inject a transaction confirmed at height 0 after the ones confirmed
+	// later.
+	pt := modules.ProcessedTransaction{
+		TransactionID:      types.TransactionID{1},
+		ConfirmationHeight: 0,
+	}
+	wt.wallet.mu.Lock()
+	err = dbAppendProcessedTransaction(wt.wallet.dbTx, pt)
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Walking over the injected transaction should fail.
+	_, err = wt.wallet.Transactions(1, math.MaxUint64)
+	unsortedErr, ok := err.(*ErrUnsortedTransactions)
+	if !ok {
+		t.Fatal("expected ErrUnsortedTransactions but got", err)
+	}
+	if unsortedErr.TransactionID != pt.TransactionID || unsortedErr.ConfirmationHeight != 0 {
+		t.Fatal("wrong transaction in error", unsortedErr)
+	}
+}
+
+// TestConfirmationDepth tests that ComputeValuedTransactions computes the
+// confirmation depth relative to the provided height.
+func TestConfirmationDepth(t *testing.T) {
+	pts := []modules.ProcessedTransaction{
+		{ConfirmationHeight: 94},
+		{ConfirmationHeight: 100},
+		{ConfirmationHeight: 101},
+		{ConfirmationHeight: types.BlockHeight(math.MaxUint64)},
+	}
+	vts, err := ComputeValuedTransactions(pts, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []types.BlockHeight{6, 0, 0, 0}
+	for i, vt := range vts {
+		if vt.ConfirmationDepth != expected[i] {
+			t.Fatalf("%v: expected depth %v but got %v", i, expected[i], vt.ConfirmationDepth)
+		}
+	}
+}
+
+// TestRawTransaction tests that RawTransaction returns the encoded transaction
+// for unconfirmed and confirmed transactions.
+func TestRawTransaction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	txns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := txns[len(txns)-1]
+	checkRaw := func() {
+		t.Helper()
+		raw, found, err := wt.wallet.RawTransaction(txn.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Fatal("transaction wasn't found")
+		}
+		var decoded types.Transaction
+		if err := encoding.Unmarshal(raw, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.ID() != txn.ID() || !reflect.DeepEqual(decoded, txn) {
+			t.Fatal("decoded transaction doesn't match")
+		}
+	}
+
+	// Check the unconfirmed and the confirmed transaction.
+	checkRaw()
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	checkRaw()
+
+	// Unknown transactions are not found.
+	raw, found, err := wt.wallet.RawTransaction(types.TransactionID{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found || raw != nil {
+		t.Fatal("unknown transaction was found")
+	}
+}
+
+// TestTransactionInputSources tests that the inputs of a transaction are traced
+// back to the transactions which created the spent outputs.
+func TestTransactionInputSources(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create two receives and a transaction spending both of their outputs
+	// and an output which is unknown to the wallet.
+	height := wt.cs.Height()
+	receive := func(txid types.TransactionID, oid types.OutputID) modules.ProcessedTransaction {
+		return modules.ProcessedTransaction{
+			TransactionID:      txid,
+			ConfirmationHeight: height,
+			Outputs: []modules.ProcessedOutput{
+				{FundType: types.SpecifierMinerFee},
+				{ID: oid, FundType: types.SpecifierSiacoinOutput, WalletAddress: true},
+			},
+		}
+	}
+	receive1 := receive(types.TransactionID{1}, types.OutputID{1})
+	receive2 := receive(types.TransactionID{2}, types.OutputID{2})
+	spend := modules.ProcessedTransaction{
+		TransactionID:      types.TransactionID{3},
+		ConfirmationHeight: height,
+		Inputs: []modules.ProcessedInput{
+			{ParentID: types.OutputID{2}, FundType: types.SpecifierSiacoinInput, WalletAddress: true},
+			{ParentID: types.OutputID{3}, FundType: types.SpecifierSiacoinInput},
+			{ParentID: types.OutputID{1}, FundType: types.SpecifierSiacoinInput, WalletAddress: true},
+		},
+	}
+	wt.wallet.mu.Lock()
+	for _, pt := range []modules.ProcessedTransaction{receive1, receive2, spend} {
+		err = errors.Compose(err, dbAppendProcessedTransaction(wt.wallet.dbTx, pt))
+	}
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources, found, err := wt.wallet.TransactionInputSources(spend.TransactionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("transaction wasn't found")
+	}
+	expected := []InputSource{
+		{ParentID: types.OutputID{2}, FundType: types.SpecifierSiacoinInput, Known: true, CreatedBy: receive2.TransactionID},
+		{ParentID: types.OutputID{3}, FundType: types.SpecifierSiacoinInput},
+		{ParentID: types.OutputID{1}, FundType: types.SpecifierSiacoinInput, Known: true, CreatedBy: receive1.TransactionID},
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Fatal("wrong sources", sources)
+	}
+
+	// Unknown transactions are not found.
+	if _, found, err := wt.wallet.TransactionInputSources(types.TransactionID{4}); err != nil || found {
+		t.Fatal("unknown transaction was found", found, err)
+	}
+}
+
+// TestTransactionReferences tests tagging transactions with references and
+// querying them back, also after restarting the wallet.
+func TestTransactionReferences(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create two transactions and confirm only the first one.
+	txns1, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	txns2, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid1 := txns1[len(txns1)-1].ID()
+	txid2 := txns2[len(txns2)-1].ID()
+
+	// Tag both transactions with the same reference and the second one with
+	// another one. Tagging twice is a no-op.
+	for _, txid := range []types.TransactionID{txid1, txid2, txid1} {
+		if err := wt.wallet.SetTransactionReference(txid, "invoice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wt.wallet.SetTransactionReference(txid2, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.SetTransactionReference(txid2, ""); err != errEmptyTransactionReference {
+		t.Fatal("expected errEmptyTransactionReference", err)
+	}
+
+	check := func(ref string, expected ...types.TransactionID) {
+		t.Helper()
+		pts, err := wt.wallet.TransactionsByReference(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pts) != len(expected) {
+			t.Fatalf("%v: expected %v transactions but got %v", ref, len(expected), len(pts))
+		}
+		for i, pt := range pts {
+			if pt.TransactionID != expected[i] {
+				t.Fatalf("%v: wrong transaction at %v", ref, i)
+			}
+		}
+	}
+	check("invoice", txid1, txid2)
+	check("other", txid2)
+	check("unknown")
+
+	// The references persist across restarts.
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet = w
+	check("invoice", txid1, txid2)
+	check("other", txid2)
+
+	// Remove a reference.
+	if err := wt.wallet.RemoveTransactionReference(txid1, "invoice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.RemoveTransactionReference(txid1, "other"); err != nil {
+		t.Fatal(err)
+	}
+	check("invoice", txid2)
+	check("other", txid2)
+	if err := wt.wallet.RemoveTransactionReference(txid2, "invoice"); err != nil {
+		t.Fatal(err)
+	}
+	check("invoice")
+}
+
+// TestFeesPaid tests that FeesPaid only sums the fees of the transactions
+// which were funded by the wallet.
+func TestFeesPaid(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	start := wt.cs.Height() + 1
+
+	// Send coins twice and confirm the transactions.
+	expected := types.ZeroCurrency
+	for i := 0; i < 2; i++ {
+		txns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, txn := range txns {
+			for _, fee := range txn.MinerFees {
+				expected = expected.Add(fee)
+			}
+		}
+		if err := wt.addBlockNoPayout(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected.IsZero() {
+		t.Fatal("sent transactions have no fees")
+	}
+
+	// Add an incoming transaction with a fee paid by the sender.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.Lock()
+	err = dbAppendProcessedTransaction(wt.wallet.dbTx, modules.ProcessedTransaction{
+		TransactionID:      types.TransactionID{1, 2, 3},
+		ConfirmationHeight: wt.cs.Height(),
+		Inputs: []modules.ProcessedInput{{
+			FundType: types.SpecifierSiacoinInput,
+			Value:    types.NewCurrency64(1100),
+		}},
+		Outputs: []modules.ProcessedOutput{{
+			FundType:       types.SpecifierSiacoinOutput,
+			RelatedAddress: uc.UnlockHash(),
+			WalletAddress:  true,
+			Value:          types.NewCurrency64(1000),
+		}, {
+			FundType: types.SpecifierMinerFee,
+			Value:    types.NewCurrency64(100),
+		}},
+	})
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the fees of the sent transactions should be counted.
+	fees, err := wt.wallet.FeesPaid(start, wt.cs.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fees.Equals(expected) {
+		t.Fatalf("expected fees of %v but got %v", expected, fees)
+	}
+
+	// The period before the transactions has no fees.
+	fees, err = wt.wallet.FeesPaid(0, start-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fees.IsZero() {
+		t.Fatal("expected no fees but got", fees)
+	}
+}
+
+// TestBlockTransactions tests that BlockTransactions returns the wallet's
+// transactions of a single block.
+func TestBlockTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Confirm two wallet transactions in the same block.
+	sent := make(map[types.TransactionID]struct{})
+	for i := 0; i < 2; i++ {
+		txns, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent[txns[len(txns)-1].ID()] = struct{}{}
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	height := wt.cs.Height()
+	vts, err := wt.wallet.BlockTransactions(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vt := range vts {
+		if vt.ConfirmationHeight != height {
+			t.Fatal("wrong confirmation height", vt.ConfirmationHeight, height)
+		}
+		if _, ok := sent[vt.TransactionID]; !ok {
+			continue
+		}
+		delete(sent, vt.TransactionID)
+		// Every send spends more than it pays back to the wallet.
+		if vt.ConfirmedOutgoingValue.Cmp(vt.ConfirmedIncomingValue.Add(types.SiacoinPrecision)) < 0 {
+			t.Fatal("wrong value", vt.ConfirmedIncomingValue, vt.ConfirmedOutgoingValue)
+		}
+		if vt.ConfirmationDepth != 0 {
+			t.Fatal("wrong confirmation depth", vt.ConfirmationDepth)
+		}
+	}
+	if len(sent) != 0 {
+		t.Fatal("missing transactions", len(sent))
+	}
+
+	// The values should match the ones computed for the transactions.
+	pts, err := wt.wallet.Transactions(height, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ComputeValuedTransactions(pts, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vts, expected) {
+		t.Fatal("valued transactions don't match", vts, expected)
+	}
+
+	// A block without wallet transactions returns an empty slice.
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	vts, err = wt.wallet.BlockTransactions(wt.cs.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vts == nil || len(vts) != 0 {
+		t.Fatal("expected an empty slice", vts)
+	}
+}
+
+// TestMinerPayoutSpends tests that a matured miner payout which is spent later
+// is linked to the spending transaction.
+func TestMinerPayoutSpends(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Collect the wallet's matured payouts.
+	pts, err := wt.wallet.Transactions(0, wt.cs.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payouts := make(map[types.SiacoinOutputID]types.BlockHeight)
+	for _, pt := range pts {
+		for _, po := range pt.Outputs {
+			if po.FundType == types.SpecifierMinerPayout && po.WalletAddress {
+				payouts[types.SiacoinOutputID(po.ID)] = pt.ConfirmationHeight
+			}
+		}
+	}
+	if len(payouts) == 0 {
+		t.Fatal("wallet has no payouts")
+	}
+
+	// Spend some coins and confirm the transaction.
+	start := wt.cs.Height() + 1
+	txns, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	expected := make(map[types.SiacoinOutputID]types.TransactionID)
+	for _, txn := range txns {
+		for _, sci := range txn.SiacoinInputs {
+			if _, ok := payouts[sci.ParentID]; ok {
+				expected[sci.ParentID] = txn.ID()
+			}
+		}
+	}
+	if len(expected) == 0 {
+		t.Fatal("transaction didn't spend a payout")
+	}
+
+	spends, err := wt.wallet.MinerPayoutSpends(start, wt.cs.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spends) != len(expected) {
+		t.Fatal("wrong number of spends", len(spends), len(expected))
+	}
+	for _, spend := range spends {
+		if spend.SpendTransactionID != expected[spend.PayoutID] {
+			t.Fatal("wrong spend transaction", spend.SpendTransactionID, expected[spend.PayoutID])
+		}
+		if spend.PayoutHeight != payouts[spend.PayoutID] || spend.SpendHeight != wt.cs.Height() {
+			t.Fatal("wrong heights", spend.PayoutHeight, spend.SpendHeight)
+		}
+		if spend.Value.IsZero() {
+			t.Fatal("spend has no value")
+		}
+	}
+
+	// The payouts weren't spent before.
+	spends, err = wt.wallet.MinerPayoutSpends(0, start-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, spend := range spends {
+		if _, ok := expected[spend.PayoutID]; ok {
+			t.Fatal("payout was spent before", spend.PayoutID)
+		}
+	}
+}
+
+// TestEstimateConfirmation tests that a pending transaction with a low fee is
+// expected to take longer to confirm than one with a high fee.
+func TestEstimateConfirmation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// sendWithFee creates a transaction paying the given fee and submits it
+	// to the transaction pool.
+	sendWithFee := func(fee types.Currency) types.TransactionID {
+		builder, err := wt.wallet.StartTransaction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		funding := types.NewCurrency64(100).Mul(types.SiacoinPrecision)
+		if err := builder.FundSiacoins(funding); err != nil {
+			t.Fatal(err)
+		}
+		builder.AddMinerFee(fee)
+		builder.AddSiacoinOutput(types.SiacoinOutput{Value: funding.Sub(fee)})
+		tset, err := builder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wt.tpool.AcceptTransactionSet(tset); err != nil {
+			t.Fatal(err)
+		}
+		return tset[len(tset)-1].ID()
+	}
+	lowFee := sendWithFee(types.SiacoinPrecision.Div64(1e3))
+	highFee := sendWithFee(types.SiacoinPrecision.Mul64(10))
+
+	// The high fee transaction should be expected in the next block and the
+	// low fee one later.
+	highBlocks, err := wt.wallet.EstimateConfirmation(highFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowBlocks, err := wt.wallet.EstimateConfirmation(lowFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if highBlocks != 1 {
+		t.Fatal("expected high fee transaction in the next block but got", highBlocks)
+	}
+	if lowBlocks <= highBlocks {
+		t.Fatalf("low fee transaction should take longer: %v <= %v", lowBlocks, highBlocks)
+	}
+
+	// Unknown transactions have no estimate.
+	if _, err := wt.wallet.EstimateConfirmation(types.TransactionID{}); !errors.Contains(err, errTransactionNotUnconfirmed) {
+		t.Fatal("expected errTransactionNotUnconfirmed but got", err)
+	}
+}
+
+// TestRebroadcastSet tests that RebroadcastSet orders parents before their
+// children and leaves out confirmed transactions but keeps their descendants.
+func TestRebroadcastSet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	wt, err := createBlankWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a parent and a child spending the parent's output as well as a
+	// confirmed transaction and its child.
+	newTxn := func(parent *types.Transaction) types.Transaction {
+		var uh types.UnlockHash
+		fastrand.Read(uh[:])
+		txn := types.Transaction{
+			SiacoinOutputs: []types.SiacoinOutput{{
+				Value:      types.SiacoinPrecision,
+				UnlockHash: uh,
+			}},
+		}
+		if parent != nil {
+			txn.SiacoinInputs = []types.SiacoinInput{{ParentID: parent.SiacoinOutputID(0)}}
+		}
+		return txn
+	}
+	parent := newTxn(nil)
+	child := newTxn(&parent)
+	confirmed := newTxn(nil)
+	confirmedChild := newTxn(&confirmed)
+
+	// Add them to the unconfirmed set with the children first.
+	w := wt.wallet
+	w.mu.Lock()
+	for _, txn := range []types.Transaction{confirmedChild, child, confirmed, parent} {
+		w.unconfirmedProcessedTransactions = append(w.unconfirmedProcessedTransactions, modules.ProcessedTransaction{
+			Transaction:   txn,
+			TransactionID: txn.ID(),
+		})
+	}
+	err = dbPutTransactionIndex(w.dbTx, confirmed.ID(), make([]byte, 8))
+	w.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The confirmed transaction is left out. Its child is still valid and
+	// the parent is followed by its child.
+	set, err := w.RebroadcastSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 3 {
+		t.Fatal("expected 3 transactions but got", len(set))
+	}
+	if set[0].ID() != confirmedChild.ID() || set[1].ID() != parent.ID() || set[2].ID() != child.ID() {
+		t.Fatal("transactions aren't sorted by their dependencies")
+	}
+}
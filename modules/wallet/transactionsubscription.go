@@ -0,0 +1,153 @@
+package wallet
+
+import (
+	"encoding/binary"
+
+	"gitlab.com/NebulousLabs/bolt"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+type (
+	// TransactionSubscriber receives updates about the wallet's confirmed
+	// transactions and its confirmed siacoin balance.
+	TransactionSubscriber interface {
+		// ReceiveTransactionUpdate is called whenever the wallet processed a
+		// consensus change. Like a ConsensusSetSubscriber, it is called
+		// while the wallet is locked and must not call the wallet's methods.
+		ReceiveTransactionUpdate(TransactionUpdate)
+	}
+
+	// TransactionUpdate describes how a consensus change affected the
+	// wallet's confirmed transactions and balance.
+	TransactionUpdate struct {
+		// AppliedTransactions are the wallet's transactions confirmed by the
+		// change. RevertedTransactions are the ids of the wallet's
+		// transactions reverted by the change.
+		AppliedTransactions  []modules.ProcessedTransaction
+		RevertedTransactions []types.TransactionID
+
+		// ConfirmedSiacoinBalance is the wallet's confirmed siacoin balance
+		// after the change. SiacoinBalanceIncrease and
+		// SiacoinBalanceDecrease contain the change of the balance since the
+		// previous update. At most one of them is non-zero, so summing up
+		// the changes of all updates results in ConfirmedSiacoinBalance.
+		// Miner payouts only become part of the balance once they matured,
+		// so the balance can change without new transactions being
+		// confirmed. In contrast to ConfirmedBalance, outputs below the dust
+		// threshold are included since the threshold depends on the
+		// transaction pool's fee estimate.
+		ConfirmedSiacoinBalance types.Currency
+		SiacoinBalanceIncrease  types.Currency
+		SiacoinBalanceDecrease  types.Currency
+	}
+
+	// transactionSubscription is a subscriber together with the balance it
+	// was sent last.
+	transactionSubscription struct {
+		subscriber TransactionSubscriber
+		balance    types.Currency
+	}
+)
+
+// TransactionSubscribe subscribes s to updates about the wallet's confirmed
+// transactions. The subscriber immediately receives an update which contains
+// the current balance as an increase.
+func (w *Wallet) TransactionSubscribe(s TransactionSubscriber) error {
+	if err := w.tg.Add(); err != nil {
+		return modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	balance, err := w.confirmedSiacoinBalance(types.ZeroCurrency)
+	if err != nil {
+		return err
+	}
+	w.transactionSubscriptions = append(w.transactionSubscriptions, &transactionSubscription{
+		subscriber: s,
+		balance:    balance,
+	})
+	s.ReceiveTransactionUpdate(TransactionUpdate{
+		ConfirmedSiacoinBalance: balance,
+		SiacoinBalanceIncrease:  balance,
+	})
+	return nil
+}
+
+// TransactionUnsubscribe removes s from the wallet's transaction subscribers.
+func (w *Wallet) TransactionUnsubscribe(s TransactionSubscriber) error {
+	if err := w.tg.Add(); err != nil {
+		return modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, sub := range w.transactionSubscriptions {
+		if sub.subscriber == s {
+			w.transactionSubscriptions = append(w.transactionSubscriptions[:i], w.transactionSubscriptions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// notifyTransactionSubscribers sends an update to all of the wallet's
+// transaction subscribers. applied are the transactions appended by the
+// consensus change and reverted the ones it removed.
+func (w *Wallet) notifyTransactionSubscribers(applied []modules.ProcessedTransaction, reverted []types.TransactionID) error {
+	balance, err := w.confirmedSiacoinBalance(types.ZeroCurrency)
+	if err != nil {
+		return err
+	}
+	for _, sub := range w.transactionSubscriptions {
+		update := TransactionUpdate{
+			AppliedTransactions:     applied,
+			RevertedTransactions:    reverted,
+			ConfirmedSiacoinBalance: balance,
+		}
+		if balance.Cmp(sub.balance) > 0 {
+			update.SiacoinBalanceIncrease = balance.Sub(sub.balance)
+		} else {
+			update.SiacoinBalanceDecrease = sub.balance.Sub(balance)
+		}
+		sub.balance = balance
+		sub.subscriber.ReceiveTransactionUpdate(update)
+	}
+	return nil
+}
+
+// dbProcessedTransactionsAfter returns the processed transactions whose key
+// is greater than seq in the order they were added.
+func dbProcessedTransactionsAfter(tx *bolt.Tx, seq uint64) ([]modules.ProcessedTransaction, error) {
+	seqBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(seqBytes, seq+1)
+	var pts []modules.ProcessedTransaction
+	c := tx.Bucket(bucketProcessedTransactions).Cursor()
+	for k, v := c.Seek(seqBytes); k != nil; k, v = c.Next() {
+		var pt modules.ProcessedTransaction
+		if err := decodeProcessedTransaction(v, &pt); err != nil {
+			return nil, err
+		}
+		pts = append(pts, pt)
+	}
+	return pts, nil
+}
+
+// dbProcessedTransactionIDsAbove returns the ids of the processed transactions
+// confirmed above height, starting with the most recent one.
+func dbProcessedTransactionIDsAbove(tx *bolt.Tx, height types.BlockHeight) ([]types.TransactionID, error) {
+	var txids []types.TransactionID
+	c := tx.Bucket(bucketProcessedTransactions).Cursor()
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		var pt modules.ProcessedTransaction
+		if err := decodeProcessedTransaction(v, &pt); err != nil {
+			return nil, err
+		}
+		if pt.ConfirmationHeight <= height {
+			break
+		}
+		txids = append(txids, pt.TransactionID)
+	}
+	return txids, nil
+}
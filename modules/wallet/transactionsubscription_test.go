@@ -0,0 +1,158 @@
+package wallet
+
+import (
+	"testing"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// testTransactionSubscriber records the updates it receives.
+type testTransactionSubscriber struct {
+	updates []TransactionUpdate
+}
+
+// ReceiveTransactionUpdate implements TransactionSubscriber.
+func (s *testTransactionSubscriber) ReceiveTransactionUpdate(update TransactionUpdate) {
+	s.updates = append(s.updates, update)
+}
+
+// TestTransactionSubscription tests that the balance changes delivered to a
+// transaction subscriber add up to the confirmed balance and that miner
+// payouts only count once they matured.
+func TestTransactionSubscription(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createBlankWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.wallet
+
+	// Watch an address.
+	uc := types.UnlockConditions{}
+	addr := uc.UnlockHash()
+	w.mu.Lock()
+	w.watchedAddrs[addr] = struct{}{}
+	w.mu.Unlock()
+
+	// processChange applies a consensus change to the wallet.
+	processChange := func(id byte, height types.BlockHeight, applied, reverted []types.Block, diffs []modules.SiacoinOutputDiff, delayed []modules.DelayedSiacoinOutputDiff) {
+		cc := modules.ConsensusChange{
+			ID:             modules.ConsensusChangeID{id},
+			AppliedBlocks:  applied,
+			RevertedBlocks: reverted,
+			BlockHeight:    height,
+		}
+		cc.SiacoinOutputDiffs = diffs
+		cc.DelayedSiacoinOutputDiffs = delayed
+		w.ProcessConsensusChange(cc)
+	}
+
+	s := &testTransactionSubscriber{}
+	if err := w.TransactionSubscribe(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm a block with a miner payout and a transaction for the address.
+	// Only the transaction's output is spendable right away.
+	payout := types.SiacoinOutput{Value: types.SiacoinPrecision.Mul64(5), UnlockHash: addr}
+	received := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.SiacoinPrecision.Mul64(10), UnlockHash: addr}},
+	}
+	b1 := types.Block{
+		Timestamp:    1,
+		MinerPayouts: []types.SiacoinOutput{payout},
+		Transactions: []types.Transaction{received},
+	}
+	processChange(1, 1, []types.Block{b1}, nil, []modules.SiacoinOutputDiff{{
+		Direction:     modules.DiffApply,
+		ID:            received.SiacoinOutputID(0),
+		SiacoinOutput: received.SiacoinOutputs[0],
+	}}, []modules.DelayedSiacoinOutputDiff{{
+		Direction:      modules.DiffApply,
+		ID:             b1.MinerPayoutID(0),
+		SiacoinOutput:  payout,
+		MaturityHeight: 1 + types.MaturityDelay,
+	}})
+
+	// The payout matures.
+	b2 := types.Block{ParentID: b1.ID(), Timestamp: 2}
+	processChange(2, 2, []types.Block{b2}, nil, []modules.SiacoinOutputDiff{{
+		Direction:     modules.DiffApply,
+		ID:            b1.MinerPayoutID(0),
+		SiacoinOutput: payout,
+	}}, nil)
+
+	// The received output is spent and the spend is reverted afterwards.
+	spent := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: received.SiacoinOutputID(0), UnlockConditions: uc}},
+	}
+	b3 := types.Block{ParentID: b2.ID(), Timestamp: 3, Transactions: []types.Transaction{spent}}
+	spendDiff := modules.SiacoinOutputDiff{
+		Direction:     modules.DiffRevert,
+		ID:            received.SiacoinOutputID(0),
+		SiacoinOutput: received.SiacoinOutputs[0],
+	}
+	processChange(3, 3, []types.Block{b3}, nil, []modules.SiacoinOutputDiff{spendDiff}, nil)
+	spendDiff.Direction = modules.DiffApply
+	processChange(4, 2, nil, []types.Block{b3}, []modules.SiacoinOutputDiff{spendDiff}, nil)
+
+	// Check the updates.
+	if len(s.updates) != 5 {
+		t.Fatal("expected 5 updates but got", len(s.updates))
+	}
+	expected := []struct {
+		increase, decrease uint64
+		applied            int
+		reverted           int
+	}{
+		{0, 0, 0, 0},
+		{10, 0, 2, 0},
+		{5, 0, 0, 0},
+		{0, 10, 1, 0},
+		{10, 0, 0, 1},
+	}
+	var increase, decrease types.Currency
+	for i, update := range s.updates {
+		e := expected[i]
+		if !update.SiacoinBalanceIncrease.Equals(types.SiacoinPrecision.Mul64(e.increase)) || !update.SiacoinBalanceDecrease.Equals(types.SiacoinPrecision.Mul64(e.decrease)) {
+			t.Fatal("wrong balance change", i, update.SiacoinBalanceIncrease, update.SiacoinBalanceDecrease)
+		}
+		if len(update.AppliedTransactions) != e.applied || len(update.RevertedTransactions) != e.reverted {
+			t.Fatal("wrong transactions", i, len(update.AppliedTransactions), len(update.RevertedTransactions))
+		}
+		increase = increase.Add(update.SiacoinBalanceIncrease)
+		decrease = decrease.Add(update.SiacoinBalanceDecrease)
+	}
+	if s.updates[4].RevertedTransactions[0] != spent.ID() {
+		t.Fatal("wrong reverted transaction")
+	}
+
+	// The sum of the changes matches the confirmed balance.
+	balance, _, _, err := w.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !increase.Sub(decrease).Equals(balance) {
+		t.Fatal("changes don't add up to the balance", increase.Sub(decrease), balance)
+	}
+	if !balance.Equals(s.updates[4].ConfirmedSiacoinBalance) || !balance.Equals(types.SiacoinPrecision.Mul64(15)) {
+		t.Fatal("wrong balance", balance, s.updates[4].ConfirmedSiacoinBalance)
+	}
+
+	// Unsubscribed subscribers don't receive updates anymore.
+	if err := w.TransactionUnsubscribe(s); err != nil {
+		t.Fatal(err)
+	}
+	processChange(5, 3, []types.Block{{ParentID: b2.ID(), Timestamp: 5}}, nil, nil, nil)
+	if len(s.updates) != 5 {
+		t.Fatal("unsubscribed subscriber received an update")
+	}
+}
@@ -1,10 +1,11 @@
 package wallet
 
 import (
-	"gitlab.com/NebulousLabs/errors"
-	"gitlab.com/NebulousLabs/fastrand"
+	"time"
 
 	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
@@ -195,6 +196,7 @@ func (w *Wallet) LoadSiagKeys(masterKey crypto.CipherKey, keyfiles []string) err
 			return err
 		}
 		w.unconfirmedProcessedTransactions = nil
+		w.unconfirmedAdded = make(map[types.TransactionID]time.Time)
 		err = dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning)
 		if err != nil {
 			return err
@@ -265,6 +267,7 @@ func (w *Wallet) Load033xWallet(masterKey crypto.CipherKey, filepath033x string)
 			return err
 		}
 		w.unconfirmedProcessedTransactions = nil
+		w.unconfirmedAdded = make(map[types.TransactionID]time.Time)
 		err = dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning)
 		if err != nil {
 			return err
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"math"
+	"time"
 
 	"gitlab.com/NebulousLabs/bolt"
 	"gitlab.com/NebulousLabs/errors"
@@ -515,10 +516,22 @@ func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
 		w.log.Severe("ERROR: failed to update confirmed set:", err)
 		w.dbRollback = true
 	}
+
+	// Remember the transactions which are reverted for the subscribers.
+	var reverted []types.TransactionID
+	if len(w.transactionSubscriptions) > 0 && len(cc.RevertedBlocks) > 0 {
+		var err error
+		reverted, err = dbProcessedTransactionIDsAbove(w.dbTx, cc.InitialHeight())
+		if err != nil {
+			w.log.Severe("ERROR: failed to get reverted transactions:", err)
+			w.dbRollback = true
+		}
+	}
 	if err := w.revertHistory(w.dbTx, cc.RevertedBlocks); err != nil {
 		w.log.Severe("ERROR: failed to revert consensus change:", err)
 		w.dbRollback = true
 	}
+	seq := w.dbTx.Bucket(bucketProcessedTransactions).Sequence()
 	if err := w.applyHistory(w.dbTx, cc); err != nil {
 		w.log.Severe("ERROR: failed to apply consensus change:", err)
 		w.dbRollback = true
@@ -532,6 +545,22 @@ func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
 		w.dbRollback = true
 	}
 
+	// Notify the subscribers before the applied transactions might be
+	// pruned.
+	if len(w.transactionSubscriptions) > 0 && !w.dbRollback {
+		applied, err := dbProcessedTransactionsAfter(w.dbTx, seq)
+		if err == nil {
+			err = w.notifyTransactionSubscribers(applied, reverted)
+		}
+		if err != nil {
+			w.log.Severe("ERROR: failed to notify transaction subscribers:", err)
+		}
+	}
+	if err := w.pruneTransactions(w.dbTx); err != nil {
+		w.log.Severe("ERROR: failed to prune processed transactions:", err)
+		w.dbRollback = true
+	}
+
 	if cc.Synced {
 		go w.threadedDefragWallet()
 	}
@@ -574,6 +603,8 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(diff *modules.Transaction
 				// Transaction was not dropped, add it to the new unconfirmed
 				// transactions.
 				newUPT = append(newUPT, txn)
+			} else {
+				delete(w.unconfirmedAdded, txn.TransactionID)
 			}
 		}
 
@@ -647,6 +678,55 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(diff *modules.Transaction
 				})
 			}
 			w.unconfirmedProcessedTransactions = append(w.unconfirmedProcessedTransactions, pt)
+			w.unconfirmedAdded[pt.TransactionID] = time.Now()
+		}
+	}
+
+	// Evict old transactions if there are too many.
+	w.evictUnconfirmedTransactions()
+}
+
+// evictUnconfirmedTransactions removes the oldest unconfirmed transactions
+// which are older than maxUnconfirmedAge until no more than maxUnconfirmed
+// unconfirmed transactions are left. The transaction pool only reports new or
+// changed transaction sets, so an evicted transaction which stays in the pool
+// is only tracked again once it is confirmed or its set changes.
+func (w *Wallet) evictUnconfirmedTransactions() {
+	if w.maxUnconfirmed == 0 || uint64(len(w.unconfirmedProcessedTransactions)) <= w.maxUnconfirmed {
+		return
+	}
+
+	// The unconfirmed transactions are ordered by the time they were added so
+	// the oldest transactions are at the front.
+	evicted := make(map[types.TransactionID]struct{})
+	for _, txn := range w.unconfirmedProcessedTransactions {
+		if uint64(len(w.unconfirmedProcessedTransactions)-len(evicted)) <= w.maxUnconfirmed {
+			break
+		}
+		if time.Since(w.unconfirmedAdded[txn.TransactionID]) < w.maxUnconfirmedAge {
+			break
+		}
+		evicted[txn.TransactionID] = struct{}{}
+		delete(w.unconfirmedAdded, txn.TransactionID)
+	}
+	if len(evicted) == 0 {
+		return
+	}
+	w.unconfirmedProcessedTransactions = append([]modules.ProcessedTransaction{}, w.unconfirmedProcessedTransactions[len(evicted):]...)
+
+	// Remove the evicted transactions from the unconfirmed sets as well.
+	for setID, txids := range w.unconfirmedSets {
+		remaining := make([]types.TransactionID, 0, len(txids))
+		for _, txid := range txids {
+			if _, exists := evicted[txid]; !exists {
+				remaining = append(remaining, txid)
+			}
+		}
+		if len(remaining) == 0 {
+			delete(w.unconfirmedSets, setID)
+		} else if len(remaining) != len(txids) {
+			w.unconfirmedSets[setID] = remaining
 		}
 	}
+	w.log.Printf("WARN: evicted %v unconfirmed transactions since the limit of %v was exceeded", len(evicted), w.maxUnconfirmed)
 }
@@ -1,7 +1,9 @@
 package wallet
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
@@ -96,3 +98,106 @@ func TestUpdate(t *testing.T) {
 		t.Fatal("transaction was not removed")
 	}
 }
+
+// TestEvictUnconfirmedTransactions tests that the oldest unconfirmed
+// transactions are evicted once the limit is exceeded.
+func TestEvictUnconfirmedTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Limit the number of unconfirmed transactions.
+	err = wt.wallet.SetSettings(modules.WalletSettings{
+		MaxUnconfirmedTransactions: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send some transactions. Each call creates 2 transactions.
+	var sendTxns []types.Transaction
+	for i := 0; i < 3; i++ {
+		sendTxns, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the transactions of the last call should be left.
+	upts, err := wt.wallet.UnconfirmedTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upts) != 2 {
+		t.Fatal("expected 2 unconfirmed transactions but got", len(upts))
+	}
+	for i, upt := range upts {
+		if upt.TransactionID != sendTxns[i].ID() {
+			t.Fatal("wrong transaction wasn't evicted")
+		}
+	}
+
+	// The evicted transactions shouldn't be in the unconfirmed sets anymore.
+	wt.wallet.mu.Lock()
+	defer wt.wallet.mu.Unlock()
+	if len(wt.wallet.unconfirmedAdded) != 2 {
+		t.Fatal("expected 2 entries but got", len(wt.wallet.unconfirmedAdded))
+	}
+	for _, txids := range wt.wallet.unconfirmedSets {
+		for _, txid := range txids {
+			if txid != sendTxns[0].ID() && txid != sendTxns[1].ID() {
+				t.Fatal("evicted transaction is still in unconfirmed set", txid)
+			}
+		}
+	}
+}
+
+// TestUnconfirmedLimitsPersist tests that the limits for unconfirmed
+// transactions survive a restart of the wallet.
+func TestUnconfirmedLimitsPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createBlankWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	settings := modules.WalletSettings{
+		MaxUnconfirmedTransactions: 2,
+		MaxUnconfirmedAge:          time.Hour,
+	}
+	if err := wt.wallet.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the wallet.
+	if err := wt.wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet, err = New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := wt.wallet.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != settings {
+		t.Fatal("settings weren't persisted", loaded, settings)
+	}
+}
@@ -0,0 +1,257 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gitlab.com/NebulousLabs/bolt"
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// errInvalidVerifierInterval is returned if the consistency verifier is
+	// started with a non-positive interval.
+	errInvalidVerifierInterval = errors.New("consistency verifier interval must be positive")
+)
+
+// ConsistencyReport is the result of a consistency check of the wallet's
+// database.
+type ConsistencyReport struct {
+	// Time is the time the check finished. It is zero if no check ran yet.
+	Time time.Time `json:"time"`
+
+	// Inconsistencies describes the inconsistencies found by the check.
+	Inconsistencies []string `json:"inconsistencies"`
+
+	// Repaired indicates that the inconsistencies were repaired.
+	Repaired bool `json:"repaired"`
+}
+
+// StartConsistencyVerifier starts a background thread which checks the
+// consistency of the wallet's database every interval. The check verifies the
+// sequence of the processed transactions bucket, like
+// RebuildTransactionSequence, and that the address index matches the processed
+// transactions. Inconsistencies are logged and exposed through
+// LastConsistencyReport. They are only repaired if autoRepair is set. Starting
+// a running verifier restarts it with the new settings.
+func (w *Wallet) StartConsistencyVerifier(interval time.Duration, autoRepair bool) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	if interval <= 0 {
+		return errInvalidVerifierInterval
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.verifierStop != nil {
+		close(w.verifierStop)
+	}
+	stop := make(chan struct{})
+	w.verifierStop = stop
+	go w.threadedVerifyConsistency(interval, autoRepair, stop)
+	return nil
+}
+
+// StopConsistencyVerifier stops the background thread started by
+// StartConsistencyVerifier. It is a no-op if the verifier isn't running.
+func (w *Wallet) StopConsistencyVerifier() error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.verifierStop != nil {
+		close(w.verifierStop)
+		w.verifierStop = nil
+	}
+	return nil
+}
+
+// LastConsistencyReport returns the report of the most recent check of the
+// consistency verifier.
+func (w *Wallet) LastConsistencyReport() (ConsistencyReport, error) {
+	if err := w.tg.Add(); err != nil {
+		return ConsistencyReport{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.consistencyReport, nil
+}
+
+// threadedVerifyConsistency checks the consistency of the wallet's database
+// every interval until stop is closed or the wallet shuts down.
+func (w *Wallet) threadedVerifyConsistency(interval time.Duration, autoRepair bool, stop <-chan struct{}) {
+	if err := w.tg.Add(); err != nil {
+		return
+	}
+	defer w.tg.Done()
+
+	for {
+		select {
+		case <-time.After(interval):
+		case <-stop:
+			return
+		case <-w.tg.StopChan():
+			return
+		}
+		report, err := w.managedVerifyConsistency(autoRepair)
+		if err == nil {
+			w.mu.Lock()
+			w.consistencyReport = report
+			w.mu.Unlock()
+		}
+		if err != nil {
+			w.log.Println("ERROR: failed to verify the consistency of the wallet database:", err)
+			continue
+		}
+		for _, inconsistency := range report.Inconsistencies {
+			w.log.Println("WARN: wallet database inconsistency:", inconsistency)
+		}
+		if report.Repaired {
+			w.log.Printf("INFO: repaired %v wallet database inconsistencies", len(report.Inconsistencies))
+		}
+	}
+}
+
+// consistencyRepairs contains the information needed to repair the
+// inconsistencies found by dbCheckConsistency.
+type consistencyRepairs struct {
+	badSequence bool
+	badAddrs    map[types.UnlockHash]struct{}
+	expected    map[types.UnlockHash]map[uint64]struct{}
+}
+
+// managedVerifyConsistency checks the sequence of the processed transactions
+// bucket and the address index and repairs them if autoRepair is set. The
+// check runs on a read-only snapshot of the database without holding the
+// wallet's lock. Since the snapshot might be outdated by the time a repair
+// starts, the check is repeated while holding the lock before repairing.
+func (w *Wallet) managedVerifyConsistency(autoRepair bool) (ConsistencyReport, error) {
+	// Commit the pending changes to include them in the snapshot.
+	w.mu.Lock()
+	err := w.syncDB()
+	w.mu.Unlock()
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+
+	var report ConsistencyReport
+	err = w.db.View(func(tx *bolt.Tx) error {
+		report, _, err = dbCheckConsistency(tx)
+		return err
+	})
+	if err != nil || !autoRepair || len(report.Inconsistencies) == 0 {
+		return report, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	report, repairs, err := dbCheckConsistency(w.dbTx)
+	if err != nil || len(report.Inconsistencies) == 0 {
+		return report, err
+	}
+	if repairs.badSequence {
+		if _, _, err := dbRepairProcessedTransactionSequence(w.dbTx); err != nil {
+			return ConsistencyReport{}, err
+		}
+	}
+	for addr := range repairs.badAddrs {
+		if err := dbRebuildAddrTransactions(w.dbTx, addr, repairs.expected[addr]); err != nil {
+			return ConsistencyReport{}, err
+		}
+	}
+	report.Repaired = true
+	return report, w.syncDB()
+}
+
+// dbCheckConsistency checks the sequence of the processed transactions bucket
+// and the address index. The returned repairs can be used to repair the
+// inconsistencies within the same transaction.
+func dbCheckConsistency(tx *bolt.Tx) (ConsistencyReport, consistencyRepairs, error) {
+	var report ConsistencyReport
+	repairs := consistencyRepairs{
+		badAddrs: make(map[types.UnlockHash]struct{}),
+		expected: make(map[types.UnlockHash]map[uint64]struct{}),
+	}
+
+	// Check the sequence.
+	b := tx.Bucket(bucketProcessedTransactions)
+	expectedSeq, err := dbExpectedProcessedTransactionSequence(tx)
+	if err != nil {
+		return ConsistencyReport{}, consistencyRepairs{}, err
+	}
+	repairs.badSequence = b.Sequence() != expectedSeq
+	if repairs.badSequence {
+		report.Inconsistencies = append(report.Inconsistencies, fmt.Sprintf("processed transactions sequence is %v but should be %v", b.Sequence(), expectedSeq))
+	}
+
+	// Compare the address index to the addresses of the processed
+	// transactions.
+	expected := repairs.expected
+	it := dbProcessedTransactionsIterator(tx)
+	for it.next() {
+		for addr := range processedTransactionAddrs(it.value()) {
+			if expected[addr] == nil {
+				expected[addr] = make(map[uint64]struct{})
+			}
+			expected[addr][it.key()] = struct{}{}
+		}
+	}
+	actual := make(map[types.UnlockHash]map[uint64]struct{})
+	err = tx.Bucket(bucketAddrTransactions).ForEach(func(k, v []byte) error {
+		var addr types.UnlockHash
+		var txns []uint64
+		if err := encoding.Unmarshal(k, &addr); err != nil {
+			return err
+		}
+		if err := encoding.Unmarshal(v, &txns); err != nil {
+			return err
+		}
+		actual[addr] = make(map[uint64]struct{}, len(txns))
+		for _, txn := range txns {
+			actual[addr][txn] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return ConsistencyReport{}, consistencyRepairs{}, err
+	}
+	for addr, txns := range actual {
+		for txn := range txns {
+			if _, ok := expected[addr][txn]; !ok {
+				report.Inconsistencies = append(report.Inconsistencies, fmt.Sprintf("address %v references missing transaction %v", addr, txn))
+				repairs.badAddrs[addr] = struct{}{}
+			}
+		}
+	}
+	for addr, txns := range expected {
+		for txn := range txns {
+			if _, ok := actual[addr][txn]; !ok {
+				report.Inconsistencies = append(report.Inconsistencies, fmt.Sprintf("transaction %v is missing from the index of address %v", txn, addr))
+				repairs.badAddrs[addr] = struct{}{}
+			}
+		}
+	}
+	report.Time = time.Now()
+	return report, repairs, nil
+}
+
+// dbRebuildAddrTransactions replaces the transactions of an address with
+// txns. The entry of the address is kept even if txns is empty since it marks
+// the address as used.
+func dbRebuildAddrTransactions(tx *bolt.Tx, addr types.UnlockHash, txns map[uint64]struct{}) error {
+	sorted := make([]uint64, 0, len(txns))
+	for txn := range txns {
+		sorted = append(sorted, txn)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return dbPutAddrTransactions(tx, addr, sorted)
+}
@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestConsistencyVerifier tests that the consistency verifier detects an
+// injected corruption within one check and only repairs it if configured.
+func TestConsistencyVerifier(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if err := wt.wallet.StartConsistencyVerifier(0, false); err != errInvalidVerifierInterval {
+		t.Fatal("expected errInvalidVerifierInterval", err)
+	}
+
+	// Corrupt the sequence and add a dangling entry to the address index.
+	wt.wallet.mu.Lock()
+	b := wt.wallet.dbTx.Bucket(bucketProcessedTransactions)
+	err = b.SetSequence(b.Sequence() + 5)
+	if err == nil {
+		err = dbAddAddrTransaction(wt.wallet.dbTx, types.UnlockHash{1}, b.Sequence()+1)
+	}
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// waitForReport waits for a report newer than after.
+	waitForReport := func(after time.Time) (report ConsistencyReport) {
+		t.Helper()
+		err := build.Retry(100, 50*time.Millisecond, func() error {
+			report, err = wt.wallet.LastConsistencyReport()
+			if err != nil {
+				return err
+			}
+			if !report.Time.After(after) {
+				return errors.New("no new report yet")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	// The verifier detects the corruption without repairing it.
+	if err := wt.wallet.StartConsistencyVerifier(100*time.Millisecond, false); err != nil {
+		t.Fatal(err)
+	}
+	report := waitForReport(time.Time{})
+	if len(report.Inconsistencies) != 2 || report.Repaired {
+		t.Fatal("unexpected report", report)
+	}
+	report = waitForReport(report.Time)
+	if len(report.Inconsistencies) != 2 || report.Repaired {
+		t.Fatal("corruption shouldn't have been repaired", report)
+	}
+
+	// Restart it with auto-repair enabled.
+	if err := wt.wallet.StartConsistencyVerifier(100*time.Millisecond, true); err != nil {
+		t.Fatal(err)
+	}
+	report = waitForReport(report.Time)
+	if len(report.Inconsistencies) != 2 || !report.Repaired {
+		t.Fatal("corruption should have been repaired", report)
+	}
+	report = waitForReport(report.Time)
+	if len(report.Inconsistencies) != 0 {
+		t.Fatal("expected no inconsistencies after repair", report)
+	}
+	if err := wt.wallet.StopConsistencyVerifier(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConsistencyVerifierPrunedHistory tests that a processed transactions
+// bucket which is empty because all of its transactions were pruned isn't
+// reported as inconsistent and that its sequence isn't reset.
+func TestConsistencyVerifierPrunedHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createBlankWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.closeWt(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.wallet
+
+	// Add a confirmed transaction and prune it.
+	addr := types.UnlockHash{1}
+	pt := modules.ProcessedTransaction{
+		TransactionID:      types.TransactionID{2},
+		ConfirmationHeight: 1,
+		Outputs: []modules.ProcessedOutput{{
+			FundType:       types.SpecifierSiacoinOutput,
+			RelatedAddress: addr,
+			WalletAddress:  true,
+		}},
+	}
+	w.mu.Lock()
+	err = errors.Compose(
+		dbAppendProcessedTransaction(w.dbTx, pt),
+		dbPutConsensusHeight(w.dbTx, 10),
+	)
+	seq := w.dbTx.Bucket(bucketProcessedTransactions).Sequence()
+	w.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetTransactionRetention(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pruned history is consistent.
+	report, err := w.managedVerifyConsistency(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Inconsistencies) != 0 || report.Repaired {
+		t.Fatal("unexpected report", report)
+	}
+
+	// Neither the verifier nor RebuildTransactionSequence resets the
+	// sequence.
+	if _, _, err := w.RebuildTransactionSequence(); err != nil {
+		t.Fatal(err)
+	}
+	w.mu.Lock()
+	newSeq := w.dbTx.Bucket(bucketProcessedTransactions).Sequence()
+	w.mu.Unlock()
+	if newSeq != seq {
+		t.Fatalf("sequence changed from %v to %v", seq, newSeq)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"sort"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/bolt"
 	"gitlab.com/NebulousLabs/errors"
@@ -89,6 +90,13 @@ type Wallet struct {
 	unconfirmedSets                  map[modules.TransactionSetID][]types.TransactionID
 	unconfirmedProcessedTransactions []modules.ProcessedTransaction
 
+	// unconfirmedAdded contains the time each of the
+	// unconfirmedProcessedTransactions was added. It is used to evict old
+	// transactions once maxUnconfirmed is exceeded.
+	unconfirmedAdded  map[types.TransactionID]time.Time
+	maxUnconfirmed    uint64
+	maxUnconfirmedAge time.Duration
+
 	// The wallet's database tracks its seeds, keys, outputs, and
 	// transactions. A global db transaction is maintained in memory to avoid
 	// excessive disk writes. Any operations involving dbTx must hold an
@@ -116,6 +124,16 @@ type Wallet struct {
 	// defragDisabled determines if the wallet is set to defrag outputs once it
 	// reaches a certain threshold
 	defragDisabled bool
+
+	// verifierStop stops the running consistency verifier. It is nil if the
+	// verifier isn't running. consistencyReport is the result of its most
+	// recent check.
+	verifierStop      chan struct{}
+	consistencyReport ConsistencyReport
+
+	// transactionSubscriptions are notified about the confirmed transactions
+	// and the balance after every consensus change.
+	transactionSubscriptions []*transactionSubscription
 }
 
 // Height return the internal processed consensus height of the wallet
@@ -206,7 +224,8 @@ func NewCustomWallet(cs modules.ConsensusSet, tpool modules.TransactionPool, per
 		unusedKeys:   make(map[types.UnlockHash]types.UnlockConditions),
 		watchedAddrs: make(map[types.UnlockHash]struct{}),
 
-		unconfirmedSets: make(map[modules.TransactionSetID][]types.TransactionID),
+		unconfirmedSets:  make(map[modules.TransactionSetID][]types.TransactionID),
+		unconfirmedAdded: make(map[types.TransactionID]time.Time),
 
 		persistDir: persistDir,
 
@@ -278,11 +297,14 @@ func (w *Wallet) Settings() (modules.WalletSettings, error) {
 	}
 	defer w.tg.Done()
 	return modules.WalletSettings{
-		NoDefrag: w.defragDisabled,
+		NoDefrag:                   w.defragDisabled,
+		MaxUnconfirmedTransactions: w.maxUnconfirmed,
+		MaxUnconfirmedAge:          w.maxUnconfirmedAge,
 	}, nil
 }
 
-// SetSettings will update the settings for the wallet.
+// SetSettings will update the settings for the wallet. The limits for
+// unconfirmed transactions are persisted.
 func (w *Wallet) SetSettings(s modules.WalletSettings) error {
 	if err := w.tg.Add(); err != nil {
 		return modules.ErrWalletShutdown
@@ -290,9 +312,18 @@ func (w *Wallet) SetSettings(s modules.WalletSettings) error {
 	defer w.tg.Done()
 
 	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := dbPutUnconfirmedLimits(w.dbTx, unconfirmedLimits{
+		MaxTransactions: s.MaxUnconfirmedTransactions,
+		MaxAge:          s.MaxUnconfirmedAge,
+	})
+	if err != nil {
+		return err
+	}
 	w.defragDisabled = s.NoDefrag
-	w.mu.Unlock()
-	return nil
+	w.maxUnconfirmed = s.MaxUnconfirmedTransactions
+	w.maxUnconfirmedAge = s.MaxUnconfirmedAge
+	return w.syncDB()
 }
 
 // managedCanSpendUnlockHash returns true if and only if the the wallet has keys to spend from
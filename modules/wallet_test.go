@@ -0,0 +1,75 @@
+package modules
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"go.sia.tech/siad/types"
+)
+
+// TestValuedTransactionJSON tests that a ValuedTransaction with large values
+// survives a round-trip through JSON and that all currency fields are encoded
+// as strings to avoid precision loss in clients.
+func TestValuedTransactionJSON(t *testing.T) {
+	// Create a value which exceeds the precision of a float64.
+	large, ok := new(big.Int).SetString("123456789012345678901234567890123456789", 10)
+	if !ok {
+		t.Fatal("failed to parse value")
+	}
+	value := types.NewCurrency(large)
+
+	vt := ValuedTransaction{
+		ProcessedTransaction: ProcessedTransaction{
+			Transaction: types.Transaction{
+				MinerFees: []types.Currency{value},
+			},
+			ConfirmationHeight: 42,
+			Inputs: []ProcessedInput{{
+				FundType: types.SpecifierSiacoinInput,
+				Value:    value,
+			}},
+			Outputs: []ProcessedOutput{{
+				FundType: types.SpecifierMinerFee,
+				Value:    value,
+			}},
+		},
+		ConfirmedIncomingValue: value,
+		ConfirmedOutgoingValue: value.Add64(1),
+	}
+	b, err := json.Marshal(vt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check that the currency fields are strings.
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"confirmedincomingvalue", "confirmedoutgoingvalue"} {
+		s, ok := raw[field].(string)
+		if !ok {
+			t.Fatalf("%v isn't encoded as a string: %v", field, raw[field])
+		}
+		if field == "confirmedincomingvalue" && s != value.String() {
+			t.Fatalf("expected %v but got %v", value.String(), s)
+		}
+	}
+	fees := raw["transaction"].(map[string]interface{})["minerfees"].([]interface{})
+	if fee, ok := fees[0].(string); !ok || fee != value.String() {
+		t.Fatalf("miner fee isn't encoded as the right string: %v", fees[0])
+	}
+
+	// Unmarshal the transaction again.
+	var vt2 ValuedTransaction
+	if err := json.Unmarshal(b, &vt2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vt, vt2) {
+		t.Log(vt)
+		t.Log(vt2)
+		t.Fatal("transactions don't match")
+	}
+}
@@ -57,6 +57,13 @@ func (c *Client) DaemonStackGet() (dsg api.DaemonStackGet, err error) {
 	return
 }
 
+// DaemonMetricsGet requests the /daemon/metrics api resource and returns the
+// metrics in the Prometheus text format.
+func (c *Client) DaemonMetricsGet() (string, error) {
+	_, resp, err := c.getRawResponse("/daemon/metrics")
+	return string(resp), err
+}
+
 // DaemonStopGet stops the daemon using the /daemon/stop endpoint.
 func (c *Client) DaemonStopGet() (err error) {
 	err = c.get("/daemon/stop", nil)
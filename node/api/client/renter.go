@@ -497,6 +497,17 @@ func (c *Client) RenterRateLimitPost(readBPS, writeBPS int64) (err error) {
 	return
 }
 
+// RenterRegistryUpdateCooldownsPost uses the /renter endpoint to change the
+// base cooldowns of the workers' UpdateRegistry queues. A cooldown of 0 uses
+// the default cooldown.
+func (c *Client) RenterRegistryUpdateCooldownsPost(signatureErr, networkErr time.Duration) (err error) {
+	values := url.Values{}
+	values.Set("registrysignatureerrcooldown", fmt.Sprint(uint64(signatureErr.Seconds())))
+	values.Set("registrynetworkerrcooldown", fmt.Sprint(uint64(networkErr.Seconds())))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
 // RenterRenamePost uses the /renter/rename/:siapath endpoint to rename a file.
 func (c *Client) RenterRenamePost(siaPathOld, siaPathNew modules.SiaPath, root bool) (err error) {
 	spo := escapeSiaPath(siaPathOld)
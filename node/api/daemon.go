@@ -448,6 +448,20 @@ func (api *API) daemonStackHandlerGET(w http.ResponseWriter, _ *http.Request, _
 	})
 }
 
+// daemonMetricsHandlerGET handles the API call that returns the daemon's
+// metrics in the Prometheus text format.
+func (api *API) daemonMetricsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	var buf bytes.Buffer
+	if api.renter != nil {
+		if err := api.renter.WriteRegistryMetrics(&buf); err != nil {
+			WriteError(w, Error{"failed to get renter registry metrics: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write(buf.Bytes())
+}
+
 // daemonStartProfileHandlerPOST handles the API call that starts a profile for the daemon.
 func (api *API) daemonStartProfileHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Parse profile string
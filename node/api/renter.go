@@ -959,6 +959,24 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		settings.IPViolationCheck = ipviolationcheck
 	}
 
+	// Scan the registry update cooldowns. (optional parameters)
+	if c := req.FormValue("registrysignatureerrcooldown"); c != "" {
+		var cooldown uint64
+		if _, err := fmt.Sscan(c, &cooldown); err != nil {
+			WriteError(w, Error{"unable to parse registrysignatureerrcooldown: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.RegistryUpdateCooldowns.SignatureErr = time.Duration(cooldown) * time.Second
+	}
+	if c := req.FormValue("registrynetworkerrcooldown"); c != "" {
+		var cooldown uint64
+		if _, err := fmt.Sscan(c, &cooldown); err != nil {
+			WriteError(w, Error{"unable to parse registrynetworkerrcooldown: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.RegistryUpdateCooldowns.NetworkErr = time.Duration(cooldown) * time.Second
+	}
+
 	// Set the settings in the renter.
 	err = api.renter.SetSettings(settings)
 	if err != nil {
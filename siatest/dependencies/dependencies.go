@@ -353,6 +353,13 @@ func NewDependencyRegistryUpdateNoOp() *DependencyWithDisableAndEnable {
 	return newDependencywithDisableAndEnable("RegistryUpdateNoOp")
 }
 
+// NewDependencyPinRegistryWorker creates a dependency, that causes registry
+// operations of the renter to only use the worker of the host with the lowest
+// public key.
+func NewDependencyPinRegistryWorker() *DependencyWithDisableAndEnable {
+	return newDependencywithDisableAndEnable("PinRegistryWorker")
+}
+
 // Disrupt returns true if the correct string is provided.
 func (d *DependencyRegistryUpdateLyingHost) Disrupt(s string) bool {
 	return s == "RegistryUpdateLyingHost"